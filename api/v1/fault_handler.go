@@ -0,0 +1,110 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/fault"
+)
+
+// FaultHandler fault injection admin API handler
+type FaultHandler struct {
+	injector *fault.Injector
+}
+
+// NewFaultHandler create fault injection admin handler
+func NewFaultHandler() *FaultHandler {
+	return &FaultHandler{
+		injector: fault.Default(),
+	}
+}
+
+// SetFaultsEnabledRequest request body switching fault injection on or off
+type SetFaultsEnabledRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// GetFaults get the fault injection configuration in effect
+// @Summary Get fault injection configuration
+// @Tags admin
+// @Produce json
+// @Success 200 {object} api.Response{data=config.Fault}
+// @Router /admin/faults [get]
+func (h *FaultHandler) GetFaults(c *gin.Context) {
+	api.Success(c, h.injector.Config())
+}
+
+// SetFaults replace the fault injection configuration
+// @Summary Replace fault injection configuration
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body config.Fault true "Fault injection configuration"
+// @Success 200 {object} api.Response{data=config.Fault}
+// @Failure 400 {object} api.Response
+// @Router /admin/faults [put]
+func (h *FaultHandler) SetFaults(c *gin.Context) {
+	var req config.Fault
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	if err := h.injector.SetConfig(req); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, h.injector.Config())
+}
+
+// SetFaultsEnabled switch fault injection on or off, keeping the rules
+// @Summary Enable or disable fault injection
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body SetFaultsEnabledRequest true "Switch"
+// @Success 200 {object} api.Response{data=config.Fault}
+// @Failure 400 {object} api.Response
+// @Router /admin/faults [patch]
+func (h *FaultHandler) SetFaultsEnabled(c *gin.Context) {
+	var req SetFaultsEnabledRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	api.Success(c, h.injector.SetEnabled(*req.Enabled))
+}
+
+// RestoreFaults restore the fault injection configuration of the config file
+// @Summary Restore fault injection configuration
+// @Tags admin
+// @Produce json
+// @Success 200 {object} api.Response{data=config.Fault}
+// @Router /admin/faults/restore [post]
+func (h *FaultHandler) RestoreFaults(c *gin.Context) {
+	api.Success(c, h.injector.Restore())
+}
+
+// handleError write the error response of fault injection errors
+func (h *FaultHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, fault.ErrInvalidRule):
+		// The error names the rule and the invalid value
+		api.Error(c, http.StatusBadRequest, err)
+	default:
+		api.Error(c, http.StatusInternalServerError, err)
+	}
+}
+
+// RegisterRoutes register fault injection admin routes
+func (h *FaultHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/admin/faults", h.GetFaults)
+	router.PUT("/admin/faults", h.SetFaults)
+	router.PATCH("/admin/faults", h.SetFaultsEnabled)
+	router.POST("/admin/faults/restore", h.RestoreFaults)
+}
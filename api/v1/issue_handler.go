@@ -0,0 +1,267 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	"github.com/zgsm/mock-kbcenter/pkg/patch"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// IssueHandler review issue API handler
+type IssueHandler struct {
+	service *service.IssueService
+}
+
+// NewIssueHandler create issue handler
+func NewIssueHandler() *IssueHandler {
+	return &IssueHandler{
+		service: service.NewIssueService(),
+	}
+}
+
+// Pagination defaults of issue listing
+const (
+	defaultIssuePageSize = 20
+	maxIssuePageSize     = 100
+)
+
+// ListIssuesRequest query of issue listing
+type ListIssuesRequest struct {
+	types.IssueFilter
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// UpdateIssueRequest request body of issue triage, omitted fields are left unchanged
+type UpdateIssueRequest struct {
+	Status   *int    `json:"status"`
+	Assignee *string `json:"assignee"`
+	Comment  string  `json:"comment"`
+}
+
+// BatchUpdateIssuesRequest request body of bulk issue status update
+type BatchUpdateIssuesRequest struct {
+	Filter  types.IssueFilter `json:"filter"`
+	Status  *int              `json:"status" binding:"required"`
+	Comment string            `json:"comment"`
+}
+
+// ListIssues list the issues of a review task with filters and pagination
+// @Summary List issues
+// @Tags issues
+// @Produce json
+// @Param review_task_id query string true "Review task ID"
+// @Param status query int false "Status: 0 open, 1 confirmed, 2 ignored, 3 fixed"
+// @Param severity query string false "Severity" Enums(low, middle, high)
+// @Param rule_id query string false "Rule ID"
+// @Param file_path query string false "File path"
+// @Param assignee query string false "Assignee"
+// @Param change query string false "Change compared with the previous review" Enums(new, unchanged, resolved)
+// @Param page query int false "Page, starting at 1" default(1)
+// @Param page_size query int false "Page size, at most 100" default(20)
+// @Success 200 {object} api.Response{data=api.PageResult{list=[]types.Issue}}
+// @Failure 400 {object} api.Response
+// @Router /issues [get]
+func (h *IssueHandler) ListIssues(c *gin.Context) {
+	var req ListIssuesRequest
+	if err := c.ShouldBindQuery(&req); err != nil || req.ReviewTaskID == "" {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+	req.Page = max(req.Page, 1)
+	if req.PageSize <= 0 {
+		req.PageSize = defaultIssuePageSize
+	}
+	req.PageSize = min(req.PageSize, maxIssuePageSize)
+
+	list, total, err := h.service.ListIssues(c.Request.Context(), req.IssueFilter, req.Page, req.PageSize)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, api.NewPageResult(list, total, req.Page, req.PageSize))
+}
+
+// GetIssue get the latest review of an issue
+// @Summary Get issue
+// @Tags issues
+// @Produce json
+// @Param id path string true "Issue ID"
+// @Success 200 {object} api.Response{data=types.Issue}
+// @Failure 404 {object} api.Response
+// @Router /issues/{id} [get]
+func (h *IssueHandler) GetIssue(c *gin.Context) {
+	issue, err := h.service.GetIssue(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, issue)
+}
+
+// UpdateIssue change the status or assignee of an issue, or comment on it
+// @Summary Triage issue
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param id path string true "Issue ID"
+// @Param X-User-ID header string false "Operator recorded in the history"
+// @Param request body UpdateIssueRequest true "Issue changes"
+// @Success 200 {object} api.Response{data=types.Issue}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /issues/{id} [patch]
+func (h *IssueHandler) UpdateIssue(c *gin.Context) {
+	var req UpdateIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	issue, err := h.service.UpdateIssue(c.Request.Context(), c.Param("id"), service.IssueUpdate{
+		Status:   req.Status,
+		Assignee: req.Assignee,
+		Comment:  req.Comment,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, issue)
+}
+
+// BatchUpdateIssues move all issues of a review task matching the filter to a status
+// @Summary Bulk update issue status
+// @Tags issues
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string false "Operator recorded in the history"
+// @Param request body BatchUpdateIssuesRequest true "Filter and target status, filter.review_task_id is required"
+// @Success 200 {object} api.Response{data=types.IssueBatchUpdateResult}
+// @Failure 400 {object} api.Response
+// @Router /issues [patch]
+func (h *IssueHandler) BatchUpdateIssues(c *gin.Context) {
+	var req BatchUpdateIssuesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Filter.ReviewTaskID == "" {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	result, err := h.service.BatchUpdateStatus(c.Request.Context(), req.Filter, *req.Status, req.Comment)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, result)
+}
+
+// GetIssueHistory list the triage history of an issue
+// @Summary Get issue history
+// @Tags issues
+// @Produce json
+// @Param id path string true "Issue ID"
+// @Success 200 {object} api.Response{data=[]types.IssueHistory}
+// @Failure 404 {object} api.Response
+// @Router /issues/{id}/history [get]
+func (h *IssueHandler) GetIssueHistory(c *gin.Context) {
+	history, err := h.service.ListHistory(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, history)
+}
+
+// PreviewFix preview the fix patch of an issue against the current file content
+// @Summary Preview issue fix
+// @Tags issues
+// @Produce json
+// @Param id path string true "Issue ID"
+// @Success 200 {object} api.Response{data=types.IssueFixResult}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /issues/{id}/fix:preview [post]
+func (h *IssueHandler) PreviewFix(c *gin.Context) {
+	result, err := h.service.PreviewFix(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, result)
+}
+
+// ApplyFix apply the fix patch of an issue to the workspace
+// @Summary Apply issue fix
+// @Tags issues
+// @Produce json
+// @Param id path string true "Issue ID"
+// @Success 200 {object} api.Response{data=types.IssueFixResult}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /issues/{id}/fix:apply [post]
+func (h *IssueHandler) ApplyFix(c *gin.Context) {
+	result, err := h.service.ApplyFix(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, result)
+}
+
+// fixAction dispatch fix:preview and fix:apply, gin matches the part after "fix" as the action parameter
+func (h *IssueHandler) fixAction(c *gin.Context) {
+	switch c.Param("action") {
+	case ":preview":
+		h.PreviewFix(c)
+	case ":apply":
+		h.ApplyFix(c)
+	default:
+		api.NotFound(c, "common.notFound")
+	}
+}
+
+// handleError write the error response of issue errors
+func (h *IssueHandler) handleError(c *gin.Context, err error) {
+	var conflict *patch.ConflictError
+	switch {
+	case errors.Is(err, service.ErrIssueNotFound):
+		api.NotFound(c, "issue.not_found")
+	case errors.Is(err, service.ErrReviewTaskNotFound):
+		api.NotFound(c, "review_task.not_found")
+	case errors.Is(err, service.ErrFixNotAvailable):
+		api.BadRequest(c, "issue.fix_not_available")
+	case errors.Is(err, service.ErrFixVirtualFile):
+		api.BadRequest(c, "issue.fix_virtual_file")
+	case errors.Is(err, service.ErrInvalidIssueStatus):
+		api.BadRequest(c, "issue.invalid_status")
+	case errors.Is(err, service.ErrIssueStatusTransition):
+		api.BadRequest(c, "issue.status_transition_not_allowed")
+	case errors.As(err, &conflict):
+		api.Error(c, http.StatusConflict, err)
+	default:
+		api.Error(c, http.StatusInternalServerError, err)
+	}
+}
+
+// RegisterRoutes register issue routes
+func (h *IssueHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/issues", h.ListIssues)
+	router.PATCH("/issues", h.BatchUpdateIssues)
+	router.GET("/issues/:id", h.GetIssue)
+	router.PATCH("/issues/:id", h.UpdateIssue)
+	router.GET("/issues/:id/history", h.GetIssueHistory)
+	router.POST("/issues/:id/fix:action", h.fixAction)
+}
@@ -0,0 +1,137 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/internal/middleware"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func TestMain(m *testing.M) {
+	// The propagated headers come from the default configuration
+	if err := config.LoadConfigWithDefault(); err != nil {
+		panic(err)
+	}
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+// newIssueRouter router serving the issue API behind the header propagation middleware, with count open issues
+// of a new review task
+func newIssueRouter(t *testing.T, count int) (*gin.Engine, string) {
+	t.Helper()
+	reviewTaskID := "rt-" + t.Name()
+	issues := make([]*model.ReviewIssue, 0, count)
+	for i := 0; i < count; i++ {
+		issues = append(issues, &model.ReviewIssue{
+			IssueID:      fmt.Sprintf("%s-%d", reviewTaskID, i),
+			ReviewTaskID: reviewTaskID,
+			FilePath:     "a.go",
+			Severity:     types.SeverityLow,
+		})
+	}
+	if err := repository.NewReviewIssueRepository().CreateBatch(context.Background(), issues); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+
+	router := gin.New()
+	router.Use(middleware.HeaderPropagator())
+	NewIssueHandler().RegisterRoutes(router.Group("/api/v1"))
+	return router, reviewTaskID
+}
+
+// serve send a request to the router and decode the data of the response
+func serve(t *testing.T, router *gin.Engine, req *http.Request, data interface{}) int {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if data != nil && w.Code == http.StatusOK {
+		body := struct {
+			Data interface{} `json:"data"`
+		}{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Decoding %s failed: %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestIssueHandler_ListIssuesPagination(t *testing.T) {
+	router, reviewTaskID := newIssueRouter(t, 3)
+
+	tests := []struct {
+		query          string
+		page, pageSize int
+		count          int
+	}{
+		{"", 1, defaultIssuePageSize, 3},
+		{"&page=0&page_size=-1", 1, defaultIssuePageSize, 3},
+		{"&page=2&page_size=2", 2, 2, 1},
+		{"&page=9&page_size=2", 9, 2, 0},
+		{"&page_size=1000", 1, maxIssuePageSize, 3},
+	}
+	for _, tt := range tests {
+		var result struct {
+			List     []types.Issue `json:"list"`
+			Total    int64         `json:"total"`
+			Page     int           `json:"page"`
+			PageSize int           `json:"page_size"`
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/issues?review_task_id="+reviewTaskID+tt.query, nil)
+		if code := serve(t, router, req, &result); code != http.StatusOK {
+			t.Fatalf("%q: expected 200, got %d", tt.query, code)
+		}
+		if result.Page != tt.page || result.PageSize != tt.pageSize || len(result.List) != tt.count || result.Total != 3 {
+			t.Errorf("%q: expected page %d of size %d with %d issues, got %+v", tt.query, tt.page, tt.pageSize, tt.count, result)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/issues", nil)
+	if code := serve(t, router, req, nil); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without review_task_id, got %d", code)
+	}
+}
+
+func TestIssueHandler_UpdateIssue(t *testing.T) {
+	router, reviewTaskID := newIssueRouter(t, 1)
+	issueID := reviewTaskID + "-0"
+
+	patch := func(body string) int {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/issues/"+issueID, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User-ID", "alice")
+		return serve(t, router, req, nil)
+	}
+	if code := patch(`{"status":1,"assignee":"bob"}`); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	// Confirmed issues cannot move to an unknown status
+	if code := patch(`{"status":7}`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown status, got %d", code)
+	}
+
+	var history []types.IssueHistory
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/issues/"+issueID+"/history", nil)
+	if code := serve(t, router, req, &history); code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", code)
+	}
+	if len(history) != 1 || history[0].Operator != "alice" || history[0].ToStatus != types.IssueStatusConfirmed || history[0].ToAssignee != "bob" {
+		t.Errorf("Expected the change recorded with the operator of X-User-ID, got %+v", history)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/issues/missing/history", nil)
+	if code := serve(t, router, req, nil); code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", code)
+	}
+}
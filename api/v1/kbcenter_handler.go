@@ -90,7 +90,7 @@ func (h *KBCenterMockHandler) GetFileStructure(c *gin.Context) {
 	}
 
 	for _, f := range funcs {
-		funcName, _ := language.GetFunctionName(lang, f.Code)
+		funcName, _ := language.GetFunctionName(c.Request.Context(), lang, f.Code)
 		// if err != nil {
 		// 	api.Error(c, http.StatusInternalServerError, err)
 		// 	return
@@ -0,0 +1,143 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/mockroute"
+)
+
+// MockRouteHandler handler of the mock routes defined in the configuration
+type MockRouteHandler struct {
+	routes []*mockroute.Route
+}
+
+// MockRouteInfo mock route with its call count
+type MockRouteInfo struct {
+	Name   string `json:"name"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Calls  int    `json:"calls"`
+}
+
+// NewMockRouteHandler create handler of the configured mock routes, invalid routes are logged and skipped
+func NewMockRouteHandler() *MockRouteHandler {
+	h := &MockRouteHandler{}
+	for _, cfg := range config.GetConfig().MockRoutes {
+		route, err := mockroute.New(cfg)
+		if err != nil {
+			logger.Error(i18n.Translate("mock_route.invalid", "", nil), "error", err)
+			continue
+		}
+		h.routes = append(h.routes, route)
+	}
+	return h
+}
+
+// Handle respond with the next response of a mock route
+func (h *MockRouteHandler) Handle(route *mockroute.Route) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		req := mockroute.Request{
+			Method:  c.Request.Method,
+			Path:    c.Request.URL.Path,
+			Params:  make(map[string]string, len(c.Params)),
+			Query:   make(map[string]string),
+			Headers: make(map[string]string),
+		}
+		for _, param := range c.Params {
+			req.Params[param.Key] = param.Value
+		}
+		for name, values := range c.Request.URL.Query() {
+			req.Query[name] = values[0]
+		}
+		for name, values := range c.Request.Header {
+			req.Headers[name] = values[0]
+		}
+		if c.Request.Body != nil {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				api.BadRequest(c, "common.invalidParameter")
+				return
+			}
+			req.Body = string(body)
+			// Bodies that are not JSON are only available raw
+			_ = json.Unmarshal(body, &req.JSON)
+		}
+
+		resp, err := route.Respond(req)
+		if err != nil {
+			// The error names the template and the failing expression
+			api.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+		for name, value := range resp.Headers {
+			c.Header(name, value)
+		}
+		contentType := resp.Headers["Content-Type"]
+		if contentType == "" {
+			contentType = mockroute.ContentType(resp.Body)
+		}
+		c.Data(resp.Status, contentType, resp.Body)
+	}
+}
+
+// register register a mock route, turning the panic of Gin on a conflicting route into an error
+func (h *MockRouteHandler) register(router *gin.RouterGroup, route *mockroute.Route) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	router.Handle(route.Method, route.Path, h.Handle(route))
+	return nil
+}
+
+// ListMockRoutes list the mock routes with their call counts
+// @Summary List mock routes
+// @Tags admin
+// @Produce json
+// @Success 200 {object} api.Response{data=[]MockRouteInfo}
+// @Router /admin/mock_routes [get]
+func (h *MockRouteHandler) ListMockRoutes(c *gin.Context) {
+	infos := make([]MockRouteInfo, 0, len(h.routes))
+	for _, route := range h.routes {
+		infos = append(infos, MockRouteInfo{Name: route.Name, Method: route.Method, Path: route.Path, Calls: route.Calls()})
+	}
+	api.Success(c, infos)
+}
+
+// ResetMockRoutes reset the call counts, starting the response sequences over
+// @Summary Reset mock routes
+// @Tags admin
+// @Produce json
+// @Success 200 {object} api.Response
+// @Router /admin/mock_routes/reset [post]
+func (h *MockRouteHandler) ResetMockRoutes(c *gin.Context) {
+	for _, route := range h.routes {
+		route.Reset()
+	}
+	api.Success(c, nil)
+}
+
+// RegisterRoutes register the mock routes and their admin routes.
+// Routes conflicting with a registered route, such as another wildcard name at the same position, are logged and skipped.
+func (h *MockRouteHandler) RegisterRoutes(router *gin.RouterGroup) {
+	registered := h.routes[:0]
+	for _, route := range h.routes {
+		if err := h.register(router, route); err != nil {
+			logger.Error(i18n.Translate("mock_route.invalid", "", nil), "name", route.Name, "path", route.Path, "error", err)
+			continue
+		}
+		registered = append(registered, route)
+	}
+	h.routes = registered
+	router.GET("/admin/mock_routes", h.ListMockRoutes)
+	router.POST("/admin/mock_routes/reset", h.ResetMockRoutes)
+}
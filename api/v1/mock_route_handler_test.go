@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+func TestMockRouteHandler_ConflictingRoutes(t *testing.T) {
+	cfg := config.GetConfig()
+	previous := cfg.MockRoutes
+	cfg.MockRoutes = []config.MockRoute{
+		{Name: "stats", Path: "/codebases/:codebase_id", Response: config.MockResponse{Body: "stats"}},
+		{Name: "conflict", Path: "/codebases/:id", Response: config.MockResponse{Body: "conflict"}},
+		{Name: "duplicate", Path: "/codebases/:codebase_id", Response: config.MockResponse{Body: "duplicate"}},
+		{Name: "other", Path: "/other", Response: config.MockResponse{Body: "other"}},
+	}
+	t.Cleanup(func() { cfg.MockRoutes = previous })
+
+	// Conflicting routes are skipped instead of stopping the startup
+	h := NewMockRouteHandler()
+	router := gin.New()
+	h.RegisterRoutes(router.Group("/api/v1"))
+	if len(h.routes) != 2 || h.routes[0].Name != "stats" || h.routes[1].Name != "other" {
+		t.Fatalf("Expected the conflicting routes skipped, got %+v", h.routes)
+	}
+	for path, body := range map[string]string{"/api/v1/codebases/c1": "stats", "/api/v1/other": "other"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Errorf("%s: expected %q, got %d %q", path, body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRegisterRoutes_MockRouteConflictingWithAPI(t *testing.T) {
+	cfg := config.GetConfig()
+	previous := cfg.MockRoutes
+	cfg.MockRoutes = []config.MockRoute{{Name: "task", Path: "/review_tasks/:task_id", Response: config.MockResponse{Body: "task"}}}
+	t.Cleanup(func() { cfg.MockRoutes = previous })
+
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Expected the mock route skipped, got %v", r)
+		}
+	}()
+	RegisterRoutes(gin.New().Group("/api/v1"), t.TempDir())
+}
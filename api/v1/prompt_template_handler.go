@@ -0,0 +1,262 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// PromptTemplateHandler prompt template API handler
+type PromptTemplateHandler struct {
+	service *service.PromptTemplateService
+}
+
+// NewPromptTemplateHandler create prompt template handler
+func NewPromptTemplateHandler() *PromptTemplateHandler {
+	return &PromptTemplateHandler{
+		service: service.NewPromptTemplateService(),
+	}
+}
+
+// PromptTemplateRequest content of a prompt template version
+type PromptTemplateRequest struct {
+	Description          string            `json:"description"`
+	SystemPrompt         string            `json:"system_prompt"`
+	UserPrompt           string            `json:"user_prompt" binding:"required"`
+	LanguageInstructions map[string]string `json:"language_instructions"` // By language
+	OutputSchema         string            `json:"output_schema"`
+}
+
+// CreatePromptTemplateRequest request body of prompt template creation
+type CreatePromptTemplateRequest struct {
+	Name string `json:"name" binding:"required"`
+	PromptTemplateRequest
+}
+
+// GetPromptTemplateRequest query of prompt template retrieval
+type GetPromptTemplateRequest struct {
+	Version int `form:"version"` // Latest version when omitted
+}
+
+// RenderPromptTemplateRequest request body of prompt preview, rendering either a stored template or a draft
+type RenderPromptTemplateRequest struct {
+	Name      string                 `json:"name"`    // Stored template, ignored when Template is set
+	Version   int                    `json:"version"` // Latest version when omitted
+	Template  *PromptTemplateRequest `json:"template"`
+	FilePath  string                 `json:"file_path" binding:"required"`
+	Language  string                 `json:"language"` // Detected from the file path when omitted
+	Code      string                 `json:"code" binding:"required"`
+	StartLine int                    `json:"start_line"` // First line when omitted
+	EndLine   int                    `json:"end_line"`   // Last line when omitted
+}
+
+// CreatePromptTemplate store version 1 of a new prompt template
+// @Summary Create prompt template
+// @Tags prompt_templates
+// @Accept json
+// @Produce json
+// @Param request body CreatePromptTemplateRequest true "Prompt template"
+// @Success 200 {object} api.Response{data=types.PromptTemplate}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /prompt_templates [post]
+func (h *PromptTemplateHandler) CreatePromptTemplate(c *gin.Context) {
+	var req CreatePromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	template, err := h.service.CreatePromptTemplate(c.Request.Context(), req.Name, req.toInput())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, template)
+}
+
+// ListPromptTemplates list the latest version of every prompt template
+// @Summary List prompt templates
+// @Tags prompt_templates
+// @Produce json
+// @Success 200 {object} api.Response{data=[]types.PromptTemplate}
+// @Router /prompt_templates [get]
+func (h *PromptTemplateHandler) ListPromptTemplates(c *gin.Context) {
+	templates, err := h.service.ListPromptTemplates(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, templates)
+}
+
+// GetPromptTemplate get a version of a prompt template
+// @Summary Get prompt template
+// @Tags prompt_templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Param version query int false "Version, the latest when omitted"
+// @Success 200 {object} api.Response{data=types.PromptTemplate}
+// @Failure 404 {object} api.Response
+// @Router /prompt_templates/{name} [get]
+func (h *PromptTemplateHandler) GetPromptTemplate(c *gin.Context) {
+	var req GetPromptTemplateRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	template, err := h.service.GetPromptTemplate(c.Request.Context(), c.Param("name"), req.Version)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, template)
+}
+
+// UpdatePromptTemplate store a new version of a prompt template, used by the analyzer from its next file on
+// @Summary Update prompt template
+// @Tags prompt_templates
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param request body PromptTemplateRequest true "Prompt template"
+// @Success 200 {object} api.Response{data=types.PromptTemplate}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /prompt_templates/{name} [put]
+func (h *PromptTemplateHandler) UpdatePromptTemplate(c *gin.Context) {
+	var req PromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	template, err := h.service.UpdatePromptTemplate(c.Request.Context(), c.Param("name"), req.toInput())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, template)
+}
+
+// ListPromptTemplateVersions list all versions of a prompt template, newest first
+// @Summary List prompt template versions
+// @Tags prompt_templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} api.Response{data=[]types.PromptTemplate}
+// @Failure 404 {object} api.Response
+// @Router /prompt_templates/{name}/versions [get]
+func (h *PromptTemplateHandler) ListPromptTemplateVersions(c *gin.Context) {
+	templates, err := h.service.ListPromptTemplateVersions(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, templates)
+}
+
+// DeletePromptTemplate remove all versions of a prompt template
+// @Summary Delete prompt template
+// @Tags prompt_templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /prompt_templates/{name} [delete]
+func (h *PromptTemplateHandler) DeletePromptTemplate(c *gin.Context) {
+	if err := h.service.DeletePromptTemplate(c.Request.Context(), c.Param("name")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, nil)
+}
+
+// RenderPromptTemplate render a stored prompt template or a draft for a piece of code, as the analyzer would send it
+// @Summary Preview prompt
+// @Tags prompt_templates
+// @Accept json
+// @Produce json
+// @Param request body RenderPromptTemplateRequest true "Template and code"
+// @Success 200 {object} api.Response{data=types.RenderedPrompt}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /prompt_templates/render [post]
+func (h *PromptTemplateHandler) RenderPromptTemplate(c *gin.Context) {
+	var req RenderPromptTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Name == "" && req.Template == nil) {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	input := service.PromptRenderInput{
+		FilePath:  req.FilePath,
+		Language:  req.Language,
+		Code:      req.Code,
+		StartLine: req.StartLine,
+		EndLine:   req.EndLine,
+	}
+	var rendered *types.RenderedPrompt
+	var err error
+	if req.Template != nil {
+		rendered, err = h.service.RenderPromptDraft(c.Request.Context(), req.Template.toInput(), input)
+	} else {
+		rendered, err = h.service.RenderPromptTemplate(c.Request.Context(), req.Name, req.Version, input)
+	}
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, rendered)
+}
+
+// toInput convert the request into service input
+func (r *PromptTemplateRequest) toInput() service.PromptTemplateInput {
+	return service.PromptTemplateInput{
+		Description:          r.Description,
+		SystemPrompt:         r.SystemPrompt,
+		UserPrompt:           r.UserPrompt,
+		LanguageInstructions: r.LanguageInstructions,
+		OutputSchema:         r.OutputSchema,
+	}
+}
+
+// handleError write the error response of prompt template errors
+func (h *PromptTemplateHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrPromptTemplateNotFound):
+		api.NotFound(c, "prompt_template.not_found")
+	case errors.Is(err, service.ErrPromptTemplateExists):
+		api.Fail(c, http.StatusConflict, "prompt_template.exists")
+	case errors.Is(err, service.ErrInvalidPromptTemplateName):
+		api.BadRequest(c, "prompt_template.invalid_name")
+	case errors.Is(err, service.ErrInvalidPromptTemplate):
+		// The template error tells where the template is wrong
+		api.Error(c, http.StatusBadRequest, err)
+	default:
+		api.Error(c, http.StatusInternalServerError, err)
+	}
+}
+
+// RegisterRoutes register prompt template routes
+func (h *PromptTemplateHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/prompt_templates", h.CreatePromptTemplate)
+	router.GET("/prompt_templates", h.ListPromptTemplates)
+	router.POST("/prompt_templates/render", h.RenderPromptTemplate)
+	router.GET("/prompt_templates/:name", h.GetPromptTemplate)
+	router.PUT("/prompt_templates/:name", h.UpdatePromptTemplate)
+	router.DELETE("/prompt_templates/:name", h.DeletePromptTemplate)
+	router.GET("/prompt_templates/:name/versions", h.ListPromptTemplateVersions)
+}
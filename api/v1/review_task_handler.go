@@ -0,0 +1,311 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	"github.com/zgsm/mock-kbcenter/pkg/report"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/tasks"
+	"golang.org/x/net/websocket"
+)
+
+// eventHeartbeatInterval interval of the keep-alive comments of idle event streams
+const eventHeartbeatInterval = 15 * time.Second
+
+// ReviewTaskHandler review task API handler
+type ReviewTaskHandler struct {
+	service *service.ReviewTaskService
+}
+
+// NewReviewTaskHandler create review task handler, baseDir is the codebase root of new tasks
+func NewReviewTaskHandler(baseDir string) *ReviewTaskHandler {
+	return &ReviewTaskHandler{
+		service: service.NewReviewTaskService(baseDir),
+	}
+}
+
+// CreateReviewTaskRequest request body of review task creation
+type CreateReviewTaskRequest struct {
+	ClientID     string         `json:"client_id"`
+	CodebasePath string         `json:"codebase_path"`
+	Targets      []types.Target `json:"targets" binding:"required,min=1"`
+	// Baseline known issues filtered out of the review, defaults to the baseline file of the codebase
+	Baseline *types.Baseline `json:"baseline"`
+}
+
+// CreateReviewTask create and dispatch a review task, a duplicate submission returns the existing task
+// @Summary Create review task
+// @Tags review_tasks
+// @Accept json
+// @Produce json
+// @Param Idempotency-Key header string false "Submissions with the same key return the task created first"
+// @Param request body CreateReviewTaskRequest true "Review task"
+// @Success 200 {object} api.Response{data=types.ReviewTask}
+// @Failure 400 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /review_tasks [post]
+func (h *ReviewTaskHandler) CreateReviewTask(c *gin.Context) {
+	var req CreateReviewTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	task, existing, err := h.service.CreateTask(c.Request.Context(), req.ClientID, req.CodebasePath, req.Targets, req.Baseline, c.GetHeader("Idempotency-Key"))
+	switch {
+	case errors.Is(err, service.ErrReviewTaskCreating):
+		api.Fail(c, http.StatusConflict, "review_task.creating")
+		return
+	case errors.Is(err, service.ErrInvalidReviewTask):
+		api.Error(c, http.StatusBadRequest, err)
+		return
+	case err != nil:
+		api.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+	if existing {
+		c.Header("Idempotent-Replayed", "true")
+		api.Success(c, task)
+		return
+	}
+
+	if err := tasks.DispatchReviewTask(c.Request.Context(), task.ReviewTaskID); err != nil {
+		api.Error(c, http.StatusInternalServerError, h.service.AbandonTask(c.Request.Context(), task.ReviewTaskID, err))
+		return
+	}
+
+	api.Success(c, task)
+}
+
+// GetReviewTask get review task status
+// @Summary Get review task
+// @Tags review_tasks
+// @Produce json
+// @Param id path string true "Review task ID"
+// @Success 200 {object} api.Response{data=types.ReviewTask}
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id} [get]
+func (h *ReviewTaskHandler) GetReviewTask(c *gin.Context) {
+	task, err := h.service.GetTask(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, task)
+}
+
+// CancelReviewTask cancel a review task, the issues found so far are kept as a partial result
+// @Summary Cancel review task
+// @Tags review_tasks
+// @Produce json
+// @Param id path string true "Review task ID"
+// @Success 200 {object} api.Response{data=types.ReviewTask}
+// @Failure 404 {object} api.Response
+// @Failure 409 {object} api.Response
+// @Router /review_tasks/{id}/cancel [post]
+func (h *ReviewTaskHandler) CancelReviewTask(c *gin.Context) {
+	task, err := h.service.CancelTask(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	if err := tasks.CancelReviewTask(task); err != nil {
+		api.Error(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	api.Success(c, task)
+}
+
+// GetReviewTaskIssues get the issues found after offset, with task progress
+// @Summary Get review task issues incrementally
+// @Tags review_tasks
+// @Produce json
+// @Param id path string true "Review task ID"
+// @Param offset query int false "Offset of the first issue, use next_offset of the previous call"
+// @Param limit query int false "Maximum number of issues, 0 means all"
+// @Success 200 {object} api.Response{data=types.IssueIncrementReviewTaskResult}
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id}/issues [get]
+func (h *ReviewTaskHandler) GetReviewTaskIssues(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	result, err := h.service.GetIncrementResult(c.Request.Context(), c.Param("id"), offset, limit)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, result)
+}
+
+// StreamReviewTaskEvents stream progress updates and newly found issues of a review task as Server-Sent Events.
+// The event ID is the offset after the issues sent so far, a reconnecting client resumes from it through Last-Event-ID.
+// @Summary Stream review task events
+// @Tags review_tasks
+// @Produce text/event-stream
+// @Param id path string true "Review task ID"
+// @Param offset query int false "Offset of the first issue to send"
+// @Param Last-Event-ID header string false "ID of the last received event, overrides offset"
+// @Success 200 {object} types.ReviewTaskEvent
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id}/events [get]
+func (h *ReviewTaskHandler) StreamReviewTaskEvents(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if lastEventID := c.GetHeader("Last-Event-ID"); lastEventID != "" {
+		offset, _ = strconv.Atoi(lastEventID)
+	}
+
+	events, err := h.service.SubscribeEvents(c.Request.Context(), c.Param("id"), offset)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.Render(-1, sse.Event{Id: strconv.Itoa(event.NextOffset), Event: event.Type, Data: event})
+			return true
+		case <-heartbeat.C:
+			// Comment line keeping idle connections open through proxies
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		}
+	})
+}
+
+// StreamReviewTaskEventsWS stream the events of a review task over WebSocket, one JSON text message per event
+// @Summary Stream review task events over WebSocket
+// @Tags review_tasks
+// @Param id path string true "Review task ID"
+// @Param offset query int false "Offset of the first issue to send"
+// @Success 101 {object} types.ReviewTaskEvent
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id}/events/ws [get]
+func (h *ReviewTaskHandler) StreamReviewTaskEventsWS(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	events, err := h.service.SubscribeEvents(ctx, c.Param("id"), offset)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	server := websocket.Server{
+		// Cross-origin clients are accepted on purpose, as the CORS middleware of the API allows every origin
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			// Messages from the client are ignored, a read failure means the client has gone
+			go func() {
+				_, _ = io.Copy(io.Discard, conn)
+				cancel()
+			}()
+			for event := range events {
+				if err := websocket.JSON.Send(conn, event); err != nil {
+					return
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// GetReviewTaskReport export the issues of a review task as a report
+// @Summary Export review task report
+// @Tags review_tasks
+// @Produce application/sarif+json
+// @Produce application/xml
+// @Produce text/markdown
+// @Produce text/html
+// @Param id path string true "Review task ID"
+// @Param format query string false "Report format" Enums(sarif, junit, markdown, html) default(sarif)
+// @Success 200 {file} file
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id}/report [get]
+func (h *ReviewTaskHandler) GetReviewTaskReport(c *gin.Context) {
+	format := c.DefaultQuery("format", report.FormatSARIF)
+	if !report.IsSupported(format) {
+		api.BadRequest(c, "report.invalid_format")
+		return
+	}
+
+	reviewTaskID := c.Param("id")
+	data, err := h.service.ExportReport(c.Request.Context(), reviewTaskID, format, c.GetString("locale"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=review-%s%s", reviewTaskID, report.FileExtension(format)))
+	c.Data(http.StatusOK, report.ContentType(format), data)
+}
+
+// GetReviewTaskBaseline export the issues of a review task as a baseline file
+// @Summary Export review task baseline
+// @Tags review_tasks
+// @Produce json
+// @Param id path string true "Review task ID"
+// @Success 200 {object} types.Baseline
+// @Failure 404 {object} api.Response
+// @Router /review_tasks/{id}/baseline [get]
+func (h *ReviewTaskHandler) GetReviewTaskBaseline(c *gin.Context) {
+	reviewTaskID := c.Param("id")
+	baseline, err := h.service.ExportBaseline(c.Request.Context(), reviewTaskID)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=baseline-%s.json", reviewTaskID))
+	c.JSON(http.StatusOK, baseline)
+}
+
+// handleError write the error response of review task errors
+func (h *ReviewTaskHandler) handleError(c *gin.Context, err error) {
+	if errors.Is(err, service.ErrReviewTaskNotFound) {
+		api.NotFound(c, "review_task.not_found")
+		return
+	}
+	if errors.Is(err, service.ErrReviewTaskFinished) {
+		api.Fail(c, http.StatusConflict, "review_task.already_finished")
+		return
+	}
+	api.Error(c, http.StatusInternalServerError, err)
+}
+
+// RegisterRoutes register review task routes
+func (h *ReviewTaskHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/review_tasks", h.CreateReviewTask)
+	router.GET("/review_tasks/:id", h.GetReviewTask)
+	router.POST("/review_tasks/:id/cancel", h.CancelReviewTask)
+	router.GET("/review_tasks/:id/issues", h.GetReviewTaskIssues)
+	router.GET("/review_tasks/:id/events", h.StreamReviewTaskEvents)
+	router.GET("/review_tasks/:id/events/ws", h.StreamReviewTaskEventsWS)
+	router.GET("/review_tasks/:id/report", h.GetReviewTaskReport)
+	router.GET("/review_tasks/:id/baseline", h.GetReviewTaskBaseline)
+}
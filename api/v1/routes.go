@@ -8,4 +8,23 @@ import (
 func RegisterRoutes(router *gin.RouterGroup, workDir string) {
 	kbcenterHandler := NewKBCenterMockHandler(workDir)
 	kbcenterHandler.RegisterRoutes(router)
+
+	reviewTaskHandler := NewReviewTaskHandler(workDir)
+	reviewTaskHandler.RegisterRoutes(router)
+
+	issueHandler := NewIssueHandler()
+	issueHandler.RegisterRoutes(router)
+
+	webhookHandler := NewWebhookHandler()
+	webhookHandler.RegisterRoutes(router)
+
+	promptTemplateHandler := NewPromptTemplateHandler()
+	promptTemplateHandler.RegisterRoutes(router)
+
+	faultHandler := NewFaultHandler()
+	faultHandler.RegisterRoutes(router)
+
+	// Mock routes come last, so that those conflicting with the API are skipped
+	mockRouteHandler := NewMockRouteHandler()
+	mockRouteHandler.RegisterRoutes(router)
 }
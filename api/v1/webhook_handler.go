@@ -0,0 +1,259 @@
+package v1
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+)
+
+// WebhookHandler webhook API handler
+type WebhookHandler struct {
+	service *service.WebhookService
+}
+
+// NewWebhookHandler create webhook handler
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		service: service.NewWebhookService(),
+	}
+}
+
+// Pagination defaults of delivery listing
+const (
+	defaultDeliveryPageSize = 20
+	maxDeliveryPageSize     = 100
+)
+
+// CreateWebhookRequest request body of webhook registration
+type CreateWebhookRequest struct {
+	URL          string   `json:"url" binding:"required"`
+	Secret       string   `json:"secret"`        // Generated when empty
+	CodebasePath string   `json:"codebase_path"` // Empty for tasks of every codebase
+	Events       []string `json:"events"`        // Empty for every event
+}
+
+// UpdateWebhookRequest request body of webhook changes, omitted fields are left unchanged
+type UpdateWebhookRequest struct {
+	URL          *string   `json:"url"`
+	Secret       *string   `json:"secret"`
+	CodebasePath *string   `json:"codebase_path"`
+	Events       *[]string `json:"events"`
+	Enabled      *bool     `json:"enabled"`
+}
+
+// ListDeliveriesRequest query of delivery listing
+type ListDeliveriesRequest struct {
+	Page     int `form:"page"`
+	PageSize int `form:"page_size"`
+}
+
+// CreateWebhook register a webhook notified of review task events
+// @Summary Create webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Webhook"
+// @Success 200 {object} api.Response{data=types.Webhook}
+// @Failure 400 {object} api.Response
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(c.Request.Context(), req.URL, req.Secret, req.CodebasePath, req.Events)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, webhook)
+}
+
+// ListWebhooks list all webhooks
+// @Summary List webhooks
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} api.Response{data=[]types.Webhook}
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	webhooks, err := h.service.ListWebhooks(c.Request.Context())
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, webhooks)
+}
+
+// GetWebhook get a webhook
+// @Summary Get webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} api.Response{data=types.Webhook}
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, err := h.service.GetWebhook(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, webhook)
+}
+
+// UpdateWebhook change a webhook, or enable and disable it
+// @Summary Update webhook
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param request body UpdateWebhookRequest true "Webhook changes"
+// @Success 200 {object} api.Response{data=types.Webhook}
+// @Failure 400 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id} [patch]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+
+	webhook, err := h.service.UpdateWebhook(c.Request.Context(), c.Param("id"), service.WebhookUpdate{
+		URL:          req.URL,
+		Secret:       req.Secret,
+		CodebasePath: req.CodebasePath,
+		Events:       req.Events,
+		Enabled:      req.Enabled,
+	})
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, webhook)
+}
+
+// DeleteWebhook remove a webhook and its deliveries
+// @Summary Delete webhook
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} api.Response
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	if err := h.service.DeleteWebhook(c.Request.Context(), c.Param("id")); err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, nil)
+}
+
+// ListDeliveries list the deliveries of a webhook, newest first
+// @Summary List webhook deliveries
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param page query int false "Page, starting at 1" default(1)
+// @Param page_size query int false "Page size, at most 100" default(20)
+// @Success 200 {object} api.Response{data=api.PageResult{list=[]types.WebhookDelivery}}
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var req ListDeliveriesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		api.BadRequest(c, "common.invalidParameter")
+		return
+	}
+	req.Page = max(req.Page, 1)
+	if req.PageSize <= 0 {
+		req.PageSize = defaultDeliveryPageSize
+	}
+	req.PageSize = min(req.PageSize, maxDeliveryPageSize)
+
+	list, total, err := h.service.ListDeliveries(c.Request.Context(), c.Param("id"), req.Page, req.PageSize)
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, api.NewPageResult(list, total, req.Page, req.PageSize))
+}
+
+// GetDelivery get a delivery of a webhook with its payload
+// @Summary Get webhook delivery
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} api.Response{data=types.WebhookDelivery}
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id}/deliveries/{delivery_id} [get]
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.service.GetDelivery(c.Request.Context(), c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, delivery)
+}
+
+// Redeliver send a delivery again and return the new delivery once it is done
+// @Summary Redeliver webhook delivery
+// @Tags webhooks
+// @Produce json
+// @Param id path string true "Webhook ID"
+// @Param delivery_id path string true "Delivery ID"
+// @Success 200 {object} api.Response{data=types.WebhookDelivery}
+// @Failure 404 {object} api.Response
+// @Router /webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	delivery, err := h.service.Redeliver(c.Request.Context(), c.Param("id"), c.Param("delivery_id"))
+	if err != nil {
+		h.handleError(c, err)
+		return
+	}
+
+	api.Success(c, delivery)
+}
+
+// handleError write the error response of webhook errors
+func (h *WebhookHandler) handleError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound):
+		api.NotFound(c, "webhook.not_found")
+	case errors.Is(err, service.ErrWebhookDeliveryNotFound):
+		api.NotFound(c, "webhook.delivery_not_found")
+	case errors.Is(err, service.ErrInvalidWebhookURL):
+		api.BadRequest(c, "webhook.invalid_url")
+	case errors.Is(err, service.ErrWebhookAddressDenied):
+		api.BadRequest(c, "webhook.address_denied")
+	case errors.Is(err, service.ErrInvalidWebhookEvent):
+		api.BadRequest(c, "webhook.invalid_event")
+	default:
+		api.Error(c, http.StatusInternalServerError, err)
+	}
+}
+
+// RegisterRoutes register webhook routes
+func (h *WebhookHandler) RegisterRoutes(router *gin.RouterGroup) {
+	router.POST("/webhooks", h.CreateWebhook)
+	router.GET("/webhooks", h.ListWebhooks)
+	router.GET("/webhooks/:id", h.GetWebhook)
+	router.PATCH("/webhooks/:id", h.UpdateWebhook)
+	router.DELETE("/webhooks/:id", h.DeleteWebhook)
+	router.GET("/webhooks/:id/deliveries", h.ListDeliveries)
+	router.GET("/webhooks/:id/deliveries/:delivery_id", h.GetDelivery)
+	router.POST("/webhooks/:id/deliveries/:delivery_id/redeliver", h.Redeliver)
+}
@@ -6,7 +6,7 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/zgsm/mock-kbcenter/config"
 
-	// "github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/model"
 	"github.com/zgsm/mock-kbcenter/pkg/db"
 )
 
@@ -28,7 +28,13 @@ var migrateCmd = &cobra.Command{
 		// Register all models that need migration
 		log.Println("Migrating database...")
 		if err := db.AutoMigrate(
-		// Add other models here
+			&model.ReviewTask{},
+			&model.ReviewIssue{},
+			&model.ReviewIssueHistory{},
+			&model.Webhook{},
+			&model.WebhookDelivery{},
+			&model.PromptTemplate{},
+			// Add other models here
 		); err != nil {
 			log.Fatalf("Failed to migrate database: %v", err)
 		}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+)
+
+// Stub OpenAI-compatible model server for running the llm analyzer locally,
+// it serves the fake reviewer of thirdPlatform at <addr>/v1/chat/completions
+func main() {
+	addr := flag.String("addr", ":8089", "listen address")
+	flag.Parse()
+
+	// Initialize configuration
+	if err := config.LoadConfigWithDefault(); err != nil {
+		log.Fatalf("config.load.failed: %v", err)
+	}
+
+	// Initialize i18n
+	if err := i18n.InitI18n(*config.GetConfig()); err != nil {
+		fmt.Printf("i18n.init.failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println(i18n.Translate("llm.stub.listening", "", map[string]interface{}{"addr": *addr}))
+	if err := http.ListenAndServe(*addr, thirdPlatform.NewFakeLLMHandler()); err != nil {
+		log.Fatalln(err)
+	}
+}
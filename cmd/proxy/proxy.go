@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/headerpropagation"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
+	"github.com/zgsm/mock-kbcenter/pkg/tunnel"
+)
+
+// clientIDParam query parameter naming the target of a request, removed before forwarding
+const clientIDParam = "client_id"
+
+// proxyService service name of the metrics of the forwarded requests
+const proxyService = "proxy"
+
+// adminPrefix path prefix of the proxy's own API, never forwarded
+const adminPrefix = "/_proxy/"
+
+// errUnknownClient client ID that is not registered
+var errUnknownClient = errors.New("unknown client")
+
+// targetKey context key of the target URL of a request
+type targetKey struct{}
+
+// NewHandler reverse proxy forwarding every request through the tunnel of the client in its client_id query
+// parameter, to the base URL registered for the client, or to the URL in it when raw URLs are allowed. The
+// request path is appended to the path of the target and the other query parameters are kept.
+func NewHandler(cfg *config.Config) (http.Handler, error) {
+	if cfg.Proxy.Tunnel.Enabled && cfg.Proxy.Tunnel.Token == "" {
+		return nil, errors.New(i18n.Translate("proxy.tunnel_token_required", "", nil))
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	policy, err := NewTargetPolicy(cfg.Proxy.Targets, dialer)
+	if err != nil {
+		return nil, err
+	}
+	registry, err := NewRegistry(cfg.Proxy.Clients, policy)
+	if err != nil {
+		return nil, err
+	}
+	hub := tunnel.NewHub(time.Duration(cfg.Proxy.Tunnel.PingInterval) * time.Second)
+
+	// The environment proxy is not used: connections through a proxy skip the address check at dial time
+	transport := &http.Transport{
+		DialContext:           policy.DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ResponseHeaderTimeout: time.Duration(cfg.Proxy.ResponseHeaderTimeout) * time.Second,
+	}
+	viaProxy := cfg.HTTPClient.ProxyURL != ""
+	if viaProxy {
+		proxyURL, err := url.Parse(cfg.HTTPClient.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		// Connections go to the outgoing proxy, target addresses are checked before forwarding instead
+		transport.DialContext = dialer.DialContext
+	}
+	if cfg.HTTPClient.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	reverseProxy := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) { rewrite(pr, cfg.HeaderPropagation.Headers) },
+		Transport: metrics.InstrumentRoundTripper(proxyService, &tunnelTransport{
+			base: &redirectTransport{base: transport, policy: policy, maxRedirects: cfg.Proxy.Targets.MaxRedirects, checkAddresses: viaProxy},
+			hub:  hub,
+		}),
+		FlushInterval: time.Duration(cfg.Proxy.FlushInterval) * time.Millisecond,
+		ErrorHandler:  handleForwardError,
+	}
+
+	mux := http.NewServeMux()
+	registry.registerAdminRoutes(mux, cfg.Proxy.AdminToken, hub)
+	if cfg.Proxy.Tunnel.Enabled {
+		registerTunnelRoutes(mux, hub, registry, cfg.Proxy.Tunnel.Token)
+	}
+	if cfg.Metrics.Enabled {
+		mux.Handle("GET "+adminPrefix+"metrics", metrics.Handler())
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, adminPrefix) {
+			http.NotFound(w, r)
+			return
+		}
+		ctx := r.Context()
+		var target *url.URL
+		var err error
+		// Registered clients win over tunnels of the same ID
+		clientID := r.URL.Query().Get(clientIDParam)
+		if _, registered := registry.Lookup(clientID); !registered && hub.Connected(clientID) {
+			// The agent forwards to its own target, the policy applies to targets the proxy connects to
+			target = &url.URL{Scheme: "http", Host: clientID}
+			ctx = context.WithValue(ctx, tunnelKey{}, clientID)
+		} else {
+			target, err = targetURL(r, registry, cfg.Proxy.Targets.AllowRawURLs)
+			if err == nil {
+				err = policy.CheckURL(target)
+			}
+			if err == nil && viaProxy {
+				if err = policy.CheckAddresses(ctx, target.Hostname()); err != nil && !errors.Is(err, ErrTargetDenied) {
+					err = errors.New(translateError("proxy.resolve_failed", err))
+				}
+			}
+		}
+		switch {
+		case errors.Is(err, ErrTargetDenied):
+			http.Error(w, translateError("proxy.target_denied", err), http.StatusForbidden)
+			return
+		case errors.Is(err, errUnknownClient):
+			http.Error(w, translateError("proxy.unknown_client", err), http.StatusNotFound)
+			return
+		case err != nil:
+			// Other errors are translated already
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if cfg.Proxy.MaxRequestBody > 0 && r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, cfg.Proxy.MaxRequestBody)
+		}
+
+		// Apply the configured header propagation, as the web server does for its outgoing calls
+		propagated := make(map[string]string)
+		for _, header := range cfg.HeaderPropagation.Headers {
+			if value := r.Header.Get(header); value != "" {
+				propagated[header] = value
+			}
+		}
+		ctx = headerpropagation.WithContext(ctx, propagated)
+		ctx = context.WithValue(ctx, targetKey{}, target)
+		reverseProxy.ServeHTTP(w, r.WithContext(ctx))
+	})
+
+	// Requests are recorded per route pattern, forwarded ones under "/"
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		metrics.InstrumentHandler(pattern, mux).ServeHTTP(w, r)
+	}), nil
+}
+
+// targetURL target of the request from its client_id query parameter: the base URL of the registered client,
+// or the URL itself when raw URLs are allowed
+func targetURL(r *http.Request, registry *Registry, allowRawURLs bool) (*url.URL, error) {
+	clientID := r.URL.Query().Get(clientIDParam)
+	if clientID == "" {
+		return nil, errors.New(i18n.Translate("proxy.missing_client_id", "", nil))
+	}
+	if target, ok := registry.Lookup(clientID); ok {
+		return target, nil
+	}
+	if !allowRawURLs {
+		return nil, fmt.Errorf("%w: %s", errUnknownClient, clientID)
+	}
+	target, err := url.Parse(clientID)
+	if err == nil && (target.Scheme != "http" && target.Scheme != "https" || target.Host == "") {
+		err = errors.New(i18n.Translate("proxy.target_not_absolute", "", nil))
+	}
+	if err != nil {
+		return nil, errors.New(translateError("proxy.invalid_client_id", err))
+	}
+	return target, nil
+}
+
+// rewrite point the outgoing request at the target and set the propagated headers. The reverse proxy has
+// already removed the hop-by-hop headers, and keeps the Upgrade headers of protocol upgrades such as WebSocket.
+func rewrite(pr *httputil.ProxyRequest, propagatedHeaders []string) {
+	target := pr.In.Context().Value(targetKey{}).(*url.URL)
+
+	out := pr.Out.URL
+	out.Scheme, out.Host = target.Scheme, target.Host
+	out.Path, out.RawPath = target.Path, ""
+	if path := pr.In.URL.Path; path != "" && path != "/" {
+		out.Path = strings.TrimSuffix(target.Path, "/") + path
+	}
+	query := target.Query()
+	for name, values := range pr.In.URL.Query() {
+		if name != clientIDParam {
+			query[name] = append(query[name], values...)
+		}
+	}
+	out.RawQuery = query.Encode()
+	pr.Out.Host = ""
+
+	pr.SetXForwarded()
+	for _, header := range propagatedHeaders {
+		if value := headerpropagation.GetHeaderValue(pr.In.Context(), header); value != "" {
+			pr.Out.Header.Set(header, value)
+		}
+	}
+}
+
+// translateError translated message of key carrying the error
+func translateError(key string, err error) string {
+	return i18n.Translate(key, "", map[string]interface{}{"error": err.Error()})
+}
+
+// handleForwardError answer requests that could not be forwarded
+func handleForwardError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		http.Error(w, translateError("proxy.request_too_large", err), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, ErrTargetDenied) {
+		// Addresses and redirect locations are only known while forwarding
+		http.Error(w, translateError("proxy.target_denied", err), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, tunnel.ErrUpgradeUnsupported) {
+		http.Error(w, translateError("proxy.upgrade_unsupported", err), http.StatusNotImplemented)
+		return
+	}
+	if errors.Is(err, context.Canceled) {
+		// The client went away, nobody reads the answer
+		return
+	}
+	log.Printf("%s: %s %s: %v", i18n.Translate("proxy.forward_error", "", nil), r.Method, r.URL.Path, err)
+	http.Error(w, translateError("proxy.forward_error", err), http.StatusBadGateway)
+}
@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/tunnel"
+)
+
+func newTestProxy(t *testing.T, maxBody int64) *httptest.Server {
+	cfg := &config.Config{}
+	cfg.Proxy.FlushInterval = -1
+	cfg.Proxy.MaxRequestBody = maxBody
+	// The test servers listen on loopback
+	cfg.Proxy.Targets = config.ProxyTargets{AllowRawURLs: true, AllowPrivate: true}
+	cfg.HeaderPropagation.Headers = []string{"X-Request-ID"}
+	return newProxyServer(t, cfg)
+}
+
+func newProxyServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	handler, err := NewHandler(cfg)
+	if err != nil {
+		t.Fatalf("NewHandler failed: %v", err)
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func proxyURL(proxy, target, path string) string {
+	return proxy + path + "?q=1&client_id=" + url.QueryEscape(target)
+}
+
+func TestProxy_ForwardsMethodsBodiesAndHeaders(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Upstream", "1")
+		w.Header().Set("Connection", "X-Private")
+		w.Header().Set("X-Private", "secret")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %s?%s %s request-id=%s hop=%s",
+			r.Method, r.URL.Path, r.URL.RawQuery, body, r.Header.Get("X-Request-ID"), r.Header.Get("X-Hop"))
+	}))
+	defer upstream.Close()
+	proxy := newTestProxy(t, 0)
+
+	req, _ := http.NewRequest(http.MethodPut, proxyURL(proxy.URL, upstream.URL+"/base?token=t", "/files/a.go"), strings.NewReader("payload"))
+	req.Header.Set("X-Request-ID", "r1")
+	// Headers listed in Connection are hop-by-hop; propagated headers are forwarded anyway
+	req.Header.Set("Connection", "X-Hop, X-Request-ID")
+	req.Header.Set("X-Hop", "dropped")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	want := "PUT /base/files/a.go?q=1&token=t payload request-id=r1 hop="
+	if resp.StatusCode != http.StatusCreated || string(body) != want {
+		t.Errorf("Unexpected response %d %q, want %q", resp.StatusCode, body, want)
+	}
+	if resp.Header.Get("X-Upstream") != "1" || resp.Header.Get("X-Private") != "" {
+		t.Errorf("Unexpected response headers: %v", resp.Header)
+	}
+}
+
+func TestProxy_RejectsInvalidTargetsAndLargeBodies(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+	}))
+	defer upstream.Close()
+	proxy := newTestProxy(t, 4)
+
+	for _, target := range []string{"", "not a url", "/relative"} {
+		resp, err := http.Get(proxyURL(proxy.URL, target, "/"))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Target %q: expected 400, got %d", target, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Post(proxyURL(proxy.URL, upstream.URL, "/"), "text/plain", strings.NewReader("too large"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413, got %d", resp.StatusCode)
+	}
+}
+
+func TestProxy_StreamsResponses(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "data: second\n\n")
+	}))
+	defer upstream.Close()
+	defer close(release)
+	proxy := newTestProxy(t, 0)
+
+	resp, err := http.Get(proxyURL(proxy.URL, upstream.URL, "/events"))
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// The first event arrives while the upstream still holds the response open
+	line := make(chan string, 1)
+	go func() {
+		text, _ := bufio.NewReader(resp.Body).ReadString('\n')
+		line <- text
+	}()
+	select {
+	case text := <-line:
+		if text != "data: first\n" {
+			t.Errorf("Unexpected event %q", text)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the first event to be flushed before the response ends")
+	}
+}
+
+func TestProxy_UpgradesConnections(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+		buf.Flush()
+		// Echo one line over the upgraded connection
+		text, _ := buf.ReadString('\n')
+		fmt.Fprint(buf, "echo: "+text)
+		buf.Flush()
+	}))
+	defer upstream.Close()
+	proxy := newTestProxy(t, 0)
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(proxy.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /ws?client_id=%s HTTP/1.1\r\nHost: proxy\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n", url.QueryEscape(upstream.URL))
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("ReadResponse failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %d", resp.StatusCode)
+	}
+	fmt.Fprint(conn, "hello\n")
+	if text, _ := reader.ReadString('\n'); text != "echo: hello\n" {
+		t.Errorf("Unexpected reply %q", text)
+	}
+}
+
+func TestProxy_ForwardsThroughTunnels(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s?%s request-id=%s", r.URL.Path, r.URL.RawQuery, r.Header.Get("X-Request-ID"))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{}
+	cfg.Proxy.Tunnel = config.ProxyTunnel{Enabled: true, Token: "secret"}
+	cfg.HeaderPropagation.Headers = []string{"X-Request-ID"}
+	proxy := newProxyServer(t, cfg)
+
+	target, _ := url.Parse(upstream.URL + "/local")
+	agent := &tunnel.Agent{ServerURL: proxy.URL, ClientID: "ide-1", Token: "wrong", Handler: tunnel.NewForwarder(target)}
+	if err := agent.Serve(context.Background()); err == nil {
+		t.Fatal("Expected the tunnel to be refused without the token")
+	}
+	agent.Token = "secret"
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	connected := make(chan struct{})
+	agent.OnConnect = func() { close(connected) }
+	go func() { _ = agent.Serve(ctx) }()
+	<-connected
+
+	// The proxy registers the tunnel after the handshake the agent has already seen
+	var body []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		req, _ := http.NewRequest(http.MethodGet, proxy.URL+"/files?q=1&client_id=ide-1", nil)
+		req.Header.Set("X-Request-ID", "r1")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ = io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNotFound {
+			break
+		}
+	}
+	if want := "/local/files?q=1 request-id=r1"; string(body) != want {
+		t.Errorf("Unexpected response %q, want %q", body, want)
+	}
+
+	resp, err := http.Get(proxy.URL + "/_proxy/clients")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if list, _ := io.ReadAll(resp.Body); !strings.Contains(string(list), `{"id":"ide-1","tunnel":true}`) {
+		t.Errorf("Expected the tunnel in the client list, got %s", list)
+	}
+}
+
+func TestProxy_TunnelsDoNotShadowRegisteredClients(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Proxy.Tunnel = config.ProxyTunnel{Enabled: true}
+	if _, err := NewHandler(cfg); err == nil {
+		t.Fatal("Expected tunnels without a token refused")
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+	cfg.Proxy.Tunnel.Token = "secret"
+	cfg.Proxy.AdminToken = "admin"
+	cfg.Proxy.Clients = map[string]string{"ide-1": upstream.URL + "/registered"}
+	cfg.Proxy.Targets = config.ProxyTargets{AllowHosts: []string{"127.0.0.1"}}
+	proxy := newProxyServer(t, cfg)
+	get := func(clientID string) string {
+		resp, err := http.Get(proxy.URL + "/files?client_id=" + clientID)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	target, _ := url.Parse(upstream.URL + "/tunnel")
+	agent := &tunnel.Agent{ServerURL: proxy.URL, ClientID: "ide-1", Token: "secret", Handler: tunnel.NewForwarder(target)}
+	if err := agent.Serve(ctx); err == nil || errors.Is(err, tunnel.ErrClosed) {
+		t.Fatalf("Expected the tunnel of a registered client refused, got %v", err)
+	}
+	if body := get("ide-1"); body != "/registered/files" {
+		t.Errorf("Expected the registered client, got %q", body)
+	}
+
+	// Clients registered after the tunnel connected win as well
+	agent.ClientID = "ide-2"
+	go func() { _ = agent.Serve(ctx) }()
+	for deadline := time.Now().Add(5 * time.Second); get("ide-2") != "/tunnel/files"; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the tunnel")
+		}
+	}
+	req, _ := http.NewRequest(http.MethodPut, proxy.URL+"/_proxy/clients/ide-2", strings.NewReader(`{"base_url":"`+upstream.URL+`/registered"}`))
+	req.Header.Set("Authorization", "Bearer admin")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if body := get("ide-2"); body != "/registered/files" {
+		t.Errorf("Expected the registered client to win over the tunnel, got %q", body)
+	}
+}
@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/tunnel"
+)
+
+// clientIDPattern valid registered client IDs
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// ErrInvalidClient client ID or base URL that cannot be registered
+var ErrInvalidClient = errors.New("invalid client")
+
+// ErrClientRegistered client ID of the registry, a tunnel cannot be opened for it
+var ErrClientRegistered = errors.New("client registered")
+
+// Registry registered client IDs and their base URLs, safe for concurrent use
+type Registry struct {
+	mu      sync.RWMutex
+	policy  *TargetPolicy
+	clients map[string]*url.URL
+}
+
+// Client registered client
+type Client struct {
+	ID      string `json:"id"`
+	BaseURL string `json:"base_url,omitempty"`
+	Tunnel  bool   `json:"tunnel,omitempty"` // Whether the client has an open tunnel, which is used instead of the base URL
+}
+
+// NewRegistry create registry with the clients of the configuration
+func NewRegistry(clients map[string]string, policy *TargetPolicy) (*Registry, error) {
+	registry := &Registry{policy: policy, clients: make(map[string]*url.URL, len(clients))}
+	for id, baseURL := range clients {
+		if err := registry.Register(id, baseURL); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// Register register or replace the base URL of a client, the URL must pass the target policy
+func (r *Registry) Register(id, baseURL string) error {
+	if !clientIDPattern.MatchString(id) {
+		return fmt.Errorf("%w: client ID %q", ErrInvalidClient, id)
+	}
+	target, err := url.Parse(baseURL)
+	if err != nil || target.Host == "" {
+		return fmt.Errorf("%w: base URL %q of %s", ErrInvalidClient, baseURL, id)
+	}
+	if err := r.policy.CheckURL(target); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.clients[id] = target
+	return nil
+}
+
+// Unregister remove a client, false when it is not registered
+func (r *Registry) Unregister(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.clients[id]
+	delete(r.clients, id)
+	return ok
+}
+
+// Lookup base URL of a client
+func (r *Registry) Lookup(id string) (*url.URL, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	target, ok := r.clients[id]
+	return target, ok
+}
+
+// List registered clients in ID order
+func (r *Registry) List() []Client {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clients := make([]Client, 0, len(r.clients))
+	for id, target := range r.clients {
+		clients = append(clients, Client{ID: id, BaseURL: target.String()})
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ID < clients[j].ID })
+	return clients
+}
+
+// registerAdminRoutes register the client registration API, guarded by the admin token when one is set.
+// Without a token clients can only be listed, registering and unregistering answer 403.
+// The list includes the clients connected through tunnels.
+func (r *Registry) registerAdminRoutes(mux *http.ServeMux, token string, hub *tunnel.Hub) {
+	guard := func(handler http.HandlerFunc) http.HandlerFunc { return bearerGuard(token, handler) }
+	writeGuard := func(handler http.HandlerFunc) http.HandlerFunc {
+		if token == "" {
+			return func(w http.ResponseWriter, req *http.Request) {
+				http.Error(w, i18n.Translate("proxy.admin_token_required", "", nil), http.StatusForbidden)
+			}
+		}
+		return guard(handler)
+	}
+
+	mux.HandleFunc("GET "+adminPrefix+"clients", guard(func(w http.ResponseWriter, req *http.Request) {
+		clients := r.List()
+		for _, id := range hub.List() {
+			i := sort.Search(len(clients), func(i int) bool { return clients[i].ID >= id })
+			if i < len(clients) && clients[i].ID == id {
+				clients[i].Tunnel = true
+				continue
+			}
+			clients = append(clients[:i], append([]Client{{ID: id, Tunnel: true}}, clients[i:]...)...)
+		}
+		writeJSON(w, http.StatusOK, clients)
+	}))
+	mux.HandleFunc("PUT "+adminPrefix+"clients/{id}", writeGuard(func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			BaseURL string `json:"base_url"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, translateError("proxy.invalid_request_body", err), http.StatusBadRequest)
+			return
+		}
+		id := req.PathValue("id")
+		if err := r.Register(id, body.BaseURL); err != nil {
+			if errors.Is(err, ErrTargetDenied) {
+				http.Error(w, translateError("proxy.target_denied", err), http.StatusForbidden)
+				return
+			}
+			http.Error(w, translateError("proxy.invalid_client", err), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, Client{ID: id, BaseURL: body.BaseURL})
+	}))
+	mux.HandleFunc("DELETE "+adminPrefix+"clients/{id}", writeGuard(func(w http.ResponseWriter, req *http.Request) {
+		if !r.Unregister(req.PathValue("id")) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// bearerGuard handler answering 401 unless the request carries the bearer token, open when the token is empty
+func bearerGuard(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		given, _ := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if token != "" && subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+// writeJSON write a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// ErrTargetDenied target the policy does not allow the proxy to call
+var ErrTargetDenied = errors.New("proxy target denied")
+
+// ErrTooManyRedirects target redirected more often than allowed
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// TargetPolicy schemes, hosts and addresses the proxy may call
+type TargetPolicy struct {
+	schemes      []string
+	allowNames   []string
+	allowNets    []*net.IPNet
+	denyNames    []string
+	denyNets     []*net.IPNet
+	allowPrivate bool
+	dialer       *net.Dialer
+	resolver     *net.Resolver
+}
+
+// NewTargetPolicy parse the target policy configuration
+func NewTargetPolicy(cfg config.ProxyTargets, dialer *net.Dialer) (*TargetPolicy, error) {
+	policy := &TargetPolicy{schemes: cfg.Schemes, allowPrivate: cfg.AllowPrivate, dialer: dialer, resolver: net.DefaultResolver}
+	if len(policy.schemes) == 0 {
+		policy.schemes = []string{"http", "https"}
+	}
+	var err error
+	if policy.allowNames, policy.allowNets, err = parseHosts(cfg.AllowHosts); err != nil {
+		return nil, err
+	}
+	if policy.denyNames, policy.denyNets, err = parseHosts(cfg.DenyHosts); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// CheckURL check the scheme and the host name of a target; addresses are checked when they are dialed
+func (p *TargetPolicy) CheckURL(u *url.URL) error {
+	if !utils.SliceContains(p.schemes, strings.ToLower(u.Scheme)) {
+		return fmt.Errorf("%w: scheme %q", ErrTargetDenied, u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return fmt.Errorf("%w: no host", ErrTargetDenied)
+	}
+	if matchAnyName(p.denyNames, host) {
+		return fmt.Errorf("%w: host %s", ErrTargetDenied, host)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+	// With allowed ranges, other names may still resolve into them: decided by the address check
+	if len(p.allowNames) > 0 && len(p.allowNets) == 0 && !matchAnyName(p.allowNames, host) {
+		return fmt.Errorf("%w: host %s", ErrTargetDenied, host)
+	}
+	return nil
+}
+
+// DialContext resolve the host and dial the first allowed address, so that the checked address is the one
+// connected to even when the name resolves differently later
+func (p *TargetPolicy) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	host = strings.ToLower(host)
+	if matchAnyName(p.denyNames, host) {
+		return nil, fmt.Errorf("%w: host %s", ErrTargetDenied, host)
+	}
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	var denied error
+	for _, ipAddr := range addrs {
+		if denied = p.checkIP(host, ipAddr.IP); denied != nil {
+			continue
+		}
+		return p.dialer.DialContext(ctx, network, net.JoinHostPort(ipAddr.IP.String(), port))
+	}
+	if denied == nil {
+		denied = fmt.Errorf("%w: host %s has no address", ErrTargetDenied, host)
+	}
+	return nil, denied
+}
+
+// CheckAddresses resolve the host and check all its addresses, for connections made through an outgoing proxy
+func (p *TargetPolicy) CheckAddresses(ctx context.Context, host string) error {
+	host = strings.ToLower(host)
+	if ip := net.ParseIP(host); ip != nil {
+		return p.checkIP(host, ip)
+	}
+	addrs, err := p.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ipAddr := range addrs {
+		if err := p.checkIP(host, ipAddr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIP check an address of a host against the deny list, the non-public ranges and the allow list
+func (p *TargetPolicy) checkIP(host string, ip net.IP) error {
+	if matchAnyNet(p.denyNets, ip) {
+		return fmt.Errorf("%w: address %s of %s", ErrTargetDenied, ip, host)
+	}
+	// Allowed ranges are also how internal services are opened up without allowing every private address
+	if matchAnyNet(p.allowNets, ip) {
+		return nil
+	}
+	if !p.allowPrivate && !utils.IsPublicIP(ip) {
+		return fmt.Errorf("%w: non-public address %s of %s", ErrTargetDenied, ip, host)
+	}
+	if len(p.allowNames)+len(p.allowNets) > 0 && !matchAnyName(p.allowNames, host) {
+		return fmt.Errorf("%w: address %s of %s", ErrTargetDenied, ip, host)
+	}
+	return nil
+}
+
+// redirectTransport transport following the redirects of the target up to a limit, checking every location
+type redirectTransport struct {
+	base           http.RoundTripper
+	policy         *TargetPolicy
+	maxRedirects   int
+	checkAddresses bool // Resolve and check the addresses of every location, when connections go to an outgoing proxy
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for redirects := 0; ; redirects++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil || t.maxRedirects <= 0 {
+			return resp, err
+		}
+		location := resp.Header.Get("Location")
+		if location == "" || !isRedirect(resp.StatusCode) {
+			return resp, nil
+		}
+		next, err := req.URL.Parse(location)
+		if err != nil {
+			return resp, nil
+		}
+		nextReq, ok := redirectRequest(req, next, resp.StatusCode)
+		if !ok {
+			// The body cannot be sent again, the client gets the redirect
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		if redirects >= t.maxRedirects {
+			return nil, fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, t.maxRedirects)
+		}
+		if err := t.policy.CheckURL(next); err != nil {
+			return nil, err
+		}
+		if t.checkAddresses {
+			if err := t.policy.CheckAddresses(req.Context(), next.Hostname()); err != nil {
+				return nil, err
+			}
+		}
+		req = nextReq
+	}
+}
+
+// redirectRequest request following a redirect: 307 and 308 repeat the method and body, the others become a GET
+func redirectRequest(req *http.Request, location *url.URL, status int) (*http.Request, bool) {
+	next := req.Clone(req.Context())
+	next.URL = location
+	next.Host = ""
+	if location.Host != req.URL.Host {
+		// Credentials are meant for the original target only
+		next.Header.Del("Authorization")
+		next.Header.Del("Cookie")
+	}
+	if status == http.StatusTemporaryRedirect || status == http.StatusPermanentRedirect {
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, false
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, false
+			}
+			next.Body = body
+		}
+		return next, true
+	}
+	if req.Method != http.MethodHead {
+		next.Method = http.MethodGet
+	}
+	next.Body, next.GetBody, next.ContentLength = nil, nil, 0
+	next.Header.Del("Content-Type")
+	next.Header.Del("Content-Length")
+	return next, true
+}
+
+// isRedirect whether the status is a redirect with a location
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// parseHosts split host entries into host name globs and networks
+func parseHosts(hosts []string) (names []string, nets []*net.IPNet, err error) {
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if ip := net.ParseIP(host); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		if strings.Contains(host, "/") {
+			_, network, err := net.ParseCIDR(host)
+			if err != nil {
+				return nil, nil, err
+			}
+			nets = append(nets, network)
+			continue
+		}
+		names = append(names, host)
+	}
+	return names, nets, nil
+}
+
+// matchAnyName whether the host name matches any of the globs, "*" matches a single label
+func matchAnyName(globs []string, host string) bool {
+	for _, glob := range globs {
+		if utils.MatchGlob(strings.ReplaceAll(glob, ".", "/"), strings.ReplaceAll(host, ".", "/")) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyNet whether any of the networks contains the address
+func matchAnyNet(nets []*net.IPNet, ip net.IP) bool {
+	for _, network := range nets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+func TestTargetPolicy(t *testing.T) {
+	policy, err := NewTargetPolicy(config.ProxyTargets{
+		AllowHosts: []string{"*.example.com", "10.1.0.0/16"},
+		DenyHosts:  []string{"admin.example.com", "10.1.2.3"},
+	}, &net.Dialer{})
+	if err != nil {
+		t.Fatalf("NewTargetPolicy failed: %v", err)
+	}
+
+	urls := []struct {
+		target  string
+		allowed bool
+	}{
+		{"https://kb.example.com/api", true},
+		{"http://kb.example.com:8080", true},
+		{"ftp://kb.example.com", false},
+		{"https://admin.example.com", false},
+		{"https://other.org", true}, // Left to the address check of the allowed ranges
+		{"http://10.1.0.8", true},
+		{"http://10.1.2.3", false},
+		{"http://10.2.0.1", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::1]:8080", false},
+	}
+	for _, tt := range urls {
+		u, _ := url.Parse(tt.target)
+		err := policy.CheckURL(u)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("CheckURL(%s) = %v, want allowed %v", tt.target, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrTargetDenied) {
+			t.Errorf("CheckURL(%s): expected a denied error, got %v", tt.target, err)
+		}
+	}
+
+	addresses := []struct {
+		host    string
+		ip      string
+		allowed bool
+	}{
+		{"kb.example.com", "93.184.216.34", true},
+		{"kb.example.com", "127.0.0.1", false},
+		{"kb.example.com", "10.1.0.9", true},
+		{"a.b.example.com", "93.184.216.34", false}, // "*" matches a single label
+		{"other.org", "93.184.216.34", false},
+		{"other.org", "10.1.4.4", true},
+		{"other.org", "100.64.0.1", false},
+		{"kb.example.com", "::ffff:127.0.0.1", false},
+	}
+	for _, tt := range addresses {
+		if allowed := policy.checkIP(tt.host, net.ParseIP(tt.ip)) == nil; allowed != tt.allowed {
+			t.Errorf("checkIP(%s, %s) = %v, want %v", tt.host, tt.ip, allowed, tt.allowed)
+		}
+	}
+}
+
+func TestProxy_BlocksPrivateTargets(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	cfg := &config.Config{}
+	cfg.Proxy.Targets.AllowRawURLs = true
+	proxy := newProxyServer(t, cfg)
+
+	// localhost resolves to loopback, which the dialer refuses
+	target := strings.Replace(upstream.URL, "127.0.0.1", "localhost", 1)
+	for _, target := range []string{upstream.URL, target} {
+		resp, err := http.Get(proxyURL(proxy.URL, target, "/"))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Target %s: expected 403, got %d", target, resp.StatusCode)
+		}
+	}
+}
+
+func TestProxy_RegisteredClients(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{}
+	cfg.Proxy.AdminToken = "secret"
+	cfg.Proxy.Clients = map[string]string{"ide-1": upstream.URL + "/one"}
+	cfg.Proxy.Targets = config.ProxyTargets{AllowHosts: []string{"127.0.0.1"}}
+	proxy := newProxyServer(t, cfg)
+
+	get := func(clientID string) (int, string) {
+		resp, err := http.Get(proxy.URL + "/files?client_id=" + url.QueryEscape(clientID))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+	register := func(token, id, baseURL string) int {
+		req, _ := http.NewRequest(http.MethodPut, proxy.URL+"/_proxy/clients/"+id, strings.NewReader(`{"base_url":"`+baseURL+`"}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status, body := get("ide-1"); status != http.StatusOK || body != "/one/files" {
+		t.Errorf("Expected the configured client, got %d %s", status, body)
+	}
+	if status, _ := get(upstream.URL); status != http.StatusNotFound {
+		t.Errorf("Expected raw URLs to be unknown clients, got %d", status)
+	}
+	if status := register("wrong", "ide-2", upstream.URL+"/two"); status != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without the admin token, got %d", status)
+	}
+	if status := register("secret", "ide-2", "http://169.254.169.254"); status != http.StatusForbidden {
+		t.Errorf("Expected 403 for a denied base URL, got %d", status)
+	}
+	if status := register("secret", "ide-2", upstream.URL+"/two"); status != http.StatusOK {
+		t.Fatalf("Register failed: %d", status)
+	}
+	if status, body := get("ide-2"); status != http.StatusOK || body != "/two/files" {
+		t.Errorf("Expected the registered client, got %d %s", status, body)
+	}
+
+	// Without an admin token clients cannot be registered
+	cfg.Proxy.AdminToken = ""
+	proxy = newProxyServer(t, cfg)
+	if status := register("", "ide-3", upstream.URL+"/three"); status != http.StatusForbidden {
+		t.Errorf("Expected 403 without an admin token configured, got %d", status)
+	}
+}
+
+func TestProxy_Redirects(t *testing.T) {
+	final := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Method+" final auth="+r.Header.Get("Authorization"))
+	}))
+	defer final.Close()
+	// Redirects to the same server keep credentials, the hop to the other port drops them
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/start":
+			http.Redirect(w, r, upstream.URL+"/next", http.StatusFound)
+		case "/next":
+			http.Redirect(w, r, final.URL, http.StatusSeeOther)
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest", http.StatusFound)
+		}
+	}))
+	defer upstream.Close()
+
+	tests := []struct {
+		maxRedirects int
+		path         string
+		status       int
+		body         string
+	}{
+		{0, "/start", http.StatusFound, ""},
+		{1, "/start", http.StatusBadGateway, ""},
+		{2, "/start", http.StatusOK, "GET final auth="},
+		{2, "/metadata", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{}
+		cfg.Proxy.Targets = config.ProxyTargets{AllowRawURLs: true, AllowHosts: []string{"127.0.0.1"}, MaxRedirects: tt.maxRedirects}
+		proxy := newProxyServer(t, cfg)
+
+		req, _ := http.NewRequest(http.MethodPost, proxyURL(proxy.URL, upstream.URL, tt.path), nil)
+		req.Header.Set("Authorization", "Bearer t")
+		resp, err := (&http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}).Do(req)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != tt.status || tt.body != "" && string(body) != tt.body {
+			t.Errorf("max %d %s: got %d %q, want %d %q", tt.maxRedirects, tt.path, resp.StatusCode, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestProxy_RedirectsThroughOutgoingProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/public":
+			http.Redirect(w, r, "http://93.184.216.34/final", http.StatusFound)
+		case "/loopback":
+			http.Redirect(w, r, "http://localhost/final", http.StatusFound)
+		case "/final":
+			_, _ = io.WriteString(w, "final")
+		}
+	}))
+	defer upstream.Close()
+	// The outgoing proxy sends every request to the upstream, whatever the host
+	outgoing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest(r.Method, upstream.URL+r.URL.Path, nil)
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			w.Header().Set("Location", location)
+		}
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	defer outgoing.Close()
+
+	cfg := &config.Config{}
+	cfg.HTTPClient.ProxyURL = outgoing.URL
+	cfg.Proxy.Targets = config.ProxyTargets{AllowRawURLs: true, MaxRedirects: 2}
+	proxy := newProxyServer(t, cfg)
+
+	// Names of redirect locations are resolved and checked, connections to the outgoing proxy cannot check them
+	for path, status := range map[string]int{"/public": http.StatusOK, "/loopback": http.StatusForbidden} {
+		resp, err := http.Get(proxyURL(proxy.URL, "http://93.184.216.34", path))
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("%s: expected %d, got %d %s", path, status, resp.StatusCode, body)
+		}
+	}
+}
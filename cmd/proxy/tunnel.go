@@ -0,0 +1,51 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/tunnel"
+)
+
+// tunnelKey context key of the client ID of a request sent through its tunnel
+type tunnelKey struct{}
+
+// registerTunnelRoutes register the endpoint agents open their tunnels on, guarded by the tunnel token.
+// Clients of the registry cannot be taken over by a tunnel.
+func registerTunnelRoutes(mux *http.ServeMux, hub *tunnel.Hub, registry *Registry, token string) {
+	mux.HandleFunc("GET "+tunnel.PathPrefix+"{id}", bearerGuard(token, func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if !clientIDPattern.MatchString(id) {
+			http.Error(w, translateError("proxy.invalid_client", fmt.Errorf("%w: client ID %q", ErrInvalidClient, id)), http.StatusBadRequest)
+			return
+		}
+		if _, registered := registry.Lookup(id); registered {
+			http.Error(w, i18n.Translate("proxy.client_registered", "", map[string]interface{}{"client_id": id}), http.StatusConflict)
+			return
+		}
+		if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
+			return
+		}
+
+		log.Printf("%s", i18n.Translate("proxy.tunnel_connected", "", map[string]interface{}{"client_id": id, "addr": r.RemoteAddr}))
+		hub.Upgrade(w, r, id)
+		log.Printf("%s", i18n.Translate("proxy.tunnel_closed", "", map[string]interface{}{"client_id": id}))
+	}))
+}
+
+// tunnelTransport transport sending the requests of clients with a tunnel through it
+type tunnelTransport struct {
+	base http.RoundTripper
+	hub  *tunnel.Hub
+}
+
+func (t *tunnelTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if id, ok := req.Context().Value(tunnelKey{}).(string); ok {
+		return t.hub.RoundTrip(id, req)
+	}
+	return t.base.RoundTrip(req)
+}
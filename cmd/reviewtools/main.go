@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+)
+
+var (
+	rootCmd = &cobra.Command{
+		Use:   "reviewtools",
+		Short: "Review tools for exporting and inspecting review results",
+		Long:  "Review tools for exporting and inspecting review results",
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(reportCmd)
+}
+
+func main() {
+	// Load configuration
+	if err := config.LoadConfigWithDefault(); err != nil {
+		log.Fatalf("config.load.failed: %v", err)
+	}
+	// Initialize configuration
+	cfg := config.GetConfig()
+
+	if err := i18n.InitI18n(*cfg); err != nil {
+		fmt.Printf("i18n.init.failed: %v\n", err)
+	}
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"github.com/zgsm/mock-kbcenter/pkg/report"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+var (
+	reportTaskID string
+	reportInput  string
+	reportFormat string
+	reportOutput string
+	reportLocale string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Export review results as a report",
+	Long:  "Export the issues of a review task, or of a JSON issue file, as SARIF, JUnit XML, Markdown or HTML",
+	Run: func(cmd *cobra.Command, args []string) {
+		if !report.IsSupported(reportFormat) {
+			log.Fatalln(i18n.Translate("report.unsupported_format", "", map[string]interface{}{"format": reportFormat}))
+		}
+
+		var data []byte
+		var err error
+		switch {
+		case reportTaskID != "":
+			// Review tasks are only shared with the web and worker processes through the database
+			if err := db.InitDB(config.GetConfig().Database); err != nil {
+				log.Fatalf("%s: %v", i18n.Translate("db.init.failed", "", nil), err)
+			}
+			defer db.CloseDB()
+			data, err = service.NewReviewTaskService("").ExportReport(context.Background(), reportTaskID, reportFormat, reportLocale)
+		case reportInput != "":
+			var issues []types.Issue
+			issues, err = readIssueFile(reportInput)
+			if err == nil {
+				data, err = report.Render(reportFormat, report.Meta{
+					ToolName:    "mock-kbcenter",
+					GeneratedAt: utils.FormatTime(time.Now(), ""),
+					Locale:      reportLocale,
+				}, issues)
+			}
+		default:
+			log.Fatalln(i18n.Translate("reviewtools.report.missing_source", "", nil))
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.Translate("reviewtools.report.failed", "", nil), err)
+		}
+
+		if reportOutput == "" {
+			_, err = os.Stdout.Write(data)
+		} else {
+			err = os.WriteFile(reportOutput, data, 0644)
+		}
+		if err != nil {
+			log.Fatalf("%s: %v", i18n.Translate("reviewtools.report.write_failed", "", nil), err)
+		}
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportTaskID, "task", "", "Review task ID, read from the database")
+	reportCmd.Flags().StringVar(&reportInput, "input", "", "JSON file holding an issue list or an incremental review result")
+	reportCmd.Flags().StringVar(&reportFormat, "format", report.FormatMarkdown, "Report format: sarif, junit, markdown or html")
+	reportCmd.Flags().StringVar(&reportOutput, "output", "", "Output file, stdout when empty")
+	reportCmd.Flags().StringVar(&reportLocale, "locale", "", "Locale of Markdown and HTML reports")
+}
+
+// readIssueFile read issues from a JSON issue list or an incremental review result
+func readIssueFile(path string) ([]types.Issue, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []types.Issue
+	if err := json.Unmarshal(content, &issues); err == nil {
+		return issues, nil
+	}
+
+	var result types.IssueIncrementReviewTaskResult
+	if err := json.Unmarshal(content, &result); err != nil {
+		return nil, err
+	}
+	return result.Issues, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/tunnel"
+)
+
+// Tunnel agent for clients the proxy cannot reach directly: it connects out to the proxy, registers the
+// client ID and forwards the requests sent through the tunnel to the local target
+func main() {
+	server := flag.String("server", "http://localhost:8081", "base URL of the proxy")
+	clientID := flag.String("id", "", "client ID to register")
+	token := flag.String("token", os.Getenv("TUNNEL_TOKEN"), "tunnel token of the proxy, defaults to $TUNNEL_TOKEN")
+	target := flag.String("target", "http://localhost:8080", "base URL requests are forwarded to")
+	ping := flag.Duration("ping", 30*time.Second, "idle time before a health check ping, 0 disables it")
+	flag.Parse()
+
+	// Initialize configuration
+	if err := config.LoadConfigWithDefault(); err != nil {
+		log.Fatalf("config.load.failed: %v", err)
+	}
+
+	// Initialize i18n
+	if err := i18n.InitI18n(*config.GetConfig()); err != nil {
+		fmt.Printf("i18n.init.failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *clientID == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	targetURL, err := url.Parse(*target)
+	if err == nil && targetURL.Host == "" {
+		err = fmt.Errorf("%q is not an absolute URL", *target)
+	}
+	if err != nil {
+		log.Fatalln(i18n.Translate("tunnel.agent.invalid_target", "", map[string]interface{}{"error": err.Error()}))
+	}
+
+	agent := &tunnel.Agent{
+		ServerURL:    *server,
+		ClientID:     *clientID,
+		Token:        *token,
+		Handler:      tunnel.NewForwarder(targetURL),
+		PingInterval: *ping,
+		OnConnect: func() {
+			log.Println(i18n.Translate("tunnel.agent.connected", "", nil))
+		},
+		OnDisconnect: func(err error) {
+			log.Println(i18n.Translate("tunnel.agent.disconnected", "", map[string]interface{}{"error": err.Error()}))
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Println(i18n.Translate("tunnel.agent.starting", "", map[string]interface{}{"client_id": *clientID, "server": *server, "target": *target}))
+	if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		log.Fatalln(err)
+	}
+}
@@ -15,11 +15,14 @@ import (
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/internal/middleware"
+	"github.com/zgsm/mock-kbcenter/internal/service"
 	"github.com/zgsm/mock-kbcenter/pkg/asynq"
 	"github.com/zgsm/mock-kbcenter/pkg/db"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
 	"github.com/zgsm/mock-kbcenter/pkg/redis"
 	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
 
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -34,6 +37,19 @@ func Run(cfg *config.Config, workDir string) {
 	}
 	defer logger.Sync()
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(cfg.Tracing, "")
+	if err != nil {
+		logger.Fatal(i18n.Translate("tracing.init.failed", "", nil), "error", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error(i18n.Translate("tracing.shutdown.failed", "", nil), "error", err)
+		}
+	}()
+
 	// Set Gin mode
 	if cfg.Server.Mode == "release" {
 		gin.SetMode(gin.ReleaseMode)
@@ -93,10 +109,25 @@ func Run(cfg *config.Config, workDir string) {
 
 	// Register middlewares
 	r.Use(middleware.Logger())
+	r.Use(middleware.Metrics())
+	r.Use(middleware.Tracing())
 	r.Use(middleware.HeaderPropagator())
 	r.Use(middleware.Recovery())
 	r.Use(middleware.Cors())
 	r.Use(middleware.I18n())
+	r.Use(middleware.FaultInjector())
+	recorder, err := middleware.Recorder()
+	if err != nil {
+		logger.Error(i18n.Translate("recording.init_failed", "", nil), "error", err)
+		panic(err)
+	}
+	r.Use(recorder)
+	contract, err := middleware.OpenAPIValidator()
+	if err != nil {
+		logger.Error(i18n.Translate("openapi.init_failed", "", nil), "error", err)
+		panic(err)
+	}
+	r.Use(contract)
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -105,6 +136,11 @@ func Run(cfg *config.Config, workDir string) {
 		})
 	})
 
+	// Prometheus metrics
+	if cfg.Metrics.Enabled {
+		r.GET(metrics.Path(cfg.Metrics), gin.WrapH(metrics.Handler()))
+	}
+
 	// Swagger documentation
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -139,6 +175,10 @@ func Run(cfg *config.Config, workDir string) {
 	if err := srv.Shutdown(ctx); err != nil {
 		logger.Fatal(i18n.Translate("server.shutdown.forced", "", nil), "error", err)
 	}
+	// Webhook deliveries queued in the process are lost on exit and stay pending
+	if err := service.WaitDeliveries(ctx); err != nil {
+		logger.Warn(i18n.Translate("webhook.shutdown_pending", "", nil), "error", err)
+	}
 
 	logger.Info(i18n.Translate("server.shutdown.success", "", nil))
 }
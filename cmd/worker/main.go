@@ -1,18 +1,24 @@
 package worker
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/pkg/asynq"
 	"github.com/zgsm/mock-kbcenter/pkg/db"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
 	"github.com/zgsm/mock-kbcenter/pkg/redis"
 	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
 	"github.com/zgsm/mock-kbcenter/tasks"
 )
 
@@ -40,6 +46,13 @@ func Run(cfg *config.Config) {
 	}
 	defer logger.Sync()
 
+	// Initialize tracing
+	shutdownTracing, err := tracing.Init(cfg.Tracing, "worker")
+	if err != nil {
+		logger.Error(i18n.Translate("tracing.init.failed", "", nil), "error", err)
+		panic(err)
+	}
+
 	// Initialize database
 	if err := db.InitDB(cfg.Database); err != nil {
 		logger.Error(i18n.Translate("db.init.failed", "", nil), "error", err)
@@ -58,6 +71,13 @@ func Run(cfg *config.Config) {
 		panic(err)
 	}
 
+	// Initialize Asynq client, handlers enqueue the subtasks of review tasks
+	if err := asynq.InitClient(*cfg); err != nil {
+		logger.Error(i18n.Translate("asynq.client.init.failed", "", nil), "error", err)
+		panic(err)
+	}
+	defer asynq.Close()
+
 	// Initialize Asynq server
 	if err := asynq.InitServer(*cfg); err != nil {
 		logger.Error(i18n.Translate("asynq.server.init.failed", "", nil), "error", err)
@@ -66,7 +86,16 @@ func Run(cfg *config.Config) {
 
 	// Register task handlers
 	mux := asynq.NewServeMux()
+	mux.Use(metrics.TaskMiddleware)
+	mux.Use(tracing.TaskMiddleware)
 	mux.HandleFunc(tasks.TypeRunReviewTask, tasks.HandleRunReviewTask)
+	mux.HandleFunc(tasks.TypeRunReviewChunk, tasks.HandleRunReviewChunk)
+	mux.HandleFunc(tasks.TypeDeliverWebhook, tasks.HandleDeliverWebhook)
+
+	// Serve the metrics, the worker has no server of its own
+	if cfg.Metrics.Enabled {
+		go serveMetrics(cfg.Metrics)
+	}
 
 	// Start worker
 	logger.Info(i18n.Translate("worker.process.start", "", nil), "pid", os.Getpid())
@@ -96,4 +125,24 @@ func Run(cfg *config.Config) {
 		logger.Error(i18n.Translate("db.connection.close.failed", "", nil), "error", err)
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Error(i18n.Translate("tracing.shutdown.failed", "", nil), "error", err)
+	}
+}
+
+// serveMetrics serve the metrics of the worker on the configured port
+func serveMetrics(cfg config.Metrics) {
+	mux := http.NewServeMux()
+	mux.Handle(metrics.Path(cfg), metrics.Handler())
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.WorkerPort),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Info(i18n.Translate("metrics.worker.listening", "", nil), "port", cfg.WorkerPort)
+	if err := srv.ListenAndServe(); err != nil {
+		logger.Error(i18n.Translate("metrics.worker.failed", "", nil), "error", err)
+	}
 }
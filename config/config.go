@@ -35,6 +35,167 @@ type Database struct {
 	Enabled  bool   `yaml:"enabled"` // Whether to enable database
 }
 
+// Review review pipeline configuration
+type Review struct {
+	Analyzers          []string `yaml:"analyzers"`             // Enabled analyzers, empty means all registered analyzers
+	MaxFileSize        int64    `yaml:"max_file_size"`         // Files larger than this are skipped, in bytes
+	PushToIssueManager bool     `yaml:"push_to_issue_manager"` // Whether to push issues of finished tasks to the issue manager
+	BaselineFile       string   `yaml:"baseline_file"`         // Baseline file relative to the codebase root, used when a task is created without a baseline
+	ChunkSize          int      `yaml:"chunk_size"`            // Files per review subtask, tasks are split into subtasks run across the worker pool
+	TaskTimeout        int      `yaml:"task_timeout"`          // Seconds a task may run before it stops with a partial result, 0 means no limit
+	FileTimeout        int      `yaml:"file_timeout"`          // Seconds the review of a single file may take before the file is skipped, 0 means no limit
+	IdempotencyTTL     int      `yaml:"idempotency_ttl"`       // Seconds a task creation is remembered, submissions with the same idempotency key return the task, 0 disables
+	// Policies applied in order to the issues found, before they are stored
+	Policies []IssuePolicy `yaml:"policies"`
+}
+
+// IssuePolicy severity and confidence policy of the issues of matching codebases, refs and files.
+// Globs use path.Match syntax, "**" also matches any number of directories.
+type IssuePolicy struct {
+	Name             string            `yaml:"name"`
+	Codebases        []string          `yaml:"codebases"`           // Codebase path globs, empty matches every codebase
+	Refs             []string          `yaml:"refs"`                // Head ref globs of diff targets, empty matches every task
+	Paths            []string          `yaml:"paths"`               // File path globs, empty matches every file
+	SeverityMap      map[string]string `yaml:"severity_map"`        // Severity remapping, e.g. middle: low
+	MinSeverity      string            `yaml:"min_severity"`        // Issues below this severity after remapping are dropped
+	MinConfidence    int               `yaml:"min_confidence"`      // Issues below this confidence are dropped
+	EnabledRules     []string          `yaml:"enabled_rules"`       // Rule ID globs kept, empty keeps every rule
+	DisabledRules    []string          `yaml:"disabled_rules"`      // Rule ID globs dropped
+	MaxIssuesPerFile int               `yaml:"max_issues_per_file"` // Issues kept per file, most severe and confident first, 0 means no limit
+}
+
+// Webhook webhook notification configuration
+type Webhook struct {
+	Timeout            int   `yaml:"timeout"`             // Seconds a webhook request may take
+	MaxRetries         int   `yaml:"max_retries"`         // Retries of a failed delivery
+	RetryDelay         int   `yaml:"retry_delay"`         // Seconds between retries
+	ProgressMilestones []int `yaml:"progress_milestones"` // Progress percentages notified with the progress event
+	Workers            int   `yaml:"workers"`             // Deliveries sent at once by a process without the worker queue
+	AllowPrivate       bool  `yaml:"allow_private"`       // Allow endpoints on loopback, private and link-local addresses
+}
+
+// Fault fault injection configuration of the mock endpoints, changed at runtime through the admin API
+type Fault struct {
+	Enabled bool        `yaml:"enabled" json:"enabled"`
+	Rules   []FaultRule `yaml:"rules" json:"rules"` // Checked in order, the first matching rule applies
+}
+
+// FaultRule fault injected into a share of the matching requests.
+// Globs use path.Match syntax, "**" also matches any number of path segments.
+type FaultRule struct {
+	Name      string            `yaml:"name" json:"name"`
+	Methods   []string          `yaml:"methods" json:"methods,omitempty"`       // HTTP methods, empty matches every method
+	Routes    []string          `yaml:"routes" json:"routes,omitempty"`         // Request path globs, empty matches every path
+	ClientIDs []string          `yaml:"client_ids" json:"client_ids,omitempty"` // clientId or client_id query values, empty matches every client
+	Headers   map[string]string `yaml:"headers" json:"headers,omitempty"`       // Header value globs that must all match
+	Rate      float64           `yaml:"rate" json:"rate"`                       // Share of the matching requests affected, 0 to 1, 0 means every request
+
+	Latency       *FaultLatency `yaml:"latency" json:"latency,omitempty"`               // Delay before the request is handled
+	Status        int           `yaml:"status" json:"status,omitempty"`                 // Error status returned instead of handling the request
+	Body          string        `yaml:"body" json:"body,omitempty"`                     // Body of the error status, an API error response when empty
+	TruncateRatio float64       `yaml:"truncate_ratio" json:"truncate_ratio,omitempty"` // Share of the response body sent before the connection is closed
+	Reset         bool          `yaml:"reset" json:"reset,omitempty"`                   // Reset the connection without a response
+}
+
+// FaultLatency latency distribution in milliseconds
+type FaultLatency struct {
+	Distribution string `yaml:"distribution" json:"distribution"` // fixed (mean) | uniform (min to max) | normal (mean, stddev) | exponential (mean)
+	Min          int    `yaml:"min" json:"min,omitempty"`
+	Max          int    `yaml:"max" json:"max,omitempty"` // Upper bound of every distribution when positive
+	Mean         int    `yaml:"mean" json:"mean,omitempty"`
+	StdDev       int    `yaml:"stddev" json:"stddev,omitempty"`
+}
+
+// Recording record and replay configuration of the KB center endpoints
+type Recording struct {
+	Mode       string         `yaml:"mode"`        // Empty serves the mock, record forwards to the kbCenter service of http_client and saves fixtures, replay serves the fixtures
+	FixtureDir string         `yaml:"fixture_dir"` // Directory of the fixture files
+	Routes     []string       `yaml:"routes"`      // Request path globs recorded or replayed, empty means /api/v1/**
+	Match      RecordingMatch `yaml:"match"`       // Rules matching a request to a fixture in replay mode
+	Fallback   bool           `yaml:"fallback"`    // Serve the mock when no fixture matches in replay mode instead of 404
+}
+
+// RecordingMatch rules matching a request to a fixture, the method and path always match
+type RecordingMatch struct {
+	Query       bool     `yaml:"query"`        // Match the query parameters
+	IgnoreQuery []string `yaml:"ignore_query"` // Query parameters left out of the match, e.g. request IDs or timestamps
+	BodyHash    bool     `yaml:"body_hash"`    // Match the SHA-256 of the request body
+}
+
+// MockRoute extra mock route registered next to the KB center mock routes.
+// Bodies are Go templates, see docs/mock_routes.md for the template data.
+type MockRoute struct {
+	Name     string         `yaml:"name"`
+	Method   string         `yaml:"method"`   // HTTP method, GET when empty
+	Path     string         `yaml:"path"`     // Gin path pattern under /api/v1, e.g. /codebases/:id/stats
+	Response MockResponse   `yaml:"response"` // Response of every call, unless a sequence is set
+	Sequence []MockResponse `yaml:"sequence"` // Responses returned in turn, the last one repeats unless loop is set
+	Loop     bool           `yaml:"loop"`     // Start the sequence over after the last response
+}
+
+// MockResponse templated response of a mock route
+type MockResponse struct {
+	Status  int               `yaml:"status"`  // 200 when empty
+	Headers map[string]string `yaml:"headers"` // Header values, also templates
+	Body    string            `yaml:"body"`    // Body template
+	File    string            `yaml:"file"`    // Fixture file used as body template instead of body
+}
+
+// OpenAPI OpenAPI 3 document the mock endpoints are served from and validated against
+type OpenAPI struct {
+	Spec              string `yaml:"spec"`               // OpenAPI 3 document, YAML or JSON, empty disables
+	BasePath          string `yaml:"base_path"`          // Path prefix of the operations, the path of the first server when empty
+	Serve             bool   `yaml:"serve"`              // Serve declared operations without a handler from their examples or schemas
+	ValidateRequests  bool   `yaml:"validate_requests"`  // Validate the parameters and bodies of requests to declared operations
+	ValidateResponses bool   `yaml:"validate_responses"` // Validate the responses of the handlers of declared operations
+	Reject            bool   `yaml:"reject"`             // Reject violations with 400 for requests and 500 for responses instead of only logging them
+}
+
+// Metrics Prometheus metrics of the processes
+type Metrics struct {
+	Enabled    bool   `yaml:"enabled"`     // Serve the metrics
+	Path       string `yaml:"path"`        // Path of the metrics on the web server and the worker, /metrics when empty
+	WorkerPort int    `yaml:"worker_port"` // Port the worker serves its metrics on, as it has no server of its own
+}
+
+// Tracing OpenTelemetry tracing of the processes
+type Tracing struct {
+	Enabled     bool    `yaml:"enabled"`      // Record and export spans
+	ServiceName string  `yaml:"service_name"` // Service name of the spans, mock-kbcenter when empty; the worker appends -worker
+	Exporter    string  `yaml:"exporter"`     // otlp, stdout or file
+	Endpoint    string  `yaml:"endpoint"`     // OTLP/HTTP collector, host:port or URL; the OTEL_EXPORTER_OTLP_* variables when empty
+	Insecure    bool    `yaml:"insecure"`     // Export to the collector over plain HTTP
+	File        string  `yaml:"file"`         // File the file exporter appends spans to, one JSON span per line
+	SampleRatio float64 `yaml:"sample_ratio"` // Ratio of the new traces sampled, all of them when 0 or 1
+}
+
+// ProxyTargets policy of the targets the proxy may call. Host entries are globs of host names, or IP
+// addresses and CIDRs checked against the addresses the host names resolve to.
+type ProxyTargets struct {
+	AllowRawURLs bool     `yaml:"allow_raw_urls"` // Accept client_id values that are URLs instead of registered client IDs
+	Schemes      []string `yaml:"schemes"`        // Allowed schemes, http and https when empty
+	AllowHosts   []string `yaml:"allow_hosts"`    // Hosts allowed, empty allows every host not denied
+	DenyHosts    []string `yaml:"deny_hosts"`     // Hosts denied, checked before the allowed ones
+	AllowPrivate bool     `yaml:"allow_private"`  // Allow loopback, private, link-local and other non-public addresses not in allow_hosts
+	MaxRedirects int      `yaml:"max_redirects"`  // Redirects of the target followed by the proxy, 0 passes them to the client
+}
+
+// ProxyTunnel tunnels opened by agents that the proxy cannot reach directly
+type ProxyTunnel struct {
+	Enabled      bool   `yaml:"enabled"`       // Accept tunnels of agents
+	Token        string `yaml:"token"`         // Bearer token agents must present, required when enabled
+	PingInterval int    `yaml:"ping_interval"` // Seconds a tunnel may stay idle before a health check ping, 0 disables the checks
+}
+
+// LLM LLM reviewer analyzer configuration
+type LLM struct {
+	Model          string  `yaml:"model"`           // Model name sent with chat completions
+	Temperature    float64 `yaml:"temperature"`     // Sampling temperature
+	MaxTokens      int     `yaml:"max_tokens"`      // Maximum tokens of a reply, 0 leaves it to the endpoint
+	MaxCodeLines   int     `yaml:"max_code_lines"`  // Code longer than this is not sent to the model, 0 means no limit
+	PromptTemplate string  `yaml:"prompt_template"` // Name of the stored prompt template, the built-in prompt while none is stored
+}
+
 // Config application configuration structure
 type Config struct {
 	Server struct {
@@ -43,7 +204,15 @@ type Config struct {
 	} `yaml:"server"`
 
 	Proxy struct {
-		Port int `yaml:"port"` // Proxy service port
+		Port                  int   `yaml:"port"`                    // Proxy service port
+		FlushInterval         int   `yaml:"flush_interval"`          // Milliseconds between flushes of response bodies, -1 flushes every write, streamed responses are always flushed at once
+		ResponseHeaderTimeout int   `yaml:"response_header_timeout"` // Seconds to wait for the response headers of the target, 0 means no limit
+		MaxRequestBody        int64 `yaml:"max_request_body"`        // Largest request body forwarded in bytes, 0 means no limit
+
+		AdminToken string            `yaml:"admin_token"` // Bearer token of the client registration API, empty only allows listing the clients
+		Clients    map[string]string `yaml:"clients"`     // Registered client IDs and their base URLs
+		Targets    ProxyTargets      `yaml:"targets"`     // Policy of the targets the proxy may call
+		Tunnel     ProxyTunnel       `yaml:"tunnel"`      // Tunnels of agents behind NAT, used before the registered clients
 	} `yaml:"proxy"`
 
 	Database Database `yaml:"database"`
@@ -86,6 +255,33 @@ type Config struct {
 	// Language query mode configuration
 	LanguageQueries map[string]string `yaml:"language_queries"`
 
+	// Review task configuration
+	Review Review `yaml:"review"`
+
+	// Webhook notification configuration
+	Webhook Webhook `yaml:"webhook"`
+
+	// LLM reviewer configuration, the endpoint is the llm service of http_client
+	LLM LLM `yaml:"llm"`
+
+	// Fault injection configuration of the mock endpoints
+	Fault Fault `yaml:"fault"`
+
+	// Record and replay configuration of the KB center endpoints
+	Recording Recording `yaml:"recording"`
+
+	// Extra mock routes
+	MockRoutes []MockRoute `yaml:"mock_routes"`
+
+	// OpenAPI document served and validated
+	OpenAPI OpenAPI `yaml:"openapi"`
+
+	// Prometheus metrics
+	Metrics Metrics `yaml:"metrics"`
+
+	// OpenTelemetry tracing
+	Tracing Tracing `yaml:"tracing"`
+
 	// HTTPClient HTTP client configuration
 	HTTPClient struct {
 		// Default timeout in seconds
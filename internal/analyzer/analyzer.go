@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// File source file handed to analyzers
+type File struct {
+	Path      string   // Path relative to the codebase root, slash separated
+	Language  string   // Language detected from the file extension
+	Content   string   // Full file content
+	Lines     []string // Content split into lines, Lines[0] is line 1
+	Functions []language.FunctionInfo
+
+	Comments     []language.CommentInfo
+	Suppressions []Suppression // Inline suppression comments found in Comments
+}
+
+// NewFile create analyzer file from content
+func NewFile(path, lang, content string, functions []language.FunctionInfo) *File {
+	return &File{
+		Path:      path,
+		Language:  lang,
+		Content:   content,
+		Lines:     splitLines(content),
+		Functions: functions,
+	}
+}
+
+// SetComments set the comments of the file and parse its suppression comments
+func (f *File) SetComments(comments []language.CommentInfo) {
+	f.Comments = comments
+	f.Suppressions = ParseSuppressions(comments)
+}
+
+// Snippet code of lines [startLine, endLine], clamped to the file
+func (f *File) Snippet(startLine, endLine int) string {
+	startLine = max(startLine, 1)
+	endLine = min(endLine, len(f.Lines))
+	if startLine > endLine {
+		return ""
+	}
+	snippet := f.Lines[startLine-1]
+	for _, line := range f.Lines[startLine:endLine] {
+		snippet += "\n" + line
+	}
+	return snippet
+}
+
+// Analyzer produces review issues for a single file
+type Analyzer interface {
+	// Name unique analyzer name used in configuration
+	Name() string
+	// Analyze analyze the file and return the issues found, IDs and timestamps are filled by the caller
+	Analyze(ctx context.Context, file *File) ([]types.Issue, error)
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Analyzer)
+	names      []string
+)
+
+// Register register an analyzer, a later analyzer with the same name replaces the earlier one
+func Register(a Analyzer) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, exists := registry[a.Name()]; !exists {
+		names = append(names, a.Name())
+	}
+	registry[a.Name()] = a
+}
+
+// Enabled analyzers matching the given names in registration order, all analyzers when names is empty
+func Enabled(enabled []string) []Analyzer {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	wanted := make(map[string]bool, len(enabled))
+	for _, name := range enabled {
+		wanted[name] = true
+	}
+
+	var analyzers []Analyzer
+	for _, name := range names {
+		if len(enabled) == 0 || wanted[name] {
+			analyzers = append(analyzers, registry[name])
+		}
+	}
+	return analyzers
+}
+
+// splitLines split content into lines, tolerating CRLF line endings
+func splitLines(content string) []string {
+	var lines []string
+	start := 0
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			end := i
+			if end > start && content[end-1] == '\r' {
+				end--
+			}
+			lines = append(lines, content[start:end])
+			start = i + 1
+		}
+	}
+	if start < len(content) {
+		lines = append(lines, content[start:])
+	}
+	return lines
+}
@@ -0,0 +1,70 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// Fingerprint fill the fingerprints of issues found in the file.
+// A fingerprint hashes the rule ID, file path, enclosing function name and whitespace-normalized code of the issue,
+// so it survives line shifts. Issues with identical inputs are told apart by their occurrence order in the file.
+func Fingerprint(ctx context.Context, file *File, issues []types.Issue) {
+	functionNames := make(map[int]string)
+	occurrences := make(map[string]int)
+
+	for i := range issues {
+		issue := &issues[i]
+		functionName := ""
+		if f := enclosingFunction(file, issue.StartLine, issue.EndLine); f >= 0 {
+			name, ok := functionNames[f]
+			if !ok {
+				name, _ = language.GetFunctionName(ctx, file.Language, file.Functions[f].Code)
+				functionNames[f] = name
+			}
+			functionName = name
+		}
+
+		key := strings.Join([]string{
+			issue.RuleID,
+			file.Path,
+			functionName,
+			normalizeCode(file.Snippet(issue.StartLine, issue.EndLine)),
+		}, "\x00")
+		occurrences[key]++
+
+		sum := sha256.Sum256([]byte(key + "\x00" + strconv.Itoa(occurrences[key])))
+		issue.Fingerprint = hex.EncodeToString(sum[:])
+	}
+}
+
+// enclosingFunction index of the innermost function containing the lines, -1 when outside any function
+func enclosingFunction(file *File, startLine, endLine int) int {
+	found := -1
+	for i, f := range file.Functions {
+		if f.StartLine > startLine || f.EndLine < endLine {
+			continue
+		}
+		if found < 0 || f.EndLine-f.StartLine < file.Functions[found].EndLine-file.Functions[found].StartLine {
+			found = i
+		}
+	}
+	return found
+}
+
+// normalizeCode collapse whitespace so that indentation and formatting changes keep the fingerprint
+func normalizeCode(code string) string {
+	lines := strings.Split(code, "\n")
+	normalized := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			normalized = append(normalized, strings.Join(fields, " "))
+		}
+	}
+	return strings.Join(normalized, "\n")
+}
@@ -0,0 +1,54 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func fingerprints(t *testing.T, content string, issues []types.Issue) []string {
+	t.Helper()
+	functions, err := language.ExtractFunctions(context.Background(), "go", content)
+	if err != nil {
+		t.Fatalf("ExtractFunctions failed: %v", err)
+	}
+	file := NewFile("main.go", "go", content, functions)
+	Fingerprint(context.Background(), file, issues)
+
+	prints := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		prints = append(prints, issue.Fingerprint)
+	}
+	return prints
+}
+
+func TestFingerprint_SurvivesLineShifts(t *testing.T) {
+	original := "package main\n\nfunc main() {\n\tfmt.Println(1)\n\tfmt.Println(1)\n}\n"
+	shifted := "package main\n\nimport \"fmt\"\n\nfunc main() {\n    fmt.Println(1)\n\tfmt.Println(1)\n}\n"
+
+	before := fingerprints(t, original, []types.Issue{
+		{RuleID: "debug-print", StartLine: 4, EndLine: 4},
+		{RuleID: "debug-print", StartLine: 5, EndLine: 5},
+	})
+	after := fingerprints(t, shifted, []types.Issue{
+		{RuleID: "debug-print", StartLine: 6, EndLine: 6},
+		{RuleID: "debug-print", StartLine: 7, EndLine: 7},
+	})
+
+	if before[0] != after[0] || before[1] != after[1] {
+		t.Errorf("Expected fingerprints to survive line shifts and indentation changes: %v, %v", before, after)
+	}
+	if before[0] == before[1] {
+		t.Error("Expected identical issues to be told apart by occurrence")
+	}
+}
+
+func TestFingerprint_DependsOnFunction(t *testing.T) {
+	a := fingerprints(t, "package main\n\nfunc a() {\n\tfmt.Println(1)\n}\n", []types.Issue{{RuleID: "debug-print", StartLine: 4, EndLine: 4}})
+	b := fingerprints(t, "package main\n\nfunc b() {\n\tfmt.Println(1)\n}\n", []types.Issue{{RuleID: "debug-print", StartLine: 4, EndLine: 4}})
+	if a[0] == b[0] {
+		t.Error("Expected fingerprints to differ between enclosing functions")
+	}
+}
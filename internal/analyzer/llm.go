@@ -0,0 +1,365 @@
+package analyzer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/prompt"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// LLMAnalyzerName name of the LLM reviewer analyzer
+const LLMAnalyzerName = "llm"
+
+const (
+	// llmRulePrefix prefix of the rule IDs of issues reported by the model
+	llmRulePrefix = "llm/"
+	// llmDefaultRule rule ID of issues the model reported without one
+	llmDefaultRule = "review"
+	// llmDefaultConfidence confidence of issues the model reported without one
+	llmDefaultConfidence = 50
+)
+
+// DefaultLLMPromptName name of the template used when none is configured
+const DefaultLLMPromptName = "default"
+
+// Built-in prompt of the reviewer model, the reply is parsed as llmReviewReply
+const (
+	llmSystemPrompt = `You are a senior code reviewer. Review the code the user sends and report real defects only:
+bugs, security problems, error handling mistakes, concurrency and resource issues, and serious maintainability problems.
+{{- with .LanguageInstructions}}
+{{.}}
+{{- end}}
+Every code line is prefixed with its line number and " | ". Report line numbers from these prefixes.
+{{.OutputSchema}}`
+
+	llmUserPrompt = `File: {{.FilePath}}
+Language: {{.Language}}
+{{- with .Function}}
+Function: {{.}}
+{{- end}}
+
+{{.Code}}`
+
+	llmOutputSchema = `Reply with a single JSON object and nothing else, in this format:
+{"issues": [{"rule_id": "short-kebab-case-id", "start_line": 1, "end_line": 1, "title": "short title",
+"message": "what is wrong and how to fix it", "severity": "low|middle|high", "issue_types": ["security"], "confidence": 0-100}]}
+Reply with {"issues": []} when the code has no problems.`
+)
+
+// DefaultLLMPrompt built-in prompt template of the LLM analyzer
+func DefaultLLMPrompt() *prompt.Template {
+	return &prompt.Template{
+		System: llmSystemPrompt,
+		User:   llmUserPrompt,
+		LanguageInstructions: map[string]string{
+			"go":         "Pay attention to ignored errors, goroutine leaks and unsynchronized access to shared state.",
+			"python":     "Pay attention to mutable default arguments, broad exception handlers and unclosed resources.",
+			"javascript": "Pay attention to unhandled promise rejections, loose equality and injection into HTML.",
+			"typescript": "Pay attention to unhandled promise rejections, unsafe any casts and injection into HTML.",
+		},
+		OutputSchema: llmOutputSchema,
+	}
+}
+
+// llmClient chat completion endpoint used by the LLM analyzer
+type llmClient interface {
+	ChatCompletion(ctx context.Context, req thirdPlatform.ChatCompletionRequest) (*thirdPlatform.ChatCompletionResponse, error)
+}
+
+// PromptLoader lookup of the prompt template the LLM analyzer renders, called for every file so that
+// template changes apply without restarting the worker
+type PromptLoader func(ctx context.Context) (*prompt.Template, error)
+
+var (
+	promptLoaderMu sync.RWMutex
+	promptLoader   PromptLoader
+)
+
+// SetPromptLoader register the lookup of the prompt template, the built-in template is used while none is registered
+func SetPromptLoader(loader PromptLoader) {
+	promptLoaderMu.Lock()
+	defer promptLoaderMu.Unlock()
+	promptLoader = loader
+}
+
+// llmAnalyzer analyzer asking a model to review each function of the file
+type llmAnalyzer struct {
+	client  llmClient    // The llm service of thirdPlatform when nil
+	prompts PromptLoader // The registered loader when nil
+}
+
+func init() {
+	Register(&llmAnalyzer{})
+}
+
+// NewLLMAnalyzer LLM analyzer calling the given model service instead of the configured one, with the prompt
+// template of prompts, the registered one when nil
+func NewLLMAnalyzer(service *thirdPlatform.LLMService, prompts PromptLoader) Analyzer {
+	return &llmAnalyzer{client: service, prompts: prompts}
+}
+
+// Name analyzer name
+func (a *llmAnalyzer) Name() string {
+	return LLMAnalyzerName
+}
+
+// llmReviewUnit code sent to the model in one request
+type llmReviewUnit struct {
+	startLine int
+	endLine   int
+	function  string // Function name, empty for the whole file or when it has none
+}
+
+// llmReviewReply reply format requested from the model
+type llmReviewReply struct {
+	Issues []llmReviewIssue `json:"issues"`
+}
+
+// llmReviewIssue issue reported by the model
+type llmReviewIssue struct {
+	RuleID     string   `json:"rule_id"`
+	StartLine  int      `json:"start_line"`
+	EndLine    int      `json:"end_line"`
+	Title      string   `json:"title"`
+	Message    string   `json:"message"`
+	Severity   string   `json:"severity"`
+	IssueTypes []string `json:"issue_types"`
+	Confidence *int     `json:"confidence"`
+}
+
+// Analyze review every function of the file with the model, the whole file when it has no functions
+func (a *llmAnalyzer) Analyze(ctx context.Context, file *File) ([]types.Issue, error) {
+	client, err := a.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.GetConfig().LLM
+	tmpl, err := a.loadPrompt(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := fileSymbols(ctx, file)
+	seen := make(map[string]bool)
+	var issues []types.Issue
+	for _, unit := range llmReviewUnits(file, symbols, cfg.MaxCodeLines) {
+		if err := ctx.Err(); err != nil {
+			return issues, err
+		}
+		rendered, err := tmpl.Render(llmPromptContext(file, symbols, unit))
+		if err != nil {
+			return nil, err
+		}
+		found, err := a.review(ctx, client, cfg, rendered, file, unit)
+		if err != nil {
+			return nil, err
+		}
+		// Nested functions are reviewed with their enclosing function as well
+		for _, issue := range found {
+			key := fmt.Sprintf("%s:%d:%d", issue.RuleID, issue.StartLine, issue.EndLine)
+			if !seen[key] {
+				seen[key] = true
+				issues = append(issues, issue)
+			}
+		}
+	}
+	return issues, nil
+}
+
+// review send a unit of the file to the model and convert the issues of its reply.
+// Replies that are not the requested JSON are logged and yield no issues, the request is not repeated.
+func (a *llmAnalyzer) review(ctx context.Context, client llmClient, cfg config.LLM, rendered *prompt.Rendered, file *File, unit llmReviewUnit) ([]types.Issue, error) {
+	resp, err := client.ChatCompletion(ctx, thirdPlatform.ChatCompletionRequest{
+		Model: cfg.Model,
+		Messages: []thirdPlatform.ChatMessage{
+			{Role: thirdPlatform.ChatRoleSystem, Content: rendered.System},
+			{Role: thirdPlatform.ChatRoleUser, Content: rendered.User},
+		},
+		Temperature:    cfg.Temperature,
+		MaxTokens:      cfg.MaxTokens,
+		ResponseFormat: &thirdPlatform.ChatResponseFormat{Type: "json_object"},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var reply llmReviewReply
+	if err := json.Unmarshal([]byte(stripCodeFence(resp.Choices[0].Message.Content)), &reply); err != nil {
+		logger.Warn(i18n.Translate("analyzer.llm.invalid_reply", "", nil), "file", file.Path, "start_line", unit.startLine, "error", err)
+		return nil, nil
+	}
+
+	var issues []types.Issue
+	for _, item := range reply.Issues {
+		if issue, ok := toLLMIssue(file, unit, item); ok {
+			issues = append(issues, issue)
+		}
+	}
+	return issues, nil
+}
+
+// getClient model service of the analyzer
+func (a *llmAnalyzer) getClient() (llmClient, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+	manager, err := thirdPlatform.GetServerManager()
+	if err != nil {
+		return nil, err
+	}
+	return &manager.LLM, nil
+}
+
+// loadPrompt prompt template of the analyzer
+func (a *llmAnalyzer) loadPrompt(ctx context.Context) (*prompt.Template, error) {
+	loader := a.prompts
+	if loader == nil {
+		promptLoaderMu.RLock()
+		loader = promptLoader
+		promptLoaderMu.RUnlock()
+	}
+	if loader == nil {
+		return DefaultLLMPrompt(), nil
+	}
+	return loader(ctx)
+}
+
+// LLMPromptContext prompt context of lines [startLine, endLine] of the file, as the LLM analyzer renders it
+func LLMPromptContext(ctx context.Context, file *File, startLine, endLine int) prompt.Context {
+	symbols := fileSymbols(ctx, file)
+	unit := llmReviewUnit{startLine: startLine, endLine: endLine, function: symbolName(symbols, startLine, endLine)}
+	return llmPromptContext(file, symbols, unit)
+}
+
+// llmPromptContext prompt context of a unit, with the other symbols of the file around it
+func llmPromptContext(file *File, symbols []prompt.Symbol, unit llmReviewUnit) prompt.Context {
+	var others []prompt.Symbol
+	for _, symbol := range symbols {
+		if symbol.StartLine != unit.startLine || symbol.EndLine != unit.endLine {
+			others = append(others, symbol)
+		}
+	}
+	return prompt.Context{
+		FilePath:  file.Path,
+		Language:  file.Language,
+		Code:      language.FormatCodeWithLineNumbers(file.Snippet(unit.startLine, unit.endLine), unit.startLine, unit.endLine, 1),
+		StartLine: unit.startLine,
+		EndLine:   unit.endLine,
+		Function:  unit.function,
+		Symbols:   others,
+	}
+}
+
+// fileSymbols named functions of the file, functions whose name cannot be parsed are left out
+func fileSymbols(ctx context.Context, file *File) []prompt.Symbol {
+	var symbols []prompt.Symbol
+	for _, f := range file.Functions {
+		name, err := language.GetFunctionName(ctx, file.Language, f.Code)
+		if err != nil || name == "" {
+			continue
+		}
+		symbols = append(symbols, prompt.Symbol{Name: name, StartLine: f.StartLine, EndLine: f.EndLine})
+	}
+	return symbols
+}
+
+// symbolName name of the symbol spanning lines [startLine, endLine], empty when there is none
+func symbolName(symbols []prompt.Symbol, startLine, endLine int) string {
+	for _, symbol := range symbols {
+		if symbol.StartLine == startLine && symbol.EndLine == endLine {
+			return symbol.Name
+		}
+	}
+	return ""
+}
+
+// llmReviewUnits line ranges of the file sent to the model, leaving out code longer than maxLines when it is positive
+func llmReviewUnits(file *File, symbols []prompt.Symbol, maxLines int) []llmReviewUnit {
+	var units []llmReviewUnit
+	for _, f := range file.Functions {
+		units = append(units, llmReviewUnit{startLine: f.StartLine, endLine: f.EndLine, function: symbolName(symbols, f.StartLine, f.EndLine)})
+	}
+	if len(file.Functions) == 0 && len(file.Lines) > 0 {
+		units = append(units, llmReviewUnit{startLine: 1, endLine: len(file.Lines)})
+	}
+
+	reviewed := units[:0]
+	for _, unit := range units {
+		if maxLines <= 0 || unit.endLine-unit.startLine+1 <= maxLines {
+			reviewed = append(reviewed, unit)
+		}
+	}
+	return reviewed
+}
+
+// toLLMIssue convert an issue of the model reply, dropping issues outside the reviewed lines
+func toLLMIssue(file *File, unit llmReviewUnit, item llmReviewIssue) (types.Issue, bool) {
+	startLine, endLine := item.StartLine, item.EndLine
+	if endLine < startLine {
+		endLine = startLine
+	}
+	if startLine < unit.startLine || endLine > unit.endLine || strings.TrimSpace(item.Message) == "" {
+		return types.Issue{}, false
+	}
+
+	ruleID := strings.ToLower(strings.TrimSpace(item.RuleID))
+	if ruleID == "" {
+		ruleID = llmDefaultRule
+	}
+	confidence := llmDefaultConfidence
+	if item.Confidence != nil {
+		confidence = min(max(*item.Confidence, 0), 100)
+	}
+	code := file.Snippet(startLine, endLine)
+	issue := types.Issue{
+		RuleID:     llmRulePrefix + ruleID,
+		FilePath:   file.Path,
+		IssueCode:  &code,
+		StartLine:  startLine,
+		EndLine:    endLine,
+		Message:    item.Message,
+		IssueTypes: item.IssueTypes,
+		Severity:   normalizeSeverity(item.Severity),
+		Confidence: confidence,
+	}
+	if item.Title != "" {
+		title := item.Title
+		issue.Title = &title
+	}
+	return issue, true
+}
+
+// normalizeSeverity map the severity wording of the model onto the issue severities
+func normalizeSeverity(severity string) string {
+	switch strings.ToLower(strings.TrimSpace(severity)) {
+	case types.SeverityHigh, "critical", "major", "error":
+		return types.SeverityHigh
+	case types.SeverityLow, "minor", "info", "trivial":
+		return types.SeverityLow
+	default:
+		return types.SeverityMiddle
+	}
+}
+
+// stripCodeFence remove a markdown code fence around the reply, models add one despite the JSON output format
+func stripCodeFence(content string) string {
+	content = strings.TrimSpace(content)
+	if !strings.HasPrefix(content, "```") {
+		return content
+	}
+	content = strings.TrimPrefix(content, "```")
+	if newline := strings.IndexByte(content, '\n'); newline >= 0 {
+		content = content[newline+1:]
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(content), "```"))
+}
@@ -0,0 +1,116 @@
+package analyzer
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/prompt"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+)
+
+func newTestLLMAnalyzer(t *testing.T, prompts PromptLoader) (Analyzer, *thirdPlatform.FakeLLMServer) {
+	t.Helper()
+	fake := thirdPlatform.NewFakeLLMServer()
+	t.Cleanup(fake.Close)
+
+	service, err := thirdPlatform.NewLLMServiceWithConfig(fake.ServiceConfig())
+	if err != nil {
+		t.Fatalf("NewLLMServiceWithConfig failed: %v", err)
+	}
+	return NewLLMAnalyzer(service, prompts), fake
+}
+
+func TestLLMAnalyzer_ReviewsFunctions(t *testing.T) {
+	a, fake := newTestLLMAnalyzer(t, nil)
+	content := "package main\n\nfunc connect() {\n\tpassword := \"hunter2\"\n\tuse(password)\n}\n\nfunc run() {\n\tpanic(\"boom\")\n}\n"
+	functions, err := language.ExtractFunctions(context.Background(), "go", content)
+	if err != nil {
+		t.Fatalf("ExtractFunctions failed: %v", err)
+	}
+
+	issues, err := a.Analyze(context.Background(), NewFile("main.go", "go", content, functions))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(fake.Requests()) != 2 {
+		t.Fatalf("Expected one request per function, got %d", len(fake.Requests()))
+	}
+	if prompt := fake.Requests()[1].Messages[1].Content; !strings.Contains(prompt, " 9 | \tpanic(\"boom\")") {
+		t.Errorf("Expected numbered function code in the prompt:\n%s", prompt)
+	}
+
+	if len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, got %+v", issues)
+	}
+	if issues[0].RuleID != "llm/hardcoded-credential" || issues[0].StartLine != 4 || issues[0].Severity != "high" || issues[0].Confidence != 85 {
+		t.Errorf("Unexpected credential issue: %+v", issues[0])
+	}
+	if issues[1].RuleID != "llm/avoid-panic" || issues[1].StartLine != 9 || *issues[1].IssueCode != "\tpanic(\"boom\")" {
+		t.Errorf("Unexpected panic issue: %+v", issues[1])
+	}
+}
+
+func TestLLMAnalyzer_ValidatesReply(t *testing.T) {
+	a, fake := newTestLLMAnalyzer(t, nil)
+	fake.Respond = func(req thirdPlatform.ChatCompletionRequest) string {
+		return "```json\n" + `{"issues": [
+			{"start_line": 2, "end_line": 2, "message": "kept", "severity": "critical", "confidence": 150},
+			{"start_line": 40, "end_line": 41, "message": "outside the code"},
+			{"start_line": 1, "end_line": 1, "message": ""}
+		]}` + "\n```"
+	}
+
+	issues, err := a.Analyze(context.Background(), NewFile("a.py", "python", "x = 1\ny = x\n", nil))
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("Expected only the issue within the code, got %+v", issues)
+	}
+	if issues[0].RuleID != "llm/review" || issues[0].Severity != "high" || issues[0].Confidence != 100 {
+		t.Errorf("Expected defaults and clamped values: %+v", issues[0])
+	}
+
+	fake.Respond = func(req thirdPlatform.ChatCompletionRequest) string { return "no issues found" }
+	if issues, err := a.Analyze(context.Background(), NewFile("a.py", "python", "x = 1\n", nil)); err != nil || len(issues) != 0 {
+		t.Errorf("Expected invalid replies to be ignored, got %+v, %v", issues, err)
+	}
+}
+
+func TestLLMAnalyzer_UsesLoadedPrompt(t *testing.T) {
+	tmpl := DefaultLLMPrompt()
+	a, fake := newTestLLMAnalyzer(t, func(ctx context.Context) (*prompt.Template, error) { return tmpl, nil })
+
+	content := "package main\n\nfunc helper() {}\n\nfunc run() {\n\thelper()\n}\n"
+	functions, err := language.ExtractFunctions(context.Background(), "go", content)
+	if err != nil {
+		t.Fatalf("ExtractFunctions failed: %v", err)
+	}
+	file := NewFile("main.go", "go", content, functions)
+
+	if _, err := a.Analyze(context.Background(), file); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if system := fake.Requests()[0].Messages[0].Content; !strings.Contains(system, "goroutine leaks") {
+		t.Errorf("Expected the built-in prompt with Go instructions:\n%s", system)
+	}
+
+	// The template is loaded again for every file
+	tmpl = &prompt.Template{
+		System:               "Review. {{.LanguageInstructions}}",
+		User:                 "{{.Function}} near{{range .Symbols}} {{.Name}}{{end}}\n{{.Code}}",
+		LanguageInstructions: map[string]string{"go": "Go rules."},
+	}
+	if _, err := a.Analyze(context.Background(), file); err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	messages := fake.Requests()[3].Messages
+	if messages[0].Content != "Review. Go rules." {
+		t.Errorf("Expected the loaded system prompt, got %q", messages[0].Content)
+	}
+	if !strings.HasPrefix(messages[1].Content, "run near helper\n5 | func run() {") {
+		t.Errorf("Expected function, symbols and numbered code in the user prompt:\n%s", messages[1].Content)
+	}
+}
@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// RuleAnalyzerName name of the builtin rule analyzer
+const RuleAnalyzerName = "rules"
+
+// maxFunctionLines functions longer than this are reported by the long-function rule
+const maxFunctionLines = 80
+
+// rule builtin review rule
+type rule struct {
+	id         string
+	severity   string
+	issueType  string
+	confidence int
+	titleID    string
+	messageID  string
+	check      func(file *File) []ruleMatch
+}
+
+// ruleMatch location matched by a rule
+type ruleMatch struct {
+	startLine int
+	endLine   int
+	data      map[string]interface{}
+	edits     []types.TextEdit // Optional fix of the match
+}
+
+var (
+	todoPattern = regexp.MustCompile(`\b(TODO|FIXME|XXX)\b`)
+
+	debugPrintPatterns = map[string]*regexp.Regexp{
+		"go":         regexp.MustCompile(`\bfmt\.Print(ln|f)?\(`),
+		"javascript": regexp.MustCompile(`\bconsole\.(log|debug)\(`),
+		"typescript": regexp.MustCompile(`\bconsole\.(log|debug)\(`),
+		"python":     regexp.MustCompile(`^\s*print\(`),
+		"java":       regexp.MustCompile(`\bSystem\.(out|err)\.print(ln|f)?\(`),
+		"php":        regexp.MustCompile(`\b(var_dump|print_r)\(`),
+		"ruby":       regexp.MustCompile(`^\s*(puts|p)\s`),
+	}
+)
+
+var builtinRules = []rule{
+	{
+		id:         "todo-comment",
+		severity:   types.SeverityLow,
+		issueType:  "maintainability",
+		confidence: 90,
+		titleID:    "analyzer.rule.todo_comment.title",
+		messageID:  "analyzer.rule.todo_comment.message",
+		check: func(file *File) []ruleMatch {
+			var matches []ruleMatch
+			for i, line := range file.Lines {
+				if marker := todoPattern.FindString(line); marker != "" {
+					matches = append(matches, ruleMatch{startLine: i + 1, endLine: i + 1, data: map[string]interface{}{"marker": marker}})
+				}
+			}
+			return matches
+		},
+	},
+	{
+		id:         "long-function",
+		severity:   types.SeverityMiddle,
+		issueType:  "maintainability",
+		confidence: 70,
+		titleID:    "analyzer.rule.long_function.title",
+		messageID:  "analyzer.rule.long_function.message",
+		check: func(file *File) []ruleMatch {
+			var matches []ruleMatch
+			for _, f := range file.Functions {
+				if lines := f.EndLine - f.StartLine + 1; lines > maxFunctionLines {
+					matches = append(matches, ruleMatch{
+						startLine: f.StartLine,
+						endLine:   f.StartLine,
+						data:      map[string]interface{}{"lines": lines, "max": maxFunctionLines},
+					})
+				}
+			}
+			return matches
+		},
+	},
+	{
+		id:         "debug-print",
+		severity:   types.SeverityLow,
+		issueType:  "code_style",
+		confidence: 60,
+		titleID:    "analyzer.rule.debug_print.title",
+		messageID:  "analyzer.rule.debug_print.message",
+		check: func(file *File) []ruleMatch {
+			pattern, ok := debugPrintPatterns[strings.ToLower(file.Language)]
+			if !ok {
+				return nil
+			}
+			var matches []ruleMatch
+			for i, line := range file.Lines {
+				if pattern.MatchString(line) {
+					match := ruleMatch{startLine: i + 1, endLine: i + 1}
+					// Remove the statement when it is complete on its own line
+					if strings.Count(line, "(") == strings.Count(line, ")") {
+						match.edits = []types.TextEdit{{StartLine: i + 1, EndLine: i + 1}}
+					}
+					matches = append(matches, match)
+				}
+			}
+			return matches
+		},
+	},
+}
+
+// ruleAnalyzer analyzer evaluating the builtin rules
+type ruleAnalyzer struct {
+	rules []rule
+}
+
+func init() {
+	Register(&ruleAnalyzer{rules: builtinRules})
+}
+
+// Name analyzer name
+func (a *ruleAnalyzer) Name() string {
+	return RuleAnalyzerName
+}
+
+// Analyze evaluate every builtin rule on the file
+func (a *ruleAnalyzer) Analyze(ctx context.Context, file *File) ([]types.Issue, error) {
+	var issues []types.Issue
+	for _, r := range a.rules {
+		if err := ctx.Err(); err != nil {
+			return issues, err
+		}
+		for _, m := range r.check(file) {
+			title := i18n.Translate(r.titleID, "", nil)
+			code := file.Snippet(m.startLine, m.endLine)
+			issues = append(issues, types.Issue{
+				RuleID:     r.id,
+				FilePath:   file.Path,
+				IssueCode:  &code,
+				StartLine:  m.startLine,
+				EndLine:    m.endLine,
+				Title:      &title,
+				Message:    i18n.Translate(r.messageID, "", m.data),
+				IssueTypes: []string{r.issueType},
+				Severity:   r.severity,
+				Confidence: r.confidence,
+				Edits:      m.edits,
+			})
+		}
+	}
+	return issues, nil
+}
@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// Inline suppression markers, followed by a comma separated rule list ("*" for all rules) and an optional reason:
+//
+//	fmt.Println(x) // kbcenter:ignore debug-print kept for the CLI output
+//	// kbcenter:ignore-file todo-comment legacy module
+const (
+	suppressionMarker     = "kbcenter:ignore"
+	fileSuppressionMarker = "kbcenter:ignore-file"
+)
+
+// Suppression inline suppression comment
+type Suppression struct {
+	RuleIDs   []string // Suppressed rules, empty for all rules
+	Reason    string
+	StartLine int // First line covered by the suppression
+	EndLine   int // Last line covered by the suppression
+	WholeFile bool
+}
+
+// ParseSuppressions find suppression comments. A comment following code covers its own lines,
+// a comment on its own lines also covers the next line.
+func ParseSuppressions(comments []language.CommentInfo) []Suppression {
+	var suppressions []Suppression
+	for _, comment := range comments {
+		index := strings.Index(comment.Text, suppressionMarker)
+		if index < 0 {
+			continue
+		}
+
+		suppression := Suppression{StartLine: comment.StartLine, EndLine: comment.EndLine}
+		rest := comment.Text[index+len(suppressionMarker):]
+		if strings.HasPrefix(rest, "-file") {
+			suppression.WholeFile = true
+			rest = rest[len("-file"):]
+		} else if !comment.Trailing {
+			suppression.EndLine++
+		}
+		// The marker must be a whole word
+		if rest != "" && rest[0] != ' ' && rest[0] != '\t' && !strings.HasPrefix(rest, "*/") {
+			continue
+		}
+
+		rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), "*/"))
+		if ruleList, reason, _ := strings.Cut(rest, " "); ruleList != "" {
+			if ruleList != "*" {
+				suppression.RuleIDs = strings.Split(ruleList, ",")
+			}
+			suppression.Reason = strings.TrimSpace(reason)
+		}
+		suppressions = append(suppressions, suppression)
+	}
+	return suppressions
+}
+
+// Suppresses whether the suppression applies to the issue
+func (s *Suppression) Suppresses(issue *types.Issue) bool {
+	if !s.WholeFile && (issue.StartLine < s.StartLine || issue.StartLine > s.EndLine) {
+		return false
+	}
+	if len(s.RuleIDs) == 0 {
+		return true
+	}
+	for _, ruleID := range s.RuleIDs {
+		if ruleID == issue.RuleID {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppressed whether any suppression comment of the file applies to the issue
+func (f *File) Suppressed(issue *types.Issue) bool {
+	for i := range f.Suppressions {
+		if f.Suppressions[i].Suppresses(issue) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package analyzer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func suppressedFile(t *testing.T, lang, content string) *File {
+	t.Helper()
+	comments, err := language.ExtractComments(context.Background(), lang, content)
+	if err != nil {
+		t.Fatalf("ExtractComments failed: %v", err)
+	}
+	file := NewFile("main", lang, content, nil)
+	file.SetComments(comments)
+	return file
+}
+
+func TestSuppressed_Go(t *testing.T) {
+	content := `package main
+
+func main() {
+	fmt.Println(1) // kbcenter:ignore debug-print kept for CLI output
+	// kbcenter:ignore *
+	fmt.Println(2)
+	fmt.Println(3)
+	s := "// kbcenter:ignore debug-print"
+	fmt.Println(s)
+}
+`
+	file := suppressedFile(t, "go", content)
+	if len(file.Suppressions) != 2 {
+		t.Fatalf("Expected 2 suppressions from comments only, got %+v", file.Suppressions)
+	}
+	if file.Suppressions[0].Reason != "kept for CLI output" {
+		t.Errorf("Unexpected reason %q", file.Suppressions[0].Reason)
+	}
+
+	cases := []struct {
+		issue types.Issue
+		want  bool
+	}{
+		{types.Issue{RuleID: "debug-print", StartLine: 4}, true},
+		{types.Issue{RuleID: "todo-comment", StartLine: 4}, false},
+		{types.Issue{RuleID: "long-function", StartLine: 6}, true},
+		{types.Issue{RuleID: "debug-print", StartLine: 7}, false},
+		{types.Issue{RuleID: "debug-print", StartLine: 9}, false},
+	}
+	for _, c := range cases {
+		if got := file.Suppressed(&c.issue); got != c.want {
+			t.Errorf("Suppressed(%s at line %d) = %v, want %v", c.issue.RuleID, c.issue.StartLine, got, c.want)
+		}
+	}
+}
+
+func TestSuppressed_WholeFilePython(t *testing.T) {
+	content := "# kbcenter:ignore-file todo-comment,debug-print legacy\nprint(1)\n# TODO: later\n"
+	file := suppressedFile(t, "python", content)
+
+	if !file.Suppressed(&types.Issue{RuleID: "todo-comment", StartLine: 3}) {
+		t.Error("Expected file suppression to cover every line")
+	}
+	if file.Suppressed(&types.Issue{RuleID: "long-function", StartLine: 2}) {
+		t.Error("Expected file suppression to cover listed rules only")
+	}
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/fault"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+)
+
+// FaultHeader response header naming the fault rule applied to the request
+const FaultHeader = "X-Fault-Injected"
+
+// faultExemptPrefix requests never affected by faults, so that the admin API can always switch them off
+const faultExemptPrefix = "/api/v1/admin/"
+
+// FaultInjector middleware injecting the configured faults into matching requests:
+// latency first, then a connection reset, an error status or a truncated response body
+func FaultInjector() gin.HandlerFunc {
+	injector := fault.Default()
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, faultExemptPrefix) {
+			c.Next()
+			return
+		}
+		rule := injector.Match(c.Request)
+		if rule == nil {
+			c.Next()
+			return
+		}
+		logger.Debug(i18n.Translate("fault.injecting", "", nil), "rule", rule.Name, "method", c.Request.Method, "path", c.Request.URL.Path)
+
+		if delay := fault.Delay(rule.Latency); delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				timer.Stop()
+				c.Abort()
+				return
+			}
+		}
+
+		switch {
+		case rule.Reset:
+			resetConnection(c)
+			c.Abort()
+		case rule.Status != 0:
+			c.Header(FaultHeader, rule.Name)
+			if rule.Body == "" {
+				api.Fail(c, rule.Status, "fault.injected")
+			} else {
+				c.Data(rule.Status, faultContentType(rule.Body), []byte(rule.Body))
+			}
+			c.Abort()
+		case rule.TruncateRatio > 0:
+			c.Header(FaultHeader, rule.Name)
+			truncateResponse(c, rule.TruncateRatio)
+		default:
+			c.Header(FaultHeader, rule.Name)
+			c.Next()
+		}
+	}
+}
+
+// bufferedWriter response writer holding the body back until the handler is done
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Flush hold the body back, streamed responses are sent once the handler is done
+func (w *bufferedWriter) Flush() {}
+
+// truncateResponse handle the request, then send the share of the body announced with its full length and close
+// the connection, so that the client reads an unexpected end of the body
+func truncateResponse(c *gin.Context, ratio float64) {
+	writer := &bufferedWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	body := writer.body.Bytes()
+	c.Writer.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	c.Writer.WriteHeaderNow()
+	_, _ = c.Writer.Write(body[:int(float64(len(body))*ratio)])
+	if conn, _, err := c.Writer.Hijack(); err == nil {
+		_ = conn.Close()
+	}
+}
+
+// resetConnection close the connection without a response, with a TCP reset where possible
+func resetConnection(c *gin.Context) {
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		_ = tcpConn.SetLinger(0)
+	}
+	_ = conn.Close()
+}
+
+// faultContentType content type of a configured error body
+func faultContentType(body string) string {
+	if trimmed := strings.TrimSpace(body); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return "application/json; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
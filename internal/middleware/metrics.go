@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
+)
+
+// Metrics middleware recording the count and latency of the requests per route and status
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		// The route pattern, not the path, keeps the number of series bounded
+		metrics.ObserveHTTPRequest(c.FullPath(), c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
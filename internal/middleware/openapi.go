@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/openapi"
+)
+
+// OpenAPIHeader response header set on responses served from the OpenAPI document
+const OpenAPIHeader = "X-OpenAPI-Example"
+
+// OpenAPIValidator middleware validating requests to the operations of the OpenAPI document and the responses
+// of their handlers, and serving the operations without a handler from the document
+func OpenAPIValidator() (gin.HandlerFunc, error) {
+	cfg := config.GetConfig().OpenAPI
+	if cfg.Spec == "" {
+		return func(c *gin.Context) { c.Next() }, nil
+	}
+	spec, err := openapi.Load(context.Background(), cfg.Spec, cfg.BasePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, faultExemptPrefix) {
+			c.Next()
+			return
+		}
+		operation, err := spec.Find(c.Request)
+		if errors.Is(err, openapi.ErrNoOperation) {
+			c.Next()
+			return
+		}
+
+		if cfg.ValidateRequests {
+			if err := operation.ValidateRequest(c.Request.Context(), c.Request); err != nil {
+				logger.Warn(i18n.Translate("openapi.request_violation", "", nil), "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+				if cfg.Reject {
+					api.Error(c, http.StatusBadRequest, err)
+					c.Abort()
+					return
+				}
+			}
+		}
+
+		// Gin leaves the full path empty when no handler matches the request
+		if c.FullPath() == "" {
+			if cfg.Serve {
+				serveExample(c, operation)
+				return
+			}
+			c.Next()
+			return
+		}
+		if !cfg.ValidateResponses {
+			c.Next()
+			return
+		}
+		validateResponse(c, operation, cfg.Reject)
+	}, nil
+}
+
+// serveExample respond with the example of the operation
+func serveExample(c *gin.Context, operation *openapi.Operation) {
+	example, err := operation.Example()
+	if err != nil {
+		api.Error(c, http.StatusInternalServerError, err)
+		c.Abort()
+		return
+	}
+	c.Header(OpenAPIHeader, "true")
+	if example.ContentType == "" {
+		c.Status(example.Status)
+	} else {
+		c.Data(example.Status, example.ContentType, example.Body)
+	}
+	c.Abort()
+}
+
+// validateResponse handle the request with the response held back, then validate and send it
+func validateResponse(c *gin.Context, operation *openapi.Operation, reject bool) {
+	writer := &bufferedWriter{ResponseWriter: c.Writer}
+	c.Writer = writer
+	c.Next()
+	c.Writer = writer.ResponseWriter
+
+	body := writer.body.Bytes()
+	if err := operation.ValidateResponse(c.Request.Context(), c.Request, c.Writer.Status(), c.Writer.Header(), body); err != nil {
+		logger.Warn(i18n.Translate("openapi.response_violation", "", nil), "method", c.Request.Method, "path", c.Request.URL.Path, "status", c.Writer.Status(), "error", err)
+		if reject {
+			api.Error(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	c.Writer.WriteHeaderNow()
+	_, _ = c.Writer.Write(body)
+}
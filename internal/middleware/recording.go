@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/zgsm/mock-kbcenter/api"
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/recording"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// RecordingHeader response header telling whether the response was recorded or replayed
+const RecordingHeader = "X-Recording"
+
+// recordingService http_client service the requests are recorded from
+const recordingService = "kbCenter"
+
+// defaultRecordingRoutes routes recorded or replayed when none are configured
+var defaultRecordingRoutes = []string{"/api/v1/**"}
+
+// skippedHeaders headers neither forwarded to the upstream nor saved in fixtures
+var skippedHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Host":                true,
+	"Content-Length":      true,
+	"Accept-Encoding":     true, // Let the transport negotiate and decompress
+	"Content-Encoding":    true,
+	"Date":                true,
+	"Set-Cookie":          true,
+}
+
+// Recorder middleware recording the requests to the real KB center or replaying the recorded fixtures,
+// depending on the recording mode; requests are handled by the mock when no mode is set
+func Recorder() (gin.HandlerFunc, error) {
+	cfg := config.GetConfig().Recording
+	routes := cfg.Routes
+	if len(routes) == 0 {
+		routes = defaultRecordingRoutes
+	}
+	store := recording.NewStore(cfg.FixtureDir, cfg.Match)
+
+	var handle gin.HandlerFunc
+	switch cfg.Mode {
+	case "":
+		return func(c *gin.Context) { c.Next() }, nil
+	case recording.ModeRecord:
+		clientConfig, err := thirdPlatform.GetServiceConfig(recordingService)
+		if err != nil {
+			return nil, err
+		}
+		// Record every status, not only the successful ones
+		clientConfig.ValidStatusCodes = allStatusCodes()
+		client, err := httpclient.NewClient(clientConfig)
+		if err != nil {
+			return nil, err
+		}
+		handle = func(c *gin.Context) { recordRequest(c, client, store) }
+	case recording.ModeReplay:
+		count, err := store.Load()
+		if err != nil {
+			return nil, err
+		}
+		logger.Info(i18n.Translate("recording.fixtures_loaded", "", nil), "count", count, "dir", cfg.FixtureDir)
+		handle = func(c *gin.Context) { replayRequest(c, store, cfg.Fallback) }
+	default:
+		return nil, fmt.Errorf("%w: %q", recording.ErrInvalidMode, cfg.Mode)
+	}
+
+	return func(c *gin.Context) {
+		// The admin API is served by the mock itself
+		if strings.HasPrefix(c.Request.URL.Path, faultExemptPrefix) || !matchAnyRoute(routes, c.Request.URL.Path) {
+			c.Next()
+			return
+		}
+		handle(c)
+	}, nil
+}
+
+// recordRequest forward the request to the upstream, save the exchange as a fixture and send the upstream response
+func recordRequest(c *gin.Context, client *httpclient.Client, store *recording.Store) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			api.BadRequest(c, "common.invalidParameter")
+			c.Abort()
+			return
+		}
+	}
+
+	headers := make(map[string]string)
+	for name, values := range c.Request.Header {
+		if !skippedHeaders[http.CanonicalHeaderKey(name)] && len(values) > 0 {
+			headers[name] = values[0]
+		}
+	}
+	var reqBody interface{}
+	if len(body) > 0 {
+		reqBody = body
+	}
+
+	resp, err := client.Request(c.Request.Context(), c.Request.Method, c.Request.URL.RequestURI(), reqBody, headers)
+	if err != nil {
+		logger.Error(i18n.Translate("recording.upstream_failed", "", nil), "path", c.Request.URL.Path, "error", err)
+		api.Fail(c, http.StatusBadGateway, "recording.upstream_failed")
+		c.Abort()
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		logger.Error(i18n.Translate("recording.upstream_failed", "", nil), "path", c.Request.URL.Path, "error", err)
+		api.Fail(c, http.StatusBadGateway, "recording.upstream_failed")
+		c.Abort()
+		return
+	}
+
+	fixture := &recording.Fixture{
+		Request:    recording.NewRequest(c.Request.Method, c.Request.URL, body),
+		Response:   recording.Response{Status: resp.StatusCode, Headers: make(map[string]string)},
+		RecordedAt: time.Now(),
+	}
+	for name, values := range resp.Header {
+		if !skippedHeaders[name] && len(values) > 0 {
+			fixture.Response.Headers[name] = values[0]
+		}
+	}
+	fixture.Response.SetBody(respBody)
+	if err := store.Save(fixture); err != nil {
+		// The client still gets the upstream response
+		logger.Error(i18n.Translate("recording.save_failed", "", nil), "path", c.Request.URL.Path, "error", err)
+	}
+
+	c.Header(RecordingHeader, recording.ModeRecord)
+	writeFixtureResponse(c, &fixture.Response, respBody)
+}
+
+// replayRequest send the response of the fixture matching the request
+func replayRequest(c *gin.Context, store *recording.Store, fallback bool) {
+	var body []byte
+	if c.Request.Body != nil {
+		var err error
+		if body, err = io.ReadAll(c.Request.Body); err != nil {
+			api.BadRequest(c, "common.invalidParameter")
+			c.Abort()
+			return
+		}
+		// Keep the body for the mock handlers when falling back
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	fixture := store.Lookup(recording.NewRequest(c.Request.Method, c.Request.URL, body))
+	if fixture == nil {
+		if fallback {
+			c.Next()
+			return
+		}
+		api.Fail(c, http.StatusNotFound, "recording.fixture_not_found")
+		c.Abort()
+		return
+	}
+	respBody, err := fixture.Response.BodyBytes()
+	if err != nil {
+		api.Error(c, http.StatusInternalServerError, err)
+		c.Abort()
+		return
+	}
+
+	c.Header(RecordingHeader, recording.ModeReplay)
+	writeFixtureResponse(c, &fixture.Response, respBody)
+}
+
+// writeFixtureResponse send the status, headers and body of a fixture response
+func writeFixtureResponse(c *gin.Context, resp *recording.Response, body []byte) {
+	for name, value := range resp.Headers {
+		c.Header(name, value)
+	}
+	c.Data(resp.Status, resp.Headers["Content-Type"], body)
+	c.Abort()
+}
+
+// matchAnyRoute whether the path matches any of the route globs
+func matchAnyRoute(routes []string, path string) bool {
+	for _, route := range routes {
+		if utils.MatchGlob(route, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allStatusCodes every HTTP status code
+func allStatusCodes() []int {
+	codes := make([]int, 0, 500)
+	for code := 100; code < 600; code++ {
+		codes = append(codes, code)
+	}
+	return codes
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// PromptTemplate version of a review prompt template, every update stores a new version
+type PromptTemplate struct {
+	ID                   uint              `gorm:"primaryKey;autoIncrement"`
+	Name                 string            `gorm:"size:128;uniqueIndex:idx_prompt_template_version"`
+	Version              int               `gorm:"uniqueIndex:idx_prompt_template_version"`
+	Description          string            `gorm:"size:1024"`
+	SystemPrompt         string            `gorm:"type:text"`
+	UserPrompt           string            `gorm:"type:text"`
+	LanguageInstructions map[string]string `gorm:"serializer:json"`
+	OutputSchema         string            `gorm:"type:text"`
+	CreatedAt            time.Time
+}
@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+// ReviewIssue issue found by a review task
+type ReviewIssue struct {
+	ID           uint    `gorm:"primaryKey;autoIncrement"`
+	IssueID      string  `gorm:"size:64;index;uniqueIndex:idx_review_issues_task_issue,priority:2"` // Kept across reviews of the same codebase
+	ReviewTaskID string  `gorm:"size:64;uniqueIndex:idx_review_issues_task_issue,priority:1"`
+	RuleID       string  `gorm:"size:128;index"`
+	Fingerprint  string  `gorm:"size:64;index"`
+	Change       string  `gorm:"size:16"`
+	FilePath     string  `gorm:"size:1024"`
+	IssueCode    *string `gorm:"type:text"`
+	FixPatch     *string `gorm:"type:text"`
+	StartLine    int
+	EndLine      int
+	Title        *string  `gorm:"size:512"`
+	Message      string   `gorm:"type:text"`
+	IssueTypes   []string `gorm:"serializer:json"`
+	Severity     string   `gorm:"size:16"`
+	Status       int
+	Assignee     string `gorm:"size:128;index"`
+	Confidence   int
+	Virtual      bool // Found in the inline code of a code target, apart from the codebase file of the same path
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
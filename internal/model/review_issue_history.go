@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// ReviewIssueHistory triage change of a review issue
+type ReviewIssueHistory struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	IssueID      string `gorm:"size:64;index"`
+	ReviewTaskID string `gorm:"size:64"`
+	Operator     string `gorm:"size:128"`
+	FromStatus   int
+	ToStatus     int
+	FromAssignee string `gorm:"size:128"`
+	ToAssignee   string `gorm:"size:128"`
+	Comment      string `gorm:"type:text"`
+	CreatedAt    time.Time
+}
@@ -0,0 +1,39 @@
+package model
+
+import (
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// ReviewTask review task persisted for the review pipeline
+type ReviewTask struct {
+	ID           uint           `gorm:"primaryKey;autoIncrement"`
+	ReviewTaskID string         `gorm:"size:64;uniqueIndex"`
+	ClientID     string         `gorm:"size:128;index"`
+	CodebasePath string         `gorm:"size:1024"`
+	RootPath     string         `gorm:"size:1024"` // Absolute directory the target paths are resolved against
+	Targets      []types.Target `gorm:"serializer:json"`
+	Status       string         `gorm:"size:32;index"`
+	Progress     float64
+	Total        int
+	Processed    int
+	Subtasks     int
+	// Subtasks whose results are stored, so that a commit repeated after a failure of the subtask state does not store them again
+	CommittedChunks []int  `gorm:"serializer:json"`
+	Error           string `gorm:"type:text"`
+	// Issue changes compared with the previous finished review of the same codebase
+	BaseReviewTaskID string `gorm:"size:64"`
+	NewIssues        int
+	UnchangedIssues  int
+	ResolvedIssues   int
+	// Fingerprints of known issues filtered out of the review, from the request or the baseline file
+	Baseline         []string `gorm:"serializer:json"`
+	SuppressedIssues int
+	BaselineIssues   int
+	FilteredIssues   int        // Dropped by the issue policies
+	StartedAt        *time.Time // Start of the first run, the task deadline counts from it
+	IdempotencyKey   string     `gorm:"size:128"` // Storage key of the idempotency key claimed by the task, empty when none
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
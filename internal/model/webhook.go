@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Webhook endpoint notified of review task events
+type Webhook struct {
+	ID           uint     `gorm:"primaryKey;autoIncrement"`
+	WebhookID    string   `gorm:"size:64;uniqueIndex"`
+	URL          string   `gorm:"size:2048"`
+	Secret       string   `gorm:"size:256"`
+	CodebasePath string   `gorm:"size:1024"` // Empty for tasks of every codebase
+	Events       []string `gorm:"serializer:json"`
+	Enabled      bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// WebhookDelivery attempt to deliver an event to a webhook, with the signed payload kept for redelivery
+type WebhookDelivery struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement"`
+	DeliveryID   string `gorm:"size:64;uniqueIndex"`
+	WebhookID    string `gorm:"size:64;index"`
+	ReviewTaskID string `gorm:"size:64"`
+	Event        string `gorm:"size:64"`
+	Payload      string `gorm:"type:text"`
+	Status       string `gorm:"size:32"`
+	StatusCode   int
+	Error        string `gorm:"type:text"`
+	Duration     int64  // Milliseconds
+	RedeliveryOf string `gorm:"size:64"`
+	CreatedAt    time.Time
+	DeliveredAt  *time.Time
+}
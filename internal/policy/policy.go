@@ -0,0 +1,137 @@
+package policy
+
+import (
+	"sort"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// severityLevels order of the issue severities, lowest first
+var severityLevels = map[string]int{
+	types.SeverityLow:    1,
+	types.SeverityMiddle: 2,
+	types.SeverityHigh:   3,
+}
+
+// Set policies applying to the issues of a review task
+type Set struct {
+	policies []config.IssuePolicy
+}
+
+// ForTask policies matching the codebase and the head refs of the diff targets of a task, in configuration order
+func ForTask(policies []config.IssuePolicy, codebasePath string, targets []types.Target) *Set {
+	var refs []string
+	for _, target := range targets {
+		if target.Type == "diff" && target.HeadRef != "" {
+			refs = append(refs, target.HeadRef)
+		}
+	}
+
+	set := &Set{}
+	for _, policy := range policies {
+		if len(policy.Codebases) > 0 && !matchAny(policy.Codebases, codebasePath) {
+			continue
+		}
+		if len(policy.Refs) > 0 && !matchAnyOf(policy.Refs, refs) {
+			continue
+		}
+		set.policies = append(set.policies, policy)
+	}
+	return set
+}
+
+// Empty whether no policy applies
+func (s *Set) Empty() bool {
+	return s == nil || len(s.policies) == 0
+}
+
+// Apply apply the policies matching the file to its issues, returning the issues kept with their
+// severities remapped and the issues dropped
+func (s *Set) Apply(filePath string, issues []types.Issue) (kept, dropped []types.Issue) {
+	kept = issues
+	if s.Empty() {
+		return kept, nil
+	}
+	for _, policy := range s.policies {
+		if len(policy.Paths) > 0 && !matchAny(policy.Paths, filePath) {
+			continue
+		}
+		var droppedByPolicy []types.Issue
+		kept, droppedByPolicy = apply(policy, kept)
+		dropped = append(dropped, droppedByPolicy...)
+	}
+	return kept, dropped
+}
+
+// apply apply a single policy to the issues of a file
+func apply(policy config.IssuePolicy, issues []types.Issue) (kept, dropped []types.Issue) {
+	kept = make([]types.Issue, 0, len(issues))
+	for _, issue := range issues {
+		if mapped, ok := policy.SeverityMap[issue.Severity]; ok {
+			issue.Severity = mapped
+		}
+		if allowed(policy, issue) {
+			kept = append(kept, issue)
+		} else {
+			dropped = append(dropped, issue)
+		}
+	}
+
+	if policy.MaxIssuesPerFile > 0 && len(kept) > policy.MaxIssuesPerFile {
+		// Keep the most severe and confident issues, then the first in the file
+		sort.SliceStable(kept, func(i, j int) bool {
+			if a, b := severityLevels[kept[i].Severity], severityLevels[kept[j].Severity]; a != b {
+				return a > b
+			}
+			if kept[i].Confidence != kept[j].Confidence {
+				return kept[i].Confidence > kept[j].Confidence
+			}
+			return kept[i].StartLine < kept[j].StartLine
+		})
+		dropped = append(dropped, kept[policy.MaxIssuesPerFile:]...)
+		kept = kept[:policy.MaxIssuesPerFile]
+		sort.SliceStable(kept, func(i, j int) bool { return kept[i].StartLine < kept[j].StartLine })
+	}
+	return kept, dropped
+}
+
+// allowed whether an issue passes the rule, confidence and severity filters of a policy
+func allowed(policy config.IssuePolicy, issue types.Issue) bool {
+	if len(policy.EnabledRules) > 0 && !matchAny(policy.EnabledRules, issue.RuleID) {
+		return false
+	}
+	if matchAny(policy.DisabledRules, issue.RuleID) {
+		return false
+	}
+	if issue.Confidence < policy.MinConfidence {
+		return false
+	}
+	// Unknown severities are only compared when both are known
+	minimum, known := severityLevels[policy.MinSeverity]
+	if level, ok := severityLevels[issue.Severity]; known && ok && level < minimum {
+		return false
+	}
+	return true
+}
+
+// matchAnyOf whether any of the values matches any of the globs
+func matchAnyOf(globs, values []string) bool {
+	for _, value := range values {
+		if matchAny(globs, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny whether the value matches any of the globs
+func matchAny(globs []string, value string) bool {
+	for _, glob := range globs {
+		if utils.MatchGlob(glob, value) {
+			return true
+		}
+	}
+	return false
+}
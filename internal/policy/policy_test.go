@@ -0,0 +1,67 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func TestForTask(t *testing.T) {
+	policies := []config.IssuePolicy{
+		{Name: "all"},
+		{Name: "services", Codebases: []string{"services/**"}},
+		{Name: "hotfix", Refs: []string{"hotfix/*"}},
+	}
+	names := func(set *Set) []string {
+		var names []string
+		for _, policy := range set.policies {
+			names = append(names, policy.Name)
+		}
+		return names
+	}
+
+	if got := names(ForTask(policies, "services/api", []types.Target{{Type: "file", FilePath: "a.go"}})); len(got) != 2 || got[1] != "services" {
+		t.Errorf("Expected all and services, got %v", got)
+	}
+	hotfix := []types.Target{{Type: "diff", BaseRef: "main", HeadRef: "hotfix/1.2"}}
+	if got := names(ForTask(policies, "web", hotfix)); len(got) != 2 || got[1] != "hotfix" {
+		t.Errorf("Expected all and hotfix, got %v", got)
+	}
+}
+
+func TestSet_Apply(t *testing.T) {
+	issue := func(ruleID, severity string, confidence, line int) types.Issue {
+		return types.Issue{RuleID: ruleID, Severity: severity, Confidence: confidence, StartLine: line, EndLine: line}
+	}
+	set := ForTask([]config.IssuePolicy{
+		{
+			SeverityMap:   map[string]string{types.SeverityMiddle: types.SeverityLow},
+			MinConfidence: 60,
+			DisabledRules: []string{"todo-comment"},
+		},
+		{Paths: []string{"legacy/**"}, MinSeverity: types.SeverityMiddle},
+		{Paths: []string{"**/*.go"}, EnabledRules: []string{"llm/*", "debug-print"}, MaxIssuesPerFile: 2},
+	}, "", nil)
+
+	kept, dropped := set.Apply("legacy/main.go", []types.Issue{
+		issue("llm/avoid-panic", types.SeverityMiddle, 70, 1), // Remapped to low, below middle
+		issue("llm/hardcoded", types.SeverityHigh, 90, 2),     // Kept
+		issue("llm/unused", types.SeverityHigh, 50, 3),        // Below confidence
+		issue("todo-comment", types.SeverityHigh, 100, 4),     // Disabled
+		issue("debug-print", types.SeverityHigh, 80, 5),       // Over the cap, least confident
+		issue("long-function", types.SeverityHigh, 100, 6),    // Not enabled for Go files
+		issue("llm/sql-injection", types.SeverityHigh, 95, 7), // Kept
+	})
+	if len(kept) != 2 || kept[0].StartLine != 2 || kept[1].StartLine != 7 {
+		t.Errorf("Expected lines 2 and 7 kept, got %+v", kept)
+	}
+	if len(dropped) != 5 {
+		t.Errorf("Expected 5 dropped issues, got %d", len(dropped))
+	}
+
+	kept, _ = set.Apply("app/main.py", []types.Issue{issue("long-function", types.SeverityMiddle, 80, 1)})
+	if len(kept) != 1 || kept[0].Severity != types.SeverityLow {
+		t.Errorf("Expected remapped issue kept outside the path policies, got %+v", kept)
+	}
+}
@@ -0,0 +1,515 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// memoryStore in-memory storage shared by the memory adapters of all repositories
+type memoryStore struct {
+	mu         sync.RWMutex
+	nextID     uint
+	tasks      map[string]*model.ReviewTask
+	taskIssues map[string][]*model.ReviewIssue
+	histories  map[string][]*model.ReviewIssueHistory // By issue ID
+	webhooks   map[string]*model.Webhook
+	deliveries map[string]*model.WebhookDelivery
+	prompts    map[string][]*model.PromptTemplate // Versions by name, oldest first
+}
+
+var defaultMemoryStore = newMemoryStore()
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		tasks:      make(map[string]*model.ReviewTask),
+		taskIssues: make(map[string][]*model.ReviewIssue),
+		histories:  make(map[string][]*model.ReviewIssueHistory),
+		webhooks:   make(map[string]*model.Webhook),
+		deliveries: make(map[string]*model.WebhookDelivery),
+		prompts:    make(map[string][]*model.PromptTemplate),
+	}
+}
+
+// memoryReviewTaskRepository memory adapter of ReviewTaskRepository
+type memoryReviewTaskRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryReviewTaskRepository) Create(ctx context.Context, task *model.ReviewTask) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextID++
+	task.ID = r.store.nextID
+	task.CreatedAt = time.Now()
+	task.UpdatedAt = task.CreatedAt
+	stored := *task
+	r.store.tasks[task.ReviewTaskID] = &stored
+	return nil
+}
+
+func (r *memoryReviewTaskRepository) GetByReviewTaskID(ctx context.Context, reviewTaskID string) (*model.ReviewTask, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	task, ok := r.store.tasks[reviewTaskID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	result := *task
+	return &result, nil
+}
+
+func (r *memoryReviewTaskRepository) Update(ctx context.Context, task *model.ReviewTask) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.tasks[task.ReviewTaskID]; !ok {
+		return ErrNotFound
+	}
+	task.UpdatedAt = time.Now()
+	stored := *task
+	r.store.tasks[task.ReviewTaskID] = &stored
+	return nil
+}
+
+func (r *memoryReviewTaskRepository) UpdateIfStatus(ctx context.Context, task *model.ReviewTask, status string) (bool, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.tasks[task.ReviewTaskID]
+	if !ok {
+		return false, ErrNotFound
+	}
+	if stored.Status != status {
+		return false, nil
+	}
+	task.UpdatedAt = time.Now()
+	updated := *task
+	r.store.tasks[task.ReviewTaskID] = &updated
+	return true, nil
+}
+
+func (r *memoryReviewTaskRepository) GetPreviousDone(ctx context.Context, codebasePath string, beforeID uint) (*model.ReviewTask, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var previous *model.ReviewTask
+	for _, task := range r.store.tasks {
+		if task.CodebasePath != codebasePath || task.Status != types.ReviewTaskStatusDone || task.ID >= beforeID {
+			continue
+		}
+		if previous == nil || task.ID > previous.ID {
+			previous = task
+		}
+	}
+	if previous == nil {
+		return nil, ErrNotFound
+	}
+	result := *previous
+	return &result, nil
+}
+
+// memoryReviewIssueRepository memory adapter of ReviewIssueRepository
+type memoryReviewIssueRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryReviewIssueRepository) CreateBatch(ctx context.Context, issues []*model.ReviewIssue) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for _, issue := range issues {
+		r.store.nextID++
+		issue.ID = r.store.nextID
+		issue.CreatedAt = now
+		issue.UpdatedAt = now
+		stored := *issue
+		r.store.taskIssues[issue.ReviewTaskID] = append(r.store.taskIssues[issue.ReviewTaskID], &stored)
+	}
+	return nil
+}
+
+func (r *memoryReviewIssueRepository) ListByReviewTask(ctx context.Context, reviewTaskID string, offset, limit int) ([]*model.ReviewIssue, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	all := r.store.taskIssues[reviewTaskID]
+	if offset >= len(all) {
+		return []*model.ReviewIssue{}, nil
+	}
+	end := len(all)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	issues := make([]*model.ReviewIssue, 0, end-offset)
+	for _, issue := range all[offset:end] {
+		copied := *issue
+		issues = append(issues, &copied)
+	}
+	return issues, nil
+}
+
+func (r *memoryReviewIssueRepository) CountByReviewTask(ctx context.Context, reviewTaskID string) (int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	return int64(len(r.store.taskIssues[reviewTaskID])), nil
+}
+
+func (r *memoryReviewIssueRepository) ListPreviousByFiles(ctx context.Context, codebasePath string, beforeID uint, filePaths []string) ([]*model.ReviewIssue, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(filePaths))
+	for _, filePath := range filePaths {
+		wanted[filePath] = true
+	}
+	var tasks []*model.ReviewTask
+	for _, task := range r.store.tasks {
+		if task.CodebasePath == codebasePath && task.Status == types.ReviewTaskStatusDone && task.ID < beforeID {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID > tasks[j].ID })
+
+	issues := []*model.ReviewIssue{}
+	for _, task := range tasks {
+		for _, issue := range r.store.taskIssues[task.ReviewTaskID] {
+			if wanted[issue.FilePath] {
+				copied := *issue
+				issues = append(issues, &copied)
+			}
+		}
+	}
+	return issues, nil
+}
+
+func (r *memoryReviewIssueRepository) GetByIssueID(ctx context.Context, issueID string) (*model.ReviewIssue, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var latest *model.ReviewIssue
+	for _, issues := range r.store.taskIssues {
+		for _, issue := range issues {
+			if issue.IssueID == issueID && (latest == nil || issue.ID > latest.ID) {
+				latest = issue
+			}
+		}
+	}
+	if latest == nil {
+		return nil, ErrNotFound
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (r *memoryReviewIssueRepository) List(ctx context.Context, filter types.IssueFilter, offset, limit int) ([]*model.ReviewIssue, int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var matched []*model.ReviewIssue
+	for _, issues := range r.store.taskIssues {
+		for _, issue := range issues {
+			if matchIssueFilter(filter, issue) {
+				matched = append(matched, issue)
+			}
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID < matched[j].ID })
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return []*model.ReviewIssue{}, total, nil
+	}
+	end := len(matched)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	issues := make([]*model.ReviewIssue, 0, end-offset)
+	for _, issue := range matched[offset:end] {
+		copied := *issue
+		issues = append(issues, &copied)
+	}
+	return issues, total, nil
+}
+
+func (r *memoryReviewIssueRepository) Update(ctx context.Context, issue *model.ReviewIssue) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for i, stored := range r.store.taskIssues[issue.ReviewTaskID] {
+		if stored.ID == issue.ID {
+			issue.UpdatedAt = time.Now()
+			copied := *issue
+			r.store.taskIssues[issue.ReviewTaskID][i] = &copied
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+// memoryReviewIssueHistoryRepository memory adapter of ReviewIssueHistoryRepository
+type memoryReviewIssueHistoryRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryReviewIssueHistoryRepository) Create(ctx context.Context, history *model.ReviewIssueHistory) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextID++
+	history.ID = r.store.nextID
+	history.CreatedAt = time.Now()
+	stored := *history
+	r.store.histories[history.IssueID] = append(r.store.histories[history.IssueID], &stored)
+	return nil
+}
+
+func (r *memoryReviewIssueHistoryRepository) ListByIssueID(ctx context.Context, issueID string) ([]*model.ReviewIssueHistory, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	histories := make([]*model.ReviewIssueHistory, 0, len(r.store.histories[issueID]))
+	for _, history := range r.store.histories[issueID] {
+		copied := *history
+		histories = append(histories, &copied)
+	}
+	return histories, nil
+}
+
+// memoryWebhookRepository memory adapter of WebhookRepository
+type memoryWebhookRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextID++
+	webhook.ID = r.store.nextID
+	webhook.CreatedAt = time.Now()
+	webhook.UpdatedAt = webhook.CreatedAt
+	stored := *webhook
+	r.store.webhooks[webhook.WebhookID] = &stored
+	return nil
+}
+
+func (r *memoryWebhookRepository) GetByWebhookID(ctx context.Context, webhookID string) (*model.Webhook, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	webhook, ok := r.store.webhooks[webhookID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *webhook
+	return &copied, nil
+}
+
+func (r *memoryWebhookRepository) List(ctx context.Context) ([]*model.Webhook, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	webhooks := make([]*model.Webhook, 0, len(r.store.webhooks))
+	for _, webhook := range r.store.webhooks {
+		copied := *webhook
+		webhooks = append(webhooks, &copied)
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].ID < webhooks[j].ID })
+	return webhooks, nil
+}
+
+func (r *memoryWebhookRepository) Update(ctx context.Context, webhook *model.Webhook) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.webhooks[webhook.WebhookID]; !ok {
+		return ErrNotFound
+	}
+	webhook.UpdatedAt = time.Now()
+	stored := *webhook
+	r.store.webhooks[webhook.WebhookID] = &stored
+	return nil
+}
+
+func (r *memoryWebhookRepository) Delete(ctx context.Context, webhookID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.webhooks[webhookID]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.webhooks, webhookID)
+	return nil
+}
+
+// memoryWebhookDeliveryRepository memory adapter of WebhookDeliveryRepository
+type memoryWebhookDeliveryRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryWebhookDeliveryRepository) Create(ctx context.Context, delivery *model.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextID++
+	delivery.ID = r.store.nextID
+	delivery.CreatedAt = time.Now()
+	stored := *delivery
+	r.store.deliveries[delivery.DeliveryID] = &stored
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) GetByDeliveryID(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	delivery, ok := r.store.deliveries[deliveryID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *delivery
+	return &copied, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) Update(ctx context.Context, delivery *model.WebhookDelivery) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.deliveries[delivery.DeliveryID]; !ok {
+		return ErrNotFound
+	}
+	stored := *delivery
+	r.store.deliveries[delivery.DeliveryID] = &stored
+	return nil
+}
+
+func (r *memoryWebhookDeliveryRepository) ListByWebhookID(ctx context.Context, webhookID string, offset, limit int) ([]*model.WebhookDelivery, int64, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	var matched []*model.WebhookDelivery
+	for _, delivery := range r.store.deliveries {
+		if delivery.WebhookID == webhookID {
+			matched = append(matched, delivery)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].ID > matched[j].ID })
+
+	total := int64(len(matched))
+	if offset >= len(matched) {
+		return []*model.WebhookDelivery{}, total, nil
+	}
+	end := len(matched)
+	if limit > 0 && offset+limit < end {
+		end = offset + limit
+	}
+
+	deliveries := make([]*model.WebhookDelivery, 0, end-offset)
+	for _, delivery := range matched[offset:end] {
+		copied := *delivery
+		deliveries = append(deliveries, &copied)
+	}
+	return deliveries, total, nil
+}
+
+func (r *memoryWebhookDeliveryRepository) DeleteByWebhookID(ctx context.Context, webhookID string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	for deliveryID, delivery := range r.store.deliveries {
+		if delivery.WebhookID == webhookID {
+			delete(r.store.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// memoryPromptTemplateRepository memory adapter of PromptTemplateRepository
+type memoryPromptTemplateRepository struct {
+	store *memoryStore
+}
+
+func (r *memoryPromptTemplateRepository) Create(ctx context.Context, template *model.PromptTemplate) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	r.store.nextID++
+	template.ID = r.store.nextID
+	template.CreatedAt = time.Now()
+	stored := *template
+	r.store.prompts[template.Name] = append(r.store.prompts[template.Name], &stored)
+	return nil
+}
+
+func (r *memoryPromptTemplateRepository) GetLatest(ctx context.Context, name string) (*model.PromptTemplate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	versions := r.store.prompts[name]
+	if len(versions) == 0 {
+		return nil, ErrNotFound
+	}
+	copied := *versions[len(versions)-1]
+	return &copied, nil
+}
+
+func (r *memoryPromptTemplateRepository) GetVersion(ctx context.Context, name string, version int) (*model.PromptTemplate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	for _, template := range r.store.prompts[name] {
+		if template.Version == version {
+			copied := *template
+			return &copied, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r *memoryPromptTemplateRepository) ListLatest(ctx context.Context) ([]*model.PromptTemplate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	templates := make([]*model.PromptTemplate, 0, len(r.store.prompts))
+	for _, versions := range r.store.prompts {
+		copied := *versions[len(versions)-1]
+		templates = append(templates, &copied)
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name < templates[j].Name })
+	return templates, nil
+}
+
+func (r *memoryPromptTemplateRepository) ListVersions(ctx context.Context, name string) ([]*model.PromptTemplate, error) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+
+	versions := r.store.prompts[name]
+	templates := make([]*model.PromptTemplate, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		copied := *versions[i]
+		templates = append(templates, &copied)
+	}
+	return templates, nil
+}
+
+func (r *memoryPromptTemplateRepository) Delete(ctx context.Context, name string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if _, ok := r.store.prompts[name]; !ok {
+		return ErrNotFound
+	}
+	delete(r.store.prompts, name)
+	return nil
+}
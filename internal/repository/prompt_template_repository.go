@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"gorm.io/gorm"
+)
+
+// PromptTemplateRepository data access of prompt template versions
+type PromptTemplateRepository interface {
+	// Create persist a new version of a template
+	Create(ctx context.Context, template *model.PromptTemplate) error
+	// GetLatest get the latest version of a template
+	GetLatest(ctx context.Context, name string) (*model.PromptTemplate, error)
+	// GetVersion get a version of a template
+	GetVersion(ctx context.Context, name string, version int) (*model.PromptTemplate, error)
+	// ListLatest list the latest version of every template, by name
+	ListLatest(ctx context.Context) ([]*model.PromptTemplate, error)
+	// ListVersions list all versions of a template, newest first
+	ListVersions(ctx context.Context, name string) ([]*model.PromptTemplate, error)
+	// Delete remove all versions of a template
+	Delete(ctx context.Context, name string) error
+}
+
+// NewPromptTemplateRepository create prompt template repository backed by the database, or memory when the database is disabled
+func NewPromptTemplateRepository() PromptTemplateRepository {
+	if useDatabase() {
+		return &gormPromptTemplateRepository{db: db.DB}
+	}
+	return &memoryPromptTemplateRepository{store: defaultMemoryStore}
+}
+
+// gormPromptTemplateRepository database adapter of PromptTemplateRepository
+type gormPromptTemplateRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormPromptTemplateRepository) Create(ctx context.Context, template *model.PromptTemplate) error {
+	return r.db.WithContext(ctx).Create(template).Error
+}
+
+func (r *gormPromptTemplateRepository) GetLatest(ctx context.Context, name string) (*model.PromptTemplate, error) {
+	var template model.PromptTemplate
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Order("version DESC").First(&template).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &template, nil
+}
+
+func (r *gormPromptTemplateRepository) GetVersion(ctx context.Context, name string, version int) (*model.PromptTemplate, error) {
+	var template model.PromptTemplate
+	if err := r.db.WithContext(ctx).Where("name = ? AND version = ?", name, version).First(&template).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &template, nil
+}
+
+func (r *gormPromptTemplateRepository) ListLatest(ctx context.Context) ([]*model.PromptTemplate, error) {
+	latest := r.db.Model(&model.PromptTemplate{}).Select("name, MAX(version) AS version").Group("name")
+	var templates []*model.PromptTemplate
+	err := r.db.WithContext(ctx).
+		Joins("JOIN (?) AS latest ON latest.name = prompt_template.name AND latest.version = prompt_template.version", latest).
+		Order("prompt_template.name ASC").
+		Find(&templates).Error
+	if err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *gormPromptTemplateRepository) ListVersions(ctx context.Context, name string) ([]*model.PromptTemplate, error) {
+	var templates []*model.PromptTemplate
+	if err := r.db.WithContext(ctx).Where("name = ?", name).Order("version DESC").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+func (r *gormPromptTemplateRepository) Delete(ctx context.Context, name string) error {
+	result := r.db.WithContext(ctx).Where("name = ?", name).Delete(&model.PromptTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound record not found in the underlying storage
+var ErrNotFound = errors.New("record not found")
+
+// useDatabase whether repositories should use the database adapter.
+// When the database is disabled, repositories fall back to the in-memory adapter.
+func useDatabase() bool {
+	return db.DB != nil
+}
+
+// wrapGormError convert gorm errors into repository errors
+func wrapGormError(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"gorm.io/gorm"
+)
+
+// ReviewIssueHistoryRepository data access of issue triage history
+type ReviewIssueHistoryRepository interface {
+	// Create persist a history entry
+	Create(ctx context.Context, history *model.ReviewIssueHistory) error
+	// ListByIssueID list history entries of an issue, oldest first
+	ListByIssueID(ctx context.Context, issueID string) ([]*model.ReviewIssueHistory, error)
+}
+
+// NewReviewIssueHistoryRepository create issue history repository backed by the database, or memory when the database is disabled
+func NewReviewIssueHistoryRepository() ReviewIssueHistoryRepository {
+	if useDatabase() {
+		return &gormReviewIssueHistoryRepository{db: db.DB}
+	}
+	return &memoryReviewIssueHistoryRepository{store: defaultMemoryStore}
+}
+
+// gormReviewIssueHistoryRepository database adapter of ReviewIssueHistoryRepository
+type gormReviewIssueHistoryRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReviewIssueHistoryRepository) Create(ctx context.Context, history *model.ReviewIssueHistory) error {
+	return r.db.WithContext(ctx).Create(history).Error
+}
+
+func (r *gormReviewIssueHistoryRepository) ListByIssueID(ctx context.Context, issueID string) ([]*model.ReviewIssueHistory, error) {
+	var histories []*model.ReviewIssueHistory
+	if err := r.db.WithContext(ctx).Where("issue_id = ?", issueID).Order("id ASC").Find(&histories).Error; err != nil {
+		return nil, err
+	}
+	return histories, nil
+}
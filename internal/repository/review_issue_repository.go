@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"gorm.io/gorm"
+)
+
+// ReviewIssueRepository data access of review issues
+type ReviewIssueRepository interface {
+	// CreateBatch persist issues in the given order
+	CreateBatch(ctx context.Context, issues []*model.ReviewIssue) error
+	// ListByReviewTask list issues of a review task in creation order, limit <= 0 means no limit
+	ListByReviewTask(ctx context.Context, reviewTaskID string, offset, limit int) ([]*model.ReviewIssue, error)
+	// CountByReviewTask count issues of a review task
+	CountByReviewTask(ctx context.Context, reviewTaskID string) (int64, error)
+	// ListPreviousByFiles list the issues of the files stored by the done review tasks of the codebase created before
+	// the task with beforeID, latest task first
+	ListPreviousByFiles(ctx context.Context, codebasePath string, beforeID uint, filePaths []string) ([]*model.ReviewIssue, error)
+	// GetByIssueID get the latest review of an issue by issue ID, ErrNotFound when missing
+	GetByIssueID(ctx context.Context, issueID string) (*model.ReviewIssue, error)
+	// List list issues matching the filter in creation order with the total count, limit <= 0 means no limit
+	List(ctx context.Context, filter types.IssueFilter, offset, limit int) ([]*model.ReviewIssue, int64, error)
+	// Update save all fields of an existing issue
+	Update(ctx context.Context, issue *model.ReviewIssue) error
+}
+
+// matchIssueFilter whether the issue matches the filter
+func matchIssueFilter(f types.IssueFilter, issue *model.ReviewIssue) bool {
+	return (f.ReviewTaskID == "" || issue.ReviewTaskID == f.ReviewTaskID) &&
+		(f.Status == nil || issue.Status == *f.Status) &&
+		(f.Severity == "" || issue.Severity == f.Severity) &&
+		(f.RuleID == "" || issue.RuleID == f.RuleID) &&
+		(f.FilePath == "" || issue.FilePath == f.FilePath) &&
+		(f.Assignee == "" || issue.Assignee == f.Assignee) &&
+		(f.Change == "" || issue.Change == f.Change)
+}
+
+// applyIssueFilter add the filter conditions to a query
+func applyIssueFilter(query *gorm.DB, f types.IssueFilter) *gorm.DB {
+	conditions := map[string]interface{}{}
+	if f.ReviewTaskID != "" {
+		conditions["review_task_id"] = f.ReviewTaskID
+	}
+	if f.Status != nil {
+		conditions["status"] = *f.Status
+	}
+	if f.Severity != "" {
+		conditions["severity"] = f.Severity
+	}
+	if f.RuleID != "" {
+		conditions["rule_id"] = f.RuleID
+	}
+	if f.FilePath != "" {
+		conditions["file_path"] = f.FilePath
+	}
+	if f.Assignee != "" {
+		conditions["assignee"] = f.Assignee
+	}
+	if f.Change != "" {
+		conditions["change"] = f.Change
+	}
+	return query.Where(conditions)
+}
+
+// NewReviewIssueRepository create review issue repository backed by the database, or memory when the database is disabled
+func NewReviewIssueRepository() ReviewIssueRepository {
+	if useDatabase() {
+		return &gormReviewIssueRepository{db: db.DB}
+	}
+	return &memoryReviewIssueRepository{store: defaultMemoryStore}
+}
+
+// gormReviewIssueRepository database adapter of ReviewIssueRepository
+type gormReviewIssueRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReviewIssueRepository) CreateBatch(ctx context.Context, issues []*model.ReviewIssue) error {
+	if len(issues) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(issues).Error
+}
+
+func (r *gormReviewIssueRepository) ListByReviewTask(ctx context.Context, reviewTaskID string, offset, limit int) ([]*model.ReviewIssue, error) {
+	var issues []*model.ReviewIssue
+	query := r.db.WithContext(ctx).Where("review_task_id = ?", reviewTaskID).Order("id ASC").Offset(offset)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&issues).Error; err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+func (r *gormReviewIssueRepository) CountByReviewTask(ctx context.Context, reviewTaskID string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.ReviewIssue{}).Where("review_task_id = ?", reviewTaskID).Count(&count).Error
+	return count, err
+}
+
+func (r *gormReviewIssueRepository) ListPreviousByFiles(ctx context.Context, codebasePath string, beforeID uint, filePaths []string) ([]*model.ReviewIssue, error) {
+	var issues []*model.ReviewIssue
+	if len(filePaths) == 0 {
+		return issues, nil
+	}
+	err := r.db.WithContext(ctx).
+		Joins("JOIN review_tasks ON review_tasks.review_task_id = review_issues.review_task_id").
+		Where("review_tasks.codebase_path = ? AND review_tasks.status = ? AND review_tasks.id < ?", codebasePath, types.ReviewTaskStatusDone, beforeID).
+		Where("review_issues.file_path IN ?", filePaths).
+		Order("review_tasks.id DESC, review_issues.id ASC").
+		Find(&issues).Error
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+func (r *gormReviewIssueRepository) GetByIssueID(ctx context.Context, issueID string) (*model.ReviewIssue, error) {
+	var issue model.ReviewIssue
+	if err := r.db.WithContext(ctx).Where("issue_id = ?", issueID).Order("id DESC").First(&issue).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &issue, nil
+}
+
+func (r *gormReviewIssueRepository) List(ctx context.Context, filter types.IssueFilter, offset, limit int) ([]*model.ReviewIssue, int64, error) {
+	var total int64
+	if err := applyIssueFilter(r.db.WithContext(ctx).Model(&model.ReviewIssue{}), filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var issues []*model.ReviewIssue
+	query := applyIssueFilter(r.db.WithContext(ctx), filter).Order("id ASC").Offset(offset)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	if err := query.Find(&issues).Error; err != nil {
+		return nil, 0, err
+	}
+	return issues, total, nil
+}
+
+func (r *gormReviewIssueRepository) Update(ctx context.Context, issue *model.ReviewIssue) error {
+	return r.db.WithContext(ctx).Save(issue).Error
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"gorm.io/gorm"
+)
+
+// ReviewTaskRepository data access of review tasks
+type ReviewTaskRepository interface {
+	// Create persist a new review task
+	Create(ctx context.Context, task *model.ReviewTask) error
+	// GetByReviewTaskID get review task by its public ID
+	GetByReviewTaskID(ctx context.Context, reviewTaskID string) (*model.ReviewTask, error)
+	// Update save all fields of an existing review task
+	Update(ctx context.Context, task *model.ReviewTask) error
+	// UpdateIfStatus save all fields of an existing review task only while its stored status is status,
+	// false when the status changed in the meantime
+	UpdateIfStatus(ctx context.Context, task *model.ReviewTask, status string) (bool, error)
+	// GetPreviousDone get the latest done review task of the codebase created before the task with beforeID, ErrNotFound when missing
+	GetPreviousDone(ctx context.Context, codebasePath string, beforeID uint) (*model.ReviewTask, error)
+}
+
+// NewReviewTaskRepository create review task repository backed by the database, or memory when the database is disabled
+func NewReviewTaskRepository() ReviewTaskRepository {
+	if useDatabase() {
+		return &gormReviewTaskRepository{db: db.DB}
+	}
+	return &memoryReviewTaskRepository{store: defaultMemoryStore}
+}
+
+// gormReviewTaskRepository database adapter of ReviewTaskRepository
+type gormReviewTaskRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormReviewTaskRepository) Create(ctx context.Context, task *model.ReviewTask) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+func (r *gormReviewTaskRepository) GetByReviewTaskID(ctx context.Context, reviewTaskID string) (*model.ReviewTask, error) {
+	var task model.ReviewTask
+	if err := r.db.WithContext(ctx).Where("review_task_id = ?", reviewTaskID).First(&task).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &task, nil
+}
+
+func (r *gormReviewTaskRepository) Update(ctx context.Context, task *model.ReviewTask) error {
+	return r.db.WithContext(ctx).Save(task).Error
+}
+
+func (r *gormReviewTaskRepository) UpdateIfStatus(ctx context.Context, task *model.ReviewTask, status string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(task).Where("status = ?", status).Select("*").Updates(task)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormReviewTaskRepository) GetPreviousDone(ctx context.Context, codebasePath string, beforeID uint) (*model.ReviewTask, error) {
+	var task model.ReviewTask
+	err := r.db.WithContext(ctx).
+		Where("codebase_path = ? AND status = ? AND id < ?", codebasePath, types.ReviewTaskStatusDone, beforeID).
+		Order("id DESC").
+		First(&task).Error
+	if err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &task, nil
+}
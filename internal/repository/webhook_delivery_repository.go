@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"gorm.io/gorm"
+)
+
+// WebhookDeliveryRepository data access of webhook deliveries
+type WebhookDeliveryRepository interface {
+	// Create persist a new delivery
+	Create(ctx context.Context, delivery *model.WebhookDelivery) error
+	// GetByDeliveryID get delivery by its business ID
+	GetByDeliveryID(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error)
+	// Update save all fields of an existing delivery
+	Update(ctx context.Context, delivery *model.WebhookDelivery) error
+	// ListByWebhookID list deliveries of a webhook, newest first, and their total count
+	ListByWebhookID(ctx context.Context, webhookID string, offset, limit int) ([]*model.WebhookDelivery, int64, error)
+	// DeleteByWebhookID remove the deliveries of a webhook
+	DeleteByWebhookID(ctx context.Context, webhookID string) error
+}
+
+// NewWebhookDeliveryRepository create webhook delivery repository backed by the database, or memory when the database is disabled
+func NewWebhookDeliveryRepository() WebhookDeliveryRepository {
+	if useDatabase() {
+		return &gormWebhookDeliveryRepository{db: db.DB}
+	}
+	return &memoryWebhookDeliveryRepository{store: defaultMemoryStore}
+}
+
+// gormWebhookDeliveryRepository database adapter of WebhookDeliveryRepository
+type gormWebhookDeliveryRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormWebhookDeliveryRepository) Create(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Create(delivery).Error
+}
+
+func (r *gormWebhookDeliveryRepository) GetByDeliveryID(ctx context.Context, deliveryID string) (*model.WebhookDelivery, error) {
+	var delivery model.WebhookDelivery
+	if err := r.db.WithContext(ctx).Where("delivery_id = ?", deliveryID).First(&delivery).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &delivery, nil
+}
+
+func (r *gormWebhookDeliveryRepository) Update(ctx context.Context, delivery *model.WebhookDelivery) error {
+	return r.db.WithContext(ctx).Save(delivery).Error
+}
+
+func (r *gormWebhookDeliveryRepository) ListByWebhookID(ctx context.Context, webhookID string, offset, limit int) ([]*model.WebhookDelivery, int64, error) {
+	query := r.db.WithContext(ctx).Model(&model.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var deliveries []*model.WebhookDelivery
+	if err := query.Order("id DESC").Offset(offset).Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, 0, err
+	}
+	return deliveries, total, nil
+}
+
+func (r *gormWebhookDeliveryRepository) DeleteByWebhookID(ctx context.Context, webhookID string) error {
+	return r.db.WithContext(ctx).Where("webhook_id = ?", webhookID).Delete(&model.WebhookDelivery{}).Error
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/db"
+	"gorm.io/gorm"
+)
+
+// WebhookRepository data access of webhooks
+type WebhookRepository interface {
+	// Create persist a new webhook
+	Create(ctx context.Context, webhook *model.Webhook) error
+	// GetByWebhookID get webhook by its business ID
+	GetByWebhookID(ctx context.Context, webhookID string) (*model.Webhook, error)
+	// List list webhooks, oldest first
+	List(ctx context.Context) ([]*model.Webhook, error)
+	// Update save all fields of an existing webhook
+	Update(ctx context.Context, webhook *model.Webhook) error
+	// Delete remove a webhook
+	Delete(ctx context.Context, webhookID string) error
+}
+
+// NewWebhookRepository create webhook repository backed by the database, or memory when the database is disabled
+func NewWebhookRepository() WebhookRepository {
+	if useDatabase() {
+		return &gormWebhookRepository{db: db.DB}
+	}
+	return &memoryWebhookRepository{store: defaultMemoryStore}
+}
+
+// gormWebhookRepository database adapter of WebhookRepository
+type gormWebhookRepository struct {
+	db *gorm.DB
+}
+
+func (r *gormWebhookRepository) Create(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Create(webhook).Error
+}
+
+func (r *gormWebhookRepository) GetByWebhookID(ctx context.Context, webhookID string) (*model.Webhook, error) {
+	var webhook model.Webhook
+	if err := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID).First(&webhook).Error; err != nil {
+		return nil, wrapGormError(err)
+	}
+	return &webhook, nil
+}
+
+func (r *gormWebhookRepository) List(ctx context.Context) ([]*model.Webhook, error) {
+	var webhooks []*model.Webhook
+	if err := r.db.WithContext(ctx).Order("id ASC").Find(&webhooks).Error; err != nil {
+		return nil, err
+	}
+	return webhooks, nil
+}
+
+func (r *gormWebhookRepository) Update(ctx context.Context, webhook *model.Webhook) error {
+	return r.db.WithContext(ctx).Save(webhook).Error
+}
+
+func (r *gormWebhookRepository) Delete(ctx context.Context, webhookID string) error {
+	result := r.db.WithContext(ctx).Where("webhook_id = ?", webhookID).Delete(&model.Webhook{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// baselineVersion version of the baseline file format
+const baselineVersion = 1
+
+// ExportBaseline generate a baseline of the issues found by a review task, to be filtered out of later reviews
+func (s *ReviewTaskService) ExportBaseline(ctx context.Context, reviewTaskID string) (*types.Baseline, error) {
+	issues, err := s.ListIssues(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	baseline := &types.Baseline{
+		Version:      baselineVersion,
+		ReviewTaskID: reviewTaskID,
+		GeneratedAt:  utils.FormatTime(time.Now(), ""),
+		Issues:       make([]types.BaselineIssue, 0, len(issues)),
+	}
+	for _, issue := range issues {
+		baseline.Issues = append(baseline.Issues, types.BaselineIssue{
+			Fingerprint: issue.Fingerprint,
+			RuleID:      issue.RuleID,
+			FilePath:    issue.FilePath,
+			StartLine:   issue.StartLine,
+		})
+	}
+	return baseline, nil
+}
+
+// loadBaseline fingerprints of the known issues of a task: the baseline given at creation,
+// otherwise the baseline file of the codebase when it exists
+func loadBaseline(task *model.ReviewTask) (map[string]bool, error) {
+	fingerprints := task.Baseline
+	if fingerprints == nil {
+		fromFile, err := readBaselineFile(task.RootPath)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = fromFile
+	}
+
+	baseline := make(map[string]bool, len(fingerprints))
+	for _, fingerprint := range fingerprints {
+		baseline[fingerprint] = true
+	}
+	return baseline, nil
+}
+
+// readBaselineFile read the configured baseline file of the codebase, nil when not configured or missing
+func readBaselineFile(rootPath string) ([]string, error) {
+	baselineFile := config.GetConfig().Review.BaselineFile
+	if baselineFile == "" {
+		return nil, nil
+	}
+	fullPath, err := resolveCodebasePath(rootPath, baselineFile)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(fullPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline types.Baseline
+	if err := json.Unmarshal(data, &baseline); err != nil {
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("review_task.invalid_baseline", "", map[string]interface{}{"path": baselineFile}), err)
+	}
+	return baselineFingerprints(&baseline), nil
+}
+
+// baselineFingerprints fingerprints of the baseline issues, never nil so that an empty baseline is kept
+func baselineFingerprints(baseline *types.Baseline) []string {
+	fingerprints := make([]string, 0, len(baseline.Issues))
+	for _, issue := range baseline.Issues {
+		if issue.Fingerprint != "" {
+			fingerprints = append(fingerprints, issue.Fingerprint)
+		}
+	}
+	return fingerprints
+}
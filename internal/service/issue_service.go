@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/headerpropagation"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/patch"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// operatorHeader header identifying the user making triage changes
+const operatorHeader = "X-User-ID"
+
+var (
+	// ErrIssueNotFound issue does not exist
+	ErrIssueNotFound = errors.New("issue not found")
+	// ErrFixNotAvailable issue has no fix patch
+	ErrFixNotAvailable = errors.New("issue has no fix patch")
+	// ErrInvalidIssueStatus unknown issue status
+	ErrInvalidIssueStatus = errors.New("invalid issue status")
+	// ErrIssueStatusTransition issue status cannot move to the requested status
+	ErrIssueStatusTransition = errors.New("issue status transition not allowed")
+	// ErrFixVirtualFile fix of an issue in inline code cannot be written to the codebase
+	ErrFixVirtualFile = errors.New("fix of virtual file cannot be applied")
+)
+
+// IssueUpdate triage change of an issue, nil fields are left unchanged
+type IssueUpdate struct {
+	Status   *int
+	Assignee *string
+	Comment  string
+}
+
+// IssueService review issue business logic: triage, history and fixes
+type IssueService struct {
+	taskRepo    repository.ReviewTaskRepository
+	issueRepo   repository.ReviewIssueRepository
+	historyRepo repository.ReviewIssueHistoryRepository
+}
+
+// NewIssueService create issue service
+func NewIssueService() *IssueService {
+	return &IssueService{
+		taskRepo:    repository.NewReviewTaskRepository(),
+		issueRepo:   repository.NewReviewIssueRepository(),
+		historyRepo: repository.NewReviewIssueHistoryRepository(),
+	}
+}
+
+// ListIssues list issues matching the filter, page starts at 1
+func (s *IssueService) ListIssues(ctx context.Context, filter types.IssueFilter, page, pageSize int) ([]types.Issue, int64, error) {
+	issues, total, err := s.issueRepo.List(ctx, filter, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	list := make([]types.Issue, 0, len(issues))
+	for _, issue := range issues {
+		list = append(list, toIssue(issue))
+	}
+	return list, total, nil
+}
+
+// GetIssue get the latest review of an issue
+func (s *IssueService) GetIssue(ctx context.Context, issueID string) (*types.Issue, error) {
+	issue, err := s.getIssueModel(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+	result := toIssue(issue)
+	return &result, nil
+}
+
+// UpdateIssue change status, assignee or add a comment to the latest review of an issue, recording the change in its history
+func (s *IssueService) UpdateIssue(ctx context.Context, issueID string, update IssueUpdate) (*types.Issue, error) {
+	issue, err := s.getIssueModel(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.updateIssue(ctx, issue, update); err != nil {
+		return nil, err
+	}
+	result := toIssue(issue)
+	return &result, nil
+}
+
+// BatchUpdateStatus move all issues matching the filter to status, skipping issues whose status cannot transition
+func (s *IssueService) BatchUpdateStatus(ctx context.Context, filter types.IssueFilter, status int, comment string) (*types.IssueBatchUpdateResult, error) {
+	if !types.ValidIssueStatus(status) {
+		return nil, ErrInvalidIssueStatus
+	}
+
+	issues, _, err := s.issueRepo.List(ctx, filter, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.IssueBatchUpdateResult{Matched: len(issues), Skipped: []string{}}
+	for _, issue := range issues {
+		if !types.CanTransitionIssueStatus(issue.Status, status) {
+			result.Skipped = append(result.Skipped, issue.IssueID)
+			continue
+		}
+		if issue.Status == status {
+			continue
+		}
+		if err := s.updateIssue(ctx, issue, IssueUpdate{Status: &status, Comment: comment}); err != nil {
+			return nil, err
+		}
+		result.Updated++
+	}
+	return result, nil
+}
+
+// ListHistory list the triage history of an issue, oldest first
+func (s *IssueService) ListHistory(ctx context.Context, issueID string) ([]types.IssueHistory, error) {
+	if _, err := s.getIssueModel(ctx, issueID); err != nil {
+		return nil, err
+	}
+	histories, err := s.historyRepo.ListByIssueID(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]types.IssueHistory, 0, len(histories))
+	for _, history := range histories {
+		list = append(list, types.IssueHistory{
+			IssueID:      history.IssueID,
+			Operator:     history.Operator,
+			FromStatus:   history.FromStatus,
+			ToStatus:     history.ToStatus,
+			FromAssignee: history.FromAssignee,
+			ToAssignee:   history.ToAssignee,
+			Comment:      history.Comment,
+			CreatedAt:    utils.FormatTime(history.CreatedAt, ""),
+		})
+	}
+	return list, nil
+}
+
+// updateIssue validate and save a triage change, then record it in the history
+func (s *IssueService) updateIssue(ctx context.Context, issue *model.ReviewIssue, update IssueUpdate) error {
+	history := &model.ReviewIssueHistory{
+		IssueID:      issue.IssueID,
+		ReviewTaskID: issue.ReviewTaskID,
+		Operator:     headerpropagation.GetHeaderValue(ctx, operatorHeader),
+		FromStatus:   issue.Status,
+		ToStatus:     issue.Status,
+		FromAssignee: issue.Assignee,
+		ToAssignee:   issue.Assignee,
+		Comment:      update.Comment,
+	}
+	if update.Status != nil {
+		if !types.ValidIssueStatus(*update.Status) {
+			return ErrInvalidIssueStatus
+		}
+		if !types.CanTransitionIssueStatus(issue.Status, *update.Status) {
+			return ErrIssueStatusTransition
+		}
+		history.ToStatus = *update.Status
+	}
+	if update.Assignee != nil {
+		history.ToAssignee = *update.Assignee
+	}
+	if history.FromStatus == history.ToStatus && history.FromAssignee == history.ToAssignee && history.Comment == "" {
+		return nil
+	}
+
+	issue.Status = history.ToStatus
+	issue.Assignee = history.ToAssignee
+	if err := s.issueRepo.Update(ctx, issue); err != nil {
+		return fmt.Errorf("%s: %w", i18n.Translate("issue.update_failed", "", nil), err)
+	}
+	if err := s.historyRepo.Create(ctx, history); err != nil {
+		return fmt.Errorf("%s: %w", i18n.Translate("issue.update_failed", "", nil), err)
+	}
+	return nil
+}
+
+// getIssueModel get the latest review of an issue, converting missing records into ErrIssueNotFound
+func (s *IssueService) getIssueModel(ctx context.Context, issueID string) (*model.ReviewIssue, error) {
+	issue, err := s.issueRepo.GetByIssueID(ctx, issueID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrIssueNotFound
+	}
+	return issue, err
+}
+
+// PreviewFix apply the fix patch of an issue to the current file content in memory and return the patched regions.
+// A *patch.ConflictError is returned when the file changed where the patch applies.
+func (s *IssueService) PreviewFix(ctx context.Context, issueID string) (*types.IssueFixResult, error) {
+	fix, err := s.prepareFix(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+	return fix.result, nil
+}
+
+// ApplyFix apply the fix patch of an issue to the workspace file, checking for conflicts first
+func (s *IssueService) ApplyFix(ctx context.Context, issueID string) (*types.IssueFixResult, error) {
+	fix, err := s.prepareFix(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+
+	if fix.fullPath == "" {
+		return nil, ErrFixVirtualFile
+	}
+	info, err := os.Stat(fix.fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(fix.fullPath, []byte(patch.JoinLines(fix.patched, fix.format)), info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("issue.fix_write_failed", "", nil), err)
+	}
+	logger.Info(i18n.Translate("issue.fix_applied", "", nil), "issue_id", issueID, "file", fix.result.FilePath)
+	fix.result.Applied = true
+
+	// An applied fix moves the issue to fixed when its status allows it
+	fixed := types.IssueStatusFixed
+	if types.CanTransitionIssueStatus(fix.issue.Status, fixed) {
+		if err := s.updateIssue(ctx, fix.issue, IssueUpdate{Status: &fixed, Comment: i18n.Translate("issue.fix_applied", "", nil)}); err != nil {
+			logger.Warn(i18n.Translate("issue.update_failed", "", nil), "issue_id", issueID, "error", err)
+		}
+	}
+	return fix.result, nil
+}
+
+// preparedFix fix patch applied in memory to the current file content
+type preparedFix struct {
+	issue    *model.ReviewIssue
+	fullPath string // Empty for virtual files
+	patched  []string
+	format   patch.LineFormat
+	result   *types.IssueFixResult
+}
+
+// prepareFix load the issue and its file, and apply the fix patch in memory
+func (s *IssueService) prepareFix(ctx context.Context, issueID string) (*preparedFix, error) {
+	issue, err := s.getIssueModel(ctx, issueID)
+	if err != nil {
+		return nil, err
+	}
+	if issue.FixPatch == nil || *issue.FixPatch == "" {
+		return nil, ErrFixNotAvailable
+	}
+
+	task, err := s.taskRepo.GetByReviewTaskID(ctx, issue.ReviewTaskID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReviewTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fileDiff, err := issueFileDiff(issue)
+	if err != nil {
+		return nil, err
+	}
+
+	// Issues of inline code are fixed against the code stored with the task, never against the codebase file
+	// of the same path
+	var fullPath string
+	var content []byte
+	if issue.Virtual {
+		var ok bool
+		if content, ok = virtualFileContent(task, issue.FilePath); !ok {
+			return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.file_not_found", "", map[string]interface{}{"path": issue.FilePath}))
+		}
+	} else {
+		fullPath, err = resolveCodebasePath(task.RootPath, issue.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		if content, err = readFile(ctx, fullPath); err != nil {
+			return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.file_not_found", "", map[string]interface{}{"path": issue.FilePath}))
+		}
+	}
+
+	lines, format := patch.SplitLines(string(content))
+	patched, regions, err := patch.Apply(lines, fileDiff)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.IssueFixResult{
+		IssueID:  issue.IssueID,
+		FilePath: issue.FilePath,
+		FixPatch: *issue.FixPatch,
+		Regions:  make([]types.FixRegion, 0, len(regions)),
+	}
+	for _, region := range regions {
+		result.Regions = append(result.Regions, types.FixRegion{
+			OriginalStartLine: region.OldStart,
+			OriginalEndLine:   region.OldStart + len(region.OldLines) - 1,
+			Original:          strings.Join(region.OldLines, "\n"),
+			StartLine:         region.NewStart,
+			EndLine:           region.NewStart + len(region.NewLines) - 1,
+			Patched:           strings.Join(region.NewLines, "\n"),
+		})
+	}
+
+	return &preparedFix{issue: issue, fullPath: fullPath, patched: patched, format: format, result: result}, nil
+}
+
+// issueFileDiff parse the fix patch of an issue, which must change the issue file only
+func issueFileDiff(issue *model.ReviewIssue) (*patch.FileDiff, error) {
+	files, err := patch.Parse(*issue.FixPatch)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) != 1 || cleanRelativePath(files[0].Path()) != cleanRelativePath(issue.FilePath) {
+		return nil, fmt.Errorf("%s", i18n.Translate("issue.fix_invalid_patch", "", nil))
+	}
+	return files[0], nil
+}
+
+// virtualFileContent inline code of the code target of the task with the virtual file path
+func virtualFileContent(task *model.ReviewTask, path string) ([]byte, bool) {
+	for _, target := range task.Targets {
+		if target.Type == "code" && target.FilePath == path {
+			return []byte(target.Code), true
+		}
+	}
+	return nil, false
+}
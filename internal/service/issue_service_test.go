@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/headerpropagation"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// seedIssues store issues of a new review task with the statuses, returning their IDs
+func seedIssues(t *testing.T, statuses ...int) (string, []string) {
+	t.Helper()
+	reviewTaskID := "rt-" + t.Name()
+	issues := make([]*model.ReviewIssue, 0, len(statuses))
+	ids := make([]string, 0, len(statuses))
+	for i, status := range statuses {
+		issueID := fmt.Sprintf("%s-%d", reviewTaskID, i)
+		issues = append(issues, &model.ReviewIssue{
+			IssueID:      issueID,
+			ReviewTaskID: reviewTaskID,
+			RuleID:       "todo-comment",
+			FilePath:     "a.go",
+			Severity:     types.SeverityLow,
+			Status:       status,
+		})
+		ids = append(ids, issueID)
+	}
+	if err := repository.NewReviewIssueRepository().CreateBatch(context.Background(), issues); err != nil {
+		t.Fatalf("CreateBatch failed: %v", err)
+	}
+	return reviewTaskID, ids
+}
+
+func TestIssueService_UpdateIssue(t *testing.T) {
+	s := NewIssueService()
+	_, ids := seedIssues(t, types.IssueStatusOpen)
+	ctx := headerpropagation.WithContext(context.Background(), map[string]string{operatorHeader: "alice"})
+	status := func(status int) *int { return &status }
+
+	tests := []struct {
+		name   string
+		status int
+		err    error
+	}{
+		{"open to confirmed", types.IssueStatusConfirmed, nil},
+		{"confirmed to ignored", types.IssueStatusIgnored, nil},
+		{"ignored to fixed", types.IssueStatusFixed, ErrIssueStatusTransition},
+		{"unknown status", 9, ErrInvalidIssueStatus},
+		{"ignored to open", types.IssueStatusOpen, nil},
+		{"open to fixed", types.IssueStatusFixed, nil},
+	}
+	for _, tt := range tests {
+		issue, err := s.UpdateIssue(ctx, ids[0], IssueUpdate{Status: status(tt.status)})
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%s: expected error %v, got %v", tt.name, tt.err, err)
+		}
+		if err == nil && issue.Status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.status, issue.Status)
+		}
+	}
+
+	// Neither a change nor a comment, nothing is recorded
+	if _, err := s.UpdateIssue(ctx, ids[0], IssueUpdate{Status: status(types.IssueStatusFixed)}); err != nil {
+		t.Fatalf("UpdateIssue failed: %v", err)
+	}
+	assignee := "bob"
+	if _, err := s.UpdateIssue(context.Background(), ids[0], IssueUpdate{Assignee: &assignee, Comment: "take it"}); err != nil {
+		t.Fatalf("UpdateIssue failed: %v", err)
+	}
+
+	history, err := s.ListHistory(context.Background(), ids[0])
+	if err != nil {
+		t.Fatalf("ListHistory failed: %v", err)
+	}
+	want := []types.IssueHistory{
+		{Operator: "alice", FromStatus: types.IssueStatusOpen, ToStatus: types.IssueStatusConfirmed},
+		{Operator: "alice", FromStatus: types.IssueStatusConfirmed, ToStatus: types.IssueStatusIgnored},
+		{Operator: "alice", FromStatus: types.IssueStatusIgnored, ToStatus: types.IssueStatusOpen},
+		{Operator: "alice", FromStatus: types.IssueStatusOpen, ToStatus: types.IssueStatusFixed},
+		{FromStatus: types.IssueStatusFixed, ToStatus: types.IssueStatusFixed, ToAssignee: "bob", Comment: "take it"},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("Expected %d history rows, got %+v", len(want), history)
+	}
+	for i, row := range history {
+		row.IssueID, row.CreatedAt = "", ""
+		if row != want[i] {
+			t.Errorf("History row %d: expected %+v, got %+v", i, want[i], row)
+		}
+	}
+
+	if _, err := s.UpdateIssue(ctx, "missing", IssueUpdate{}); !errors.Is(err, ErrIssueNotFound) {
+		t.Errorf("Expected ErrIssueNotFound, got %v", err)
+	}
+}
+
+func TestIssueService_BatchUpdateStatus(t *testing.T) {
+	s := NewIssueService()
+	reviewTaskID, ids := seedIssues(t, types.IssueStatusOpen, types.IssueStatusConfirmed, types.IssueStatusIgnored, types.IssueStatusFixed)
+	filter := types.IssueFilter{ReviewTaskID: reviewTaskID}
+
+	// Ignored issues cannot be fixed and fixed ones are already there
+	result, err := s.BatchUpdateStatus(context.Background(), filter, types.IssueStatusFixed, "released")
+	if err != nil {
+		t.Fatalf("BatchUpdateStatus failed: %v", err)
+	}
+	if result.Matched != 4 || result.Updated != 2 || len(result.Skipped) != 1 || result.Skipped[0] != ids[2] {
+		t.Errorf("Expected 2 updated and the ignored issue skipped, got %+v", result)
+	}
+	for _, id := range ids[:2] {
+		history, err := s.ListHistory(context.Background(), id)
+		if err != nil || len(history) != 1 || history[0].ToStatus != types.IssueStatusFixed || history[0].Comment != "released" {
+			t.Errorf("Expected one history row of %s, got %+v %v", id, history, err)
+		}
+	}
+	if history, _ := s.ListHistory(context.Background(), ids[3]); len(history) != 0 {
+		t.Errorf("Expected no history of the issue already fixed, got %+v", history)
+	}
+
+	// Filters narrow the matched issues
+	ignored := types.IssueStatusIgnored
+	result, err = s.BatchUpdateStatus(context.Background(), types.IssueFilter{ReviewTaskID: reviewTaskID, Status: &ignored}, types.IssueStatusOpen, "")
+	if err != nil || result.Matched != 1 || result.Updated != 1 || len(result.Skipped) != 0 {
+		t.Errorf("Expected the ignored issue reopened, got %+v %v", result, err)
+	}
+
+	if _, err := s.BatchUpdateStatus(context.Background(), filter, -1, ""); !errors.Is(err, ErrInvalidIssueStatus) {
+		t.Errorf("Expected ErrInvalidIssueStatus, got %v", err)
+	}
+}
+
+func TestIssueService_ListIssues(t *testing.T) {
+	s := NewIssueService()
+	reviewTaskID, ids := seedIssues(t, types.IssueStatusOpen, types.IssueStatusOpen, types.IssueStatusConfirmed)
+	filter := types.IssueFilter{ReviewTaskID: reviewTaskID}
+
+	tests := []struct {
+		page, pageSize int
+		want           []string
+	}{
+		{1, 2, ids[:2]},
+		{2, 2, ids[2:]},
+		{3, 2, nil},
+	}
+	for _, tt := range tests {
+		list, total, err := s.ListIssues(context.Background(), filter, tt.page, tt.pageSize)
+		if err != nil {
+			t.Fatalf("ListIssues failed: %v", err)
+		}
+		if total != 3 || len(list) != len(tt.want) {
+			t.Fatalf("Page %d: expected %d of 3 issues, got %d of %d", tt.page, len(tt.want), len(list), total)
+		}
+		for i, issue := range list {
+			if issue.IssueID != tt.want[i] {
+				t.Errorf("Page %d: expected %s, got %s", tt.page, tt.want[i], issue.IssueID)
+			}
+		}
+	}
+}
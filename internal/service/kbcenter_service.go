@@ -25,7 +25,7 @@ func NewKBCenterMockService(baseDir string) *KBCenterMockService {
 func (s *KBCenterMockService) GetFileContent(ctx context.Context, filePath string, startLine, endLine int) ([]byte, error) {
 	fullPath := filepath.Join(s.baseDir, filePath)
 
-	content, err := os.ReadFile(fullPath)
+	content, err := readFile(ctx, fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.file_not_found", "", map[string]interface{}{"path": fullPath}))
@@ -127,7 +127,7 @@ func (s *KBCenterMockService) buildDirectoryTree(basePath, relativePath string,
 func (s *KBCenterMockService) GetFileStructure(ctx context.Context, filePath string) ([]language.FunctionInfo, error) {
 	fullPath := filepath.Join(s.baseDir, filePath)
 
-	content, err := os.ReadFile(fullPath)
+	content, err := readFile(ctx, fullPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.file_not_found", "", map[string]interface{}{"path": fullPath}))
@@ -143,7 +143,7 @@ func (s *KBCenterMockService) GetFileStructure(ctx context.Context, filePath str
 		}))
 	}
 
-	return language.ExtractFunctions(lang, string(content))
+	return language.ExtractFunctions(ctx, lang, string(content))
 }
 
 func (s *KBCenterMockService) GetDirectoryTree(ctx context.Context, clientId, projectPath, subDir string, depth int, includeFiles bool) (interface{}, error) {
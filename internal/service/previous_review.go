@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// previousReview open issues of the reviewed files left by the previous done reviews of a codebase, matched against
+// a new review by fingerprint
+type previousReview struct {
+	issues  []*model.ReviewIssue
+	byPrint map[string]*model.ReviewIssue
+	matched map[string]bool
+}
+
+// loadPreviousReview load the open issues of the files from the done reviews of the task codebase before the task.
+// Reviews may cover different files, so each issue is taken from the latest review that stored it; an issue
+// that review reported as resolved is gone. Virtual files and their issues stay out of the codebase history.
+func (s *ReviewTaskService) loadPreviousReview(ctx context.Context, task *model.ReviewTask, files []reviewFile) (*previousReview, error) {
+	previous := &previousReview{
+		byPrint: make(map[string]*model.ReviewIssue),
+		matched: make(map[string]bool),
+	}
+
+	paths := make([]string, 0, len(files))
+	for i := range files {
+		if !files[i].virtual {
+			paths = append(paths, files[i].path)
+		}
+	}
+	issues, err := s.issueRepo.ListPreviousByFiles(ctx, task.CodebasePath, task.ID, paths)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, issue := range issues {
+		// Latest review first, older reviews of the same issue are outdated
+		if issue.Fingerprint == "" || issue.Virtual || seen[issue.Fingerprint] {
+			continue
+		}
+		seen[issue.Fingerprint] = true
+		if issue.Change == types.IssueChangeResolved {
+			continue
+		}
+		previous.issues = append(previous.issues, issue)
+		previous.byPrint[issue.Fingerprint] = issue
+	}
+	return previous, nil
+}
+
+// previousReviewTaskID ID of the latest done review of the task codebase before the task, empty when there is none
+func (s *ReviewTaskService) previousReviewTaskID(ctx context.Context, task *model.ReviewTask) (string, error) {
+	base, err := s.taskRepo.GetPreviousDone(ctx, task.CodebasePath, task.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return base.ReviewTaskID, nil
+}
+
+// match find the previous issue with the fingerprint and mark it as found again, each issue matches once
+func (p *previousReview) match(fingerprint string) *model.ReviewIssue {
+	issue, ok := p.byPrint[fingerprint]
+	if !ok || p.matched[fingerprint] {
+		return nil
+	}
+	p.matched[fingerprint] = true
+	return issue
+}
+
+// resolved copies of the previous issues that lie in the reviewed files but were not found again
+func (p *previousReview) resolved(reviewTaskID string, files []reviewFile) []*model.ReviewIssue {
+	reviewed := make(map[string]*reviewFile, len(files))
+	for i := range files {
+		// Virtual files stand for unsaved code, they resolve nothing of the codebase
+		if !files[i].virtual {
+			reviewed[files[i].path] = &files[i]
+		}
+	}
+
+	var resolved []*model.ReviewIssue
+	for _, issue := range p.issues {
+		if p.matched[issue.Fingerprint] {
+			continue
+		}
+		file, ok := reviewed[issue.FilePath]
+		if !ok || !file.inTargets(issue.StartLine, issue.EndLine, nil) {
+			continue
+		}
+		copied := *issue
+		copied.ID = 0
+		copied.ReviewTaskID = reviewTaskID
+		copied.Change = types.IssueChangeResolved
+		resolved = append(resolved, &copied)
+	}
+	return resolved
+}
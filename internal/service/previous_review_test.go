@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func TestPreviousReview_MatchesPerFile(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n\n// TODO: retry\nfunc a() {}\n")
+	writeCodebaseFile(t, dir, "b.go", "package main\n\n// FIXME: close\nfunc b() {}\n")
+	fileA := types.Target{Type: "file", FilePath: "a.go"}
+	fileB := types.Target{Type: "file", FilePath: "b.go"}
+
+	first, issues := runReview(t, s, fileA)
+	if len(issues) != 1 || issues[0].Change != types.IssueChangeNew {
+		t.Fatalf("Expected one new issue, got %+v", issues)
+	}
+	assignee, status := "alice", types.IssueStatusConfirmed
+	if _, err := NewIssueService().UpdateIssue(context.Background(), issues[0].IssueID, IssueUpdate{Status: &status, Assignee: &assignee}); err != nil {
+		t.Fatalf("UpdateIssue failed: %v", err)
+	}
+
+	// Reviewing another file in between neither resolves nor forgets the issues of a.go
+	second, issues := runReview(t, s, fileB)
+	if second.BaseReviewTaskID != first.ReviewTaskID || len(issues) != 1 || issues[0].FilePath != "b.go" {
+		t.Fatalf("Expected only the new issue of b.go, got %+v", issues)
+	}
+
+	third, issues := runReview(t, s, fileA)
+	if third.BaseReviewTaskID != second.ReviewTaskID {
+		t.Errorf("Expected the latest review as base, got %q", third.BaseReviewTaskID)
+	}
+	if len(issues) != 1 {
+		t.Fatalf("Expected the issue of a.go again, got %+v", issues)
+	}
+	issue := issues[0]
+	if issue.Change != types.IssueChangeUnchanged || issue.Status != types.IssueStatusConfirmed || issue.Assignee != "alice" {
+		t.Errorf("Expected the triaged issue to be matched, got %+v", issue)
+	}
+
+	// Once resolved, the issue is not matched by later reviews
+	writeCodebaseFile(t, dir, "a.go", "package main\n\nfunc a() {}\n")
+	if _, issues := runReview(t, s, fileA, fileB); len(issuesOf(issues, "a.go")) != 1 || issuesOf(issues, "a.go")[0].Change != types.IssueChangeResolved {
+		t.Fatalf("Expected the issue of a.go resolved, got %+v", issues)
+	}
+	if _, issues := runReview(t, s, fileA, fileB); len(issuesOf(issues, "a.go")) != 0 || issuesOf(issues, "b.go")[0].Change != types.IssueChangeUnchanged {
+		t.Errorf("Expected only the unchanged issue of b.go, got %+v", issues)
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/internal/analyzer"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/prompt"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+var (
+	// ErrPromptTemplateNotFound prompt template or version does not exist
+	ErrPromptTemplateNotFound = errors.New("prompt template not found")
+	// ErrPromptTemplateExists prompt template of the name already exists
+	ErrPromptTemplateExists = errors.New("prompt template already exists")
+	// ErrInvalidPromptTemplateName name is empty or has characters other than letters, digits, '_', '-' and '.'
+	ErrInvalidPromptTemplateName = errors.New("invalid prompt template name")
+	// ErrInvalidPromptTemplate system or user prompt does not render
+	ErrInvalidPromptTemplate = errors.New("invalid prompt template")
+)
+
+// promptTemplateNamePattern valid prompt template names
+var promptTemplateNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,128}$`)
+
+// PromptTemplateInput content of a prompt template version
+type PromptTemplateInput struct {
+	Description          string
+	SystemPrompt         string
+	UserPrompt           string
+	LanguageInstructions map[string]string
+	OutputSchema         string
+}
+
+// PromptRenderInput code a prompt template is rendered for
+type PromptRenderInput struct {
+	FilePath  string
+	Language  string // Detected from FilePath when empty
+	Code      string // Full file content
+	StartLine int    // 1 when not positive
+	EndLine   int    // Last line when not positive
+}
+
+// PromptTemplateService management and rendering of the prompt templates of the LLM analyzer
+type PromptTemplateService struct {
+	repo repository.PromptTemplateRepository
+}
+
+func init() {
+	// The repository is chosen once the database is initialized
+	analyzer.SetPromptLoader(func(ctx context.Context) (*prompt.Template, error) {
+		return NewPromptTemplateService().ActivePrompt(ctx)
+	})
+}
+
+// NewPromptTemplateService create prompt template service
+func NewPromptTemplateService() *PromptTemplateService {
+	return &PromptTemplateService{
+		repo: repository.NewPromptTemplateRepository(),
+	}
+}
+
+// CreatePromptTemplate store version 1 of a new template
+func (s *PromptTemplateService) CreatePromptTemplate(ctx context.Context, name string, input PromptTemplateInput) (*types.PromptTemplate, error) {
+	if !promptTemplateNamePattern.MatchString(name) {
+		return nil, ErrInvalidPromptTemplateName
+	}
+	if _, err := s.repo.GetLatest(ctx, name); err == nil {
+		return nil, ErrPromptTemplateExists
+	} else if !errors.Is(err, repository.ErrNotFound) {
+		return nil, err
+	}
+	return s.createVersion(ctx, name, 1, input)
+}
+
+// UpdatePromptTemplate store a new version of an existing template, earlier versions are kept
+func (s *PromptTemplateService) UpdatePromptTemplate(ctx context.Context, name string, input PromptTemplateInput) (*types.PromptTemplate, error) {
+	latest, err := s.repo.GetLatest(ctx, name)
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil, ErrPromptTemplateNotFound
+		}
+		return nil, err
+	}
+	return s.createVersion(ctx, name, latest.Version+1, input)
+}
+
+// ListPromptTemplates latest version of every template, with the built-in template while the active one is not stored
+func (s *PromptTemplateService) ListPromptTemplates(ctx context.Context) ([]types.PromptTemplate, error) {
+	templates, err := s.repo.ListLatest(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	active := activePromptTemplateName()
+	list := make([]types.PromptTemplate, 0, len(templates)+1)
+	stored := false
+	for _, template := range templates {
+		list = append(list, toPromptTemplate(template))
+		stored = stored || template.Name == active
+	}
+	if !stored {
+		list = append(list, builtinPromptTemplate(active))
+	}
+	return list, nil
+}
+
+// GetPromptTemplate get a version of a template, the latest one when version is not positive.
+// The built-in template is returned for the active name while no template of that name is stored.
+func (s *PromptTemplateService) GetPromptTemplate(ctx context.Context, name string, version int) (*types.PromptTemplate, error) {
+	var template *model.PromptTemplate
+	var err error
+	if version > 0 {
+		template, err = s.repo.GetVersion(ctx, name, version)
+	} else {
+		template, err = s.repo.GetLatest(ctx, name)
+	}
+	if err != nil {
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
+		if version <= 0 && name == activePromptTemplateName() {
+			builtin := builtinPromptTemplate(name)
+			return &builtin, nil
+		}
+		return nil, ErrPromptTemplateNotFound
+	}
+
+	result := toPromptTemplate(template)
+	if version > 0 {
+		// Only the latest version is used by the analyzer
+		if latest, err := s.repo.GetLatest(ctx, name); err != nil || latest.Version != version {
+			result.Active = false
+		}
+	}
+	return &result, nil
+}
+
+// ActivePrompt template rendered by the LLM analyzer, the latest version of the active name or the built-in
+// template while none is stored
+func (s *PromptTemplateService) ActivePrompt(ctx context.Context) (*prompt.Template, error) {
+	template, err := s.GetPromptTemplate(ctx, activePromptTemplateName(), 0)
+	if err != nil {
+		return nil, err
+	}
+	return toPrompt(template), nil
+}
+
+// ListPromptTemplateVersions list all versions of a template, newest first
+func (s *PromptTemplateService) ListPromptTemplateVersions(ctx context.Context, name string) ([]types.PromptTemplate, error) {
+	templates, err := s.repo.ListVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return nil, ErrPromptTemplateNotFound
+	}
+	list := make([]types.PromptTemplate, 0, len(templates))
+	for i, template := range templates {
+		version := toPromptTemplate(template)
+		// Only the latest version is used by the analyzer
+		version.Active = version.Active && i == 0
+		list = append(list, version)
+	}
+	return list, nil
+}
+
+// DeletePromptTemplate remove all versions of a template
+func (s *PromptTemplateService) DeletePromptTemplate(ctx context.Context, name string) error {
+	if err := s.repo.Delete(ctx, name); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrPromptTemplateNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// RenderPromptTemplate render a version of a stored template for the code, the latest one when version is not positive
+func (s *PromptTemplateService) RenderPromptTemplate(ctx context.Context, name string, version int, input PromptRenderInput) (*types.RenderedPrompt, error) {
+	template, err := s.GetPromptTemplate(ctx, name, version)
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := renderPrompt(ctx, toPrompt(template), input)
+	if err != nil {
+		return nil, err
+	}
+	rendered.Name = template.Name
+	rendered.Version = template.Version
+	return rendered, nil
+}
+
+// RenderPromptDraft render template content that is not stored, to preview changes before saving them
+func (s *PromptTemplateService) RenderPromptDraft(ctx context.Context, draft PromptTemplateInput, input PromptRenderInput) (*types.RenderedPrompt, error) {
+	return renderPrompt(ctx, &prompt.Template{
+		System:               draft.SystemPrompt,
+		User:                 draft.UserPrompt,
+		LanguageInstructions: normalizeLanguageInstructions(draft.LanguageInstructions),
+		OutputSchema:         draft.OutputSchema,
+	}, input)
+}
+
+// createVersion validate and store a template version
+func (s *PromptTemplateService) createVersion(ctx context.Context, name string, version int, input PromptTemplateInput) (*types.PromptTemplate, error) {
+	template := &model.PromptTemplate{
+		Name:                 name,
+		Version:              version,
+		Description:          input.Description,
+		SystemPrompt:         input.SystemPrompt,
+		UserPrompt:           input.UserPrompt,
+		LanguageInstructions: normalizeLanguageInstructions(input.LanguageInstructions),
+		OutputSchema:         input.OutputSchema,
+	}
+	result := toPromptTemplate(template)
+	if err := toPrompt(&result).Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	if err := s.repo.Create(ctx, template); err != nil {
+		return nil, err
+	}
+	result = toPromptTemplate(template)
+	return &result, nil
+}
+
+// renderPrompt render a template for the code the way the LLM analyzer does
+func renderPrompt(ctx context.Context, tmpl *prompt.Template, input PromptRenderInput) (*types.RenderedPrompt, error) {
+	lang := input.Language
+	if lang == "" {
+		lang, _ = language.Detect(input.FilePath)
+	}
+	// Functions provide the reviewed function name and the surrounding symbols, unsupported languages have none
+	functions, _ := language.ExtractFunctions(ctx, lang, input.Code)
+	file := analyzer.NewFile(input.FilePath, lang, input.Code, functions)
+
+	startLine := max(input.StartLine, 1)
+	endLine := input.EndLine
+	if endLine <= 0 || endLine > len(file.Lines) {
+		endLine = len(file.Lines)
+	}
+	rendered, err := tmpl.Render(analyzer.LLMPromptContext(ctx, file, startLine, endLine))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPromptTemplate, err)
+	}
+	return &types.RenderedPrompt{SystemPrompt: rendered.System, UserPrompt: rendered.User}, nil
+}
+
+// activePromptTemplateName name of the template used by the LLM analyzer
+func activePromptTemplateName() string {
+	if name := config.GetConfig().LLM.PromptTemplate; name != "" {
+		return name
+	}
+	return analyzer.DefaultLLMPromptName
+}
+
+// builtinPromptTemplate built-in template of the LLM analyzer under the given name
+func builtinPromptTemplate(name string) types.PromptTemplate {
+	builtin := analyzer.DefaultLLMPrompt()
+	return types.PromptTemplate{
+		Name:                 name,
+		Description:          "Built-in review prompt, used until a template of this name is stored",
+		SystemPrompt:         builtin.System,
+		UserPrompt:           builtin.User,
+		LanguageInstructions: builtin.LanguageInstructions,
+		OutputSchema:         builtin.OutputSchema,
+		Active:               true,
+	}
+}
+
+// normalizeLanguageInstructions key language instructions by lowercase language, as they are looked up when rendering
+func normalizeLanguageInstructions(instructions map[string]string) map[string]string {
+	if len(instructions) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(instructions))
+	for lang, instruction := range instructions {
+		normalized[strings.ToLower(strings.TrimSpace(lang))] = instruction
+	}
+	return normalized
+}
+
+// toPrompt convert a prompt template into its renderable form
+func toPrompt(template *types.PromptTemplate) *prompt.Template {
+	return &prompt.Template{
+		System:               template.SystemPrompt,
+		User:                 template.UserPrompt,
+		LanguageInstructions: template.LanguageInstructions,
+		OutputSchema:         template.OutputSchema,
+	}
+}
+
+// toPromptTemplate convert prompt template model into API type
+func toPromptTemplate(template *model.PromptTemplate) types.PromptTemplate {
+	result := types.PromptTemplate{
+		Name:                 template.Name,
+		Version:              template.Version,
+		Description:          template.Description,
+		SystemPrompt:         template.SystemPrompt,
+		UserPrompt:           template.UserPrompt,
+		LanguageInstructions: template.LanguageInstructions,
+		OutputSchema:         template.OutputSchema,
+		Active:               template.Name == activePromptTemplateName(),
+	}
+	if !template.CreatedAt.IsZero() {
+		result.CreatedAt = utils.FormatTime(template.CreatedAt, "")
+	}
+	return result
+}
@@ -0,0 +1,369 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/zgsm/mock-kbcenter/pkg/redis"
+)
+
+const (
+	// chunkStateExpiration expiration of the subtask state of a review task in Redis
+	chunkStateExpiration = 24 * time.Hour
+	// chunkLockExpiration expiration of the commit lock, so that a crashed holder does not block the task
+	chunkLockExpiration = 30 * time.Second
+	// chunkLockRetryInterval wait between attempts to take the commit lock
+	chunkLockRetryInterval = 20 * time.Millisecond
+)
+
+// chunkProgress subtask completion of a review task
+type chunkProgress struct {
+	Chunks         int // Number of subtasks
+	CompletedFiles int // Files of the completed subtasks, committed or not
+	Committed      int // Subtasks whose results are stored, in subtask order
+}
+
+// completedChunk stored result of a completed subtask
+type completedChunk struct {
+	Index  int
+	Result []byte
+}
+
+// chunkTracker subtask state of review tasks: completion counts and results waiting to be committed in order.
+// Completing a subtask again overwrites its result, so that retried subtasks are counted once.
+type chunkTracker interface {
+	// Start reset the subtask state of a review task split into chunks subtasks
+	Start(ctx context.Context, reviewTaskID string, chunks int) error
+	// Started whether the subtask state of a review task exists
+	Started(ctx context.Context, reviewTaskID string) (bool, error)
+	// Complete record the result of a subtask and the number of files it reviewed
+	Complete(ctx context.Context, reviewTaskID string, index, files int, result []byte) error
+	// Drain pass the results ready to commit of a started task, from the commit position on in subtask order, to commit under the task lock.
+	// With all, every completed result not committed yet is passed in subtask order, skipping the subtasks not completed.
+	// Committed results are removed, the commit position moves past the results committed without gaps.
+	// The state is updated after the commit returns, the commit is passed the same results again when that fails.
+	Drain(ctx context.Context, reviewTaskID string, all bool, commit func(results []completedChunk, progress chunkProgress) error) error
+	// Clear remove the subtask state and the cancellation flag of a finished review task
+	Clear(ctx context.Context, reviewTaskID string) error
+	// Cancel flag a review task as cancelled, the flag outlives the subtask state
+	Cancel(ctx context.Context, reviewTaskID string) error
+	// Cancelled whether the review task is flagged as cancelled
+	Cancelled(ctx context.Context, reviewTaskID string) (bool, error)
+}
+
+// newChunkTracker tracker shared by the web and worker processes through Redis, in memory when Redis is disabled
+func newChunkTracker() chunkTracker {
+	if _, err := redis.GetClient(); err == nil {
+		return &redisChunkTracker{}
+	}
+	return defaultMemoryChunkTracker
+}
+
+// redisChunkTracker Redis adapter of chunkTracker
+type redisChunkTracker struct{}
+
+func chunkKey(reviewTaskID, name string) string {
+	return fmt.Sprintf("review_task:%s:%s", reviewTaskID, name)
+}
+
+func (t *redisChunkTracker) Start(ctx context.Context, reviewTaskID string, chunks int) error {
+	// A task cancelled before it started stays flagged
+	if err := t.clearState(reviewTaskID); err != nil {
+		return err
+	}
+	return redis.Set(chunkKey(reviewTaskID, "chunks"), chunks, chunkStateExpiration)
+}
+
+func (t *redisChunkTracker) Started(ctx context.Context, reviewTaskID string) (bool, error) {
+	return redis.Exists(chunkKey(reviewTaskID, "chunks"))
+}
+
+func (t *redisChunkTracker) Complete(ctx context.Context, reviewTaskID string, index, files int, result []byte) error {
+	field := strconv.Itoa(index)
+	for key, value := range map[string]interface{}{
+		chunkKey(reviewTaskID, "results"):   result,
+		chunkKey(reviewTaskID, "completed"): files,
+	} {
+		if err := redis.HSet(key, field, value); err != nil {
+			return err
+		}
+		if err := redis.Expire(key, chunkStateExpiration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *redisChunkTracker) Drain(ctx context.Context, reviewTaskID string, all bool, commit func(results []completedChunk, progress chunkProgress) error) error {
+	lockKey := chunkKey(reviewTaskID, "lock")
+	for {
+		locked, err := redis.TryLock(lockKey, chunkLockExpiration)
+		if err != nil {
+			return err
+		}
+		if locked {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(chunkLockRetryInterval):
+		}
+	}
+	defer redis.Unlock(lockKey)
+	// Storing the results may outlast the lock expiration, the lock is kept until the commit returns
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(chunkLockExpiration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				_ = redis.Expire(lockKey, chunkLockExpiration)
+			}
+		}
+	}()
+
+	// Nothing to commit when the task was not started or its state is cleared
+	chunksKey := chunkKey(reviewTaskID, "chunks")
+	if exists, err := redis.Exists(chunksKey); err != nil || !exists {
+		return err
+	}
+	chunks, err := t.getInt(chunksKey)
+	if err != nil {
+		return err
+	}
+	committed, err := t.getInt(chunkKey(reviewTaskID, "committed"))
+	if err != nil {
+		return err
+	}
+	completed, err := redis.HGetAll(chunkKey(reviewTaskID, "completed"))
+	if err != nil {
+		return err
+	}
+	progress := chunkProgress{Chunks: chunks, Committed: committed}
+	for _, files := range completed {
+		count, _ := strconv.Atoi(files)
+		progress.CompletedFiles += count
+	}
+
+	resultsKey := chunkKey(reviewTaskID, "results")
+	var results []completedChunk
+	var fields []string
+	position := committed
+	for index := committed; index < chunks; index++ {
+		field := strconv.Itoa(index)
+		result, err := redis.HGet(resultsKey, field)
+		if errors.Is(err, goredis.Nil) {
+			if all {
+				continue
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		results = append(results, completedChunk{Index: index, Result: []byte(result)})
+		fields = append(fields, field)
+		if position == index {
+			position++
+		}
+	}
+
+	if err := commit(results, progress); err != nil {
+		return err
+	}
+	if len(results) == 0 {
+		return nil
+	}
+	if err := redis.Set(chunkKey(reviewTaskID, "committed"), position, chunkStateExpiration); err != nil {
+		return err
+	}
+	return redis.HDel(resultsKey, fields...)
+}
+
+func (t *redisChunkTracker) Clear(ctx context.Context, reviewTaskID string) error {
+	if err := t.clearState(reviewTaskID); err != nil {
+		return err
+	}
+	return redis.Del(chunkKey(reviewTaskID, "cancelled"))
+}
+
+// clearState remove the subtask state of a review task, keeping its cancellation flag
+func (t *redisChunkTracker) clearState(reviewTaskID string) error {
+	return redis.Del(
+		chunkKey(reviewTaskID, "chunks"),
+		chunkKey(reviewTaskID, "committed"),
+		chunkKey(reviewTaskID, "completed"),
+		chunkKey(reviewTaskID, "results"),
+	)
+}
+
+func (t *redisChunkTracker) Cancel(ctx context.Context, reviewTaskID string) error {
+	return redis.Set(chunkKey(reviewTaskID, "cancelled"), 1, chunkStateExpiration)
+}
+
+func (t *redisChunkTracker) Cancelled(ctx context.Context, reviewTaskID string) (bool, error) {
+	return redis.Exists(chunkKey(reviewTaskID, "cancelled"))
+}
+
+// getInt integer value of the key, 0 when missing
+func (t *redisChunkTracker) getInt(key string) (int, error) {
+	value, err := redis.Get(key)
+	if errors.Is(err, goredis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// memoryChunkTracker memory adapter of chunkTracker, for tasks run in the web process without Redis.
+// Like the Redis keys, the state of stopped tasks, which are never cleared, expires after chunkStateExpiration.
+type memoryChunkTracker struct {
+	mu        sync.Mutex
+	tasks     map[string]*memoryChunkState
+	cancelled map[string]time.Time // Expiration of the cancellation flags
+}
+
+type memoryChunkState struct {
+	chunks    int
+	committed int
+	completed map[int]int
+	results   map[int][]byte
+	expiresAt time.Time
+}
+
+var defaultMemoryChunkTracker = newMemoryChunkTracker()
+
+// newMemoryChunkTracker create an empty memory chunk tracker
+func newMemoryChunkTracker() *memoryChunkTracker {
+	return &memoryChunkTracker{
+		tasks:     make(map[string]*memoryChunkState),
+		cancelled: make(map[string]time.Time),
+	}
+}
+
+func (t *memoryChunkTracker) Start(ctx context.Context, reviewTaskID string, chunks int) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	t.tasks[reviewTaskID] = &memoryChunkState{
+		chunks:    chunks,
+		completed: make(map[int]int),
+		results:   make(map[int][]byte),
+		expiresAt: time.Now().Add(chunkStateExpiration),
+	}
+	return nil
+}
+
+// prune remove the expired task states and cancellation flags, called with the lock held
+func (t *memoryChunkTracker) prune() {
+	now := time.Now()
+	for reviewTaskID, state := range t.tasks {
+		if now.After(state.expiresAt) {
+			delete(t.tasks, reviewTaskID)
+		}
+	}
+	for reviewTaskID, expiresAt := range t.cancelled {
+		if now.After(expiresAt) {
+			delete(t.cancelled, reviewTaskID)
+		}
+	}
+}
+
+func (t *memoryChunkTracker) Started(ctx context.Context, reviewTaskID string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.tasks[reviewTaskID]
+	return ok && time.Now().Before(state.expiresAt), nil
+}
+
+func (t *memoryChunkTracker) Complete(ctx context.Context, reviewTaskID string, index, files int, result []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.tasks[reviewTaskID]
+	if !ok {
+		return nil
+	}
+	state.completed[index] = files
+	state.results[index] = result
+	state.expiresAt = time.Now().Add(chunkStateExpiration)
+	return nil
+}
+
+func (t *memoryChunkTracker) Drain(ctx context.Context, reviewTaskID string, all bool, commit func(results []completedChunk, progress chunkProgress) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.tasks[reviewTaskID]
+	if !ok {
+		return nil
+	}
+	progress := chunkProgress{Chunks: state.chunks, Committed: state.committed}
+	for _, files := range state.completed {
+		progress.CompletedFiles += files
+	}
+	var results []completedChunk
+	position := state.committed
+	for index := state.committed; index < state.chunks; index++ {
+		result, ok := state.results[index]
+		if !ok {
+			if all {
+				continue
+			}
+			break
+		}
+		results = append(results, completedChunk{Index: index, Result: result})
+		if position == index {
+			position++
+		}
+	}
+
+	if err := commit(results, progress); err != nil {
+		return err
+	}
+	for _, result := range results {
+		delete(state.results, result.Index)
+	}
+	state.committed = position
+	return nil
+}
+
+func (t *memoryChunkTracker) Clear(ctx context.Context, reviewTaskID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.tasks, reviewTaskID)
+	delete(t.cancelled, reviewTaskID)
+	return nil
+}
+
+func (t *memoryChunkTracker) Cancel(ctx context.Context, reviewTaskID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	t.cancelled[reviewTaskID] = time.Now().Add(chunkStateExpiration)
+	return nil
+}
+
+func (t *memoryChunkTracker) Cancelled(ctx context.Context, reviewTaskID string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expiresAt, ok := t.cancelled[reviewTaskID]
+	return ok && time.Now().Before(expiresAt), nil
+}
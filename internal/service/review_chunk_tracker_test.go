@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// drain drain the results of the task from the tracker, returning them with the progress passed to the commit
+func drain(t *testing.T, tracker chunkTracker, reviewTaskID string, all bool) ([]string, chunkProgress) {
+	t.Helper()
+	var results []string
+	var progress chunkProgress
+	err := tracker.Drain(context.Background(), reviewTaskID, all, func(data []completedChunk, p chunkProgress) error {
+		for _, result := range data {
+			results = append(results, string(result.Result))
+		}
+		progress = p
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	return results, progress
+}
+
+func TestMemoryChunkTracker_CommitOrder(t *testing.T) {
+	ctx := context.Background()
+	tracker := newMemoryChunkTracker()
+	if err := tracker.Start(ctx, "rt-1", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subtasks finishing out of order wait for the ones before them
+	_ = tracker.Complete(ctx, "rt-1", 1, 2, []byte("r1"))
+	results, progress := drain(t, tracker, "rt-1", false)
+	if len(results) != 0 || progress != (chunkProgress{Chunks: 3, CompletedFiles: 2}) {
+		t.Fatalf("Expected nothing to commit, got %v %+v", results, progress)
+	}
+
+	// A retried subtask overwrites its result and is counted once
+	_ = tracker.Complete(ctx, "rt-1", 0, 1, []byte("r0"))
+	_ = tracker.Complete(ctx, "rt-1", 0, 1, []byte("r0 retried"))
+	results, progress = drain(t, tracker, "rt-1", false)
+	if !reflect.DeepEqual(results, []string{"r0 retried", "r1"}) || progress.CompletedFiles != 3 || progress.Committed != 0 {
+		t.Fatalf("Expected the first two results in order, got %v %+v", results, progress)
+	}
+	if progress.Committed+len(results) >= progress.Chunks {
+		t.Error("Expected the task not finished")
+	}
+
+	// Results of subtasks already committed are not committed again
+	_ = tracker.Complete(ctx, "rt-1", 1, 2, []byte("r1 retried"))
+	_ = tracker.Complete(ctx, "rt-1", 2, 4, []byte("r2"))
+	results, progress = drain(t, tracker, "rt-1", false)
+	if !reflect.DeepEqual(results, []string{"r2"}) || progress.CompletedFiles != 7 || progress.Committed != 2 {
+		t.Fatalf("Expected the last result only, got %v %+v", results, progress)
+	}
+	if progress.Committed+len(results) < progress.Chunks {
+		t.Error("Expected the task finished")
+	}
+}
+
+func TestMemoryChunkTracker_DrainAll(t *testing.T) {
+	ctx := context.Background()
+	tracker := newMemoryChunkTracker()
+	_ = tracker.Start(ctx, "rt-1", 3)
+	_ = tracker.Complete(ctx, "rt-1", 2, 1, []byte("r2"))
+
+	// A failed commit keeps the results
+	failed := errors.New("failed")
+	if err := tracker.Drain(ctx, "rt-1", true, func([]completedChunk, chunkProgress) error { return failed }); !errors.Is(err, failed) {
+		t.Fatalf("Expected the commit error, got %v", err)
+	}
+
+	// A stopped task commits every completed result, skipping the gaps without moving past them
+	if results, _ := drain(t, tracker, "rt-1", true); !reflect.DeepEqual(results, []string{"r2"}) {
+		t.Fatalf("Expected the completed result, got %v", results)
+	}
+	_ = tracker.Complete(ctx, "rt-1", 0, 1, []byte("r0"))
+	results, progress := drain(t, tracker, "rt-1", true)
+	if !reflect.DeepEqual(results, []string{"r0"}) || progress.Committed != 0 {
+		t.Fatalf("Expected the late result, got %v %+v", results, progress)
+	}
+	if _, progress = drain(t, tracker, "rt-1", true); progress.Committed != 1 {
+		t.Errorf("Expected the commit position past the first subtask, got %+v", progress)
+	}
+}
+
+func TestMemoryChunkTracker_Cancelled(t *testing.T) {
+	ctx := context.Background()
+	tracker := newMemoryChunkTracker()
+	_ = tracker.Start(ctx, "rt-1", 1)
+	_ = tracker.Cancel(ctx, "rt-1")
+	_ = tracker.Cancel(ctx, "rt-2")
+
+	if cancelled, _ := tracker.Cancelled(ctx, "rt-1"); !cancelled {
+		t.Error("Expected rt-1 cancelled")
+	}
+	// Finished tasks drop their flag
+	_ = tracker.Clear(ctx, "rt-1")
+	if cancelled, _ := tracker.Cancelled(ctx, "rt-1"); cancelled || len(tracker.cancelled) != 1 {
+		t.Errorf("Expected the flag of rt-1 removed, got %v", tracker.cancelled)
+	}
+
+	// Stopped tasks are never cleared, their state expires
+	tracker.cancelled["rt-2"] = time.Now().Add(-time.Second)
+	_ = tracker.Start(ctx, "rt-3", 1)
+	tracker.tasks["rt-3"].expiresAt = time.Now().Add(-time.Second)
+	if cancelled, _ := tracker.Cancelled(ctx, "rt-2"); cancelled {
+		t.Error("Expected the flag of rt-2 expired")
+	}
+	_ = tracker.Start(ctx, "rt-4", 1)
+	if len(tracker.cancelled) != 0 || len(tracker.tasks) != 1 {
+		t.Errorf("Expected the expired state pruned, got %v %v", tracker.cancelled, tracker.tasks)
+	}
+}
+
+// forgetfulChunkTracker chunk tracker whose state fails to record the next commits, as when Redis fails after the commit
+type forgetfulChunkTracker struct {
+	chunkTracker
+	failures int
+}
+
+func (t *forgetfulChunkTracker) Drain(ctx context.Context, reviewTaskID string, all bool, commit func(results []completedChunk, progress chunkProgress) error) error {
+	if t.failures == 0 {
+		return t.chunkTracker.Drain(ctx, reviewTaskID, all, commit)
+	}
+	t.failures--
+	failed := errors.New("state not recorded")
+	err := t.chunkTracker.Drain(ctx, reviewTaskID, all, func(results []completedChunk, progress chunkProgress) error {
+		if err := commit(results, progress); err != nil {
+			return err
+		}
+		return failed
+	})
+	if errors.Is(err, failed) {
+		return err
+	}
+	return errors.New("expected the commit to run")
+}
+
+func TestCommitChunks_Repeated(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.ChunkSize = 1
+	writeCodebaseFile(t, dir, "a.go", "package main\n\n// TODO: retry\nfunc a() {}\n")
+	writeCodebaseFile(t, dir, "b.go", "package main\n\n// TODO: close\nfunc b() {}\n")
+	s.chunks = &forgetfulChunkTracker{chunkTracker: s.chunks, failures: 1}
+
+	ctx := context.Background()
+	created, _, err := s.CreateTask(ctx, "test", "", []types.Target{{Type: "folder"}}, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	chunks, err := s.PrepareTask(ctx, created.ReviewTaskID)
+	if err != nil || len(chunks) != 2 {
+		t.Fatalf("Expected 2 subtasks, got %d %v", len(chunks), err)
+	}
+
+	// The first subtask is stored but the subtask state does not record it, the retried subtask commits it again
+	if err := s.RunChunk(ctx, chunks[0]); err == nil {
+		t.Fatal("Expected the subtask state error")
+	}
+	for _, chunk := range []types.ReviewChunk{chunks[0], chunks[1]} {
+		if err := s.RunChunk(ctx, chunk); err != nil {
+			t.Fatalf("RunChunk failed: %v", err)
+		}
+	}
+	task, issues := reviewResult(t, s, created.ReviewTaskID)
+	if task.Status != types.ReviewTaskStatusDone || len(issues) != 2 || task.NewIssues != 2 {
+		t.Errorf("Expected the issues of both subtasks stored once, got %+v %+v", task, issues)
+	}
+}
+
+func TestPrepareTask_Retried(t *testing.T) {
+	s, reviewTaskID, _ := prepareTwoChunks(t)
+
+	// A retried run keeps the committed subtask and runs the other one only
+	ctx := context.Background()
+	chunks, err := s.PrepareTask(ctx, reviewTaskID)
+	if err != nil || len(chunks) != 1 || chunks[0].Index != 1 {
+		t.Fatalf("Expected the second subtask only, got %+v %v", chunks, err)
+	}
+	if err := s.RunChunk(ctx, chunks[0]); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	task, issues := reviewResult(t, s, reviewTaskID)
+	if task.Status != types.ReviewTaskStatusDone || len(issues) != 2 || task.NewIssues != 2 || task.Processed != 2 {
+		t.Errorf("Expected the issues of both subtasks stored once, got %+v %+v", task, issues)
+	}
+}
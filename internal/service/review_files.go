@@ -0,0 +1,333 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/git"
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/patch"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// reviewFile file to review and the targets it was collected from
+type reviewFile struct {
+	path    string // Path relative to the codebase root, slash separated
+	targets []types.Target
+	ref     string       // Git ref the content is read from, empty for the working tree
+	changed map[int]bool // Changed lines of diff targets
+
+	// Virtual file of a code target, its content is the inline code instead of a file in the codebase
+	virtual  bool
+	language string
+	code     string
+}
+
+// inTargets whether the lines intersect the line range of any target of the file.
+// For diff targets the lines must intersect changed lines, an issue reported on the first line of a
+// function covers the whole function, so that changes inside the function count.
+func (f *reviewFile) inTargets(startLine, endLine int, functions []language.FunctionInfo) bool {
+	for i := range f.targets {
+		if f.targets[i].Type != "diff" {
+			if f.targets[i].InLineRange(startLine, endLine) {
+				return true
+			}
+			continue
+		}
+
+		start, end := startLine, endLine
+		for _, function := range functions {
+			if function.StartLine == startLine {
+				end = max(end, function.EndLine)
+			}
+		}
+		for line := start; line <= end; line++ {
+			if f.changed[line] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readContent read the file content from its git ref, the working tree or the inline code of a virtual file
+func (f *reviewFile) readContent(ctx context.Context, rootPath string) (content []byte, err error) {
+	if f.virtual {
+		return []byte(f.code), nil
+	}
+	ctx, span := tracing.Start(ctx, "file.read", attribute.String("file.path", f.path), attribute.String("git.ref", f.ref))
+	defer func() {
+		span.SetAttributes(attribute.Int("file.size", len(content)))
+		tracing.End(span, err)
+	}()
+
+	if f.ref != "" {
+		return git.Show(ctx, rootPath, f.ref, f.path)
+	}
+	fullPath, err := resolveCodebasePath(rootPath, f.path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(fullPath)
+}
+
+// readFile read a file within a span
+func readFile(ctx context.Context, path string) (content []byte, err error) {
+	_, span := tracing.Start(ctx, "file.read", attribute.String("file.path", path))
+	defer func() {
+		span.SetAttributes(attribute.Int("file.size", len(content)))
+		tracing.End(span, err)
+	}()
+	return os.ReadFile(path)
+}
+
+// collectReviewFiles expand targets into the files to review, in target order without duplicates.
+// A file is read from a single source, collecting it both from the working tree and from a git ref is an error.
+func collectReviewFiles(ctx context.Context, rootPath string, targets []types.Target) ([]reviewFile, error) {
+	var files []reviewFile
+	index := make(map[string]int)
+	add := func(path, ref string, target types.Target) (*reviewFile, error) {
+		if i, ok := index[path]; ok {
+			if files[i].ref != ref {
+				return nil, fmt.Errorf("%s", i18n.Translate("review_task.conflicting_file_ref", "", map[string]interface{}{
+					"path": path, "ref": refName(files[i].ref), "other": refName(ref),
+				}))
+			}
+			files[i].targets = append(files[i].targets, target)
+			return &files[i], nil
+		}
+		index[path] = len(files)
+		files = append(files, reviewFile{path: path, targets: []types.Target{target}, ref: ref})
+		return &files[len(files)-1], nil
+	}
+
+	for _, target := range targets {
+		switch target.Type {
+		case "file":
+			fullPath, err := resolveCodebasePath(rootPath, target.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			if info, err := os.Stat(fullPath); err != nil || info.IsDir() {
+				return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.file_not_found", "", map[string]interface{}{"path": target.FilePath}))
+			}
+			if _, err := add(cleanRelativePath(target.FilePath), "", target); err != nil {
+				return nil, err
+			}
+		case "folder":
+			fullPath, err := resolveCodebasePath(rootPath, target.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			var conflict error
+			err = filepath.WalkDir(fullPath, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				// Skip hidden entries and node_modules, same as the directory tree
+				if path != fullPath && (strings.HasPrefix(d.Name(), ".") || (d.IsDir() && d.Name() == "node_modules")) {
+					if d.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+				if d.IsDir() {
+					return nil
+				}
+				if _, err := language.Detect(path); err != nil {
+					return nil
+				}
+				rel, err := filepath.Rel(rootPath, path)
+				if err != nil {
+					return err
+				}
+				if _, conflict = add(filepath.ToSlash(rel), "", types.Target{Type: "file", FilePath: filepath.ToSlash(rel)}); conflict != nil {
+					return filepath.SkipAll
+				}
+				return nil
+			})
+			if conflict != nil {
+				return nil, conflict
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s", i18n.Translate("kbcenter.read_dir_failed", "", map[string]interface{}{"path": target.FilePath, "error": err.Error()}))
+			}
+		case "diff":
+			fileDiffs, err := loadTargetDiff(ctx, rootPath, target)
+			if err != nil {
+				return nil, err
+			}
+			for _, fileDiff := range fileDiffs {
+				// Deleted files have nothing left to review
+				if fileDiff.NewPath == "" || fileDiff.NewPath == "/dev/null" {
+					continue
+				}
+				path := cleanRelativePath(fileDiff.NewPath)
+				if !withinPath(path, target.FilePath) {
+					continue
+				}
+				if _, err := resolveCodebasePath(rootPath, path); err != nil {
+					return nil, err
+				}
+				if _, err := language.Detect(path); err != nil {
+					continue
+				}
+
+				file, err := add(path, target.HeadRef, target)
+				if err != nil {
+					return nil, err
+				}
+				if file.changed == nil {
+					file.changed = make(map[int]bool)
+				}
+				for _, line := range fileDiff.ChangedLines() {
+					file.changed[line] = true
+				}
+			}
+		case "code":
+			// Virtual files are kept apart from codebase files of the same path
+			files = append(files, reviewFile{
+				path:     target.FilePath,
+				targets:  []types.Target{target},
+				virtual:  true,
+				language: target.Language,
+				code:     target.Code,
+			})
+		}
+	}
+	return files, nil
+}
+
+// loadTargetDiff parse the raw diff of a diff target, or the git diff between its refs
+func loadTargetDiff(ctx context.Context, rootPath string, target types.Target) ([]*patch.FileDiff, error) {
+	diff := target.Diff
+	if diff == "" {
+		var paths []string
+		if target.FilePath != "" {
+			paths = append(paths, cleanRelativePath(target.FilePath))
+		}
+		output, err := git.Diff(ctx, rootPath, target.BaseRef, target.HeadRef, paths...)
+		if err != nil {
+			return nil, err
+		}
+		diff = output
+	}
+
+	fileDiffs, err := patch.Parse(diff)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("review_task.invalid_diff", "", nil), err)
+	}
+	return fileDiffs, nil
+}
+
+// refName name of the git ref a file is read from in messages
+func refName(ref string) string {
+	if ref == "" {
+		return i18n.Translate("review_task.working_tree", "", nil)
+	}
+	return ref
+}
+
+// withinPath whether the relative path is the prefix path or inside it, an empty prefix contains everything
+func withinPath(path, prefix string) bool {
+	if prefix == "" {
+		return true
+	}
+	prefix = cleanRelativePath(prefix)
+	return prefix == "." || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// prepareCodeTargets name the virtual files of code targets and resolve their language.
+// Unnamed snippets are named snippet-<n>, virtual file paths must be unique within a task.
+func prepareCodeTargets(targets []types.Target) error {
+	paths := make(map[string]bool)
+	snippets := 0
+	for i := range targets {
+		target := &targets[i]
+		if target.Type != "code" {
+			continue
+		}
+		if target.FilePath == "" {
+			snippets++
+			target.FilePath = fmt.Sprintf("snippet-%d", snippets)
+		}
+		target.FilePath = cleanRelativePath(target.FilePath)
+		if paths[target.FilePath] {
+			return fmt.Errorf("%s", i18n.Translate("review_task.duplicate_virtual_file", "", map[string]interface{}{"path": target.FilePath}))
+		}
+		paths[target.FilePath] = true
+
+		if target.Language == "" {
+			lang, err := language.Detect(target.FilePath)
+			if err != nil {
+				return fmt.Errorf("%s", i18n.Translate("review_task.unsupported_language", "", map[string]interface{}{"language": target.FilePath}))
+			}
+			target.Language = lang
+		}
+		target.Language = strings.ToLower(target.Language)
+		if !language.Supported(target.Language) {
+			return fmt.Errorf("%s", i18n.Translate("review_task.unsupported_language", "", map[string]interface{}{"language": target.Language}))
+		}
+	}
+	return nil
+}
+
+// toChunkFile convert the file into its subtask form
+func (f *reviewFile) toChunkFile() types.ReviewChunkFile {
+	chunkFile := types.ReviewChunkFile{
+		Path:    f.path,
+		Targets: f.targets,
+		Ref:     f.ref,
+		Virtual: f.virtual,
+	}
+	for line := range f.changed {
+		chunkFile.ChangedLines = append(chunkFile.ChangedLines, line)
+	}
+	sort.Ints(chunkFile.ChangedLines)
+	return chunkFile
+}
+
+// fromChunkFile restore the file of a subtask, virtual files take their content from their code target
+func fromChunkFile(chunkFile types.ReviewChunkFile) reviewFile {
+	file := reviewFile{
+		path:    chunkFile.Path,
+		targets: chunkFile.Targets,
+		ref:     chunkFile.Ref,
+		virtual: chunkFile.Virtual,
+	}
+	if len(chunkFile.ChangedLines) > 0 {
+		file.changed = make(map[int]bool, len(chunkFile.ChangedLines))
+		for _, line := range chunkFile.ChangedLines {
+			file.changed[line] = true
+		}
+	}
+	if file.virtual && len(file.targets) > 0 {
+		file.language = file.targets[0].Language
+		file.code = file.targets[0].Code
+	}
+	return file
+}
+
+// splitReviewFiles split the files into subtasks of at most size files, in file order
+func splitReviewFiles(reviewTaskID string, files []reviewFile, size int) []types.ReviewChunk {
+	if size <= 0 {
+		size = len(files)
+	}
+	var chunks []types.ReviewChunk
+	for start := 0; start < len(files); start += size {
+		chunk := types.ReviewChunk{ReviewTaskID: reviewTaskID, Index: len(chunks)}
+		for i := start; i < min(start+size, len(files)); i++ {
+			chunk.Files = append(chunk.Files, files[i].toChunkFile())
+		}
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
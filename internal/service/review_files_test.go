@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// gitCommit commit all changes of the repository at dir
+func gitCommit(t *testing.T, dir, message string) {
+	t.Helper()
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", message},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v %s", args[0], err, out)
+		}
+	}
+}
+
+func TestCollectReviewFiles_Subdirectory(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	_, repo := newTestReviewTaskService(t)
+	if out, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v %s", err, out)
+	}
+	writeCodebaseFile(t, repo, "svc/a.go", "package main\n\nfunc a() {}\n")
+	writeCodebaseFile(t, repo, "web/a.go", "package main\n\nfunc a() {}\n")
+	gitCommit(t, repo, "init")
+	writeCodebaseFile(t, repo, "svc/a.go", "package main\n\n// TODO: retry\nfunc a() {}\n")
+	writeCodebaseFile(t, repo, "web/a.go", "package main\n\n// TODO: cache\nfunc a() {}\n")
+	gitCommit(t, repo, "todo")
+
+	// The codebase is the svc directory of the repository, changes outside it are not reviewed
+	s := NewReviewTaskService(filepath.Join(repo, "svc"))
+	diff := types.Target{Type: "diff", BaseRef: "HEAD~1", HeadRef: "HEAD"}
+	_, issues := runReview(t, s, diff)
+	if len(issues) != 1 || issues[0].FilePath != "a.go" || issues[0].StartLine != 3 {
+		t.Fatalf("Expected the issue of svc/a.go only, got %+v", issues)
+	}
+	if issues[0].IssueCode == nil || !strings.Contains(*issues[0].IssueCode, "retry") {
+		t.Errorf("Expected the code of svc/a.go at HEAD, got %+v", issues[0])
+	}
+
+	// A file cannot be read both from the working tree and from a ref
+	for _, targets := range [][]types.Target{
+		{{Type: "file", FilePath: "a.go"}, diff},
+		{diff, {Type: "folder"}},
+	} {
+		if _, err := collectReviewFiles(context.Background(), filepath.Join(repo, "svc"), targets); err == nil {
+			t.Errorf("Expected a conflict of the sources of a.go for %+v", targets)
+		}
+	}
+	files, err := collectReviewFiles(context.Background(), filepath.Join(repo, "svc"), []types.Target{
+		{Type: "file", FilePath: "a.go"},
+		{Type: "diff", BaseRef: "HEAD~1"},
+	})
+	if err != nil || len(files) != 1 || files[0].ref != "" || !files[0].changed[3] {
+		t.Errorf("Expected the working tree diff merged into the file target, got %+v %v", files, err)
+	}
+}
+
+func TestPrepareCodeTargets(t *testing.T) {
+	targets := []types.Target{
+		{Type: "code", Language: "Go", Code: "package main\n"},
+		{Type: "file", FilePath: "a.go"},
+		{Type: "code", FilePath: "./pkg/a.py", Code: "print(1)\n"},
+		{Type: "code", Language: "go", Code: "package main\n"},
+	}
+	if err := prepareCodeTargets(targets); err != nil {
+		t.Fatalf("prepareCodeTargets failed: %v", err)
+	}
+	want := []struct{ path, language string }{{"snippet-1", "go"}, {"a.go", ""}, {"pkg/a.py", "python"}, {"snippet-2", "go"}}
+	for i, target := range targets {
+		if target.FilePath != want[i].path || target.Language != want[i].language {
+			t.Errorf("Target %d: expected %s in %q, got %s in %q", i, want[i].path, want[i].language, target.FilePath, target.Language)
+		}
+	}
+
+	for name, targets := range map[string][]types.Target{
+		"duplicate path":       {{Type: "code", FilePath: "a.go", Code: "a"}, {Type: "code", FilePath: "./a.go", Code: "b"}},
+		"duplicate snippet":    {{Type: "code", FilePath: "snippet-1", Language: "go", Code: "a"}, {Type: "code", Language: "go", Code: "b"}},
+		"unknown extension":    {{Type: "code", FilePath: "a.unknown", Code: "a"}},
+		"unsupported language": {{Type: "code", Language: "cobol", Code: "a"}},
+	} {
+		if err := prepareCodeTargets(targets); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// Virtual files are kept apart from the codebase file of the same path
+	files, err := collectReviewFiles(context.Background(), t.TempDir(), []types.Target{
+		{Type: "code", FilePath: "a.go", Language: "go", Code: "package main\n"},
+	})
+	if err != nil || len(files) != 1 || !files[0].virtual || files[0].language != "go" || files[0].code != "package main\n" {
+		t.Errorf("Expected a virtual file, got %+v %v", files, err)
+	}
+}
+
+func TestVirtualFiles(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n\n// TODO: retry\nfunc a() {}\n")
+	_, issues := runReview(t, s, types.Target{Type: "file", FilePath: "a.go"})
+	if len(issues) != 1 {
+		t.Fatalf("Expected the issue of a.go, got %+v", issues)
+	}
+	todo := issues[0]
+
+	// Unsaved code of a.go without the TODO reports its own issues and resolves nothing of the codebase
+	_, issues = runReview(t, s, types.Target{Type: "code", FilePath: "a.go", Code: "package main\n\nfunc a() {\n\tfmt.Println(1)\n}\n"})
+	if len(issues) != 1 || issues[0].RuleID != "debug-print" || issues[0].StartLine != 4 {
+		t.Fatalf("Expected only the debug print of the code, got %+v", issues)
+	}
+	debug := issues[0]
+	if _, issues = runReview(t, s, types.Target{Type: "file", FilePath: "a.go"}); len(issues) != 1 || issues[0].IssueID != todo.IssueID || issues[0].Change != types.IssueChangeUnchanged {
+		t.Errorf("Expected the issue of a.go unchanged, got %+v", issues)
+	}
+
+	// Fixes of virtual files are previewed on the inline code and never written to the codebase
+	issueService := NewIssueService()
+	if result, err := issueService.PreviewFix(context.Background(), debug.IssueID); err != nil || result.Applied {
+		t.Errorf("Expected the fix previewed, got %+v %v", result, err)
+	}
+	if _, err := issueService.ApplyFix(context.Background(), debug.IssueID); !errors.Is(err, ErrFixVirtualFile) {
+		t.Errorf("Expected ErrFixVirtualFile, got %v", err)
+	}
+}
+
+func TestVirtualFiles_SharedPath(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n\nfunc a() {\n\tfmt.Println(\"codebase\")\n}\n")
+	_, issues := runReview(t, s,
+		types.Target{Type: "file", FilePath: "a.go"},
+		types.Target{Type: "code", FilePath: "a.go", Code: "package main\n\nfunc a() {\n\tx := 1\n\tfmt.Println(x)\n}\n"})
+	var real, virtual *types.Issue
+	for i := range issues {
+		switch issues[i].StartLine {
+		case 4:
+			real = &issues[i]
+		case 5:
+			virtual = &issues[i]
+		}
+	}
+	if len(issues) != 2 || real == nil || virtual == nil {
+		t.Fatalf("Expected the debug prints of the file and of the code, got %+v", issues)
+	}
+
+	// The issue of the codebase file is fixed against the file even though the inline code has the same path
+	issueService := NewIssueService()
+	if result, err := issueService.ApplyFix(context.Background(), real.IssueID); err != nil || !result.Applied {
+		t.Fatalf("Expected the fix applied, got %+v %v", result, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "a.go")); string(content) != "package main\n\nfunc a() {\n}\n" {
+		t.Errorf("Expected the debug print removed from a.go, got %q", content)
+	}
+	if _, err := issueService.ApplyFix(context.Background(), virtual.IssueID); !errors.Is(err, ErrFixVirtualFile) {
+		t.Errorf("Expected ErrFixVirtualFile, got %v", err)
+	}
+}
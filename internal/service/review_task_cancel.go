@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/analyzer"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/policy"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// cancelPollInterval interval at which running subtasks check the cancellation flag of their task
+const cancelPollInterval = 500 * time.Millisecond
+
+var (
+	// ErrReviewTaskFinished review task has already reached a final status
+	ErrReviewTaskFinished = errors.New("review task already finished")
+
+	// errTaskCancelled cause of the run context of a subtask whose task was cancelled
+	errTaskCancelled = errors.New("review task cancelled")
+)
+
+// CancelTask flag a review task as cancelled and stop it with the issues stored so far.
+// Running subtasks notice the flag, stop parsing and add the issues of the files they reviewed to the partial result.
+func (s *ReviewTaskService) CancelTask(ctx context.Context, reviewTaskID string) (*types.ReviewTask, error) {
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+	if toReviewTask(task).IsFinished() {
+		return nil, ErrReviewTaskFinished
+	}
+
+	if err := s.chunks.Cancel(ctx, reviewTaskID); err != nil {
+		return nil, err
+	}
+	// A pending task has no subtask state yet, it stops here unless it was started in the meantime,
+	// then the task notices the flag once its subtasks are started
+	if task.Status == types.ReviewTaskStatusPending {
+		task.Status = types.ReviewTaskStatusCancelled
+		task.Error = stopReason(task.Status)
+		stopped, err := s.taskRepo.UpdateIfStatus(ctx, task, types.ReviewTaskStatusPending)
+		if err != nil {
+			return nil, err
+		}
+		if stopped {
+			s.publishTask(ctx, task)
+			s.notifyWebhooks(ctx, types.WebhookEventTaskCancelled, task)
+			return toReviewTask(task), nil
+		}
+	}
+
+	if err := s.commitChunks(ctx, reviewTaskID, types.ReviewTaskStatusCancelled); err != nil {
+		return nil, err
+	}
+	return s.GetTask(ctx, reviewTaskID)
+}
+
+// taskContext run context of a subtask, done at the task deadline or when the task is flagged as cancelled
+func (s *ReviewTaskService) taskContext(ctx context.Context, task *model.ReviewTask) (context.Context, context.CancelFunc) {
+	cancelDeadline := context.CancelFunc(func() {})
+	if deadline, ok := taskDeadline(task); ok {
+		ctx, cancelDeadline = context.WithDeadline(ctx, deadline)
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if cancelled, err := s.chunks.Cancelled(ctx, task.ReviewTaskID); err == nil && cancelled {
+					cancel(errTaskCancelled)
+					return
+				}
+			}
+		}
+	}()
+
+	return ctx, func() {
+		cancel(nil)
+		cancelDeadline()
+	}
+}
+
+// stopStatus status a task stops with when the run context of its subtask is done,
+// empty when the run was interrupted for another reason such as a worker shutdown, so that the subtask is retried
+func (s *ReviewTaskService) stopStatus(ctx context.Context, runCtx context.Context, task *model.ReviewTask) string {
+	if errors.Is(context.Cause(runCtx), errTaskCancelled) {
+		return types.ReviewTaskStatusCancelled
+	}
+	// The queue cancels the subtasks of a cancelled task before they notice the flag
+	if cancelled, err := s.chunks.Cancelled(ctx, task.ReviewTaskID); err == nil && cancelled {
+		return types.ReviewTaskStatusCancelled
+	}
+	if deadline, ok := taskDeadline(task); ok && !time.Now().Before(deadline) {
+		return types.ReviewTaskStatusTimeout
+	}
+	return ""
+}
+
+// stopReason error message recorded for a task stopped with the status
+func stopReason(status string) string {
+	return i18n.Translate("review_task.stopped."+status, "", nil)
+}
+
+// taskDeadline time the task must be done by, counted from its start
+func taskDeadline(task *model.ReviewTask) (time.Time, bool) {
+	timeout := config.GetConfig().Review.TaskTimeout
+	if timeout <= 0 || task.StartedAt == nil {
+		return time.Time{}, false
+	}
+	return task.StartedAt.Add(time.Duration(timeout) * time.Second), true
+}
+
+// reviewFileWithTimeout review a file within the configured per-file deadline
+func (s *ReviewTaskService) reviewFileWithTimeout(ctx context.Context, task *model.ReviewTask, file reviewFile, analyzers []analyzer.Analyzer, previous *previousReview, baseline map[string]bool, policies *policy.Set) (*fileReview, error) {
+	if timeout := config.GetConfig().Review.FileTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+		defer cancel()
+	}
+	return s.reviewFile(ctx, task, file, analyzers, previous, baseline, policies)
+}
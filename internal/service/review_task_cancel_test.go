@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// prepareTwoChunks create a review task of two files with an issue each, split into a subtask per file
+func prepareTwoChunks(t *testing.T) (*ReviewTaskService, string, []types.ReviewChunk) {
+	t.Helper()
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.ChunkSize = 1
+	writeCodebaseFile(t, dir, "a.go", "package main\n\n// TODO: retry\nfunc a() {}\n")
+	writeCodebaseFile(t, dir, "b.go", "package main\n\n// TODO: close\nfunc b() {}\n")
+
+	ctx := context.Background()
+	task, _, err := s.CreateTask(ctx, "test", "", []types.Target{{Type: "folder"}}, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	chunks, err := s.PrepareTask(ctx, task.ReviewTaskID)
+	if err != nil || len(chunks) != 2 {
+		t.Fatalf("Expected 2 subtasks, got %d %v", len(chunks), err)
+	}
+	if err := s.RunChunk(ctx, chunks[0]); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	return s, task.ReviewTaskID, chunks
+}
+
+func TestCancelTask_PartialResult(t *testing.T) {
+	s, reviewTaskID, chunks := prepareTwoChunks(t)
+	task, err := s.CancelTask(context.Background(), reviewTaskID)
+	if err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	if task.Status != types.ReviewTaskStatusCancelled || task.Error == "" {
+		t.Errorf("Expected the task cancelled, got %+v", task)
+	}
+
+	// Subtasks of the stopped task do nothing
+	if err := s.RunChunk(context.Background(), chunks[1]); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	task, issues := reviewResult(t, s, reviewTaskID)
+	if task.Status != types.ReviewTaskStatusCancelled || len(issues) != 1 || issues[0].FilePath != "a.go" {
+		t.Errorf("Expected the issue of the first subtask kept, got %s %+v", task.Status, issues)
+	}
+	if _, err := s.CancelTask(context.Background(), reviewTaskID); err != ErrReviewTaskFinished {
+		t.Errorf("Expected ErrReviewTaskFinished, got %v", err)
+	}
+}
+
+func TestRunChunk_TimeoutPartialResult(t *testing.T) {
+	s, reviewTaskID, chunks := prepareTwoChunks(t)
+	config.GetConfig().Review.TaskTimeout = 60
+	task, err := s.getTaskModel(context.Background(), reviewTaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	startedAt := time.Now().Add(-time.Hour)
+	task.StartedAt = &startedAt
+	if err := s.taskRepo.Update(context.Background(), task); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RunChunk(context.Background(), chunks[1]); err != nil {
+		t.Fatalf("RunChunk failed: %v", err)
+	}
+	result, issues := reviewResult(t, s, reviewTaskID)
+	if result.Status != types.ReviewTaskStatusTimeout || len(issues) != 1 || issues[0].FilePath != "a.go" {
+		t.Errorf("Expected the task timed out with the issue of the first subtask, got %s %+v", result.Status, issues)
+	}
+}
+
+func TestCancelTask_Pending(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n")
+	ctx := context.Background()
+	targets := []types.Target{{Type: "file", FilePath: "a.go"}}
+
+	created, _, err := s.CreateTask(ctx, "test", "", targets, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if task, err := s.CancelTask(ctx, created.ReviewTaskID); err != nil || task.Status != types.ReviewTaskStatusCancelled {
+		t.Fatalf("Expected the pending task cancelled, got %+v %v", task, err)
+	}
+	if chunks, err := s.PrepareTask(ctx, created.ReviewTaskID); err != nil || len(chunks) != 0 {
+		t.Errorf("Expected the cancelled task not started, got %d subtasks %v", len(chunks), err)
+	}
+
+	// A task started after the cancellation read it as pending is not overwritten, it stops once prepared
+	created, _, err = s.CreateTask(ctx, "test", "", targets, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	stale, err := s.getTaskModel(ctx, created.ReviewTaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.Status = types.ReviewTaskStatusRunning
+	if err := s.taskRepo.Update(ctx, stale); err != nil {
+		t.Fatal(err)
+	}
+	stale.Status = types.ReviewTaskStatusCancelled
+	if updated, err := s.taskRepo.UpdateIfStatus(ctx, stale, types.ReviewTaskStatusPending); err != nil || updated {
+		t.Fatalf("Expected the running task kept, got %v %v", updated, err)
+	}
+	if _, err := s.CancelTask(ctx, created.ReviewTaskID); err != nil {
+		t.Fatalf("CancelTask failed: %v", err)
+	}
+	if chunks, err := s.PrepareTask(ctx, created.ReviewTaskID); err != nil || len(chunks) != 0 {
+		t.Fatalf("Expected no subtasks, got %d %v", len(chunks), err)
+	}
+	if task, _ := s.GetTask(ctx, created.ReviewTaskID); task.Status != types.ReviewTaskStatusCancelled {
+		t.Errorf("Expected the task cancelled once prepared, got %s", task.Status)
+	}
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/pubsub"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// reviewTaskEventChannel pub/sub channel of the events of a review task
+func reviewTaskEventChannel(reviewTaskID string) string {
+	return "review_task:" + reviewTaskID + ":events"
+}
+
+// publishEvent publish an event of a review task, failures only lose the event for live subscribers
+func (s *ReviewTaskService) publishEvent(ctx context.Context, event types.ReviewTaskEvent) {
+	data, err := json.Marshal(event)
+	if err == nil {
+		err = pubsub.Publish(ctx, reviewTaskEventChannel(event.ReviewTaskID), data)
+	}
+	if err != nil {
+		logger.Warn(i18n.Translate("review_task.publish_event_failed", "", nil), "review_task_id", event.ReviewTaskID, "type", event.Type, "error", err)
+	}
+}
+
+// publishTask publish the progress of a review task, or its finished event once it reached a final status
+func (s *ReviewTaskService) publishTask(ctx context.Context, task *model.ReviewTask) {
+	event := types.ReviewTaskEvent{
+		Type:         types.ReviewTaskEventProgress,
+		ReviewTaskID: task.ReviewTaskID,
+		Task:         toReviewTask(task),
+	}
+	if event.Task.IsFinished() {
+		event.Type = types.ReviewTaskEventFinished
+	}
+	s.publishEvent(ctx, event)
+}
+
+// publishIssues publish the issues stored for a review task, offset is their position in the incremental result
+func (s *ReviewTaskService) publishIssues(ctx context.Context, reviewTaskID string, offset int, issues []*model.ReviewIssue) {
+	if len(issues) == 0 {
+		return
+	}
+	event := types.ReviewTaskEvent{
+		Type:         types.ReviewTaskEventIssues,
+		ReviewTaskID: reviewTaskID,
+		Offset:       offset,
+		NextOffset:   offset + len(issues),
+		Issues:       make([]types.Issue, 0, len(issues)),
+	}
+	for _, issue := range issues {
+		event.Issues = append(event.Issues, toIssue(issue))
+	}
+	s.publishEvent(ctx, event)
+}
+
+// finishedWebhookEvents webhook events of the final statuses of a review task
+var finishedWebhookEvents = map[string]string{
+	types.ReviewTaskStatusDone:      types.WebhookEventTaskCompleted,
+	types.ReviewTaskStatusFailed:    types.WebhookEventTaskFailed,
+	types.ReviewTaskStatusCancelled: types.WebhookEventTaskCancelled,
+	types.ReviewTaskStatusTimeout:   types.WebhookEventTaskTimeout,
+}
+
+// notifyWebhooks notify the webhooks of a lifecycle event of a review task
+func (s *ReviewTaskService) notifyWebhooks(ctx context.Context, event string, task *model.ReviewTask) {
+	s.webhooks.Notify(ctx, event, toReviewTask(task))
+}
+
+// notifyTaskChange notify the webhooks of the final status a review task reached since its previous state,
+// or of the progress milestones it passed while still running
+func (s *ReviewTaskService) notifyTaskChange(ctx context.Context, previous *types.ReviewTask, task *model.ReviewTask) {
+	if previous.IsFinished() {
+		return
+	}
+	if event, ok := finishedWebhookEvents[task.Status]; ok {
+		s.notifyWebhooks(ctx, event, task)
+		return
+	}
+	for _, milestone := range config.GetConfig().Webhook.ProgressMilestones {
+		percent := float64(milestone) / 100
+		if previous.Progress < percent && task.Progress >= percent {
+			s.notifyWebhooks(ctx, types.WebhookEventTaskProgress, task)
+			return
+		}
+	}
+}
+
+// SubscribeEvents stream the events of a review task from the issue offset on, until the task is finished or ctx is done.
+// The stream starts with the issues already stored after offset and the current progress, then relays published events.
+// Issues are delivered exactly once in incremental result order, issues missed by the relay are read from storage.
+func (s *ReviewTaskService) SubscribeEvents(ctx context.Context, reviewTaskID string, offset int) (<-chan types.ReviewTaskEvent, error) {
+	if _, err := s.getTaskModel(ctx, reviewTaskID); err != nil {
+		return nil, err
+	}
+	// Subscribe before reading the stored state, so that no event falls in between
+	sub, err := pubsub.Subscribe(ctx, reviewTaskEventChannel(reviewTaskID))
+	if err != nil {
+		return nil, err
+	}
+
+	events := make(chan types.ReviewTaskEvent)
+	go func() {
+		defer close(events)
+		defer sub.Close()
+
+		send := func(event types.ReviewTaskEvent) bool {
+			select {
+			case events <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		// catchUp send the stored issues after offset, and the task progress when withTask
+		catchUp := func(withTask bool) (finished bool, ok bool) {
+			result, err := s.GetIncrementResult(ctx, reviewTaskID, offset, 0)
+			if err != nil {
+				return false, false
+			}
+			if len(result.Issues) > 0 {
+				if !send(types.ReviewTaskEvent{
+					Type:         types.ReviewTaskEventIssues,
+					ReviewTaskID: reviewTaskID,
+					Offset:       offset,
+					NextOffset:   result.NextOffset,
+					Issues:       result.Issues,
+				}) {
+					return false, false
+				}
+				offset = result.NextOffset
+			}
+			if !withTask {
+				return false, true
+			}
+			task, err := s.GetTask(ctx, reviewTaskID)
+			if err != nil {
+				return false, false
+			}
+			event := types.ReviewTaskEvent{Type: types.ReviewTaskEventProgress, ReviewTaskID: reviewTaskID, Task: task, Offset: offset, NextOffset: offset}
+			if task.IsFinished() {
+				event.Type = types.ReviewTaskEventFinished
+			}
+			return task.IsFinished(), send(event)
+		}
+
+		if finished, ok := catchUp(true); finished || !ok {
+			return
+		}
+		for {
+			var data []byte
+			select {
+			case <-ctx.Done():
+				return
+			case message, open := <-sub.Messages():
+				if !open {
+					return
+				}
+				data = message
+			}
+
+			var event types.ReviewTaskEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				continue
+			}
+			switch event.Type {
+			case types.ReviewTaskEventIssues:
+				if event.NextOffset <= offset {
+					continue
+				}
+				if event.Offset != offset {
+					if _, ok := catchUp(false); !ok {
+						return
+					}
+					continue
+				}
+				if !send(event) {
+					return
+				}
+				offset = event.NextOffset
+			case types.ReviewTaskEventFinished:
+				// Issues stored with the final status may not have been relayed yet
+				catchUp(true)
+				return
+			default:
+				event.Offset, event.NextOffset = offset, offset
+				if !send(event) {
+					return
+				}
+			}
+		}
+	}()
+	return events, nil
+}
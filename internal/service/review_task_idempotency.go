@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+	"github.com/zgsm/mock-kbcenter/pkg/git"
+	"github.com/zgsm/mock-kbcenter/pkg/redis"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+const (
+	// idempotencyWaitAttempts attempts to find the task of a key claimed by a concurrent submission
+	idempotencyWaitAttempts = 10
+	// idempotencyWaitInterval wait between the attempts
+	idempotencyWaitInterval = 50 * time.Millisecond
+)
+
+// ErrReviewTaskCreating a submission with the same idempotency key is still creating its task
+var ErrReviewTaskCreating = errors.New("review task with the same idempotency key is being created")
+
+// idempotencyStore review task IDs claimed by idempotency keys
+type idempotencyStore interface {
+	// Claim bind the key to the review task ID unless it is bound already, reporting whether it was bound now
+	Claim(ctx context.Context, key, reviewTaskID string, ttl time.Duration) (bool, error)
+	// Get review task ID bound to the key, empty when none
+	Get(ctx context.Context, key string) (string, error)
+	// Release unbind the key
+	Release(ctx context.Context, key string) error
+}
+
+// newIdempotencyStore store shared by the web processes through Redis, in memory when Redis is disabled
+func newIdempotencyStore() idempotencyStore {
+	if _, err := redis.GetClient(); err == nil {
+		return &redisIdempotencyStore{}
+	}
+	return defaultMemoryIdempotencyStore
+}
+
+// idempotencyStoreKey storage key of an idempotency key, scoped by client so that clients cannot see each other's tasks
+func idempotencyStoreKey(clientID, key string) string {
+	sum := sha256.Sum256([]byte(clientID + "\x00" + key))
+	return "review_task:idempotency:" + hex.EncodeToString(sum[:])
+}
+
+// deriveIdempotencyKey key of a submission without Idempotency-Key header, from the codebase, targets, baseline and head commit.
+// Nothing identifies the reviewed content outside a git repository or with uncommitted changes, so no key is derived then.
+func deriveIdempotencyKey(ctx context.Context, rootPath, codebasePath string, targets []types.Target, baseline *types.Baseline) string {
+	head, err := git.Head(ctx, rootPath)
+	if err != nil {
+		return ""
+	}
+	if clean, err := git.Clean(ctx, rootPath); err != nil || !clean {
+		return ""
+	}
+
+	data, err := json.Marshal(struct {
+		CodebasePath string          `json:"codebase_path"`
+		Targets      []types.Target  `json:"targets"`
+		Baseline     *types.Baseline `json:"baseline"`
+		Head         string          `json:"head"`
+	}{codebasePath, targets, baseline, head})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return "derived:" + hex.EncodeToString(sum[:])
+}
+
+// redisIdempotencyStore Redis adapter of idempotencyStore
+type redisIdempotencyStore struct{}
+
+func (s *redisIdempotencyStore) Claim(ctx context.Context, key, reviewTaskID string, ttl time.Duration) (bool, error) {
+	client, err := redis.GetClient()
+	if err != nil {
+		return false, err
+	}
+	return client.SetNX(ctx, key, reviewTaskID, ttl).Result()
+}
+
+func (s *redisIdempotencyStore) Get(ctx context.Context, key string) (string, error) {
+	reviewTaskID, err := redis.Get(key)
+	if errors.Is(err, goredis.Nil) {
+		return "", nil
+	}
+	return reviewTaskID, err
+}
+
+func (s *redisIdempotencyStore) Release(ctx context.Context, key string) error {
+	return redis.Del(key)
+}
+
+// memoryIdempotencyStore memory adapter of idempotencyStore
+type memoryIdempotencyStore struct {
+	mu   sync.Mutex
+	keys map[string]memoryIdempotencyKey
+}
+
+type memoryIdempotencyKey struct {
+	reviewTaskID string
+	expiresAt    time.Time
+}
+
+var defaultMemoryIdempotencyStore = &memoryIdempotencyStore{keys: make(map[string]memoryIdempotencyKey)}
+
+func (s *memoryIdempotencyStore) Claim(ctx context.Context, key, reviewTaskID string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+	if _, ok := s.keys[key]; ok {
+		return false, nil
+	}
+	s.keys[key] = memoryIdempotencyKey{reviewTaskID: reviewTaskID, expiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+// prune remove the expired keys, called with the lock held
+func (s *memoryIdempotencyStore) prune(now time.Time) {
+	for key, claimed := range s.keys {
+		if !now.Before(claimed.expiresAt) {
+			delete(s.keys, key)
+		}
+	}
+}
+
+func (s *memoryIdempotencyStore) Get(ctx context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	claimed, ok := s.keys[key]
+	if !ok || !time.Now().Before(claimed.expiresAt) {
+		return "", nil
+	}
+	return claimed.reviewTaskID, nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.keys, key)
+	return nil
+}
+
+// existingTask task created by the submission that claimed the key, waiting briefly for a concurrent submission to create it
+func (s *ReviewTaskService) existingTask(ctx context.Context, key string) (*types.ReviewTask, error) {
+	for attempt := 0; attempt < idempotencyWaitAttempts; attempt++ {
+		reviewTaskID, err := s.idempotency.Get(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if reviewTaskID != "" {
+			task, err := s.GetTask(ctx, reviewTaskID)
+			if !errors.Is(err, ErrReviewTaskNotFound) {
+				return task, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(idempotencyWaitInterval):
+		}
+	}
+	return nil, ErrReviewTaskCreating
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryIdempotencyStore{keys: make(map[string]memoryIdempotencyKey)}
+
+	if claimed, _ := store.Claim(ctx, "k", "rt-1", time.Minute); !claimed {
+		t.Fatal("Expected the key claimed")
+	}
+	if claimed, _ := store.Claim(ctx, "k", "rt-2", time.Minute); claimed {
+		t.Error("Expected the key bound already")
+	}
+	if reviewTaskID, _ := store.Get(ctx, "k"); reviewTaskID != "rt-1" {
+		t.Errorf("Expected rt-1, got %q", reviewTaskID)
+	}
+
+	_ = store.Release(ctx, "k")
+	if reviewTaskID, _ := store.Get(ctx, "k"); reviewTaskID != "" {
+		t.Errorf("Expected the key released, got %q", reviewTaskID)
+	}
+	// Expired keys are claimed again
+	_, _ = store.Claim(ctx, "k", "rt-3", -time.Second)
+	if claimed, _ := store.Claim(ctx, "k", "rt-4", time.Minute); !claimed {
+		t.Error("Expected the expired key claimed")
+	}
+
+	// Expired keys that are never claimed again are removed
+	_, _ = store.Claim(ctx, "other", "rt-5", -time.Second)
+	_, _ = store.Claim(ctx, "next", "rt-6", time.Minute)
+	if _, ok := store.keys["other"]; ok || len(store.keys) != 2 {
+		t.Errorf("Expected the expired key removed, got %v", store.keys)
+	}
+}
+
+func TestCreateTask_Idempotency(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.IdempotencyTTL = 60
+	writeCodebaseFile(t, dir, "a.go", "package main\n")
+	ctx := context.Background()
+	targets := []types.Target{{Type: "file", FilePath: "a.go"}}
+	// Keys of the shared memory store are unique to the run
+	key := "k-" + dir
+	create := func(clientID, key string) (*types.ReviewTask, bool) {
+		t.Helper()
+		task, existing, err := s.CreateTask(ctx, clientID, "", targets, nil, key)
+		if err != nil {
+			t.Fatalf("CreateTask failed: %v", err)
+		}
+		return task, existing
+	}
+
+	first, existing := create("ide-1", key)
+	if existing {
+		t.Fatal("Expected a new task")
+	}
+	if replayed, existing := create("ide-1", key); !existing || replayed.ReviewTaskID != first.ReviewTaskID {
+		t.Errorf("Expected the first task replayed, got %+v", replayed)
+	}
+	if other, existing := create("ide-2", key); existing || other.ReviewTaskID == first.ReviewTaskID {
+		t.Errorf("Expected keys scoped by client, got %+v", other)
+	}
+
+	// A task that could not be dispatched fails and leaves the key to the retried submission
+	cause := errors.New("queue unavailable")
+	if err := s.AbandonTask(ctx, first.ReviewTaskID, cause); !errors.Is(err, cause) {
+		t.Fatalf("Expected the cause, got %v", err)
+	}
+	if abandoned, _ := s.GetTask(ctx, first.ReviewTaskID); abandoned.Status != types.ReviewTaskStatusFailed {
+		t.Errorf("Expected the abandoned task failed, got %s", abandoned.Status)
+	}
+	if retried, existing := create("ide-1", key); existing || retried.ReviewTaskID == first.ReviewTaskID {
+		t.Errorf("Expected a new task for the retried submission, got %+v", retried)
+	}
+
+	// Outside a git repository no key is derived
+	if _, existing := create("ide-1", ""); existing {
+		t.Error("Expected no derived key outside a git repository")
+	}
+	if _, existing := create("ide-1", ""); existing {
+		t.Error("Expected no derived key outside a git repository")
+	}
+}
+
+func TestDeriveIdempotencyKey(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.IdempotencyTTL = 60
+	ctx := context.Background()
+	targets := []types.Target{{Type: "file", FilePath: "a.go"}}
+	derive := func(targets []types.Target) string {
+		return deriveIdempotencyKey(ctx, dir, dir, targets, nil)
+	}
+
+	writeCodebaseFile(t, dir, "a.go", "package main\n")
+	if key := derive(targets); key != "" {
+		t.Errorf("Expected no key outside a git repository, got %q", key)
+	}
+	if out, err := exec.Command("git", "init", "-q", dir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v %s", err, out)
+	}
+	gitCommit(t, dir, "init")
+
+	key := derive(targets)
+	if key == "" || derive(targets) != key {
+		t.Fatalf("Expected a stable key of the clean worktree, got %q", key)
+	}
+	if derive([]types.Target{{Type: "folder"}}) == key {
+		t.Error("Expected other targets to derive another key")
+	}
+
+	// Uncommitted changes are not identified by the head commit
+	writeCodebaseFile(t, dir, "a.go", "package main\n\nfunc a() {}\n")
+	if changed := derive(targets); changed != "" {
+		t.Errorf("Expected no key with uncommitted changes, got %q", changed)
+	}
+	gitCommit(t, dir, "a")
+	if committed := derive(targets); committed == "" || committed == key {
+		t.Errorf("Expected another key at the new head, got %q", committed)
+	}
+
+	// Submissions without a key replay the task of the same head
+	first, _, err := s.CreateTask(ctx, "ci", "", targets, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if replayed, existing, err := s.CreateTask(ctx, "ci", "", targets, nil, ""); err != nil || !existing || replayed.ReviewTaskID != first.ReviewTaskID {
+		t.Errorf("Expected the task of the derived key replayed, got %+v %v", replayed, err)
+	}
+}
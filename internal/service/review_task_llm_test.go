@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/internal/analyzer"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// useFakeLLM point the configured llm service at a fake model server
+func useFakeLLM(t *testing.T) *thirdPlatform.FakeLLMServer {
+	t.Helper()
+	fake := thirdPlatform.NewFakeLLMServer()
+	t.Cleanup(fake.Close)
+
+	services := config.GetConfig().HTTPClient.Services
+	previous := services[thirdPlatform.TypeLLM]
+	llm := previous
+	llm.BaseURL = fake.URL + "/v1"
+	llm.AuthType = "none"
+	services[thirdPlatform.TypeLLM] = llm
+	t.Cleanup(func() { services[thirdPlatform.TypeLLM] = previous })
+	if err := thirdPlatform.InitHTTPClient(); err != nil {
+		t.Fatalf("InitHTTPClient failed: %v", err)
+	}
+	return fake
+}
+
+func TestRunTask_LLMAnalyzer(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.Analyzers = []string{analyzer.RuleAnalyzerName, analyzer.LLMAnalyzerName}
+	fake := useFakeLLM(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n\n// TODO: rotate\nfunc connect() {\n\tpassword := \"hunter2\"\n\tuse(password)\n}\n")
+
+	task, issues := runReview(t, s, types.Target{Type: "file", FilePath: "a.go"})
+	if task.Status != types.ReviewTaskStatusDone {
+		t.Fatalf("Expected the task done, got %+v", task)
+	}
+	if len(fake.Requests()) != 1 {
+		t.Errorf("Expected one model request for the function, got %d", len(fake.Requests()))
+	}
+
+	want := map[string]struct {
+		line, confidence int
+		severity         string
+	}{
+		"todo-comment":             {3, 90, types.SeverityLow},
+		"llm/hardcoded-credential": {5, 85, types.SeverityHigh},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Expected %d issues, got %+v", len(want), issues)
+	}
+	for _, issue := range issues {
+		expected, ok := want[issue.RuleID]
+		if !ok || issue.StartLine != expected.line || issue.Confidence != expected.confidence || issue.Severity != expected.severity {
+			t.Errorf("Unexpected issue %s: line %d, confidence %d, severity %s", issue.RuleID, issue.StartLine, issue.Confidence, issue.Severity)
+		}
+	}
+}
+
+func TestRunTask_LLMStoredPrompt(t *testing.T) {
+	s, dir := newTestReviewTaskService(t)
+	config.GetConfig().Review.Analyzers = []string{analyzer.LLMAnalyzerName}
+	fake := useFakeLLM(t)
+	writeCodebaseFile(t, dir, "a.go", "package main\n\nfunc run() {}\n")
+
+	cfg := &config.GetConfig().LLM
+	previous := cfg.PromptTemplate
+	cfg.PromptTemplate = t.Name()
+	t.Cleanup(func() { cfg.PromptTemplate = previous })
+	prompts := NewPromptTemplateService()
+	_, err := prompts.CreatePromptTemplate(context.Background(), t.Name(), PromptTemplateInput{
+		SystemPrompt: "Stored review prompt.",
+		UserPrompt:   "{{.Code}}",
+	})
+	if err != nil {
+		t.Fatalf("CreatePromptTemplate failed: %v", err)
+	}
+	t.Cleanup(func() { _ = prompts.DeletePromptTemplate(context.Background(), t.Name()) })
+
+	runReview(t, s, types.Target{Type: "file", FilePath: "a.go"})
+	if requests := fake.Requests(); len(requests) != 1 || requests[0].Messages[0].Content != "Stored review prompt." {
+		t.Errorf("Expected the active stored template, got %+v", requests)
+	}
+}
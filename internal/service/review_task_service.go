@@ -0,0 +1,786 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/analyzer"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/policy"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/idgen"
+	"github.com/zgsm/mock-kbcenter/pkg/language"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/patch"
+	"github.com/zgsm/mock-kbcenter/pkg/report"
+	"github.com/zgsm/mock-kbcenter/pkg/thirdPlatform"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// reportToolName tool name written into exported reports
+const reportToolName = "mock-kbcenter"
+
+// ErrReviewTaskNotFound review task does not exist
+var ErrReviewTaskNotFound = errors.New("review task not found")
+
+// ErrInvalidReviewTask targets of a new review task are invalid
+var ErrInvalidReviewTask = errors.New("invalid review task")
+
+// ReviewTaskService review task business logic: creation, execution and result queries
+type ReviewTaskService struct {
+	baseDir     string
+	taskRepo    repository.ReviewTaskRepository
+	issueRepo   repository.ReviewIssueRepository
+	chunks      chunkTracker
+	idempotency idempotencyStore
+	webhooks    *WebhookService
+}
+
+// NewReviewTaskService create review task service, baseDir is the codebase root of new tasks
+func NewReviewTaskService(baseDir string) *ReviewTaskService {
+	return &ReviewTaskService{
+		baseDir:     baseDir,
+		taskRepo:    repository.NewReviewTaskRepository(),
+		issueRepo:   repository.NewReviewIssueRepository(),
+		chunks:      newChunkTracker(),
+		idempotency: newIdempotencyStore(),
+		webhooks:    NewWebhookService(),
+	}
+}
+
+// CreateTask validate targets and persist a pending review task, issues of the optional baseline are filtered out of the review.
+// Submissions of a client with the same idempotency key, or the same derived key when the key is empty, return the task
+// created first instead of a new one, existing reports whether the task was created before.
+func (s *ReviewTaskService) CreateTask(ctx context.Context, clientID, codebasePath string, targets []types.Target, baseline *types.Baseline, idempotencyKey string) (task *types.ReviewTask, existing bool, err error) {
+	if len(targets) == 0 {
+		return nil, false, fmt.Errorf("%w: %s", ErrInvalidReviewTask, i18n.Translate("review_task.empty_targets", "", nil))
+	}
+	for i := range targets {
+		if err := targets[i].Validate(); err != nil {
+			return nil, false, fmt.Errorf("%w: %v", ErrInvalidReviewTask, err)
+		}
+	}
+	if err := prepareCodeTargets(targets); err != nil {
+		return nil, false, fmt.Errorf("%w: %v", ErrInvalidReviewTask, err)
+	}
+
+	rootPath, err := filepath.Abs(s.baseDir)
+	if err != nil {
+		return nil, false, err
+	}
+	reviewTaskID, err := idgen.GenerateString()
+	if err != nil {
+		return nil, false, err
+	}
+	if codebasePath == "" {
+		codebasePath = rootPath
+	}
+
+	storeKey := ""
+	if ttl := config.GetConfig().Review.IdempotencyTTL; ttl > 0 {
+		if idempotencyKey == "" {
+			idempotencyKey = deriveIdempotencyKey(ctx, rootPath, codebasePath, targets, baseline)
+		}
+		if idempotencyKey != "" {
+			key := idempotencyStoreKey(clientID, idempotencyKey)
+			claimed, err := s.idempotency.Claim(ctx, key, reviewTaskID, time.Duration(ttl)*time.Second)
+			if err != nil {
+				return nil, false, err
+			}
+			if !claimed {
+				task, err := s.existingTask(ctx, key)
+				return task, err == nil, err
+			}
+			storeKey = key
+			// A failed creation leaves the key to the next submission
+			defer func() {
+				if err != nil {
+					if releaseErr := s.idempotency.Release(context.WithoutCancel(ctx), key); releaseErr != nil {
+						logger.Warn(i18n.Translate("review_task.release_idempotency_key_failed", "", nil), "error", releaseErr)
+					}
+				}
+			}()
+		}
+	}
+
+	taskModel := &model.ReviewTask{
+		ReviewTaskID:   reviewTaskID,
+		ClientID:       clientID,
+		CodebasePath:   codebasePath,
+		RootPath:       rootPath,
+		Targets:        targets,
+		Status:         types.ReviewTaskStatusPending,
+		IdempotencyKey: storeKey,
+	}
+	if baseline != nil {
+		taskModel.Baseline = baselineFingerprints(baseline)
+	}
+	if err := s.taskRepo.Create(ctx, taskModel); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", i18n.Translate("review_task.create_failed", "", nil), err)
+	}
+	s.notifyWebhooks(ctx, types.WebhookEventTaskCreated, taskModel)
+
+	return toReviewTask(taskModel), false, nil
+}
+
+// GetTask get review task by ID
+func (s *ReviewTaskService) GetTask(ctx context.Context, reviewTaskID string) (*types.ReviewTask, error) {
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+	return toReviewTask(task), nil
+}
+
+// GetIncrementResult get task progress and the issues found after offset, limit <= 0 means all remaining issues
+func (s *ReviewTaskService) GetIncrementResult(ctx context.Context, reviewTaskID string, offset, limit int) (*types.IssueIncrementReviewTaskResult, error) {
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	offset = max(offset, 0)
+	total, err := s.issueRepo.CountByReviewTask(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+	issues, err := s.issueRepo.ListByReviewTask(ctx, reviewTaskID, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &types.IssueIncrementReviewTaskResult{
+		IsDone:     toReviewTask(task).IsFinished(),
+		Progress:   task.Progress,
+		Total:      int(total),
+		NextOffset: offset + len(issues),
+		Issues:     make([]types.Issue, 0, len(issues)),
+		Suppressed: task.SuppressedIssues,
+		Baselined:  task.BaselineIssues,
+		Filtered:   task.FilteredIssues,
+	}
+	for _, issue := range issues {
+		result.Issues = append(result.Issues, toIssue(issue))
+	}
+	return result, nil
+}
+
+// ListIssues list the issues found by a review task in the order they were found, without the resolved ones
+func (s *ReviewTaskService) ListIssues(ctx context.Context, reviewTaskID string) ([]types.Issue, error) {
+	if _, err := s.getTaskModel(ctx, reviewTaskID); err != nil {
+		return nil, err
+	}
+
+	issues, err := s.issueRepo.ListByReviewTask(ctx, reviewTaskID, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]types.Issue, 0, len(issues))
+	for _, issue := range issues {
+		if issue.Change == types.IssueChangeResolved {
+			continue
+		}
+		list = append(list, toIssue(issue))
+	}
+	return list, nil
+}
+
+// ExportReport render the issues of a review task as a report of the given format
+func (s *ReviewTaskService) ExportReport(ctx context.Context, reviewTaskID, format, locale string) ([]byte, error) {
+	if !report.IsSupported(format) {
+		return nil, fmt.Errorf("%s", i18n.Translate("report.unsupported_format", "", map[string]interface{}{"format": format}))
+	}
+
+	issues, err := s.ListIssues(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return report.Render(format, report.Meta{
+		ReviewTaskID: reviewTaskID,
+		ToolName:     reportToolName,
+		GeneratedAt:  utils.FormatTime(time.Now(), ""),
+		Locale:       locale,
+	}, issues)
+}
+
+// RunTask execute a review task in the current process, running its subtasks one after another
+func (s *ReviewTaskService) RunTask(ctx context.Context, reviewTaskID string) error {
+	chunks, err := s.PrepareTask(ctx, reviewTaskID)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := s.RunChunk(ctx, chunk); err != nil {
+			return s.FailTask(ctx, reviewTaskID, err)
+		}
+	}
+	return nil
+}
+
+// PrepareTask start a review task: collect the target files and split them into subtasks.
+// A task without files to review is finished at once and has no subtasks.
+// Preparing a running task again, as a retried run does, returns only the subtasks not committed yet.
+func (s *ReviewTaskService) PrepareTask(ctx context.Context, reviewTaskID string) ([]types.ReviewChunk, error) {
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		return nil, err
+	}
+	if toReviewTask(task).IsFinished() {
+		return nil, nil
+	}
+
+	// A task cancelled since it was read is not started
+	status := task.Status
+	task.Status = types.ReviewTaskStatusRunning
+	task.Error = ""
+	firstRun := task.StartedAt == nil
+	if firstRun {
+		now := time.Now()
+		task.StartedAt = &now
+	}
+	if started, err := s.taskRepo.UpdateIfStatus(ctx, task, status); err != nil || !started {
+		return nil, err
+	}
+	if firstRun {
+		s.notifyWebhooks(ctx, types.WebhookEventTaskStarted, task)
+	}
+
+	files, err := collectReviewFiles(ctx, task.RootPath, task.Targets)
+	if err != nil {
+		return nil, s.failTask(ctx, task, err)
+	}
+	baseReviewTaskID, err := s.previousReviewTaskID(ctx, task)
+	if err != nil {
+		return nil, s.failTask(ctx, task, err)
+	}
+	if _, err := loadBaseline(task); err != nil {
+		return nil, s.failTask(ctx, task, err)
+	}
+
+	chunks := splitReviewFiles(reviewTaskID, files, config.GetConfig().Review.ChunkSize)
+	// A retried run of a task whose subtasks are started keeps their state and the results stored so far
+	resumed := status == types.ReviewTaskStatusRunning && task.Subtasks > 0 && task.Subtasks == len(chunks)
+	if resumed {
+		if resumed, err = s.chunks.Started(ctx, reviewTaskID); err != nil {
+			return nil, s.failTask(ctx, task, err)
+		}
+	}
+	if !resumed {
+		task.Total = len(files)
+		task.Processed, task.Progress = 0, 0
+		task.Subtasks = len(chunks)
+		task.CommittedChunks = nil
+		task.BaseReviewTaskID = baseReviewTaskID
+		task.NewIssues, task.UnchangedIssues, task.ResolvedIssues = 0, 0, 0
+		task.SuppressedIssues, task.BaselineIssues, task.FilteredIssues = 0, 0, 0
+		if err := s.taskRepo.Update(ctx, task); err != nil {
+			return nil, err
+		}
+		s.publishTask(ctx, task)
+		if err := s.chunks.Start(ctx, reviewTaskID, len(chunks)); err != nil {
+			return nil, s.failTask(ctx, task, err)
+		}
+	}
+	// Cancelled while the files were collected
+	if cancelled, err := s.chunks.Cancelled(ctx, reviewTaskID); err == nil && cancelled {
+		return nil, s.commitChunks(ctx, reviewTaskID, types.ReviewTaskStatusCancelled)
+	}
+
+	// Only the subtasks not committed yet are run again
+	committed := make(map[int]bool, len(task.CommittedChunks))
+	for _, index := range task.CommittedChunks {
+		committed[index] = true
+	}
+	pending := make([]types.ReviewChunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		if !committed[chunk.Index] {
+			pending = append(pending, chunk)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, s.commitChunks(ctx, reviewTaskID, "")
+	}
+	return pending, nil
+}
+
+// chunkResult result of a subtask waiting to be stored in subtask order
+type chunkResult struct {
+	Issues     []*model.ReviewIssue `json:"issues"`
+	Resolved   []*model.ReviewIssue `json:"resolved"`
+	Suppressed int                  `json:"suppressed"`
+	Baselined  int                  `json:"baselined"`
+	Filtered   int                  `json:"filtered"`
+}
+
+// RunChunk run the analyzers on the files of a subtask, then store the results of the subtasks completed so far in subtask order.
+// Running a subtask again replaces its result, so that failed subtasks are retried on their own.
+// A subtask of a cancelled or expired task stops at once and stores the results of the files reviewed so far.
+func (s *ReviewTaskService) RunChunk(ctx context.Context, chunk types.ReviewChunk) error {
+	task, err := s.getTaskModel(ctx, chunk.ReviewTaskID)
+	if err != nil {
+		return err
+	}
+	if toReviewTask(task).IsFinished() {
+		return nil
+	}
+	chunkFiles := make([]reviewFile, 0, len(chunk.Files))
+	for _, chunkFile := range chunk.Files {
+		chunkFiles = append(chunkFiles, fromChunkFile(chunkFile))
+	}
+	previous, err := s.loadPreviousReview(ctx, task, chunkFiles)
+	if err != nil {
+		return err
+	}
+	baseline, err := loadBaseline(task)
+	if err != nil {
+		return err
+	}
+
+	runCtx, cancel := s.taskContext(ctx, task)
+	defer cancel()
+
+	analyzers := analyzer.Enabled(config.GetConfig().Review.Analyzers)
+	policies := policy.ForTask(config.GetConfig().Review.Policies, task.CodebasePath, task.Targets)
+	result := &chunkResult{}
+	files := make([]reviewFile, 0, len(chunk.Files))
+	for _, file := range chunkFiles {
+		if runCtx.Err() != nil {
+			break
+		}
+		reviewed, err := s.reviewFileWithTimeout(runCtx, task, file, analyzers, previous, baseline, policies)
+		if runCtx.Err() != nil {
+			break
+		}
+		files = append(files, file)
+		if err != nil {
+			logger.Warn(i18n.Translate("review_task.review_file_failed", "", nil), "review_task_id", task.ReviewTaskID, "file", file.path, "error", err)
+			continue
+		}
+		result.Issues = append(result.Issues, reviewed.issues...)
+		result.Suppressed += reviewed.suppressed
+		result.Baselined += reviewed.baselined
+		result.Filtered += reviewed.filtered
+	}
+	// Previous issues of the reviewed files that were not found again are reported as resolved
+	result.Resolved = previous.resolved(task.ReviewTaskID, files)
+
+	// The results of a stopped subtask are still stored, with a context that is not done
+	stopStatus := ""
+	if runCtx.Err() != nil {
+		ctx = context.WithoutCancel(ctx)
+		if stopStatus = s.stopStatus(ctx, runCtx, task); stopStatus == "" {
+			return runCtx.Err()
+		}
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := s.chunks.Complete(ctx, task.ReviewTaskID, chunk.Index, len(files), data); err != nil {
+		return err
+	}
+	return s.commitChunks(ctx, task.ReviewTaskID, stopStatus)
+}
+
+// commitChunks store the issues of the completed subtasks that are next in subtask order,
+// update the task progress and finish the task once every subtask is stored.
+// With a stop status, the task stops with it and keeps every completed result, leaving out the subtasks not completed.
+// Results of subtasks completed after their task stopped are added to its partial result.
+func (s *ReviewTaskService) commitChunks(ctx context.Context, reviewTaskID, stopStatus string) error {
+	finished := false
+	err := s.chunks.Drain(ctx, reviewTaskID, stopStatus != "", func(results []completedChunk, progress chunkProgress) error {
+		task, err := s.getTaskModel(ctx, reviewTaskID)
+		if err != nil {
+			return err
+		}
+		if task.Status == types.ReviewTaskStatusDone || task.Status == types.ReviewTaskStatusFailed {
+			return nil
+		}
+		offset, err := s.issueRepo.CountByReviewTask(ctx, reviewTaskID)
+		if err != nil {
+			return err
+		}
+		// Every stored issue is counted by the task, issues past the counted ones were stored by a commit
+		// that failed before the task was updated and are not stored again
+		stored := make(map[string]bool)
+		if counted := int64(task.NewIssues + task.UnchangedIssues + task.ResolvedIssues); offset > counted {
+			leftovers, err := s.issueRepo.ListByReviewTask(ctx, reviewTaskID, int(counted), 0)
+			if err != nil {
+				return err
+			}
+			for _, issue := range leftovers {
+				stored[issue.IssueID] = true
+			}
+			offset = counted
+		}
+		committedChunks := make(map[int]bool, len(task.CommittedChunks))
+		for _, index := range task.CommittedChunks {
+			committedChunks[index] = true
+		}
+		previous := *toReviewTask(task)
+
+		var committed []*model.ReviewIssue
+		for _, chunk := range results {
+			// Committed before the subtask state failed to record it
+			if committedChunks[chunk.Index] {
+				continue
+			}
+			var result chunkResult
+			if err := json.Unmarshal(chunk.Result, &result); err != nil {
+				return err
+			}
+			issues := append(result.Issues, result.Resolved...)
+			created := make([]*model.ReviewIssue, 0, len(issues))
+			for _, issue := range issues {
+				if !stored[issue.IssueID] {
+					created = append(created, issue)
+				}
+			}
+			if err := s.issueRepo.CreateBatch(ctx, created); err != nil {
+				return err
+			}
+			committed = append(committed, issues...)
+			task.CommittedChunks = append(task.CommittedChunks, chunk.Index)
+			task.SuppressedIssues += result.Suppressed
+			task.BaselineIssues += result.Baselined
+			task.FilteredIssues += result.Filtered
+			task.ResolvedIssues += len(result.Resolved)
+			for _, issue := range result.Issues {
+				if issue.Change == types.IssueChangeUnchanged {
+					task.UnchangedIssues++
+				} else {
+					task.NewIssues++
+				}
+			}
+		}
+
+		task.Processed = progress.CompletedFiles
+		if task.Total > 0 {
+			task.Progress = float64(task.Processed) / float64(task.Total)
+		}
+		switch {
+		case toReviewTask(task).IsFinished():
+			// Stopped before, only the partial result grows
+		case stopStatus != "":
+			task.Status = stopStatus
+			task.Error = stopReason(stopStatus)
+		case progress.Committed+len(results) >= progress.Chunks:
+			task.Status = types.ReviewTaskStatusDone
+			task.Progress = 1
+			finished = true
+		}
+		if err := s.taskRepo.Update(ctx, task); err != nil {
+			return err
+		}
+
+		s.publishIssues(ctx, reviewTaskID, int(offset), committed)
+		s.publishTask(ctx, task)
+		s.notifyTaskChange(ctx, &previous, task)
+		return nil
+	})
+	if err != nil || !finished {
+		return err
+	}
+
+	if err := s.chunks.Clear(ctx, reviewTaskID); err != nil {
+		logger.Warn(i18n.Translate("review_task.clear_subtasks_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+	}
+	logger.Info(i18n.Translate("review_task.finished", "", nil), "review_task_id", reviewTaskID)
+	s.pushToIssueManager(ctx, reviewTaskID)
+	return nil
+}
+
+// AbandonTask mark a created review task that could not be dispatched as failed and return the cause.
+// Its idempotency key is released, so that a retried submission creates a new task.
+func (s *ReviewTaskService) AbandonTask(ctx context.Context, reviewTaskID string, cause error) error {
+	ctx = context.WithoutCancel(ctx)
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		logger.Error(i18n.Translate("review_task.update_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+		return cause
+	}
+	if task.IdempotencyKey != "" {
+		if err := s.idempotency.Release(ctx, task.IdempotencyKey); err != nil {
+			logger.Warn(i18n.Translate("review_task.release_idempotency_key_failed", "", nil), "error", err)
+		}
+	}
+	if toReviewTask(task).IsFinished() {
+		return cause
+	}
+	return s.failTask(ctx, task, cause)
+}
+
+// FailTask mark a running review task as failed, used when a subtask has failed for good
+func (s *ReviewTaskService) FailTask(ctx context.Context, reviewTaskID string, cause error) error {
+	task, err := s.getTaskModel(ctx, reviewTaskID)
+	if err != nil {
+		return err
+	}
+	if toReviewTask(task).IsFinished() {
+		return cause
+	}
+	return s.failTask(ctx, task, cause)
+}
+
+// fileReview issues found in a reviewed file, and the number of issues filtered out
+type fileReview struct {
+	issues     []*model.ReviewIssue
+	suppressed int // Filtered out by inline suppression comments
+	baselined  int // Filtered out by the baseline
+	filtered   int // Dropped by the issue policies
+}
+
+// reviewFile run analyzers on a single file and convert the issues into models
+func (s *ReviewTaskService) reviewFile(ctx context.Context, task *model.ReviewTask, file reviewFile, analyzers []analyzer.Analyzer, previous *previousReview, baseline map[string]bool, policies *policy.Set) (*fileReview, error) {
+	reviewed := &fileReview{}
+	// Unsaved code neither continues nor resolves the issues of the codebase
+	if file.virtual {
+		previous = &previousReview{}
+	}
+	lang := file.language
+	if lang == "" {
+		detected, err := language.Detect(file.path)
+		if err != nil {
+			return reviewed, nil
+		}
+		lang = detected
+	}
+	content, err := file.readContent(ctx, task.RootPath)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize := config.GetConfig().Review.MaxFileSize; maxSize > 0 && int64(len(content)) > maxSize {
+		logger.Info(i18n.Translate("review_task.file_too_large", "", nil), "file", file.path, "size", len(content))
+		return reviewed, nil
+	}
+
+	functions, err := language.ExtractFunctions(ctx, lang, string(content))
+	if err != nil {
+		logger.Warn(i18n.Translate("review_task.extract_functions_failed", "", nil), "file", file.path, "error", err)
+	}
+	source := analyzer.NewFile(file.path, lang, string(content), functions)
+	comments, err := language.ExtractComments(ctx, lang, string(content))
+	if err != nil {
+		logger.Warn(i18n.Translate("review_task.extract_comments_failed", "", nil), "file", file.path, "error", err)
+	}
+	// Parsing stopped at the deadline or on cancellation
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	source.SetComments(comments)
+
+	var found []types.Issue
+	for _, a := range analyzers {
+		analyzed, err := a.Analyze(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, analyzed...)
+	}
+	// Fingerprint all issues of the file before filtering, so occurrence order does not depend on the targets
+	analyzer.Fingerprint(ctx, source, found)
+
+	var candidates []types.Issue
+	for _, issue := range found {
+		if !file.inTargets(issue.StartLine, issue.EndLine, source.Functions) {
+			continue
+		}
+		// Filtered issues are still matched, so they are not reported as resolved
+		if source.Suppressed(&issue) {
+			previous.match(issue.Fingerprint)
+			reviewed.suppressed++
+			continue
+		}
+		if baseline[issue.Fingerprint] {
+			previous.match(issue.Fingerprint)
+			reviewed.baselined++
+			continue
+		}
+		candidates = append(candidates, issue)
+	}
+
+	// Policies see every remaining issue of the file at once, to cap the issues per file
+	kept, dropped := policies.Apply(file.path, candidates)
+	for _, issue := range dropped {
+		previous.match(issue.Fingerprint)
+		reviewed.filtered++
+	}
+	for _, issue := range kept {
+		if matched := previous.match(issue.Fingerprint); matched != nil {
+			issue.IssueID = matched.IssueID
+			issue.Status = matched.Status
+			issue.Assignee = matched.Assignee
+			issue.Change = types.IssueChangeUnchanged
+		} else {
+			issueID, err := idgen.GenerateString()
+			if err != nil {
+				return nil, err
+			}
+			issue.IssueID = issueID
+			issue.Change = types.IssueChangeNew
+		}
+		issue.FilePath = file.path
+		if len(issue.Edits) > 0 && issue.FixPatch == nil {
+			fixPatch, err := patch.Unified(file.path, source.Lines, issue.Edits, patch.DefaultContextLines)
+			if err != nil {
+				logger.Warn(i18n.Translate("review_task.render_fix_failed", "", nil), "file", file.path, "rule_id", issue.RuleID, "error", err)
+			} else {
+				issue.FixPatch = &fixPatch
+			}
+		}
+		issueModel := toReviewIssueModel(task.ReviewTaskID, issue)
+		issueModel.Virtual = file.virtual
+		reviewed.issues = append(reviewed.issues, issueModel)
+	}
+	return reviewed, nil
+}
+
+// failTask mark the review task as failed and return the cause
+func (s *ReviewTaskService) failTask(ctx context.Context, task *model.ReviewTask, cause error) error {
+	task.Status = types.ReviewTaskStatusFailed
+	task.Error = cause.Error()
+	if err := s.taskRepo.Update(ctx, task); err != nil {
+		logger.Error(i18n.Translate("review_task.update_failed", "", nil), "review_task_id", task.ReviewTaskID, "error", err)
+	} else {
+		s.publishTask(ctx, task)
+		s.notifyWebhooks(ctx, types.WebhookEventTaskFailed, task)
+	}
+	if err := s.chunks.Clear(ctx, task.ReviewTaskID); err != nil {
+		logger.Warn(i18n.Translate("review_task.clear_subtasks_failed", "", nil), "review_task_id", task.ReviewTaskID, "error", err)
+	}
+	return cause
+}
+
+// pushToIssueManager push the issues of a finished task to the issue manager when enabled
+func (s *ReviewTaskService) pushToIssueManager(ctx context.Context, reviewTaskID string) {
+	if !config.GetConfig().Review.PushToIssueManager {
+		return
+	}
+
+	manager, err := thirdPlatform.GetServerManager()
+	if err != nil {
+		logger.Error(i18n.Translate("review_task.push_issues_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+		return
+	}
+	issues, err := s.ListIssues(ctx, reviewTaskID)
+	if err != nil {
+		logger.Error(i18n.Translate("review_task.push_issues_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+		return
+	}
+	created, err := manager.IssueManager.PushReviewResult(ctx, reviewTaskID, issues)
+	if err != nil {
+		logger.Error(i18n.Translate("review_task.push_issues_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+		return
+	}
+	logger.Info(i18n.Translate("review_task.push_issues_success", "", nil), "review_task_id", reviewTaskID, "created", created)
+}
+
+// getTaskModel get review task model, converting missing records into ErrReviewTaskNotFound
+func (s *ReviewTaskService) getTaskModel(ctx context.Context, reviewTaskID string) (*model.ReviewTask, error) {
+	task, err := s.taskRepo.GetByReviewTaskID(ctx, reviewTaskID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrReviewTaskNotFound
+	}
+	return task, err
+}
+
+// resolveCodebasePath join a relative path to the codebase root, rejecting paths escaping the root
+func resolveCodebasePath(rootPath, relativePath string) (string, error) {
+	fullPath := filepath.Join(rootPath, filepath.FromSlash(relativePath))
+	rel, err := filepath.Rel(rootPath, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s", i18n.Translate("review_task.path_outside_codebase", "", map[string]interface{}{"path": relativePath}))
+	}
+	return fullPath, nil
+}
+
+// cleanRelativePath normalize a relative path into slash separated form
+func cleanRelativePath(path string) string {
+	return filepath.ToSlash(filepath.Clean(strings.TrimPrefix(filepath.ToSlash(path), "/")))
+}
+
+// toReviewTask convert review task model into API type
+func toReviewTask(task *model.ReviewTask) *types.ReviewTask {
+	result := &types.ReviewTask{
+		ReviewTaskID:     task.ReviewTaskID,
+		ClientID:         task.ClientID,
+		CodebasePath:     task.CodebasePath,
+		Targets:          task.Targets,
+		Status:           task.Status,
+		Progress:         task.Progress,
+		Total:            task.Total,
+		Processed:        task.Processed,
+		Subtasks:         task.Subtasks,
+		Error:            task.Error,
+		BaseReviewTaskID: task.BaseReviewTaskID,
+		NewIssues:        task.NewIssues,
+		UnchangedIssues:  task.UnchangedIssues,
+		ResolvedIssues:   task.ResolvedIssues,
+		SuppressedIssues: task.SuppressedIssues,
+		BaselineIssues:   task.BaselineIssues,
+		FilteredIssues:   task.FilteredIssues,
+		CreatedAt:        utils.FormatTime(task.CreatedAt, ""),
+		UpdatedAt:        utils.FormatTime(task.UpdatedAt, ""),
+	}
+	if task.StartedAt != nil {
+		result.StartedAt = utils.FormatTime(*task.StartedAt, "")
+	}
+	return result
+}
+
+// toIssue convert review issue model into API type
+func toIssue(issue *model.ReviewIssue) types.Issue {
+	return types.Issue{
+		IssueID:     issue.IssueID,
+		RuleID:      issue.RuleID,
+		Fingerprint: issue.Fingerprint,
+		Change:      issue.Change,
+		FilePath:    issue.FilePath,
+		IssueCode:   issue.IssueCode,
+		FixPatch:    issue.FixPatch,
+		StartLine:   issue.StartLine,
+		EndLine:     issue.EndLine,
+		Title:       issue.Title,
+		Message:     issue.Message,
+		IssueTypes:  issue.IssueTypes,
+		Severity:    issue.Severity,
+		Status:      issue.Status,
+		Assignee:    issue.Assignee,
+		Confidence:  issue.Confidence,
+		CreatedAt:   utils.FormatTime(issue.CreatedAt, ""),
+		UpdatedAt:   utils.FormatTime(issue.UpdatedAt, ""),
+	}
+}
+
+// toReviewIssueModel convert analyzer issue into review issue model
+func toReviewIssueModel(reviewTaskID string, issue types.Issue) *model.ReviewIssue {
+	return &model.ReviewIssue{
+		IssueID:      issue.IssueID,
+		ReviewTaskID: reviewTaskID,
+		RuleID:       issue.RuleID,
+		Fingerprint:  issue.Fingerprint,
+		Change:       issue.Change,
+		FilePath:     issue.FilePath,
+		IssueCode:    issue.IssueCode,
+		FixPatch:     issue.FixPatch,
+		StartLine:    issue.StartLine,
+		EndLine:      issue.EndLine,
+		Title:        issue.Title,
+		Message:      issue.Message,
+		IssueTypes:   issue.IssueTypes,
+		Severity:     issue.Severity,
+		Status:       issue.Status,
+		Assignee:     issue.Assignee,
+		Confidence:   issue.Confidence,
+	}
+}
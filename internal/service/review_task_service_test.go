@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/internal/analyzer"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func TestMain(m *testing.M) {
+	// Language detection and the review defaults come from the default configuration
+	if err := config.LoadConfigWithDefault(); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// newTestReviewTaskService review task service over a new codebase directory, with the memory adapters and
+// the rule analyzer only
+func newTestReviewTaskService(t *testing.T) (*ReviewTaskService, string) {
+	t.Helper()
+	cfg := config.GetConfig()
+	review := cfg.Review
+	cfg.Review = config.Review{Analyzers: []string{analyzer.RuleAnalyzerName}}
+	t.Cleanup(func() { cfg.Review = review })
+
+	dir := t.TempDir()
+	return NewReviewTaskService(dir), dir
+}
+
+// writeCodebaseFile write a file of the codebase
+func writeCodebaseFile(t *testing.T, dir, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runReview create and run a review task of the targets, returning the finished task and its issues
+func runReview(t *testing.T, s *ReviewTaskService, targets ...types.Target) (*types.ReviewTask, []types.Issue) {
+	t.Helper()
+	ctx := context.Background()
+	task, _, err := s.CreateTask(ctx, "test", "", targets, nil, "")
+	if err != nil {
+		t.Fatalf("CreateTask failed: %v", err)
+	}
+	if err := s.RunTask(ctx, task.ReviewTaskID); err != nil {
+		t.Fatalf("RunTask failed: %v", err)
+	}
+	return reviewResult(t, s, task.ReviewTaskID)
+}
+
+// reviewResult get a review task and all of its issues
+func reviewResult(t *testing.T, s *ReviewTaskService, reviewTaskID string) (*types.ReviewTask, []types.Issue) {
+	t.Helper()
+	task, err := s.GetTask(context.Background(), reviewTaskID)
+	if err != nil {
+		t.Fatalf("GetTask failed: %v", err)
+	}
+	result, err := s.GetIncrementResult(context.Background(), reviewTaskID, 0, 0)
+	if err != nil {
+		t.Fatalf("GetIncrementResult failed: %v", err)
+	}
+	return task, result.Issues
+}
+
+// issuesOf issues of the file
+func issuesOf(issues []types.Issue, path string) []types.Issue {
+	var matched []types.Issue
+	for _, issue := range issues {
+		if issue.FilePath == path {
+			matched = append(matched, issue)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,577 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/model"
+	"github.com/zgsm/mock-kbcenter/internal/repository"
+	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+	"github.com/zgsm/mock-kbcenter/pkg/idgen"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Headers of webhook requests
+const (
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookDeliveryHeader  = "X-Webhook-Delivery"
+	WebhookSignatureHeader = "X-Webhook-Signature-256" // "sha256=" and the hex HMAC-SHA256 of the body keyed by the secret
+)
+
+const (
+	// webhookSecretLength length of the secrets generated for webhooks created without one
+	webhookSecretLength = 32
+	// maxDeliveryErrorLength longest error message recorded for a delivery
+	maxDeliveryErrorLength = 1024
+	// webhookQueueSize deliveries waiting for the senders of the process, further deliveries stay pending
+	webhookQueueSize = 1000
+)
+
+var (
+	// ErrWebhookNotFound webhook does not exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrWebhookDeliveryNotFound webhook delivery does not exist
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrInvalidWebhookURL webhook URL is not an absolute http or https URL
+	ErrInvalidWebhookURL = errors.New("invalid webhook url")
+	// ErrInvalidWebhookEvent unknown webhook event
+	ErrInvalidWebhookEvent = errors.New("invalid webhook event")
+	// ErrWebhookAddressDenied webhook endpoint is on a loopback, private or link-local address
+	ErrWebhookAddressDenied = errors.New("webhook address denied")
+	// ErrWebhookQueueFull too many deliveries are waiting to be sent by the process
+	ErrWebhookQueueFull = errors.New("webhook delivery queue full")
+)
+
+// WebhookUpdate change of a webhook, nil fields are left unchanged
+type WebhookUpdate struct {
+	URL          *string
+	Secret       *string
+	CodebasePath *string
+	Events       *[]string
+	Enabled      *bool
+}
+
+// WebhookService webhook registration and delivery of review task events
+type WebhookService struct {
+	webhookRepo  repository.WebhookRepository
+	deliveryRepo repository.WebhookDeliveryRepository
+}
+
+// NewWebhookService create webhook service
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		webhookRepo:  repository.NewWebhookRepository(),
+		deliveryRepo: repository.NewWebhookDeliveryRepository(),
+	}
+}
+
+var (
+	webhookClient     *httpclient.Client
+	webhookClientErr  error
+	webhookClientOnce sync.Once
+)
+
+// getWebhookClient HTTP client of webhook deliveries, shared so that connections to the endpoints are reused
+func getWebhookClient() (*httpclient.Client, error) {
+	webhookClientOnce.Do(func() {
+		cfg := config.GetConfig().Webhook
+		httpConfig := httpclient.DefaultHttpServiceConfig()
+		httpConfig.Service = "webhook"
+		httpConfig.Timeout = time.Duration(cfg.Timeout) * time.Second
+		httpConfig.MaxRetries = cfg.MaxRetries
+		httpConfig.RetryDelay = time.Duration(cfg.RetryDelay) * time.Second
+		httpConfig.DialContext = dialWebhook
+		webhookClient, webhookClientErr = httpclient.NewClient(httpConfig)
+	})
+	return webhookClient, webhookClientErr
+}
+
+// dialWebhook resolve the host of an endpoint and dial its first public address, so that names resolving to
+// loopback, private or link-local addresses such as the cloud metadata address are not reached
+func dialWebhook(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, KeepAlive: 30 * time.Second}
+	if config.GetConfig().Webhook.AllowPrivate {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ipAddr := range addrs {
+		if utils.IsPublicIP(ipAddr.IP) {
+			return dialer.DialContext(ctx, network, net.JoinHostPort(ipAddr.IP.String(), port))
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrWebhookAddressDenied, host)
+}
+
+// WebhookDispatcher send a recorded delivery in the background, returning once the delivery is queued
+type WebhookDispatcher func(ctx context.Context, deliveryID string) error
+
+// webhookDispatcher dispatcher of the deliveries, the senders of the process unless another one is registered
+var webhookDispatcher WebhookDispatcher = DeliverInProcess
+
+// SetWebhookDispatcher register the dispatcher of the deliveries, such as the worker queue
+func SetWebhookDispatcher(dispatcher WebhookDispatcher) {
+	webhookDispatcher = dispatcher
+}
+
+var webhookSenders struct {
+	once    sync.Once
+	queue   chan string
+	mu      sync.Mutex
+	pending int
+}
+
+// DeliverInProcess queue a delivery for the senders of the process, at most config webhook.workers at once.
+// Deliveries beyond the queue stay pending and can be redelivered.
+func DeliverInProcess(ctx context.Context, deliveryID string) error {
+	webhookSenders.once.Do(func() {
+		webhookSenders.queue = make(chan string, webhookQueueSize)
+		for i := 0; i < max(config.GetConfig().Webhook.Workers, 1); i++ {
+			go sendQueuedDeliveries()
+		}
+	})
+
+	webhookSenders.mu.Lock()
+	defer webhookSenders.mu.Unlock()
+	select {
+	case webhookSenders.queue <- deliveryID:
+		webhookSenders.pending++
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+// sendQueuedDeliveries send the deliveries queued in the process
+func sendQueuedDeliveries() {
+	s := NewWebhookService()
+	for deliveryID := range webhookSenders.queue {
+		// Propagated headers of the request that triggered the event are not meant for the endpoint
+		if err := s.DeliverPending(context.Background(), deliveryID); err != nil {
+			logger.Warn(i18n.Translate("webhook.delivery_failed", "", nil), "delivery_id", deliveryID, "error", err)
+		}
+		webhookSenders.mu.Lock()
+		webhookSenders.pending--
+		webhookSenders.mu.Unlock()
+	}
+}
+
+// WaitDeliveries wait until the deliveries queued in the process are sent, called on shutdown
+func WaitDeliveries(ctx context.Context) error {
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		webhookSenders.mu.Lock()
+		pending := webhookSenders.pending
+		webhookSenders.mu.Unlock()
+		if pending == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CreateWebhook register a webhook for the events of the tasks of a codebase, of every codebase when codebasePath is empty.
+// A secret is generated when none is given, the result carries the secret only here.
+func (s *WebhookService) CreateWebhook(ctx context.Context, rawURL, secret, codebasePath string, events []string) (*types.Webhook, error) {
+	if err := validateWebhook(rawURL, events); err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		generated, err := utils.GenerateRandomString(webhookSecretLength)
+		if err != nil {
+			return nil, err
+		}
+		secret = generated
+	}
+	webhookID, err := idgen.GenerateString()
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &model.Webhook{
+		WebhookID:    webhookID,
+		URL:          rawURL,
+		Secret:       secret,
+		CodebasePath: codebasePath,
+		Events:       events,
+		Enabled:      true,
+	}
+	if err := s.webhookRepo.Create(ctx, webhook); err != nil {
+		return nil, err
+	}
+	result := toWebhook(webhook)
+	result.Secret = secret
+	return &result, nil
+}
+
+// ListWebhooks list all webhooks
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]types.Webhook, error) {
+	webhooks, err := s.webhookRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]types.Webhook, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		list = append(list, toWebhook(webhook))
+	}
+	return list, nil
+}
+
+// GetWebhook get webhook by ID
+func (s *WebhookService) GetWebhook(ctx context.Context, webhookID string) (*types.Webhook, error) {
+	webhook, err := s.getWebhookModel(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	result := toWebhook(webhook)
+	return &result, nil
+}
+
+// UpdateWebhook change the endpoint, secret, scope or events of a webhook, or enable and disable it
+func (s *WebhookService) UpdateWebhook(ctx context.Context, webhookID string, update WebhookUpdate) (*types.Webhook, error) {
+	webhook, err := s.getWebhookModel(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if update.URL != nil {
+		webhook.URL = *update.URL
+	}
+	if update.Secret != nil && *update.Secret != "" {
+		webhook.Secret = *update.Secret
+	}
+	if update.CodebasePath != nil {
+		webhook.CodebasePath = *update.CodebasePath
+	}
+	if update.Events != nil {
+		webhook.Events = *update.Events
+	}
+	if update.Enabled != nil {
+		webhook.Enabled = *update.Enabled
+	}
+	if err := validateWebhook(webhook.URL, webhook.Events); err != nil {
+		return nil, err
+	}
+	if err := s.webhookRepo.Update(ctx, webhook); err != nil {
+		return nil, err
+	}
+	result := toWebhook(webhook)
+	return &result, nil
+}
+
+// DeleteWebhook remove a webhook and its deliveries
+func (s *WebhookService) DeleteWebhook(ctx context.Context, webhookID string) error {
+	if err := s.webhookRepo.Delete(ctx, webhookID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return ErrWebhookNotFound
+		}
+		return err
+	}
+	return s.deliveryRepo.DeleteByWebhookID(ctx, webhookID)
+}
+
+// ListDeliveries list the deliveries of a webhook, newest first, page starts at 1
+func (s *WebhookService) ListDeliveries(ctx context.Context, webhookID string, page, pageSize int) ([]types.WebhookDelivery, int64, error) {
+	if _, err := s.getWebhookModel(ctx, webhookID); err != nil {
+		return nil, 0, err
+	}
+	deliveries, total, err := s.deliveryRepo.ListByWebhookID(ctx, webhookID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+	list := make([]types.WebhookDelivery, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		list = append(list, toWebhookDelivery(delivery))
+	}
+	return list, total, nil
+}
+
+// GetDelivery get a delivery of a webhook, with the payload sent
+func (s *WebhookService) GetDelivery(ctx context.Context, webhookID, deliveryID string) (*types.WebhookDelivery, error) {
+	delivery, err := s.getDeliveryModel(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	result := toWebhookDelivery(delivery)
+	return &result, nil
+}
+
+// Redeliver send the payload of a delivery again to the current endpoint of its webhook, signed with the current secret.
+// The redelivery is recorded as a new delivery and sent before returning, so that its result can be inspected at once.
+func (s *WebhookService) Redeliver(ctx context.Context, webhookID, deliveryID string) (*types.WebhookDelivery, error) {
+	webhook, err := s.getWebhookModel(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	original, err := s.getDeliveryModel(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery, err := s.createDelivery(ctx, webhook, original.ReviewTaskID, original.Event, original.Payload, original.DeliveryID)
+	if err != nil {
+		return nil, err
+	}
+	s.deliver(ctx, webhook, delivery)
+	result := toWebhookDelivery(delivery)
+	return &result, nil
+}
+
+// Notify deliver a review task event to the enabled webhooks subscribed to it, in the background.
+// Deliveries are recorded before they are sent, failures to notify are only logged.
+func (s *WebhookService) Notify(ctx context.Context, event string, task *types.ReviewTask) {
+	webhooks, err := s.webhookRepo.List(ctx)
+	if err != nil {
+		logger.Warn(i18n.Translate("webhook.notify_failed", "", nil), "event", event, "review_task_id", task.ReviewTaskID, "error", err)
+		return
+	}
+
+	var payload []byte
+	for _, webhook := range webhooks {
+		if !webhookSubscribed(webhook, event, task) {
+			continue
+		}
+		if payload == nil {
+			payload, err = json.Marshal(types.WebhookPayload{
+				Event:     event,
+				Timestamp: utils.FormatTime(time.Now(), ""),
+				Task:      task,
+			})
+			if err != nil {
+				logger.Warn(i18n.Translate("webhook.notify_failed", "", nil), "event", event, "review_task_id", task.ReviewTaskID, "error", err)
+				return
+			}
+		}
+
+		delivery, err := s.createDelivery(ctx, webhook, task.ReviewTaskID, event, string(payload), "")
+		if err != nil {
+			logger.Warn(i18n.Translate("webhook.notify_failed", "", nil), "event", event, "webhook_id", webhook.WebhookID, "error", err)
+			continue
+		}
+		if err := webhookDispatcher(ctx, delivery.DeliveryID); err != nil {
+			logger.Warn(i18n.Translate("webhook.notify_failed", "", nil), "event", event, "delivery_id", delivery.DeliveryID, "error", err)
+		}
+	}
+}
+
+// DeliverPending send a recorded delivery that is still pending, deliveries already sent are left unchanged
+func (s *WebhookService) DeliverPending(ctx context.Context, deliveryID string) error {
+	delivery, err := s.deliveryRepo.GetByDeliveryID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) {
+		// The webhook was deleted with its deliveries
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != types.WebhookDeliveryPending {
+		return nil
+	}
+	webhook, err := s.webhookRepo.GetByWebhookID(ctx, delivery.WebhookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.deliver(ctx, webhook, delivery)
+	return nil
+}
+
+// createDelivery record a pending delivery of the payload to the webhook, redeliveryOf is the delivery it repeats if any
+func (s *WebhookService) createDelivery(ctx context.Context, webhook *model.Webhook, reviewTaskID, event, payload, redeliveryOf string) (*model.WebhookDelivery, error) {
+	deliveryID, err := idgen.GenerateString()
+	if err != nil {
+		return nil, err
+	}
+	delivery := &model.WebhookDelivery{
+		DeliveryID:   deliveryID,
+		WebhookID:    webhook.WebhookID,
+		ReviewTaskID: reviewTaskID,
+		Event:        event,
+		Payload:      payload,
+		Status:       types.WebhookDeliveryPending,
+		RedeliveryOf: redeliveryOf,
+	}
+	if err := s.deliveryRepo.Create(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// deliver POST the signed payload to the webhook endpoint, retrying failures, and record the outcome on the delivery
+func (s *WebhookService) deliver(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) {
+	start := time.Now()
+	err := postWebhook(ctx, webhook, delivery)
+	now := time.Now()
+	delivery.Duration = now.Sub(start).Milliseconds()
+	delivery.DeliveredAt = &now
+	if err != nil {
+		delivery.Status = types.WebhookDeliveryFailed
+		delivery.Error = utils.TruncateString(err.Error(), maxDeliveryErrorLength)
+		logger.Warn(i18n.Translate("webhook.delivery_failed", "", nil), "webhook_id", webhook.WebhookID, "delivery_id", delivery.DeliveryID, "error", err)
+	} else {
+		delivery.Status = types.WebhookDeliverySucceeded
+		delivery.Error = ""
+	}
+
+	if err := s.deliveryRepo.Update(context.WithoutCancel(ctx), delivery); err != nil {
+		logger.Warn(i18n.Translate("webhook.delivery_update_failed", "", nil), "delivery_id", delivery.DeliveryID, "error", err)
+	}
+}
+
+// postWebhook send a delivery request, setting the response status on the delivery
+func postWebhook(ctx context.Context, webhook *model.Webhook, delivery *model.WebhookDelivery) error {
+	client, err := getWebhookClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := webhookContext(ctx)
+	defer cancel()
+	body := []byte(delivery.Payload)
+	resp, err := client.Post(ctx, webhook.URL, body, map[string]string{
+		"Content-Type":         "application/json",
+		WebhookEventHeader:     delivery.Event,
+		WebhookDeliveryHeader:  delivery.DeliveryID,
+		WebhookSignatureHeader: SignWebhookPayload(webhook.Secret, body),
+	})
+	var statusErr *httpclient.StatusError
+	if errors.As(err, &statusErr) {
+		delivery.StatusCode = statusErr.StatusCode
+	}
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+	return nil
+}
+
+// webhookContext context of a delivery request with the trace and the cancellation of ctx but none of its values,
+// so that the headers propagated from an incoming request, such as its Authorization, never reach the endpoint
+func webhookContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(trace.ContextWithSpan(context.Background(), trace.SpanFromContext(ctx)))
+	stop := context.AfterFunc(ctx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
+
+// SignWebhookPayload signature header value of a webhook payload, receivers recompute it to verify the request
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookSubscribed whether the webhook is notified of the event of the task
+func webhookSubscribed(webhook *model.Webhook, event string, task *types.ReviewTask) bool {
+	if !webhook.Enabled {
+		return false
+	}
+	if webhook.CodebasePath != "" && webhook.CodebasePath != task.CodebasePath {
+		return false
+	}
+	return len(webhook.Events) == 0 || utils.SliceContains(webhook.Events, event)
+}
+
+// validateWebhook check the endpoint URL and the subscribed events of a webhook. Names are checked again when
+// dialing, since they may resolve to other addresses by then.
+func validateWebhook(rawURL string, events []string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidWebhookURL
+	}
+	if !config.GetConfig().Webhook.AllowPrivate {
+		host := strings.ToLower(parsed.Hostname())
+		if ip := net.ParseIP(host); (ip != nil && !utils.IsPublicIP(ip)) || host == "localhost" || strings.HasSuffix(host, ".localhost") {
+			return ErrWebhookAddressDenied
+		}
+	}
+	for _, event := range events {
+		if !utils.SliceContains(types.WebhookEvents, event) {
+			return ErrInvalidWebhookEvent
+		}
+	}
+	return nil
+}
+
+// getWebhookModel get webhook model, converting missing records into ErrWebhookNotFound
+func (s *WebhookService) getWebhookModel(ctx context.Context, webhookID string) (*model.Webhook, error) {
+	webhook, err := s.webhookRepo.GetByWebhookID(ctx, webhookID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, err
+}
+
+// getDeliveryModel get a delivery model of a webhook, converting missing records into ErrWebhookDeliveryNotFound
+func (s *WebhookService) getDeliveryModel(ctx context.Context, webhookID, deliveryID string) (*model.WebhookDelivery, error) {
+	delivery, err := s.deliveryRepo.GetByDeliveryID(ctx, deliveryID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && delivery.WebhookID != webhookID) {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, err
+}
+
+// toWebhook convert webhook model into API type, leaving out the secret
+func toWebhook(webhook *model.Webhook) types.Webhook {
+	return types.Webhook{
+		WebhookID:    webhook.WebhookID,
+		URL:          webhook.URL,
+		CodebasePath: webhook.CodebasePath,
+		Events:       webhook.Events,
+		Enabled:      webhook.Enabled,
+		CreatedAt:    utils.FormatTime(webhook.CreatedAt, ""),
+		UpdatedAt:    utils.FormatTime(webhook.UpdatedAt, ""),
+	}
+}
+
+// toWebhookDelivery convert webhook delivery model into API type
+func toWebhookDelivery(delivery *model.WebhookDelivery) types.WebhookDelivery {
+	result := types.WebhookDelivery{
+		DeliveryID:   delivery.DeliveryID,
+		WebhookID:    delivery.WebhookID,
+		ReviewTaskID: delivery.ReviewTaskID,
+		Event:        delivery.Event,
+		Payload:      delivery.Payload,
+		Status:       delivery.Status,
+		StatusCode:   delivery.StatusCode,
+		Error:        delivery.Error,
+		Duration:     delivery.Duration,
+		RedeliveryOf: delivery.RedeliveryOf,
+		CreatedAt:    utils.FormatTime(delivery.CreatedAt, ""),
+	}
+	if delivery.DeliveredAt != nil {
+		result.DeliveredAt = utils.FormatTime(*delivery.DeliveredAt, "")
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/headerpropagation"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// allowPrivateWebhooks set webhook.allow_private for the test
+func allowPrivateWebhooks(t *testing.T, allow bool) {
+	t.Helper()
+	cfg := &config.GetConfig().Webhook
+	previous := cfg.AllowPrivate
+	cfg.AllowPrivate = allow
+	t.Cleanup(func() { cfg.AllowPrivate = previous })
+}
+
+func TestValidateWebhook_Addresses(t *testing.T) {
+	allowPrivateWebhooks(t, false)
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://api.localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/hook",
+		"http://[::1]/hook",
+	} {
+		if err := validateWebhook(rawURL, nil); !errors.Is(err, ErrWebhookAddressDenied) {
+			t.Errorf("%s: expected ErrWebhookAddressDenied, got %v", rawURL, err)
+		}
+	}
+	if err := validateWebhook("https://hooks.example.com/review", nil); err != nil {
+		t.Errorf("Expected a public endpoint accepted, got %v", err)
+	}
+
+	allowPrivateWebhooks(t, true)
+	if err := validateWebhook("http://127.0.0.1:8080/hook", nil); err != nil {
+		t.Errorf("Expected private endpoints accepted when allowed, got %v", err)
+	}
+}
+
+func TestDialWebhook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	// Names are resolved and checked when dialing, not only when the webhook is registered
+	allowPrivateWebhooks(t, false)
+	if _, err := dialWebhook(context.Background(), "tcp", net.JoinHostPort("localhost", port)); !errors.Is(err, ErrWebhookAddressDenied) {
+		t.Fatalf("Expected ErrWebhookAddressDenied, got %v", err)
+	}
+
+	allowPrivateWebhooks(t, true)
+	conn, err := dialWebhook(context.Background(), "tcp", net.JoinHostPort("localhost", port))
+	if err != nil {
+		t.Fatalf("Expected the connection allowed, got %v", err)
+	}
+	conn.Close()
+}
+
+func TestNotify_Deliver(t *testing.T) {
+	allowPrivateWebhooks(t, true)
+	received := make(chan *http.Request, 1)
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		received <- r
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	s := NewWebhookService()
+	codebasePath := t.TempDir()
+	webhook, err := s.CreateWebhook(ctx, server.URL, "secret", codebasePath, []string{types.WebhookEventTaskCompleted})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.DeleteWebhook(ctx, webhook.WebhookID) })
+
+	task := &types.ReviewTask{ReviewTaskID: "rt-" + t.Name(), CodebasePath: codebasePath}
+	s.Notify(ctx, types.WebhookEventTaskStarted, task)
+	s.Notify(ctx, types.WebhookEventTaskCompleted, task)
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := WaitDeliveries(waitCtx); err != nil {
+		t.Fatalf("WaitDeliveries failed: %v", err)
+	}
+
+	req := <-received
+	if req.Header.Get(WebhookEventHeader) != types.WebhookEventTaskCompleted || req.Header.Get(WebhookSignatureHeader) != SignWebhookPayload("secret", body) {
+		t.Errorf("Expected the signed completed event, got %v", req.Header)
+	}
+	deliveries, total, err := s.ListDeliveries(ctx, webhook.WebhookID, 1, 10)
+	if err != nil || total != 1 || deliveries[0].Status != types.WebhookDeliverySucceeded || deliveries[0].StatusCode != http.StatusOK {
+		t.Fatalf("Expected one delivery succeeded, got %+v %v", deliveries, err)
+	}
+
+	// Sent deliveries are not sent again by a retried queue task
+	if err := s.DeliverPending(ctx, deliveries[0].DeliveryID); err != nil || len(received) != 0 {
+		t.Errorf("Expected the delivery not sent again, got %v", err)
+	}
+}
+
+func TestRedeliver_PropagatedHeaders(t *testing.T) {
+	allowPrivateWebhooks(t, true)
+	received := make(chan *http.Request, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	s := NewWebhookService()
+	codebasePath := t.TempDir()
+	webhook, err := s.CreateWebhook(ctx, server.URL, "secret", codebasePath, nil)
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+	t.Cleanup(func() { _ = s.DeleteWebhook(ctx, webhook.WebhookID) })
+	s.Notify(ctx, types.WebhookEventTaskCompleted, &types.ReviewTask{ReviewTaskID: "rt-" + t.Name(), CodebasePath: codebasePath})
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := WaitDeliveries(waitCtx); err != nil {
+		t.Fatalf("WaitDeliveries failed: %v", err)
+	}
+	<-received
+	deliveries, _, err := s.ListDeliveries(ctx, webhook.WebhookID, 1, 10)
+	if err != nil || len(deliveries) != 1 {
+		t.Fatalf("Expected one delivery, got %+v %v", deliveries, err)
+	}
+
+	// Headers of the redelivery request are not forwarded to the endpoint
+	requestCtx := headerpropagation.WithContext(ctx, map[string]string{"Authorization": "Bearer caller", "X-User-ID": "caller"})
+	if _, err := s.Redeliver(requestCtx, webhook.WebhookID, deliveries[0].DeliveryID); err != nil {
+		t.Fatalf("Redeliver failed: %v", err)
+	}
+	req := <-received
+	if req.Header.Get("Authorization") != "" || req.Header.Get("X-User-ID") != "" {
+		t.Errorf("Expected no headers of the caller, got %v", req.Header)
+	}
+}
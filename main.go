@@ -22,8 +22,7 @@ import (
 
 	proxy "github.com/zgsm/mock-kbcenter/cmd/proxy"
 	web "github.com/zgsm/mock-kbcenter/cmd/web"
-
-	// worker "github.com/zgsm/mock-kbcenter/cmd/worker"
+	worker "github.com/zgsm/mock-kbcenter/cmd/worker"
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
 )
@@ -53,9 +52,16 @@ func main() {
 	}
 	fmt.Println(i18n.Translate("kbcenter.workdir", "", map[string]interface{}{"workdir": workDir}))
 
-	if len(os.Args) > 2 && os.Args[2] == "proxy" {
+	mode := ""
+	if len(os.Args) > 2 {
+		mode = os.Args[2]
+	}
+	switch mode {
+	case "proxy":
 		proxy.Run(cfg, workDir)
-	} else {
+	case "worker":
+		worker.Run(cfg)
+	default:
 		web.Run(cfg, workDir)
 	}
 }
@@ -1,6 +1,7 @@
 package asynq
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
@@ -8,9 +9,17 @@ import (
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-var client *asynq.Client
+var (
+	client    *asynq.Client
+	inspector *asynq.Inspector
+)
 
 // InitClient initialize Asynq client
 func InitClient(cfg config.Config) error {
@@ -27,6 +36,7 @@ func InitClient(cfg config.Config) error {
 	}
 
 	client = asynq.NewClient(redisOpt)
+	inspector = asynq.NewInspector(redisOpt)
 
 	logger.Info(i18n.Translate("asynq.client.init.success", "", nil))
 	return nil
@@ -39,29 +49,79 @@ func GetClient() *asynq.Client {
 
 // Close close Asynq client connection
 func Close() error {
+	if inspector != nil {
+		if err := inspector.Close(); err != nil {
+			return err
+		}
+	}
 	if client != nil {
 		return client.Close()
 	}
 	return nil
 }
 
+// CancelTask remove a task waiting in its queue, or cancel it when it is being processed.
+// Tasks that are not found or already completed are ignored.
+func CancelTask(queue, taskID string) error {
+	if inspector == nil {
+		return errors.New(i18n.Translate("asynq.client.nil", "", nil))
+	}
+
+	info, err := inspector.GetTaskInfo(queue, taskID)
+	if errors.Is(err, asynq.ErrTaskNotFound) || errors.Is(err, asynq.ErrQueueNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	switch info.State {
+	case asynq.TaskStateActive:
+		return inspector.CancelProcessing(taskID)
+	case asynq.TaskStateCompleted:
+		return nil
+	default:
+		err := inspector.DeleteTask(queue, taskID)
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return nil
+		}
+		return err
+	}
+}
+
 // EnqueueTaskFunc defines function type for enqueueing tasks
-type EnqueueTaskFunc func(task *asynq.Task, queue string, retryCount ...int) (string, error)
+type EnqueueTaskFunc func(ctx context.Context, taskType string, payload []byte, queue string, opts ...asynq.Option) (string, error)
+
+// EnqueueTask task enqueue function variable, creating the task of the payload within a producer span. The
+// options are applied after the queue and the configured retry count.
+var EnqueueTask EnqueueTaskFunc = func(ctx context.Context, taskType string, payload []byte, queue string, opts ...asynq.Option) (id string, err error) {
+	task, span := startEnqueue(ctx, taskType, payload, queue)
+	defer func() {
+		// A conflict is no failure, the task is already enqueued
+		if errors.Is(err, asynq.ErrTaskIDConflict) {
+			span.SetAttributes(attribute.Bool("asynq.task.duplicate", true))
+			span.End()
+			return
+		}
+		if id != "" {
+			span.SetAttributes(semconv.MessagingMessageID(id))
+		}
+		tracing.End(span, err)
+	}()
 
-// EnqueueTask task enqueue function variable
-var EnqueueTask EnqueueTaskFunc = func(task *asynq.Task, queue string, retryCount ...int) (string, error) {
 	if client == nil {
 		logger.Error(i18n.Translate("asynq.client.nil", "", nil))
-		return "", errors.New(i18n.Translate("asynq.client.nil", "", nil))
+		err := errors.New(i18n.Translate("asynq.client.nil", "", nil))
+		metrics.ObserveEnqueue(task.Type(), err)
+		return "", err
 	}
 
-	// Get retry count, use default from config if not provided
-	count := config.GetConfig().Asynq.RetryCount
-	if len(retryCount) > 0 {
-		count = retryCount[0]
+	options := append([]asynq.Option{asynq.Queue(queue), asynq.MaxRetry(config.GetConfig().Asynq.RetryCount)}, opts...)
+	info, err := client.Enqueue(task, options...)
+	metrics.ObserveEnqueue(task.Type(), err)
+	// A task with the same ID is already enqueued, left to the caller to decide
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return "", err
 	}
-
-	info, err := client.Enqueue(task, asynq.Queue(queue), asynq.MaxRetry(count))
 	if err != nil {
 		logger.Error(i18n.Translate("asynq.enqueue.failed", "", map[string]interface{}{
 			"queue": queue,
@@ -74,3 +134,14 @@ var EnqueueTask EnqueueTaskFunc = func(task *asynq.Task, queue string, retryCoun
 	}
 	return info.ID, nil
 }
+
+// startEnqueue start the producer span of a task and create the task carrying its trace context in the payload,
+// so that the worker processes the task as a child of the span, see tracing.InjectPayload
+func startEnqueue(ctx context.Context, taskType string, payload []byte, queue string) (*asynq.Task, trace.Span) {
+	ctx, span := tracing.StartKind(ctx, trace.SpanKindProducer, "send "+taskType,
+		semconv.MessagingSystemKey.String("asynq"),
+		semconv.MessagingDestinationName(queue),
+		attribute.String("asynq.task.type", taskType),
+	)
+	return asynq.NewTask(taskType, tracing.InjectPayload(ctx, payload)), span
+}
@@ -0,0 +1,35 @@
+package asynq
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartEnqueue_ParentsTaskOnProducerSpan(t *testing.T) {
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	ctx, request := tracing.Start(context.Background(), "request")
+	defer request.End()
+	task, span := startEnqueue(ctx, "review:run", []byte(`{"review_task_id":"rt-1"}`), "default")
+	span.End()
+
+	if span.SpanContext().TraceID() != request.SpanContext().TraceID() {
+		t.Fatal("Expected the producer span in the trace of the request")
+	}
+	parent := trace.SpanContextFromContext(tracing.ExtractPayload(context.Background(), task.Payload()))
+	if parent.SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("Expected the task to carry the producer span %s, got %s", span.SpanContext().SpanID(), parent.SpanID())
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
 
 	// "gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -83,6 +84,8 @@ func InitDB(cfg config.Database) error {
 	sqlDB.SetMaxOpenConns(100)
 	// Set connection max lifetime
 	sqlDB.SetConnMaxLifetime(time.Hour)
+
+	metrics.RegisterDBStats(cfg.DBName, sqlDB)
 	return nil
 }
 
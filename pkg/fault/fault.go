@@ -0,0 +1,232 @@
+package fault
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+	"github.com/zgsm/mock-kbcenter/pkg/utils"
+)
+
+// Latency distributions
+const (
+	DistributionFixed       = "fixed"
+	DistributionUniform     = "uniform"
+	DistributionNormal      = "normal"
+	DistributionExponential = "exponential"
+)
+
+// ErrInvalidRule fault rule with values out of range
+var ErrInvalidRule = errors.New("invalid fault rule")
+
+// Injector fault rules of the mock endpoints, safe for concurrent use
+type Injector struct {
+	mu      sync.RWMutex
+	config  config.Fault
+	initial config.Fault // Restored by Restore
+}
+
+var (
+	defaultInjector *Injector
+	defaultOnce     sync.Once
+)
+
+// Default injector of the process, starting with the fault configuration of the config file
+func Default() *Injector {
+	defaultOnce.Do(func() {
+		defaultInjector = NewInjector(config.GetConfig().Fault)
+	})
+	return defaultInjector
+}
+
+// NewInjector create injector with the given configuration
+func NewInjector(cfg config.Fault) *Injector {
+	return &Injector{config: copyConfig(cfg), initial: copyConfig(cfg)}
+}
+
+// Config current fault configuration
+func (i *Injector) Config() config.Fault {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return copyConfig(i.config)
+}
+
+// SetConfig validate and replace the fault configuration
+func (i *Injector) SetConfig(cfg config.Fault) error {
+	if err := Validate(cfg); err != nil {
+		return err
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.config = copyConfig(cfg)
+	return nil
+}
+
+// SetEnabled switch fault injection on or off, keeping the rules
+func (i *Injector) SetEnabled(enabled bool) config.Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.config.Enabled = enabled
+	return copyConfig(i.config)
+}
+
+// Restore restore the configuration the injector was created with
+func (i *Injector) Restore() config.Fault {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.config = copyConfig(i.initial)
+	return copyConfig(i.config)
+}
+
+// Match first rule matching the request whose rate draw succeeds, nil when faults are disabled or none applies
+func (i *Injector) Match(r *http.Request) *config.FaultRule {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if !i.config.Enabled {
+		return nil
+	}
+	for idx := range i.config.Rules {
+		rule := &i.config.Rules[idx]
+		if !matchRequest(rule, r) {
+			continue
+		}
+		if rule.Rate > 0 && rule.Rate < 1 && rand.Float64() >= rule.Rate {
+			continue
+		}
+		matched := *rule
+		return &matched
+	}
+	return nil
+}
+
+// Delay draw a delay from the latency distribution, clamped to [min, max] when they are set
+func Delay(latency *config.FaultLatency) time.Duration {
+	if latency == nil {
+		return 0
+	}
+	var ms float64
+	switch latency.Distribution {
+	case DistributionUniform:
+		ms = float64(latency.Min) + rand.Float64()*float64(latency.Max-latency.Min)
+	case DistributionNormal:
+		ms = float64(latency.Mean) + rand.NormFloat64()*float64(latency.StdDev)
+	case DistributionExponential:
+		ms = rand.ExpFloat64() * float64(latency.Mean)
+	default:
+		ms = float64(latency.Mean)
+	}
+	ms = math.Max(ms, float64(latency.Min))
+	if latency.Max > 0 {
+		ms = math.Min(ms, float64(latency.Max))
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// Validate check the values of the fault rules
+func Validate(cfg config.Fault) error {
+	for idx, rule := range cfg.Rules {
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", idx+1)
+		}
+		if err := validateRule(rule); err != nil {
+			return fmt.Errorf("%w %s: %s", ErrInvalidRule, name, err)
+		}
+	}
+	return nil
+}
+
+// validateRule describe the first invalid value of a rule
+func validateRule(rule config.FaultRule) error {
+	if rule.Rate < 0 || rule.Rate > 1 {
+		return errors.New("rate must be between 0 and 1")
+	}
+	if rule.Status != 0 && (rule.Status < 100 || rule.Status > 599) {
+		return errors.New("status must be an HTTP status code")
+	}
+	if rule.TruncateRatio < 0 || rule.TruncateRatio >= 1 {
+		return errors.New("truncate_ratio must be at least 0 and below 1")
+	}
+	if rule.Latency == nil && rule.Status == 0 && rule.TruncateRatio == 0 && !rule.Reset {
+		return errors.New("no fault set")
+	}
+	if latency := rule.Latency; latency != nil {
+		switch latency.Distribution {
+		case "", DistributionFixed, DistributionUniform, DistributionNormal, DistributionExponential:
+		default:
+			return fmt.Errorf("unknown latency distribution %q", latency.Distribution)
+		}
+		if latency.Min < 0 || latency.Max < 0 || latency.Mean < 0 || latency.StdDev < 0 {
+			return errors.New("latency must not be negative")
+		}
+		if latency.Max > 0 && latency.Max < latency.Min {
+			return errors.New("latency max must not be below min")
+		}
+	}
+	return nil
+}
+
+// matchRequest whether the request matches the method, route, client and header conditions of a rule
+func matchRequest(rule *config.FaultRule, r *http.Request) bool {
+	if len(rule.Methods) > 0 && !containsFold(rule.Methods, r.Method) {
+		return false
+	}
+	if len(rule.Routes) > 0 && !matchAny(rule.Routes, r.URL.Path) {
+		return false
+	}
+	if len(rule.ClientIDs) > 0 {
+		query := r.URL.Query()
+		clientID := query.Get("clientId")
+		if clientID == "" {
+			clientID = query.Get("client_id")
+		}
+		if !matchAny(rule.ClientIDs, clientID) {
+			return false
+		}
+	}
+	for name, glob := range rule.Headers {
+		if !utils.MatchGlob(glob, r.Header.Get(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchAny whether the value matches any of the globs
+func matchAny(globs []string, value string) bool {
+	for _, glob := range globs {
+		if utils.MatchGlob(glob, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold whether the values contain the value, ignoring case
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyConfig copy of the configuration not sharing the rule slice
+func copyConfig(cfg config.Fault) config.Fault {
+	rules := make([]config.FaultRule, len(cfg.Rules))
+	copy(rules, cfg.Rules)
+	cfg.Rules = rules
+	return cfg
+}
@@ -0,0 +1,92 @@
+package fault
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+func TestInjector_Match(t *testing.T) {
+	injector := NewInjector(config.Fault{
+		Enabled: true,
+		Rules: []config.FaultRule{
+			{Name: "slow-agent", ClientIDs: []string{"agent-*"}, Headers: map[string]string{"X-Env": "ci"}, Latency: &config.FaultLatency{Mean: 10}},
+			{Name: "files", Methods: []string{"get"}, Routes: []string{"/api/v1/files/**"}, Status: 503},
+		},
+	})
+
+	tests := []struct {
+		target string
+		header string
+		want   string
+	}{
+		{"/api/v1/files/content?clientId=agent-1", "ci", "slow-agent"},
+		{"/api/v1/files/content?clientId=agent-1", "", "files"},
+		{"/api/v1/codebases/directory?clientId=agent-1", "", ""},
+		{"/api/v1/review_tasks?client_id=agent-2", "ci", "slow-agent"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.target, nil)
+		if tt.header != "" {
+			req.Header.Set("X-Env", tt.header)
+		}
+		got := ""
+		if rule := injector.Match(req); rule != nil {
+			got = rule.Name
+		}
+		if got != tt.want {
+			t.Errorf("Match(%s, %q) = %q, want %q", tt.target, tt.header, got, tt.want)
+		}
+	}
+
+	if rule := injector.Match(httptest.NewRequest("POST", "/api/v1/files/content", nil)); rule != nil {
+		t.Errorf("Expected no rule for other methods, got %s", rule.Name)
+	}
+	injector.SetEnabled(false)
+	if rule := injector.Match(httptest.NewRequest("GET", "/api/v1/files/content", nil)); rule != nil {
+		t.Errorf("Expected no rule while disabled, got %s", rule.Name)
+	}
+}
+
+func TestInjector_SetConfigAndRestore(t *testing.T) {
+	initial := config.Fault{Rules: []config.FaultRule{{Name: "reset", Reset: true}}}
+	injector := NewInjector(initial)
+
+	err := injector.SetConfig(config.Fault{Enabled: true, Rules: []config.FaultRule{{Name: "bad", Rate: 2, Status: 500}}})
+	if !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("Expected invalid rule error, got %v", err)
+	}
+	if err := injector.SetConfig(config.Fault{Enabled: true, Rules: []config.FaultRule{{Name: "noop"}}}); !errors.Is(err, ErrInvalidRule) {
+		t.Fatalf("Expected rules without a fault to be rejected, got %v", err)
+	}
+	if err := injector.SetConfig(config.Fault{Enabled: true, Rules: []config.FaultRule{{Name: "half", Rate: 0.5, TruncateRatio: 0.5}}}); err != nil {
+		t.Fatalf("SetConfig failed: %v", err)
+	}
+	if cfg := injector.Config(); !cfg.Enabled || cfg.Rules[0].Name != "half" {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+
+	if cfg := injector.Restore(); cfg.Enabled || len(cfg.Rules) != 1 || cfg.Rules[0].Name != "reset" {
+		t.Errorf("Expected the initial config, got %+v", cfg)
+	}
+}
+
+func TestDelay(t *testing.T) {
+	if d := Delay(&config.FaultLatency{Distribution: DistributionFixed, Mean: 25}); d != 25*time.Millisecond {
+		t.Errorf("Expected fixed delay, got %v", d)
+	}
+	for i := 0; i < 100; i++ {
+		if d := Delay(&config.FaultLatency{Distribution: DistributionUniform, Min: 10, Max: 20}); d < 10*time.Millisecond || d > 20*time.Millisecond {
+			t.Fatalf("Uniform delay out of range: %v", d)
+		}
+		if d := Delay(&config.FaultLatency{Distribution: DistributionNormal, Mean: 50, StdDev: 100, Max: 80}); d < 0 || d > 80*time.Millisecond {
+			t.Fatalf("Normal delay not clamped: %v", d)
+		}
+	}
+	if d := Delay(nil); d != 0 {
+		t.Errorf("Expected no delay, got %v", d)
+	}
+}
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+)
+
+// Diff unified diff without context lines between two refs of the repository at dir, limited to paths.
+// An empty head compares the base ref with the working tree. When dir is a subdirectory of the repository, only
+// its changes are listed, with paths relative to it.
+func Diff(ctx context.Context, dir, base, head string, paths ...string) (string, error) {
+	args := []string{"diff", "--no-color", "--no-ext-diff", "--unified=0", "--relative"}
+	for _, ref := range []string{base, head} {
+		if ref == "" {
+			continue
+		}
+		if err := validateRef(ref); err != nil {
+			return "", err
+		}
+		args = append(args, ref)
+	}
+	args = append(args, "--")
+	args = append(args, paths...)
+
+	out, err := run(ctx, dir, args...)
+	return string(out), err
+}
+
+// Show content of a file at a ref of the repository at dir, path is relative to dir
+func Show(ctx context.Context, dir, ref, path string) ([]byte, error) {
+	if err := validateRef(ref); err != nil {
+		return nil, err
+	}
+	return run(ctx, dir, "show", ref+":./"+path)
+}
+
+// Head commit the HEAD of the repository at dir points to
+func Head(ctx context.Context, dir string) (string, error) {
+	out, err := run(ctx, dir, "rev-parse", "--verify", "HEAD")
+	return strings.TrimSpace(string(out)), err
+}
+
+// Clean whether the working tree of the repository at dir has no uncommitted changes, untracked files included
+func Clean(ctx context.Context, dir string) (bool, error) {
+	out, err := run(ctx, dir, "status", "--porcelain")
+	return len(bytes.TrimSpace(out)) == 0, err
+}
+
+// validateRef reject refs that git would parse as options
+func validateRef(ref string) error {
+	if strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n:") {
+		return fmt.Errorf("%s", i18n.Translate("git.invalid_ref", "", map[string]interface{}{"ref": ref}))
+	}
+	return nil
+}
+
+// run execute a git command in dir and return its standard output
+func run(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: %s", i18n.Translate("git.command_failed", "", map[string]interface{}{"command": args[0]}), strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
@@ -18,8 +18,17 @@ import (
 	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/pkg/headerpropagation"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// defaultService service name of the metrics of clients without one
+const defaultService = "default"
+
 // Client HTTP client
 type Client struct {
 	config      *HttpServiceConfig
@@ -40,6 +49,10 @@ func NewClient(config *HttpServiceConfig) (*Client, error) {
 		IdleConnTimeout:     90 * time.Second,
 	}
 
+	if config.DialContext != nil {
+		transport.DialContext = config.DialContext
+	}
+
 	// Configure proxy
 	if config.ProxyURL != "" {
 		proxyURL, err := url.Parse(config.ProxyURL)
@@ -136,8 +149,33 @@ func (c *Client) AddMiddleware(middleware Middleware) {
 	c.middlewares = append(c.middlewares, middleware)
 }
 
-// Request send HTTP request
+// service service name of the metrics of the client
+func (c *Client) service() string {
+	if c.config.Service == "" {
+		return defaultService
+	}
+	return c.config.Service
+}
+
+// Request send HTTP request within a client span, propagating its trace context in the traceparent header
 func (c *Client) Request(ctx context.Context, method, path string, body interface{}, headers map[string]string) (*http.Response, error) {
+	ctx, span := tracing.StartKind(ctx, trace.SpanKindClient, method+" "+c.service(),
+		semconv.HTTPRequestMethodKey.String(method),
+		attribute.String("http.client.service", c.service()),
+	)
+	resp, err := c.request(ctx, method, path, body, headers)
+	if resp != nil {
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if err == nil && resp.StatusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		}
+	}
+	tracing.End(span, err)
+	return resp, err
+}
+
+// request send HTTP request, with retries
+func (c *Client) request(ctx context.Context, method, path string, body interface{}, headers map[string]string) (*http.Response, error) {
 	// Merge propagated headers from context
 	propagatedHeaders := headerpropagation.GetAllPropagatedHeaders(ctx)
 	if headers == nil {
@@ -193,6 +231,13 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 	for key, value := range headers {
 		req.Header.Set(key, value)
 	}
+	tracing.Inject(ctx, req.Header)
+
+	// The query may carry credentials, leave it out of the span
+	span := trace.SpanFromContext(ctx)
+	spanURL := *req.URL
+	spanURL.User, spanURL.RawQuery = nil, ""
+	span.SetAttributes(semconv.URLFull(spanURL.String()), semconv.ServerAddress(req.URL.Hostname()))
 
 	// Apply request middlewares
 	for _, middleware := range c.middlewares {
@@ -208,7 +253,9 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 	// Implement retry logic
 	retries := 0
 	for {
+		start := time.Now()
 		resp, respErr = c.httpClient.Do(req)
+		metrics.ObserveOutbound(c.service(), method, resp, respErr, time.Since(start))
 
 		// Apply response middlewares
 		for i := len(c.middlewares) - 1; i >= 0; i-- {
@@ -237,6 +284,7 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 		}
 
 		retries++
+		span.SetAttributes(semconv.HTTPRequestResendCount(retries))
 		logger.Debug(i18n.Translate("httpclient.retry.attempt", "", map[string]interface{}{
 			"attempt": retries,
 			"max":     c.config.MaxRetries,
@@ -266,6 +314,7 @@ func (c *Client) Request(ctx context.Context, method, path string, body interfac
 			for key, value := range headers {
 				req.Header.Set(key, value)
 			}
+			tracing.Inject(ctx, req.Header)
 			// Re-apply request middlewares
 			for _, middleware := range c.middlewares {
 				if err := middleware.ProcessRequest(req); err != nil {
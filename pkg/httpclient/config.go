@@ -1,6 +1,8 @@
 package httpclient
 
 import (
+	"context"
+	"net"
 	"time"
 
 	"github.com/zgsm/mock-kbcenter/config"
@@ -8,6 +10,9 @@ import (
 
 // HttpServiceConfig HTTP client configuration
 type HttpServiceConfig struct {
+	// Service name, labels the metrics of the requests
+	Service string `yaml:"service"`
+
 	// Base URL, all requests will be based on this URL
 	BaseURL string `yaml:"base_url"`
 
@@ -31,6 +36,9 @@ type HttpServiceConfig struct {
 	// Proxy settings
 	ProxyURL string `yaml:"proxy_url"`
 
+	// Dial function of the connections, the default dialer when nil
+	DialContext func(ctx context.Context, network, addr string) (net.Conn, error) `yaml:"-"`
+
 	// TLS settings
 	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
 	CertFile           string `yaml:"cert_file"`
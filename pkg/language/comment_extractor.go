@@ -0,0 +1,65 @@
+package language
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/zgsm/mock-kbcenter/i18n"
+)
+
+// CommentInfo contains comment text and its location information
+type CommentInfo struct {
+	Text      string // Comment text including its delimiters
+	StartLine int    // Start line number
+	EndLine   int    // End line number
+	Trailing  bool   // Whether the comment follows code on its start line
+}
+
+// ExtractComments extracts all comments from source code using tree-sitter
+//
+// Comment nodes are recognized by their node type, which ends with "comment" in every supported
+// grammar (comment, line_comment, block_comment). Parsing stops when ctx is done.
+func ExtractComments(ctx context.Context, lang string, content string) ([]CommentInfo, error) {
+	parser := sitter.NewParser()
+	defer parser.Close()
+
+	language, err := getLanguage(lang)
+	if err != nil {
+		return nil, err
+	}
+
+	if language == nil {
+		return nil, fmt.Errorf("%s", i18n.Translate("language.invalid_language", "", map[string]interface{}{
+			"language": lang,
+		}))
+	}
+	parser.SetLanguage(language)
+	tree, err := parse(ctx, parser, lang, []byte(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []CommentInfo
+	var walk func(node *sitter.Node)
+	walk = func(node *sitter.Node) {
+		if strings.HasSuffix(node.Type(), "comment") {
+			start := node.StartByte()
+			lineStart := strings.LastIndexByte(content[:start], '\n') + 1
+			comments = append(comments, CommentInfo{
+				Text:      content[start:node.EndByte()],
+				StartLine: int(node.StartPoint().Row) + 1,
+				EndLine:   int(node.EndPoint().Row) + 1,
+				Trailing:  strings.TrimSpace(content[lineStart:start]) != "",
+			})
+			return
+		}
+		for i := 0; i < int(node.ChildCount()); i++ {
+			walk(node.Child(i))
+		}
+	}
+	walk(tree.RootNode())
+
+	return comments, nil
+}
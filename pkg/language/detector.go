@@ -15,3 +15,13 @@ func Detect(filePath string) (string, error) {
 	}
 	return "", errors.New("not supported file type")
 }
+
+// Supported whether the language is mapped from any file extension
+func Supported(lang string) bool {
+	for _, mapped := range config.GetConfig().LanguageMapping {
+		if mapped == lang {
+			return true
+		}
+	}
+	return false
+}
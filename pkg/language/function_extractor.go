@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
 	tree_sitter_c "github.com/smacker/go-tree-sitter/c"
@@ -18,6 +19,9 @@ import (
 	tree_sitter_typescript "github.com/smacker/go-tree-sitter/typescript/typescript"
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
+	"github.com/zgsm/mock-kbcenter/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func getLanguage(lang string) (*sitter.Language, error) {
@@ -50,6 +54,23 @@ func getLanguage(lang string) (*sitter.Language, error) {
 	}
 }
 
+// parse parse the source of the language with the parser, returning the context error when parsing stopped
+// because ctx is done
+func parse(ctx context.Context, parser *sitter.Parser, lang string, source []byte) (tree *sitter.Tree, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "language.parse", attribute.String("code.language", strings.ToLower(lang)), attribute.Int("file.size", len(source)))
+	defer func() {
+		metrics.ObserveParse(strings.ToLower(lang), len(source), time.Since(start), err)
+		tracing.End(span, err)
+	}()
+
+	tree, err = parser.ParseCtx(ctx, nil, source)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return tree, err
+}
+
 // FunctionInfo contains function code and its location information
 type FunctionInfo struct {
 	Code      string // Function code content
@@ -57,8 +78,8 @@ type FunctionInfo struct {
 	EndLine   int    // End line number
 }
 
-// GetFunctionName extracts function name from code using tree-sitter
-func GetFunctionName(lang string, code string) (string, error) {
+// GetFunctionName extracts function name from code using tree-sitter, parsing stops when ctx is done
+func GetFunctionName(ctx context.Context, lang string, code string) (name string, err error) {
 	parser := sitter.NewParser()
 	defer parser.Close()
 
@@ -68,12 +89,14 @@ func GetFunctionName(lang string, code string) (string, error) {
 	}
 
 	parser.SetLanguage(language)
-	tree, err := parser.ParseCtx(context.Background(), nil, []byte(code))
+	tree, err := parse(ctx, parser, lang, []byte(code))
 	if err != nil {
 		return "", err
 	}
 
 	rootNode := tree.RootNode()
+	_, span := tracing.Start(ctx, "language.query", attribute.String("code.language", strings.ToLower(lang)))
+	defer func() { tracing.End(span, err) }()
 
 	// Query pattern depends on language
 	var queryPattern string
@@ -141,13 +164,14 @@ func FormatCodeWithLineNumbers(code string, startLine, endLine, relativeStart in
 
 // ExtractFunctions extracts function definitions from source code
 // Parameters:
+//   - ctx: Context, parsing and matching stop when it is done
 //   - lang: Language type (go, javascript, python)
 //   - content: Source code content
 //
 // Returns:
 //   - Slice of function info (containing code content and line range)
 //   - Error information
-func ExtractFunctions(lang string, content string) ([]FunctionInfo, error) {
+func ExtractFunctions(ctx context.Context, lang string, content string) (functions []FunctionInfo, err error) {
 	parser := sitter.NewParser()
 	defer parser.Close()
 
@@ -162,13 +186,17 @@ func ExtractFunctions(lang string, content string) ([]FunctionInfo, error) {
 		}))
 	}
 	parser.SetLanguage(language)
-	tree, err := parser.ParseCtx(context.Background(), nil, []byte(content))
+	tree, err := parse(ctx, parser, lang, []byte(content))
 	if err != nil {
 		return nil, err
 	}
 
 	rootNode := tree.RootNode()
-	var functions []FunctionInfo
+	ctx, span := tracing.Start(ctx, "language.query", attribute.String("code.language", strings.ToLower(lang)))
+	defer func() {
+		span.SetAttributes(attribute.Int("language.functions", len(functions)))
+		tracing.End(span, err)
+	}()
 
 	// Get query pattern from config
 	cfg := config.GetConfig()
@@ -201,6 +229,9 @@ func ExtractFunctions(lang string, content string) ([]FunctionInfo, error) {
 	qc.Exec(query, rootNode)
 
 	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		match, ok := qc.NextMatch()
 		if !ok {
 			break
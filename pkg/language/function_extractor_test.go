@@ -1,6 +1,7 @@
 package language
 
 import (
+	"context"
 	"strings"
 	"testing"
 )
@@ -57,7 +58,7 @@ func helloWorld() {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := GetFunctionName(tt.lang, tt.code)
+			got, err := GetFunctionName(context.Background(), tt.lang, tt.code)
 			if tt.wantErr {
 				if err == nil {
 					t.Error("Expected error, got nil")
@@ -88,7 +89,7 @@ func (p *Person) greet() string {
 	return "Hello, " + p.Name
 }`
 
-	functions, err := ExtractFunctions("go", code)
+	functions, err := ExtractFunctions(context.Background(), "go", code)
 	if err != nil {
 		t.Fatalf("ExtractFunctions failed: %v", err)
 	}
@@ -123,7 +124,7 @@ const multiply = (a, b) => {
 	return a * b;
 }`
 
-	functions, err := ExtractFunctions("javascript", code)
+	functions, err := ExtractFunctions(context.Background(), "javascript", code)
 	if err != nil {
 		t.Fatalf("ExtractFunctions failed: %v", err)
 	}
@@ -141,7 +142,7 @@ class Calculator:
 	def multiply(self, a, b):
 		return a * b`
 
-	functions, err := ExtractFunctions("python", code)
+	functions, err := ExtractFunctions(context.Background(), "python", code)
 	if err != nil {
 		t.Fatalf("ExtractFunctions failed: %v", err)
 	}
@@ -152,14 +153,14 @@ class Calculator:
 }
 
 func TestExtractFunctions_UnsupportedLanguage(t *testing.T) {
-	_, err := ExtractFunctions("unknown", "some code")
+	_, err := ExtractFunctions(context.Background(), "unknown", "some code")
 	if err == nil {
 		t.Error("Expected error for unsupported language")
 	}
 }
 
 func TestExtractFunctions_EmptyCode(t *testing.T) {
-	functions, err := ExtractFunctions("go", "")
+	functions, err := ExtractFunctions(context.Background(), "go", "")
 	if err != nil {
 		t.Fatalf("ExtractFunctions failed: %v", err)
 	}
@@ -167,3 +168,11 @@ func TestExtractFunctions_EmptyCode(t *testing.T) {
 		t.Errorf("Expected 0 functions, got %d", len(functions))
 	}
 }
+
+func TestExtractFunctions_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ExtractFunctions(ctx, "go", "package main\n\nfunc main() {}\n"); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+// namespace prefix of the metric names
+const namespace = "kbcenter"
+
+// Results of tasks and enqueues
+const (
+	ResultSuccess   = "success"
+	ResultFailure   = "failure"
+	ResultDuplicate = "duplicate" // Task with the same ID already enqueued
+)
+
+// defaultPath path of the metrics when none is configured
+const defaultPath = "/metrics"
+
+// UnmatchedRoute route label of requests matching no route
+const UnmatchedRoute = "unmatched"
+
+// registry registry of the metrics of the process, served by Handler
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "http", Name: "requests_total",
+		Help: "HTTP requests served, by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "http", Name: "request_duration_seconds",
+		Help:    "Duration of the HTTP requests served, by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "code"})
+
+	parseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "parse", Name: "duration_seconds",
+		Help:    "Duration of the tree-sitter parses, by language.",
+		Buckets: prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"language"})
+	parseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "parse", Name: "file_size_bytes",
+		Help:    "Size of the sources parsed with tree-sitter, by language.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"language"})
+	parseErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "parse", Name: "errors_total",
+		Help: "Failed or canceled tree-sitter parses, by language.",
+	}, []string{"language"})
+
+	tasksEnqueued = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "tasks", Name: "enqueued_total",
+		Help: "Asynq tasks enqueued, by type and result.",
+	}, []string{"type", "result"})
+	tasksProcessed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "tasks", Name: "processed_total",
+		Help: "Asynq tasks processed, by type and result.",
+	}, []string{"type", "result"})
+	taskDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "tasks", Name: "duration_seconds",
+		Help:    "Duration of the Asynq tasks processed, by type and result.",
+		Buckets: prometheus.ExponentialBuckets(0.01, 2, 16),
+	}, []string{"type", "result"})
+
+	outboundRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbound", Name: "requests_total",
+		Help: "Outbound HTTP requests answered, by service, method and status code.",
+	}, []string{"service", "method", "code"})
+	outboundDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace, Subsystem: "outbound", Name: "request_duration_seconds",
+		Help:    "Time to the response headers of outbound HTTP requests, failed ones included, by service and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"service", "method"})
+	outboundErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace, Subsystem: "outbound", Name: "errors_total",
+		Help: "Outbound HTTP requests failed without a response, by service and method.",
+	}, []string{"service", "method"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		parseDuration, parseSize, parseErrors,
+		tasksEnqueued, tasksProcessed, taskDuration,
+		outboundRequests, outboundDuration, outboundErrors,
+	)
+}
+
+// Path path the metrics are served on
+func Path(cfg config.Metrics) string {
+	if cfg.Path == "" {
+		return defaultPath
+	}
+	return cfg.Path
+}
+
+// Handler handler serving the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// ObserveHTTPRequest record a served HTTP request, route is the route pattern and not the path so that the
+// number of series stays bounded
+func ObserveHTTPRequest(route, method string, code int, duration time.Duration) {
+	if route == "" {
+		route = UnmatchedRoute
+	}
+	labels := prometheus.Labels{"route": route, "method": method, "code": strconv.Itoa(code)}
+	httpRequests.With(labels).Inc()
+	httpDuration.With(labels).Observe(duration.Seconds())
+}
+
+// InstrumentHandler handler recording the requests served by the handler of a net/http route
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, code: http.StatusOK}
+		handler.ServeHTTP(recorder, r)
+		ObserveHTTPRequest(route, r.Method, recorder.code, time.Since(start))
+	})
+}
+
+// ObserveParse record a tree-sitter parse of a source of size bytes
+func ObserveParse(language string, size int, duration time.Duration, err error) {
+	parseDuration.WithLabelValues(language).Observe(duration.Seconds())
+	parseSize.WithLabelValues(language).Observe(float64(size))
+	if err != nil {
+		parseErrors.WithLabelValues(language).Inc()
+	}
+}
+
+// ObserveEnqueue record an enqueue of a task
+func ObserveEnqueue(taskType string, err error) {
+	outcome := result(err)
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		outcome = ResultDuplicate
+	}
+	tasksEnqueued.WithLabelValues(taskType, outcome).Inc()
+}
+
+// TaskMiddleware Asynq middleware recording the tasks processed by the handler
+func TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		start := time.Now()
+		err := next.ProcessTask(ctx, task)
+		outcome := result(err)
+		tasksProcessed.WithLabelValues(task.Type(), outcome).Inc()
+		taskDuration.WithLabelValues(task.Type(), outcome).Observe(time.Since(start).Seconds())
+		return err
+	})
+}
+
+// ObserveOutbound record an outbound request of a service, resp is nil when it failed without a response
+func ObserveOutbound(service, method string, resp *http.Response, err error, duration time.Duration) {
+	outboundDuration.WithLabelValues(service, method).Observe(duration.Seconds())
+	if err != nil || resp == nil {
+		outboundErrors.WithLabelValues(service, method).Inc()
+		return
+	}
+	outboundRequests.WithLabelValues(service, method, strconv.Itoa(resp.StatusCode)).Inc()
+}
+
+// InstrumentRoundTripper round tripper recording the requests sent through it as outbound requests of the service
+func InstrumentRoundTripper(service string, next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		// The client going away is not a failure of the service
+		if !errors.Is(err, context.Canceled) {
+			ObserveOutbound(service, req.Method, resp, err, time.Since(start))
+		}
+		return resp, err
+	})
+}
+
+// RegisterDBStats expose the connection pool statistics of a database, replacing those of an earlier connection
+func RegisterDBStats(name string, db *sql.DB) {
+	register(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterRedisPool expose the connection pool statistics of a Redis client, replacing those of an earlier client
+func RegisterRedisPool(client *redis.Client) {
+	register(&redisPoolCollector{client: client})
+}
+
+// register register a collector, unregistering the collector of the same metrics first
+func register(collector prometheus.Collector) {
+	registry.Unregister(collector)
+	registry.MustRegister(collector)
+}
+
+// result result label of an error
+func result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
+
+// redisPoolCollector collector of the pool statistics of a Redis client
+type redisPoolCollector struct {
+	client *redis.Client
+}
+
+var (
+	redisHits     = prometheus.NewDesc(namespace+"_redis_pool_hits_total", "Times a free connection was found in the pool.", nil, nil)
+	redisMisses   = prometheus.NewDesc(namespace+"_redis_pool_misses_total", "Times a free connection was not found in the pool.", nil, nil)
+	redisTimeouts = prometheus.NewDesc(namespace+"_redis_pool_timeouts_total", "Times waiting for a connection of the pool timed out.", nil, nil)
+	redisTotal    = prometheus.NewDesc(namespace+"_redis_pool_connections", "Connections in the pool.", nil, nil)
+	redisIdle     = prometheus.NewDesc(namespace+"_redis_pool_idle_connections", "Idle connections in the pool.", nil, nil)
+	redisStale    = prometheus.NewDesc(namespace+"_redis_pool_stale_connections_total", "Stale connections removed from the pool.", nil, nil)
+)
+
+func (c *redisPoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redisHits
+	ch <- redisMisses
+	ch <- redisTimeouts
+	ch <- redisTotal
+	ch <- redisIdle
+	ch <- redisStale
+}
+
+func (c *redisPoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	ch <- prometheus.MustNewConstMetric(redisHits, prometheus.CounterValue, float64(stats.Hits))
+	ch <- prometheus.MustNewConstMetric(redisMisses, prometheus.CounterValue, float64(stats.Misses))
+	ch <- prometheus.MustNewConstMetric(redisTimeouts, prometheus.CounterValue, float64(stats.Timeouts))
+	ch <- prometheus.MustNewConstMetric(redisTotal, prometheus.GaugeValue, float64(stats.TotalConns))
+	ch <- prometheus.MustNewConstMetric(redisIdle, prometheus.GaugeValue, float64(stats.IdleConns))
+	ch <- prometheus.MustNewConstMetric(redisStale, prometheus.CounterValue, float64(stats.StaleConns))
+}
+
+// statusRecorder response writer remembering the status code. Flushing and hijacking go to the wrapped
+// writer, so that streamed responses, WebSocket handshakes and protocol upgrades keep working.
+type statusRecorder struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.code, r.wroteHeader = code, code >= 200 || code == http.StatusSwitchingProtocols
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(p)
+}
+
+func (r *statusRecorder) Flush() {
+	_ = http.NewResponseController(r.ResponseWriter).Flush()
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(r.ResponseWriter).Hijack()
+	if err == nil && !r.wroteHeader {
+		// The handler answers on the connection itself, as WebSocket servers do
+		r.code, r.wroteHeader = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// roundTripperFunc function implementing http.RoundTripper
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
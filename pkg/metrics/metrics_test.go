@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hibiken/asynq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// delta returns a function giving the increase of the collector since the call
+func delta(collector prometheus.Collector) func() float64 {
+	start := testutil.ToFloat64(collector)
+	return func() float64 { return testutil.ToFloat64(collector) - start }
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	codes := map[string]string{"test/created": "201", "test/stream": "200", "test/hijack": "101"}
+	counts := make(map[string]func() float64)
+	for route, code := range codes {
+		counts[route] = delta(httpRequests.WithLabelValues(route, http.MethodGet, code))
+	}
+	handler := http.NewServeMux()
+	handler.HandleFunc("/created", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	handler.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data")
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("Flush failed: %v", err)
+		}
+	})
+	handler.HandleFunc("/hijack", func(w http.ResponseWriter, r *http.Request) {
+		// WebSocket servers assert the Hijacker interface instead of using a ResponseController
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Hijack failed: %v", err)
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(buf, "HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+		buf.Flush()
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		InstrumentHandler("test"+r.URL.Path, handler).ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	for _, path := range []string{"/created", "/stream"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	fmt.Fprint(conn, "GET /hijack HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: test\r\n\r\n")
+	if resp, err := http.ReadResponse(bufio.NewReader(conn), nil); err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected 101, got %v %v", resp, err)
+	}
+	conn.Close()
+
+	for route, code := range codes {
+		// The hijacked request is recorded once its handler returns, after the client has its response
+		for deadline := time.Now().Add(2 * time.Second); counts[route]() == 0 && time.Now().Before(deadline); {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if count := counts[route](); count != 1 {
+			t.Errorf("Expected 1 request of %s with %s, got %v", route, code, count)
+		}
+	}
+}
+
+func TestTasksAndOutboundRequests(t *testing.T) {
+	duplicates := delta(tasksEnqueued.WithLabelValues("review:run", ResultDuplicate))
+	failedTasks := delta(tasksProcessed.WithLabelValues("review:chunk", ResultFailure))
+	answered := delta(outboundRequests.WithLabelValues("upstream", http.MethodGet, "404"))
+	failed := delta(outboundErrors.WithLabelValues("upstream", http.MethodGet))
+
+	ObserveEnqueue("review:run", nil)
+	ObserveEnqueue("review:run", fmt.Errorf("enqueue: %w", asynq.ErrTaskIDConflict))
+	if count := duplicates(); count != 1 {
+		t.Errorf("Expected 1 duplicate enqueue, got %v", count)
+	}
+
+	handler := TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		return errors.New("failed")
+	}))
+	if err := handler.ProcessTask(context.Background(), asynq.NewTask("review:chunk", nil)); err == nil {
+		t.Error("Expected the error of the handler")
+	}
+	if count := failedTasks(); count != 1 {
+		t.Errorf("Expected 1 failed task, got %v", count)
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	client := &http.Client{Transport: InstrumentRoundTripper("upstream", http.DefaultTransport)}
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	upstream.Close()
+	if _, err := client.Get(upstream.URL); err == nil {
+		t.Fatal("Expected the closed server to fail")
+	}
+	if count := answered(); count != 1 {
+		t.Errorf("Expected 1 answered request, got %v", count)
+	}
+	if count := failed(); count != 1 {
+		t.Errorf("Expected 1 failed request, got %v", count)
+	}
+
+	// Everything is exposed by the handler
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, name := range []string{"kbcenter_tasks_enqueued_total", "kbcenter_tasks_duration_seconds", "kbcenter_outbound_errors_total", "go_goroutines"} {
+		if !strings.Contains(recorder.Body.String(), name) {
+			t.Errorf("Expected %s in the exposed metrics", name)
+		}
+	}
+}
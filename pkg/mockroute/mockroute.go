@@ -0,0 +1,208 @@
+package mockroute
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+// ErrInvalidRoute mock route with an invalid method, path or response
+var ErrInvalidRoute = errors.New("invalid mock route")
+
+// Request template data of a call to a mock route
+type Request struct {
+	Method  string
+	Path    string
+	Params  map[string]string // Path parameters
+	Query   map[string]string // First value of each query parameter
+	Headers map[string]string // First value of each header
+	Body    string            // Raw request body
+	JSON    interface{}       // Request body decoded as JSON, an empty object when it is not JSON
+	Call    int               // Number of the call since start or reset, from 1
+}
+
+// Response rendered response of a mock route
+type Response struct {
+	Status  int
+	Headers map[string]string
+	Body    []byte
+}
+
+// Route mock route with its compiled responses and call count, safe for concurrent use
+type Route struct {
+	Name   string
+	Method string
+	Path   string
+
+	responses []*response
+	loop      bool
+
+	mu    sync.Mutex
+	calls int
+}
+
+// response compiled templated response
+type response struct {
+	status  int
+	headers map[string]*template.Template
+	body    *template.Template
+}
+
+// templateFuncs functions available in the templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"default": func(fallback, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"now": func() string {
+		return time.Now().Format(time.RFC3339)
+	},
+}
+
+// New compile a mock route, reading the fixture files of its responses
+func New(cfg config.MockRoute) (*Route, error) {
+	route := &Route{Name: cfg.Name, Method: strings.ToUpper(cfg.Method), Path: cfg.Path, loop: cfg.Loop}
+	if route.Method == "" {
+		route.Method = http.MethodGet
+	}
+	if route.Name == "" {
+		route.Name = route.Method + " " + route.Path
+	}
+	if !strings.HasPrefix(cfg.Path, "/") {
+		return nil, fmt.Errorf("%w %s: path must start with /", ErrInvalidRoute, route.Name)
+	}
+
+	responses := cfg.Sequence
+	if len(responses) == 0 {
+		responses = []config.MockResponse{cfg.Response}
+	}
+	for idx, resp := range responses {
+		compiled, err := compile(resp)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: response #%d: %s", ErrInvalidRoute, route.Name, idx+1, err)
+		}
+		route.responses = append(route.responses, compiled)
+	}
+	return route, nil
+}
+
+// Respond render the next response of the route for the request
+func (r *Route) Respond(req Request) (*Response, error) {
+	r.mu.Lock()
+	r.calls++
+	req.Call = r.calls
+	r.mu.Unlock()
+
+	if req.JSON == nil {
+		// Field lookups render empty instead of failing on requests without a JSON body
+		req.JSON = map[string]interface{}{}
+	}
+
+	idx := req.Call - 1
+	if r.loop {
+		idx %= len(r.responses)
+	} else if idx >= len(r.responses) {
+		idx = len(r.responses) - 1
+	}
+	resp := r.responses[idx]
+
+	body, err := render(resp.body, req)
+	if err != nil {
+		return nil, err
+	}
+	headers := make(map[string]string, len(resp.headers))
+	for name, tmpl := range resp.headers {
+		value, err := render(tmpl, req)
+		if err != nil {
+			return nil, err
+		}
+		headers[name] = string(value)
+	}
+	return &Response{Status: resp.status, Headers: headers, Body: body}, nil
+}
+
+// Calls number of calls since start or reset
+func (r *Route) Calls() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.calls
+}
+
+// Reset start the sequence over
+func (r *Route) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = 0
+}
+
+// ContentType content type of a response without one: JSON when the body looks like JSON, plain text otherwise
+func ContentType(body []byte) string {
+	if trimmed := bytes.TrimSpace(body); bytes.HasPrefix(trimmed, []byte("{")) || bytes.HasPrefix(trimmed, []byte("[")) {
+		return "application/json; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// compile parse the templates of a response
+func compile(cfg config.MockResponse) (*response, error) {
+	resp := &response{status: cfg.Status, headers: make(map[string]*template.Template, len(cfg.Headers))}
+	if resp.status == 0 {
+		resp.status = http.StatusOK
+	}
+	if resp.status < 100 || resp.status > 599 {
+		return nil, errors.New("status must be an HTTP status code")
+	}
+	if cfg.Body != "" && cfg.File != "" {
+		return nil, errors.New("body and file are exclusive")
+	}
+
+	text := cfg.Body
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	var err error
+	if resp.body, err = newTemplate("body").Parse(text); err != nil {
+		return nil, err
+	}
+	for name, value := range cfg.Headers {
+		if resp.headers[name], err = newTemplate(name).Parse(value); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// newTemplate template with the template functions, missing path and query parameters render empty
+func newTemplate(name string) *template.Template {
+	return template.New(name).Funcs(templateFuncs).Option("missingkey=zero")
+}
+
+// render execute a template with the request as data
+func render(tmpl *template.Template, req Request) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, req); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,99 @@
+package mockroute
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+func TestRoute_Respond(t *testing.T) {
+	route, err := New(config.MockRoute{
+		Method: "post",
+		Path:   "/codebases/:id/search",
+		Response: config.MockResponse{
+			Status:  201,
+			Headers: map[string]string{"X-Codebase": "{{.Params.id}}"},
+			Body:    `{"id":"{{.Params.id}}","q":"{{.Query.q}}","limit":{{default 10 .JSON.limit}},"missing":"{{.Query.none}}","call":{{.Call}}}`,
+		},
+	})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if route.Method != "POST" || route.Name != "POST /codebases/:id/search" {
+		t.Errorf("Unexpected route: %s %s", route.Method, route.Name)
+	}
+
+	resp, err := route.Respond(Request{
+		Params: map[string]string{"id": "cb1"},
+		Query:  map[string]string{"q": "main"},
+		JSON:   map[string]interface{}{"limit": 5},
+	})
+	if err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	want := `{"id":"cb1","q":"main","limit":5,"missing":"","call":1}`
+	if resp.Status != 201 || string(resp.Body) != want || resp.Headers["X-Codebase"] != "cb1" {
+		t.Errorf("Unexpected response: %d %s %v", resp.Status, resp.Body, resp.Headers)
+	}
+
+	resp, err = route.Respond(Request{})
+	if err != nil {
+		t.Fatalf("Respond failed: %v", err)
+	}
+	if want := `{"id":"","q":"","limit":10,"missing":"","call":2}`; string(resp.Body) != want {
+		t.Errorf("Expected %s, got %s", want, resp.Body)
+	}
+}
+
+func TestRoute_Sequence(t *testing.T) {
+	fixture := filepath.Join(t.TempDir(), "ready.json")
+	if err := os.WriteFile(fixture, []byte(`{"status":"ready","call":{{.Call}}}`), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	sequence := []config.MockResponse{{Status: 202, Body: "pending"}, {File: fixture}}
+
+	tests := []struct {
+		loop bool
+		want []string
+	}{
+		{false, []string{"pending", `{"status":"ready","call":2}`, `{"status":"ready","call":3}`}},
+		{true, []string{"pending", `{"status":"ready","call":2}`, "pending"}},
+	}
+	for _, tt := range tests {
+		route, err := New(config.MockRoute{Path: "/jobs/:id", Sequence: sequence, Loop: tt.loop})
+		if err != nil {
+			t.Fatalf("New failed: %v", err)
+		}
+		for i, want := range tt.want {
+			resp, err := route.Respond(Request{})
+			if err != nil {
+				t.Fatalf("Respond failed: %v", err)
+			}
+			if string(resp.Body) != want {
+				t.Errorf("loop=%v call %d: expected %s, got %s", tt.loop, i+1, want, resp.Body)
+			}
+		}
+		route.Reset()
+		if resp, _ := route.Respond(Request{}); resp.Status != 202 || route.Calls() != 1 {
+			t.Errorf("loop=%v: expected the sequence to start over after reset, got %d", tt.loop, resp.Status)
+		}
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	invalid := []config.MockRoute{
+		{Path: "no-slash"},
+		{Path: "/bad-status", Response: config.MockResponse{Status: 42}},
+		{Path: "/both", Response: config.MockResponse{Body: "x", File: "x.json"}},
+		{Path: "/missing-file", Response: config.MockResponse{File: "does-not-exist.json"}},
+		{Path: "/bad-template", Sequence: []config.MockResponse{{Body: "{{.Params.id"}}},
+	}
+	for _, cfg := range invalid {
+		if _, err := New(cfg); !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("Expected invalid route error for %s, got %v", cfg.Path, err)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+)
+
+// maxExampleDepth nesting depth of the examples generated from schemas, deeper (e.g. recursive) schemas are left out
+const maxExampleDepth = 8
+
+// ErrNoOperation request not declared in the document
+var ErrNoOperation = errors.New("no operation declared")
+
+// Spec OpenAPI 3 document with the operations matched by request path
+type Spec struct {
+	doc      *openapi3.T
+	basePath string
+	paths    []specPath // In matching order, concrete paths first
+}
+
+// specPath templated path of the document
+type specPath struct {
+	path     string
+	segments []string
+	item     *openapi3.PathItem
+}
+
+// Operation operation a request resolves to
+type Operation struct {
+	Route      *routers.Route
+	PathParams map[string]string
+}
+
+// Example response served for an operation
+type Example struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Load load and validate an OpenAPI 3 document, YAML or JSON. Operation paths are relative to the base path,
+// the path of the first server when it is empty.
+func Load(ctx context.Context, file, basePath string) (*Spec, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+	doc, err := loader.LoadFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := doc.Validate(ctx); err != nil {
+		return nil, err
+	}
+
+	if basePath == "" && len(doc.Servers) > 0 {
+		if basePath, err = doc.Servers[0].BasePath(); err != nil {
+			return nil, err
+		}
+	}
+	spec := &Spec{doc: doc, basePath: strings.TrimSuffix(basePath, "/")}
+	for _, path := range doc.Paths.InMatchingOrder() {
+		spec.paths = append(spec.paths, specPath{
+			path:     path,
+			segments: strings.Split(strings.Trim(path, "/"), "/"),
+			item:     doc.Paths.Value(path),
+		})
+	}
+	return spec, nil
+}
+
+// Find operation of the request, ErrNoOperation when the document declares none
+func (s *Spec) Find(r *http.Request) (*Operation, error) {
+	path, ok := strings.CutPrefix(r.URL.Path, s.basePath)
+	if !ok {
+		return nil, ErrNoOperation
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, p := range s.paths {
+		params, ok := matchSegments(p.segments, segments)
+		if !ok {
+			continue
+		}
+		operation := p.item.GetOperation(r.Method)
+		if operation == nil {
+			return nil, ErrNoOperation
+		}
+		return &Operation{
+			Route: &routers.Route{
+				Spec:      s.doc,
+				Path:      p.path,
+				PathItem:  p.item,
+				Method:    r.Method,
+				Operation: operation,
+			},
+			PathParams: params,
+		}, nil
+	}
+	return nil, ErrNoOperation
+}
+
+// ValidateRequest validate the parameters and body of the request, the body is left readable
+func (o *Operation) ValidateRequest(ctx context.Context, r *http.Request) error {
+	return openapi3filter.ValidateRequest(ctx, o.requestInput(r))
+}
+
+// ValidateResponse validate the status, headers and body of the response to the request
+func (o *Operation) ValidateResponse(ctx context.Context, r *http.Request, status int, header http.Header, body []byte) error {
+	input := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: o.requestInput(r),
+		Status:                 status,
+		Header:                 header,
+		Body:                   io.NopCloser(strings.NewReader(string(body))),
+		Options:                &openapi3filter.Options{MultiError: true, IncludeResponseStatus: true},
+	}
+	return openapi3filter.ValidateResponse(ctx, input)
+}
+
+// requestInput validation input of the request, security requirements are not checked by the mock
+func (o *Operation) requestInput(r *http.Request) *openapi3filter.RequestValidationInput {
+	return &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: o.PathParams,
+		Route:      o.Route,
+		Options: &openapi3filter.Options{
+			MultiError:         true,
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+		},
+	}
+}
+
+// Example response of the operation: the lowest declared success status, or the default response, with the
+// example of its JSON content when declared and one generated from its schema otherwise
+func (o *Operation) Example() (*Example, error) {
+	status, response := successResponse(o.Route.Operation.Responses)
+	example := &Example{Status: status}
+	if response == nil || len(response.Content) == 0 {
+		return example, nil
+	}
+
+	contentType := "application/json"
+	media := response.Content.Get(contentType)
+	if media == nil {
+		// The first content type in name order
+		types := make([]string, 0, len(response.Content))
+		for name := range response.Content {
+			types = append(types, name)
+		}
+		sort.Strings(types)
+		contentType, media = types[0], response.Content[types[0]]
+	}
+	example.ContentType = contentType
+
+	value := mediaExample(media)
+	if text, ok := value.(string); ok && !strings.Contains(contentType, "json") {
+		example.Body = []byte(text)
+		return example, nil
+	}
+	body, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("example of %s %s: %w", o.Route.Method, o.Route.Path, err)
+	}
+	example.Body = body
+	return example, nil
+}
+
+// successResponse lowest declared 2xx response, the default response when there is none
+func successResponse(responses *openapi3.Responses) (int, *openapi3.Response) {
+	if responses == nil {
+		return http.StatusOK, nil
+	}
+	best := 0
+	for code := range responses.Map() {
+		if status, err := strconv.Atoi(code); err == nil && status >= 200 && status < 300 && (best == 0 || status < best) {
+			best = status
+		}
+	}
+	if best != 0 {
+		return best, responses.Status(best).Value
+	}
+	if ref := responses.Default(); ref != nil {
+		return http.StatusOK, ref.Value
+	}
+	return http.StatusOK, nil
+}
+
+// mediaExample declared example of the content, the first named example or one generated from the schema
+func mediaExample(media *openapi3.MediaType) interface{} {
+	if media.Example != nil {
+		return media.Example
+	}
+	if len(media.Examples) > 0 {
+		names := make([]string, 0, len(media.Examples))
+		for name := range media.Examples {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if ref := media.Examples[names[0]]; ref != nil && ref.Value != nil {
+			return ref.Value.Value
+		}
+	}
+	if media.Schema == nil {
+		return nil
+	}
+	return SchemaExample(media.Schema.Value, 0)
+}
+
+// SchemaExample example value of a schema: its example, default or first enum value, built from the
+// properties and items otherwise
+func SchemaExample(schema *openapi3.Schema, depth int) interface{} {
+	if schema == nil || depth > maxExampleDepth {
+		return nil
+	}
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, ref := range schema.AllOf {
+			if object, ok := SchemaExample(ref.Value, depth+1).(map[string]interface{}); ok {
+				for name, value := range object {
+					merged[name] = value
+				}
+			}
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return SchemaExample(schema.OneOf[0].Value, depth+1)
+	case len(schema.AnyOf) > 0:
+		return SchemaExample(schema.AnyOf[0].Value, depth+1)
+	}
+
+	switch {
+	case schema.Type.Is(openapi3.TypeArray):
+		if schema.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{SchemaExample(schema.Items.Value, depth+1)}
+	case schema.Type.Is(openapi3.TypeString):
+		return stringExample(schema.Format)
+	case schema.Type.Is(openapi3.TypeInteger):
+		if schema.Min != nil {
+			return int64(*schema.Min)
+		}
+		return 0
+	case schema.Type.Is(openapi3.TypeNumber):
+		if schema.Min != nil {
+			return *schema.Min
+		}
+		return 0.0
+	case schema.Type.Is(openapi3.TypeBoolean):
+		return false
+	case schema.Type.Is(openapi3.TypeObject) || len(schema.Properties) > 0:
+		object := make(map[string]interface{}, len(schema.Properties))
+		for name, ref := range schema.Properties {
+			object[name] = SchemaExample(ref.Value, depth+1)
+		}
+		return object
+	}
+	return nil
+}
+
+// stringExample example of a string format
+func stringExample(format string) string {
+	switch format {
+	case "date-time":
+		return "2024-01-01T00:00:00Z"
+	case "date":
+		return "2024-01-01"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	}
+	return "string"
+}
+
+// matchSegments path parameters when the request path segments match the templated ones
+func matchSegments(templated, segments []string) (map[string]string, bool) {
+	if len(templated) != len(segments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range templated {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(segment, "{}")] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
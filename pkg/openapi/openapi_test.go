@@ -0,0 +1,169 @@
+package openapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSpec = `openapi: 3.0.3
+info: {title: kbcenter, version: "1.0"}
+servers:
+  - url: http://kb-center/api/v1
+paths:
+  /codebases/{id}/stats:
+    get:
+      parameters:
+        - {name: id, in: path, required: true, schema: {type: string}}
+        - {name: depth, in: query, schema: {type: integer, minimum: 1}}
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: object
+                required: [files]
+                properties:
+                  files: {type: integer, minimum: 1}
+                  language: {type: string, enum: [go, python]}
+                  updated_at: {type: string, format: date-time}
+                  tags: {type: array, items: {type: string}}
+  /codebases/summary:
+    get:
+      responses:
+        "201":
+          description: ok
+          content:
+            application/json:
+              example: {total: 3}
+  /search:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              type: object
+              required: [query]
+              properties:
+                query: {type: string}
+      responses:
+        default: {description: ok}
+`
+
+func loadTestSpec(t *testing.T) *Spec {
+	file := filepath.Join(t.TempDir(), "openapi.yaml")
+	if err := os.WriteFile(file, []byte(testSpec), 0644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	spec, err := Load(context.Background(), file, "")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	return spec
+}
+
+func TestSpec_Find(t *testing.T) {
+	spec := loadTestSpec(t)
+
+	tests := []struct {
+		method string
+		target string
+		want   string
+		id     string
+	}{
+		{"GET", "/api/v1/codebases/summary", "/codebases/summary", ""},
+		{"GET", "/api/v1/codebases/cb1/stats", "/codebases/{id}/stats", "cb1"},
+		{"POST", "/api/v1/codebases/cb1/stats", "", ""},
+		{"GET", "/api/v1/files/content", "", ""},
+		{"GET", "/codebases/summary", "", ""},
+	}
+	for _, tt := range tests {
+		operation, err := spec.Find(httptest.NewRequest(tt.method, tt.target, nil))
+		if tt.want == "" {
+			if !errors.Is(err, ErrNoOperation) {
+				t.Errorf("Find(%s %s): expected no operation, got %v", tt.method, tt.target, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Find(%s %s) failed: %v", tt.method, tt.target, err)
+		}
+		if operation.Route.Path != tt.want || operation.PathParams["id"] != tt.id {
+			t.Errorf("Find(%s %s) = %s %v", tt.method, tt.target, operation.Route.Path, operation.PathParams)
+		}
+	}
+}
+
+func TestOperation_Validate(t *testing.T) {
+	spec := loadTestSpec(t)
+	ctx := context.Background()
+
+	validate := func(method, target, body string) error {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if body != "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		operation, err := spec.Find(req)
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		return operation.ValidateRequest(ctx, req)
+	}
+	if err := validate("GET", "/api/v1/codebases/cb1/stats?depth=2", ""); err != nil {
+		t.Errorf("Expected valid request, got %v", err)
+	}
+	if err := validate("GET", "/api/v1/codebases/cb1/stats?depth=0", ""); err == nil {
+		t.Error("Expected depth below the minimum to be rejected")
+	}
+	if err := validate("POST", "/api/v1/search", `{"limit":1}`); err == nil {
+		t.Error("Expected a body without the required query to be rejected")
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/codebases/cb1/stats", nil)
+	operation, _ := spec.Find(req)
+	header := http.Header{"Content-Type": []string{"application/json"}}
+	if err := operation.ValidateResponse(ctx, req, 200, header, []byte(`{"files":2,"language":"go"}`)); err != nil {
+		t.Errorf("Expected valid response, got %v", err)
+	}
+	if err := operation.ValidateResponse(ctx, req, 200, header, []byte(`{"language":"rust"}`)); err == nil {
+		t.Error("Expected a response without files and with an unknown language to be rejected")
+	}
+	if err := operation.ValidateResponse(ctx, req, 404, header, []byte(`{}`)); err == nil {
+		t.Error("Expected an undeclared status to be rejected")
+	}
+}
+
+func TestOperation_Example(t *testing.T) {
+	spec := loadTestSpec(t)
+
+	tests := []struct {
+		method string
+		target string
+		status int
+		body   string
+	}{
+		{"GET", "/api/v1/codebases/summary", 201, `{"total":3}`},
+		{"GET", "/api/v1/codebases/cb1/stats", 200, `{"files":1,"language":"go","tags":["string"],"updated_at":"2024-01-01T00:00:00Z"}`},
+		{"POST", "/api/v1/search", 200, ""},
+	}
+	for _, tt := range tests {
+		operation, err := spec.Find(httptest.NewRequest(tt.method, tt.target, nil))
+		if err != nil {
+			t.Fatalf("Find failed: %v", err)
+		}
+		example, err := operation.Example()
+		if err != nil {
+			t.Fatalf("Example failed: %v", err)
+		}
+		if example.Status != tt.status || string(example.Body) != tt.body {
+			t.Errorf("Example(%s %s) = %d %s, want %d %s", tt.method, tt.target, example.Status, example.Body, tt.status, tt.body)
+		}
+	}
+}
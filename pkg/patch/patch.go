@@ -0,0 +1,443 @@
+package patch
+
+import (
+	"bufio"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// DefaultContextLines number of context lines around each hunk
+const DefaultContextLines = 3
+
+// Line operations of a hunk line
+const (
+	OpContext = ' '
+	OpDelete  = '-'
+	OpInsert  = '+'
+)
+
+// Line single line of a hunk
+type Line struct {
+	Op   byte
+	Text string
+}
+
+// Hunk contiguous change of a file diff
+type Hunk struct {
+	OldStart int // First line of the hunk in the original file, 1-based
+	OldLines int
+	NewStart int // First line of the hunk in the patched file, 1-based
+	NewLines int
+	Lines    []Line
+}
+
+// FileDiff changes of a single file
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+// Path path of the file after the change, or before it when the file was deleted
+func (d *FileDiff) Path() string {
+	if d.NewPath != "" && d.NewPath != "/dev/null" {
+		return d.NewPath
+	}
+	return d.OldPath
+}
+
+// ConflictError hunk does not match the current file content
+type ConflictError struct {
+	Hunk int // Index of the conflicting hunk
+	Line int // Line of the original file the hunk was expected at
+}
+
+func (e *ConflictError) Error() string {
+	return i18n.Translate("patch.conflict", "", map[string]interface{}{
+		"hunk": e.Hunk + 1,
+		"line": e.Line,
+	})
+}
+
+// Region changed region of a patched file
+type Region struct {
+	OldStart int      // First line of the region in the original file
+	OldLines []string // Original lines of the region
+	NewStart int      // First line of the region in the patched file
+	NewLines []string // Patched lines of the region
+}
+
+// LineFormat line ending details of file content, kept when joining patched lines
+type LineFormat struct {
+	CRLF            bool // Lines end with \r\n
+	TrailingNewline bool // Content ends with a line ending
+}
+
+// SplitLines split file content into lines without line endings
+func SplitLines(content string) ([]string, LineFormat) {
+	var format LineFormat
+	if content == "" {
+		return nil, format
+	}
+	format.CRLF = strings.Contains(content, "\r\n")
+	format.TrailingNewline = strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines, format
+}
+
+// JoinLines join lines into file content of the given format
+func JoinLines(lines []string, format LineFormat) string {
+	eol := "\n"
+	if format.CRLF {
+		eol = "\r\n"
+	}
+	content := strings.Join(lines, eol)
+	if format.TrailingNewline && len(lines) > 0 {
+		content += eol
+	}
+	return content
+}
+
+// Unified render whole-line edits of the original lines as a unified diff of path
+func Unified(path string, original []string, edits []types.TextEdit, contextLines int) (string, error) {
+	if len(edits) == 0 {
+		return "", nil
+	}
+
+	sorted := append([]types.TextEdit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].StartLine < sorted[j].StartLine })
+
+	// Validate edits: inside the file and not overlapping
+	prevEnd := 0
+	for _, edit := range sorted {
+		if edit.StartLine < 1 || edit.StartLine > len(original)+1 || edit.EndLine < edit.StartLine-1 || edit.EndLine > len(original) || edit.StartLine <= prevEnd {
+			return "", fmt.Errorf("%s", i18n.Translate("patch.invalid_edit", "", map[string]interface{}{
+				"start": edit.StartLine,
+				"end":   edit.EndLine,
+			}))
+		}
+		prevEnd = edit.EndLine
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- a/%s\n+++ b/%s\n", path, path)
+
+	delta := 0
+	for i := 0; i < len(sorted); {
+		// Merge edits whose context overlaps into one hunk
+		j := i + 1
+		for j < len(sorted) && sorted[j].StartLine-sorted[j-1].EndLine-1 <= 2*contextLines {
+			j++
+		}
+		group := sorted[i:j]
+
+		oldStart := max(group[0].StartLine-contextLines, 1)
+		oldEnd := min(group[len(group)-1].EndLine+contextLines, len(original))
+
+		var lines []Line
+		cursor := oldStart
+		for _, edit := range group {
+			for ; cursor < edit.StartLine; cursor++ {
+				lines = append(lines, Line{Op: OpContext, Text: original[cursor-1]})
+			}
+			for ; cursor <= edit.EndLine; cursor++ {
+				lines = append(lines, Line{Op: OpDelete, Text: original[cursor-1]})
+			}
+			for _, text := range edit.NewLines {
+				lines = append(lines, Line{Op: OpInsert, Text: text})
+			}
+		}
+		for ; cursor <= oldEnd; cursor++ {
+			lines = append(lines, Line{Op: OpContext, Text: original[cursor-1]})
+		}
+
+		hunk := Hunk{OldStart: oldStart, NewStart: oldStart + delta, Lines: lines}
+		for _, line := range lines {
+			if line.Op != OpInsert {
+				hunk.OldLines++
+			}
+			if line.Op != OpDelete {
+				hunk.NewLines++
+			}
+		}
+		delta += hunk.NewLines - hunk.OldLines
+		writeHunk(&b, hunk)
+
+		i = j
+	}
+
+	return b.String(), nil
+}
+
+// writeHunk write hunk header and lines
+func writeHunk(b *strings.Builder, hunk Hunk) {
+	fmt.Fprintf(b, "@@ -%s +%s @@\n", hunkRange(hunk.OldStart, hunk.OldLines), hunkRange(hunk.NewStart, hunk.NewLines))
+	for _, line := range hunk.Lines {
+		b.WriteByte(line.Op)
+		b.WriteString(line.Text)
+		b.WriteByte('\n')
+	}
+}
+
+// hunkRange format a hunk range, an empty range starts at the line before it
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start-1)
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+// Parse parse a unified diff, which may contain several files
+func Parse(diff string) ([]*FileDiff, error) {
+	var files []*FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+
+	scanner := bufio.NewScanner(strings.NewReader(diff))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		text := scanner.Text()
+
+		// Lines of the current hunk, counted against the header so that "--- " content lines are not taken as headers
+		if hunk != nil && (hunk.remainingOld() > 0 || hunk.remainingNew() > 0) {
+			if text == "" {
+				text = " "
+			}
+			switch text[0] {
+			case OpContext, OpDelete, OpInsert:
+				hunk.Lines = append(hunk.Lines, Line{Op: text[0], Text: strings.TrimSuffix(text[1:], "\r")})
+				continue
+			case '\\':
+				continue
+			}
+			return nil, parseError(lineNo)
+		}
+
+		switch {
+		case strings.HasPrefix(text, "--- "):
+			current = &FileDiff{OldPath: parsePath(text[4:])}
+			files = append(files, current)
+			hunk = nil
+		case strings.HasPrefix(text, "+++ "):
+			if current == nil {
+				return nil, parseError(lineNo)
+			}
+			current.NewPath = parsePath(text[4:])
+		case strings.HasPrefix(text, "@@ "):
+			if current == nil {
+				return nil, parseError(lineNo)
+			}
+			parsed, err := parseHunkHeader(text)
+			if err != nil {
+				return nil, parseError(lineNo)
+			}
+			current.Hunks = append(current.Hunks, parsed)
+			hunk = &current.Hunks[len(current.Hunks)-1]
+		default:
+			// Preamble such as "diff --git" and "index" lines, or "\ No newline at end of file"
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if hunk != nil && (hunk.remainingOld() > 0 || hunk.remainingNew() > 0) {
+		return nil, parseError(lineNo)
+	}
+	return files, nil
+}
+
+// remainingOld number of original lines still expected by the hunk header
+func (h *Hunk) remainingOld() int {
+	count := 0
+	for _, line := range h.Lines {
+		if line.Op != OpInsert {
+			count++
+		}
+	}
+	return h.OldLines - count
+}
+
+// remainingNew number of patched lines still expected by the hunk header
+func (h *Hunk) remainingNew() int {
+	count := 0
+	for _, line := range h.Lines {
+		if line.Op != OpDelete {
+			count++
+		}
+	}
+	return h.NewLines - count
+}
+
+// parsePath strip the a/ b/ prefixes and timestamps of a diff header path
+func parsePath(path string) string {
+	if i := strings.IndexByte(path, '\t'); i >= 0 {
+		path = path[:i]
+	}
+	path = strings.TrimSpace(path)
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+// parseHunkHeader parse "@@ -l,s +l,s @@"
+func parseHunkHeader(text string) (Hunk, error) {
+	var hunk Hunk
+	fields := strings.Fields(text)
+	if len(fields) < 4 || fields[0] != "@@" || fields[3] != "@@" {
+		return hunk, fmt.Errorf("invalid hunk header")
+	}
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseRange(fields[1], '-'); err != nil {
+		return hunk, err
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseRange(fields[2], '+'); err != nil {
+		return hunk, err
+	}
+	// Empty ranges point at the line before the change
+	if hunk.OldLines == 0 {
+		hunk.OldStart++
+	}
+	if hunk.NewLines == 0 {
+		hunk.NewStart++
+	}
+	return hunk, nil
+}
+
+// parseRange parse "-l,s" or "+l"
+func parseRange(field string, prefix byte) (int, int, error) {
+	if len(field) < 2 || field[0] != prefix {
+		return 0, 0, fmt.Errorf("invalid range")
+	}
+	start, count, found := strings.Cut(field[1:], ",")
+	startLine, err := strconv.Atoi(start)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !found {
+		return startLine, 1, nil
+	}
+	lineCount, err := strconv.Atoi(count)
+	return startLine, lineCount, err
+}
+
+// parseError diff parse error at a line
+func parseError(lineNo int) error {
+	return fmt.Errorf("%s", i18n.Translate("patch.parse_failed", "", map[string]interface{}{"line": lineNo}))
+}
+
+// Apply apply the hunks of a file diff to the lines, returning the patched lines and the changed regions.
+// Each hunk must match the current lines exactly, a hunk moved by unrelated edits is located by searching
+// for its original lines nearest to the expected position. Otherwise a ConflictError is returned.
+func Apply(lines []string, diff *FileDiff) ([]string, []Region, error) {
+	result := make([]string, 0, len(lines))
+	regions := make([]Region, 0, len(diff.Hunks))
+	cursor := 0 // Next unconsumed index of lines
+	offset := 0 // Shift between expected and actual hunk positions
+
+	for i, hunk := range diff.Hunks {
+		var oldLines, newLines []string
+		for _, line := range hunk.Lines {
+			if line.Op != OpInsert {
+				oldLines = append(oldLines, line.Text)
+			}
+			if line.Op != OpDelete {
+				newLines = append(newLines, line.Text)
+			}
+		}
+
+		expected := hunk.OldStart - 1 + offset
+		pos := locate(lines, oldLines, expected, cursor)
+		if pos < 0 {
+			return nil, nil, &ConflictError{Hunk: i, Line: hunk.OldStart}
+		}
+		offset = pos - (hunk.OldStart - 1)
+
+		result = append(result, lines[cursor:pos]...)
+		regions = append(regions, Region{
+			OldStart: pos + 1,
+			OldLines: oldLines,
+			NewStart: len(result) + 1,
+			NewLines: newLines,
+		})
+		result = append(result, newLines...)
+		cursor = pos + len(oldLines)
+	}
+
+	result = append(result, lines[cursor:]...)
+	return result, regions, nil
+}
+
+// locate find the index where want matches lines, nearest to expected and not before minIndex
+func locate(lines, want []string, expected, minIndex int) int {
+	matches := func(pos int) bool {
+		if pos < minIndex || pos+len(want) > len(lines) {
+			return false
+		}
+		for k, text := range want {
+			if lines[pos+k] != text {
+				return false
+			}
+		}
+		return true
+	}
+
+	if matches(expected) {
+		return expected
+	}
+	// An empty hunk cannot be located by content
+	if len(want) == 0 {
+		return -1
+	}
+	for distance := 1; distance <= len(lines); distance++ {
+		if matches(expected - distance) {
+			return expected - distance
+		}
+		if matches(expected + distance) {
+			return expected + distance
+		}
+	}
+	return -1
+}
+
+// ChangedLines lines of the patched file that were added, or that follow a deletion, in ascending order
+func (d *FileDiff) ChangedLines() []int {
+	seen := make(map[int]bool)
+	var lines []int
+	mark := func(line int) {
+		if !seen[line] {
+			seen[line] = true
+			lines = append(lines, line)
+		}
+	}
+
+	for _, hunk := range d.Hunks {
+		newLine := hunk.NewStart
+		for _, line := range hunk.Lines {
+			switch line.Op {
+			case OpInsert:
+				mark(newLine)
+				newLine++
+			case OpDelete:
+				mark(newLine)
+			default:
+				newLine++
+			}
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
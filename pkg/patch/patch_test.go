@@ -0,0 +1,120 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func testLines() []string {
+	return []string{"package main", "", "func main() {", "\tx := 1", "\tfmt.Println(x)", "\treturn", "}"}
+}
+
+func TestUnified_ParseApplyRoundTrip(t *testing.T) {
+	lines := testLines()
+	diff, err := Unified("main.go", lines, []types.TextEdit{{StartLine: 5, EndLine: 5}}, DefaultContextLines)
+	if err != nil {
+		t.Fatalf("Unified failed: %v", err)
+	}
+	if !strings.Contains(diff, "@@ -2,6 +2,5 @@\n") || !strings.Contains(diff, "-\tfmt.Println(x)\n") {
+		t.Fatalf("Unexpected diff:\n%s", diff)
+	}
+
+	files, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Path() != "main.go" || len(files[0].Hunks) != 1 {
+		t.Fatalf("Unexpected parse result: %+v", files)
+	}
+
+	patched, regions, err := Apply(lines, files[0])
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(patched) != len(lines)-1 || patched[4] != "\treturn" {
+		t.Errorf("Unexpected patched lines: %q", patched)
+	}
+	if len(regions) != 1 || regions[0].OldStart != 2 || len(regions[0].NewLines) != 5 {
+		t.Errorf("Unexpected regions: %+v", regions)
+	}
+}
+
+func TestApply_ShiftedAndConflict(t *testing.T) {
+	lines := testLines()
+	diff, err := Unified("main.go", lines, []types.TextEdit{{StartLine: 4, EndLine: 4, NewLines: []string{"\tx := 2"}}}, 1)
+	if err != nil {
+		t.Fatalf("Unified failed: %v", err)
+	}
+	files, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Lines inserted above the hunk move it without conflicting
+	shifted := append([]string{"// header", "// header"}, lines...)
+	patched, _, err := Apply(shifted, files[0])
+	if err != nil {
+		t.Fatalf("Apply on shifted content failed: %v", err)
+	}
+	if patched[5] != "\tx := 2" {
+		t.Errorf("Unexpected patched lines: %q", patched)
+	}
+
+	// Changing the lines the hunk covers conflicts
+	changed := testLines()
+	changed[3] = "\tx := 3"
+	var conflict *ConflictError
+	if _, _, err := Apply(changed, files[0]); !errors.As(err, &conflict) {
+		t.Errorf("Expected ConflictError, got %v", err)
+	}
+}
+
+func TestUnified_InvalidEdit(t *testing.T) {
+	if _, err := Unified("main.go", testLines(), []types.TextEdit{{StartLine: 3, EndLine: 4}, {StartLine: 4, EndLine: 4}}, 3); err == nil {
+		t.Error("Expected error for overlapping edits")
+	}
+}
+
+func TestSplitJoinLines_KeepsFormat(t *testing.T) {
+	content := "a\r\nb\r\n"
+	lines, format := SplitLines(content)
+	if len(lines) != 2 || lines[1] != "b" {
+		t.Fatalf("Unexpected lines: %q", lines)
+	}
+	if got := JoinLines(lines, format); got != content {
+		t.Errorf("Expected %q, got %q", content, got)
+	}
+}
+
+func TestChangedLines(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -3,0 +4,2 @@ func main() {
++	a := 1
++	b := 2
+@@ -9,2 +10,0 @@
+-	c := 3
+-	d := 4
+@@ -20 +19 @@
+-	e := 5
++	e := 6
+`
+	files, err := Parse(diff)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	got := files[0].ChangedLines()
+	want := []int{4, 5, 11, 19}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Template prompt of a model request, System and User are Go text/template sources executed with a Context
+type Template struct {
+	System               string
+	User                 string
+	LanguageInstructions map[string]string // Extra instructions by language, exposed as .LanguageInstructions
+	OutputSchema         string            // Description of the expected reply, exposed as .OutputSchema
+}
+
+// Symbol named code element of the file around the reviewed code
+type Symbol struct {
+	Name      string
+	StartLine int
+	EndLine   int
+}
+
+// Context variables of a prompt
+type Context struct {
+	FilePath  string
+	Language  string
+	Code      string // Reviewed code with line numbers
+	StartLine int
+	EndLine   int
+	Function  string   // Name of the reviewed function, empty for whole files
+	Symbols   []Symbol // Other symbols of the file
+
+	// Filled from the template when rendering
+	LanguageInstructions string
+	OutputSchema         string
+}
+
+// Rendered prompt ready to be sent
+type Rendered struct {
+	System string `json:"system"`
+	User   string `json:"user"`
+}
+
+// Render execute the templates with the context
+func (t *Template) Render(ctx Context) (*Rendered, error) {
+	system, user, err := t.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.LanguageInstructions = t.LanguageInstructions[strings.ToLower(ctx.Language)]
+	ctx.OutputSchema = t.OutputSchema
+	var rendered Rendered
+	if rendered.System, err = execute(system, ctx); err != nil {
+		return nil, err
+	}
+	if rendered.User, err = execute(user, ctx); err != nil {
+		return nil, err
+	}
+	return &rendered, nil
+}
+
+// Validate check that the templates parse and execute with a sample context
+func (t *Template) Validate() error {
+	_, err := t.Render(Context{
+		FilePath:  "main.go",
+		Language:  "go",
+		Code:      "1 | package main",
+		StartLine: 1,
+		EndLine:   1,
+		Function:  "main",
+		Symbols:   []Symbol{{Name: "main", StartLine: 1, EndLine: 1}},
+	})
+	return err
+}
+
+// parse parse the system and user templates
+func (t *Template) parse() (*template.Template, *template.Template, error) {
+	system, err := template.New("system").Option("missingkey=error").Parse(t.System)
+	if err != nil {
+		return nil, nil, err
+	}
+	user, err := template.New("user").Option("missingkey=error").Parse(t.User)
+	if err != nil {
+		return nil, nil, err
+	}
+	return system, user, nil
+}
+
+// execute execute a template into a string
+func execute(tmpl *template.Template, ctx Context) (string, error) {
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, ctx); err != nil {
+		return "", fmt.Errorf("%s: %w", tmpl.Name(), err)
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,53 @@
+package prompt
+
+import (
+	"testing"
+)
+
+func TestTemplate_Render(t *testing.T) {
+	tmpl := &Template{
+		System:               "Review {{.Language}} code.{{with .LanguageInstructions}} {{.}}{{end}}\n{{.OutputSchema}}",
+		User:                 "{{.FilePath}} {{.Function}} {{.StartLine}}-{{.EndLine}}\n{{range .Symbols}}{{.Name}};{{end}}\n{{.Code}}",
+		LanguageInstructions: map[string]string{"go": "Check error handling."},
+		OutputSchema:         "Reply with JSON.",
+	}
+
+	rendered, err := tmpl.Render(Context{
+		FilePath:  "main.go",
+		Language:  "Go",
+		Code:      "3 | func run() {}",
+		StartLine: 3,
+		EndLine:   3,
+		Function:  "run",
+		Symbols:   []Symbol{{Name: "main"}, {Name: "helper"}},
+	})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.System != "Review Go code. Check error handling.\nReply with JSON." {
+		t.Errorf("Unexpected system prompt: %q", rendered.System)
+	}
+	if rendered.User != "main.go run 3-3\nmain;helper;\n3 | func run() {}" {
+		t.Errorf("Unexpected user prompt: %q", rendered.User)
+	}
+
+	rendered, err = tmpl.Render(Context{Language: "python"})
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if rendered.System != "Review python code.\nReply with JSON." {
+		t.Errorf("Expected no instructions for other languages: %q", rendered.System)
+	}
+}
+
+func TestTemplate_Validate(t *testing.T) {
+	if err := (&Template{System: "{{.Language}}", User: "{{.Code}}"}).Validate(); err != nil {
+		t.Errorf("Expected valid template: %v", err)
+	}
+	if err := (&Template{System: "{{.Language", User: ""}).Validate(); err == nil {
+		t.Error("Expected parse error")
+	}
+	if err := (&Template{System: "", User: "{{.Unknown}}"}).Validate(); err == nil {
+		t.Error("Expected error for unknown variable")
+	}
+}
@@ -0,0 +1,112 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zgsm/mock-kbcenter/pkg/redis"
+)
+
+// subscriptionBuffer messages buffered per subscription, a subscriber falling further behind misses messages
+const subscriptionBuffer = 64
+
+// Subscription messages published to a channel after subscribing
+type Subscription struct {
+	messages chan []byte
+	close    func() error
+	once     sync.Once
+}
+
+// Messages channel of the received messages, closed when the subscription is closed
+func (s *Subscription) Messages() <-chan []byte {
+	return s.messages
+}
+
+// Close stop receiving messages
+func (s *Subscription) Close() error {
+	var err error
+	s.once.Do(func() {
+		err = s.close()
+	})
+	return err
+}
+
+// Publish send a message to the subscribers of the channel in every process through Redis,
+// only to the subscribers of the current process when Redis is disabled
+func Publish(ctx context.Context, channel string, message []byte) error {
+	if client, err := redis.GetClient(); err == nil {
+		return client.Publish(ctx, channel, message).Err()
+	}
+	local.publish(channel, message)
+	return nil
+}
+
+// Subscribe receive the messages published to the channel, through Redis unless it is disabled
+func Subscribe(ctx context.Context, channel string) (*Subscription, error) {
+	client, err := redis.GetClient()
+	if err != nil {
+		return local.subscribe(channel), nil
+	}
+
+	pubsub := client.Subscribe(ctx, channel)
+	// Wait for the confirmation, so that messages published after Subscribe returns are received
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &Subscription{messages: make(chan []byte, subscriptionBuffer), close: pubsub.Close}
+	go func() {
+		defer close(sub.messages)
+		for msg := range pubsub.Channel() {
+			select {
+			case sub.messages <- []byte(msg.Payload):
+			default:
+			}
+		}
+	}()
+	return sub, nil
+}
+
+// localBroker in-process broker used when Redis is disabled
+type localBroker struct {
+	mu   sync.RWMutex
+	subs map[string]map[*Subscription]struct{}
+}
+
+var local = &localBroker{subs: make(map[string]map[*Subscription]struct{})}
+
+func (b *localBroker) publish(channel string, message []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs[channel] {
+		select {
+		case sub.messages <- message:
+		default:
+		}
+	}
+}
+
+func (b *localBroker) subscribe(channel string) *Subscription {
+	sub := &Subscription{messages: make(chan []byte, subscriptionBuffer)}
+	sub.close = func() error {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subs[channel], sub)
+		if len(b.subs[channel]) == 0 {
+			delete(b.subs, channel)
+		}
+		close(sub.messages)
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[channel] == nil {
+		b.subs[channel] = make(map[*Subscription]struct{})
+	}
+	b.subs[channel][sub] = struct{}{}
+	return sub
+}
@@ -0,0 +1,49 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLocalPublishSubscribe(t *testing.T) {
+	ctx := context.Background()
+	sub, err := Subscribe(ctx, "test:channel")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	other, err := Subscribe(ctx, "test:other")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer other.Close()
+
+	if err := Publish(ctx, "test:channel", []byte("hello")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if msg := <-sub.Messages(); string(msg) != "hello" {
+		t.Errorf("Unexpected message: %q", msg)
+	}
+	select {
+	case msg := <-other.Messages():
+		t.Errorf("Message delivered to another channel: %q", msg)
+	default:
+	}
+
+	// Publishing never blocks on a subscriber that does not read
+	for i := 0; i < subscriptionBuffer*2; i++ {
+		if err := Publish(ctx, "test:channel", []byte("x")); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	if len(sub.Messages()) != subscriptionBuffer {
+		t.Errorf("Unexpected buffered messages: %d", len(sub.Messages()))
+	}
+
+	sub.Close()
+	sub.Close()
+	for range sub.Messages() {
+	}
+	if err := Publish(ctx, "test:channel", []byte("after close")); err != nil {
+		t.Fatalf("Publish after close failed: %v", err)
+	}
+}
@@ -0,0 +1,207 @@
+package recording
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+// Modes of the mock endpoints
+const (
+	ModeRecord = "record"
+	ModeReplay = "replay"
+)
+
+// ErrInvalidMode recording mode other than record or replay
+var ErrInvalidMode = errors.New("invalid recording mode")
+
+// EncodingBase64 body encoding of bodies that are not valid UTF-8
+const EncodingBase64 = "base64"
+
+// Request request of a fixture
+type Request struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Query    string `json:"query,omitempty"`     // Raw query string
+	BodyHash string `json:"body_hash,omitempty"` // SHA-256 of the request body, empty without a body
+}
+
+// Response upstream response of a fixture
+type Response struct {
+	Status   int               `json:"status"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body"`
+	Encoding string            `json:"encoding,omitempty"` // base64 when the body is not valid UTF-8
+}
+
+// Fixture request and response pair captured from the real KB center
+type Fixture struct {
+	Request    Request   `json:"request"`
+	Response   Response  `json:"response"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// NewRequest fixture request of a method, URL and request body
+func NewRequest(method string, u *url.URL, body []byte) Request {
+	req := Request{Method: strings.ToUpper(method), Path: u.Path, Query: u.RawQuery}
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		req.BodyHash = hex.EncodeToString(sum[:])
+	}
+	return req
+}
+
+// SetBody set the response body, base64 encoded when it is not valid UTF-8
+func (r *Response) SetBody(body []byte) {
+	if utf8.Valid(body) {
+		r.Body, r.Encoding = string(body), ""
+		return
+	}
+	r.Body, r.Encoding = base64.StdEncoding.EncodeToString(body), EncodingBase64
+}
+
+// BodyBytes decoded response body
+func (r *Response) BodyBytes() ([]byte, error) {
+	if r.Encoding == EncodingBase64 {
+		return base64.StdEncoding.DecodeString(r.Body)
+	}
+	return []byte(r.Body), nil
+}
+
+// Store fixtures of a directory, matched by the configured rules, safe for concurrent use
+type Store struct {
+	mu       sync.RWMutex
+	dir      string
+	match    config.RecordingMatch
+	fixtures map[string]*Fixture // Latest fixture by match key
+}
+
+// NewStore create a store of the fixtures of a directory
+func NewStore(dir string, match config.RecordingMatch) *Store {
+	return &Store{dir: dir, match: match, fixtures: make(map[string]*Fixture)}
+}
+
+// Load read the fixtures of the directory, a missing directory has none
+func (s *Store) Load() (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, entry.Name()))
+		if err != nil {
+			return 0, err
+		}
+		var fixture Fixture
+		if err := json.Unmarshal(data, &fixture); err != nil {
+			return 0, fmt.Errorf("fixture %s: %w", entry.Name(), err)
+		}
+		s.add(&fixture)
+	}
+	return len(s.fixtures), nil
+}
+
+// Save write the fixture to the directory, replacing an earlier recording of the same request
+func (s *Store) Save(fixture *Fixture) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return err
+	}
+	// Keep queries and bodies readable, without escaping &, < and >
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(fixture); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, FileName(fixture.Request)), data.Bytes(), 0644); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.add(fixture)
+	return nil
+}
+
+// Lookup latest fixture matching the request, nil when none matches
+func (s *Store) Lookup(req Request) *Fixture {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.fixtures[s.key(req)]
+}
+
+// add index the fixture, keeping the latest recording of a key
+func (s *Store) add(fixture *Fixture) {
+	key := s.key(fixture.Request)
+	if existing, ok := s.fixtures[key]; ok && existing.RecordedAt.After(fixture.RecordedAt) {
+		return
+	}
+	s.fixtures[key] = fixture
+}
+
+// key match key of a request: method and path, the query and the body hash when the rules match them
+func (s *Store) key(req Request) string {
+	parts := []string{strings.ToUpper(req.Method), req.Path}
+	if s.match.Query {
+		parts = append(parts, normalizeQuery(req.Query, s.match.IgnoreQuery))
+	}
+	if s.match.BodyHash {
+		parts = append(parts, req.BodyHash)
+	}
+	return strings.Join(parts, " ")
+}
+
+// normalizeQuery query string with sorted parameters, leaving out the ignored ones
+func normalizeQuery(rawQuery string, ignore []string) string {
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return rawQuery
+	}
+	for _, name := range ignore {
+		values.Del(name)
+	}
+	for _, v := range values {
+		sort.Strings(v)
+	}
+	// Encode sorts by parameter name
+	return values.Encode()
+}
+
+// FileName fixture file name of a request: the method and path for readability and a hash of the full request
+func FileName(req Request) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{strings.ToUpper(req.Method), req.Path, req.Query, req.BodyHash}, "\n")))
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(req.Path, "/"))
+	return fmt.Sprintf("%s_%s_%s.json", strings.ToLower(req.Method), name, hex.EncodeToString(sum[:])[:12])
+}
@@ -0,0 +1,98 @@
+package recording
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/config"
+)
+
+func newRequest(t *testing.T, method, target string, body []byte) Request {
+	u, err := url.Parse(target)
+	if err != nil {
+		t.Fatalf("Parse %s failed: %v", target, err)
+	}
+	return NewRequest(method, u, body)
+}
+
+func TestStore_SaveAndLookup(t *testing.T) {
+	dir := t.TempDir()
+	match := config.RecordingMatch{Query: true, IgnoreQuery: []string{"requestId"}, BodyHash: true}
+	store := NewStore(dir, match)
+
+	older := &Fixture{
+		Request:    newRequest(t, "get", "/api/v1/files/content?clientId=c1&filePath=a.go&requestId=1", nil),
+		Response:   Response{Status: 200, Body: `{"code":0,"data":"old"}`},
+		RecordedAt: time.Now().Add(-time.Hour),
+	}
+	latest := &Fixture{
+		Request:    newRequest(t, "GET", "/api/v1/files/content?filePath=a.go&clientId=c1&requestId=2", nil),
+		Response:   Response{Status: 200, Body: `{"code":0,"data":"new"}`},
+		RecordedAt: time.Now(),
+	}
+	posted := &Fixture{
+		Request:    newRequest(t, "POST", "/api/v1/files/structure", []byte(`{"a":1}`)),
+		Response:   Response{Status: 404, Headers: map[string]string{"Content-Type": "application/json"}},
+		RecordedAt: time.Now(),
+	}
+	posted.Response.SetBody([]byte{0xff, 0xfe})
+	for _, fixture := range []*Fixture{latest, older, posted} {
+		if err := store.Save(fixture); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	// Reload from disk so that matching does not depend on the saved pointers
+	reloaded := NewStore(dir, match)
+	if count, err := reloaded.Load(); err != nil || count != 2 {
+		t.Fatalf("Expected 2 fixtures, got %d, %v", count, err)
+	}
+
+	tests := []struct {
+		method string
+		target string
+		body   []byte
+		want   string
+	}{
+		{"GET", "/api/v1/files/content?requestId=3&clientId=c1&filePath=a.go", nil, `{"code":0,"data":"new"}`},
+		{"GET", "/api/v1/files/content?clientId=c1&filePath=b.go", nil, ""},
+		{"POST", "/api/v1/files/structure", []byte(`{"a":1}`), "\xff\xfe"},
+		{"POST", "/api/v1/files/structure", []byte(`{"a":2}`), ""},
+	}
+	for _, tt := range tests {
+		fixture := reloaded.Lookup(newRequest(t, tt.method, tt.target, tt.body))
+		got := ""
+		if fixture != nil {
+			body, err := fixture.Response.BodyBytes()
+			if err != nil {
+				t.Fatalf("BodyBytes failed: %v", err)
+			}
+			got = string(body)
+		}
+		if got != tt.want {
+			t.Errorf("Lookup(%s %s, %s) = %q, want %q", tt.method, tt.target, tt.body, got, tt.want)
+		}
+	}
+
+	// Without query and body matching any request to the path matches
+	loose := NewStore(dir, config.RecordingMatch{})
+	if _, err := loose.Load(); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	fixture := loose.Lookup(newRequest(t, "POST", "/api/v1/files/structure?x=1", []byte("other")))
+	if fixture == nil || fixture.Response.Status != 404 || fixture.Response.Encoding != EncodingBase64 {
+		t.Errorf("Unexpected fixture: %+v", fixture)
+	}
+	if body, _ := fixture.Response.BodyBytes(); !bytes.Equal(body, []byte{0xff, 0xfe}) {
+		t.Errorf("Unexpected body: %v", body)
+	}
+}
+
+func TestStore_LoadMissingDir(t *testing.T) {
+	store := NewStore(t.TempDir()+"/missing", config.RecordingMatch{})
+	if count, err := store.Load(); err != nil || count != 0 {
+		t.Errorf("Expected no fixtures, got %d, %v", count, err)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/zgsm/mock-kbcenter/config"
 	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/metrics"
 )
 
 var (
@@ -45,6 +46,7 @@ func InitRedis(cfg config.Config) error {
 		return fmt.Errorf(i18n.Translate("redis.connect.failed", "", nil)+": %w", err)
 	}
 
+	metrics.RegisterRedisPool(client)
 	logger.Info(i18n.Translate("redis.connect.success", "", nil), "addr", fmt.Sprintf("%s:%d", cfg.Redis.Host, cfg.Redis.Port))
 	return nil
 }
@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"ruleID": ruleID,
+	"title":  title,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Labels.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+.high { color: #b00020; } .middle { color: #b36b00; } .low { color: #555; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>{{.Labels.Title}}</h1>
+<ul>
+{{- if .Meta.ReviewTaskID}}
+<li>{{.Labels.ReviewTask}}: <code>{{.Meta.ReviewTaskID}}</code></li>
+{{- end}}
+{{- if .Meta.GeneratedAt}}
+<li>{{.Labels.GeneratedAt}}: {{.Meta.GeneratedAt}}</li>
+{{- end}}
+<li>{{.Labels.TotalIssues}}: {{.Total}}</li>
+</ul>
+{{- if not .Groups}}
+<p>{{.Labels.NoIssues}}</p>
+{{- else}}
+<table>
+<tr><th>{{.Labels.Severity}}</th><th>{{.Labels.Count}}</th></tr>
+{{- range .Summary}}
+<tr><td class="{{.Severity}}">{{.Severity}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- range .Groups}}
+<h2>{{.FilePath}} ({{.Count}})</h2>
+{{- range .Severities}}
+<h3 class="{{.Severity}}">{{.Severity}}</h3>
+<table>
+<tr><th>{{$.Labels.Line}}</th><th>{{$.Labels.Rule}}</th><th>{{$.Labels.Message}}</th></tr>
+{{- range .Issues}}
+<tr><td>{{.StartLine}}-{{.EndLine}}</td><td><code>{{ruleID .}}</code></td><td><strong>{{title .}}</strong><br>{{.Message}}{{if .IssueCode}}<pre>{{.IssueCode}}</pre>{{end}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// severityCount number of issues of a severity
+type severityCount struct {
+	Severity string
+	Count    int
+}
+
+// renderHTML render issues as an HTML report grouped by file and severity
+func renderHTML(meta Meta, issues []types.Issue) ([]byte, error) {
+	counts := severityCounts(issues)
+	summary := make([]severityCount, 0, 3)
+	for _, severity := range []string{types.SeverityHigh, types.SeverityMiddle, types.SeverityLow} {
+		summary = append(summary, severityCount{Severity: severity, Count: counts[severity]})
+	}
+
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, map[string]interface{}{
+		"Meta":    meta,
+		"Labels":  newLabels(meta),
+		"Total":   len(issues),
+		"Summary": summary,
+		"Groups":  groupByFile(issues),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,80 @@
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// JUnit XML report, one test suite per file and one failed test case per issue
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string       `xml:"name,attr"`
+	ClassName string       `xml:"classname,attr"`
+	Failure   junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// renderJUnit render issues as JUnit XML
+func renderJUnit(meta Meta, issues []types.Issue) ([]byte, error) {
+	suites := junitTestSuites{
+		Name:     meta.ToolName,
+		Tests:    len(issues),
+		Failures: len(issues),
+		Suites:   []junitTestSuite{},
+	}
+
+	for _, group := range groupByFile(issues) {
+		suite := junitTestSuite{
+			Name:      group.FilePath,
+			Tests:     group.Count,
+			Failures:  group.Count,
+			Timestamp: meta.GeneratedAt,
+		}
+		for _, severity := range group.Severities {
+			for _, issue := range severity.Issues {
+				text := fmt.Sprintf("%s:%d-%d\n%s", issue.FilePath, issue.StartLine, issue.EndLine, issue.Message)
+				if issue.IssueCode != nil && *issue.IssueCode != "" {
+					text += "\n\n" + *issue.IssueCode
+				}
+				suite.Cases = append(suite.Cases, junitTestCase{
+					Name:      fmt.Sprintf("%s:%d %s", issue.FilePath, issue.StartLine, ruleID(issue)),
+					ClassName: ruleID(issue),
+					Failure: junitFailure{
+						Message: title(issue),
+						Type:    issue.Severity,
+						Text:    text,
+					},
+				})
+			}
+		}
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,83 @@
+package report
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// renderMarkdown render issues as a Markdown report grouped by file and severity
+func renderMarkdown(meta Meta, issues []types.Issue) ([]byte, error) {
+	labels := newLabels(meta)
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", labels.Title)
+	if meta.ReviewTaskID != "" {
+		fmt.Fprintf(&b, "- %s: `%s`\n", labels.ReviewTask, meta.ReviewTaskID)
+	}
+	if meta.GeneratedAt != "" {
+		fmt.Fprintf(&b, "- %s: %s\n", labels.GeneratedAt, meta.GeneratedAt)
+	}
+	fmt.Fprintf(&b, "- %s: %d\n\n", labels.TotalIssues, len(issues))
+
+	if len(issues) == 0 {
+		fmt.Fprintf(&b, "%s\n", labels.NoIssues)
+		return []byte(b.String()), nil
+	}
+
+	counts := severityCounts(issues)
+	fmt.Fprintf(&b, "| %s | %s |\n|---|---|\n", labels.Severity, labels.Count)
+	for _, severity := range []string{types.SeverityHigh, types.SeverityMiddle, types.SeverityLow} {
+		fmt.Fprintf(&b, "| %s | %d |\n", severity, counts[severity])
+	}
+
+	for _, group := range groupByFile(issues) {
+		fmt.Fprintf(&b, "\n## %s (%d)\n", group.FilePath, group.Count)
+		for _, severity := range group.Severities {
+			fmt.Fprintf(&b, "\n### %s\n\n", severity.Severity)
+			for _, issue := range severity.Issues {
+				fmt.Fprintf(&b, "- **L%d-%d** `%s` %s: %s\n",
+					issue.StartLine, issue.EndLine, ruleID(issue), title(issue), escapeMarkdownLine(issue.Message))
+			}
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// escapeMarkdownLine keep a message on a single list item line
+func escapeMarkdownLine(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "\n", " ")
+}
+
+// labels translated labels of the human-readable reports
+type labels struct {
+	Title       string
+	ReviewTask  string
+	GeneratedAt string
+	TotalIssues string
+	NoIssues    string
+	Severity    string
+	Count       string
+	Line        string
+	Rule        string
+	Message     string
+}
+
+// newLabels translate the report labels in the report locale
+func newLabels(meta Meta) labels {
+	return labels{
+		Title:       i18n.Translate("report.label.title", meta.Locale, nil),
+		ReviewTask:  i18n.Translate("report.label.review_task", meta.Locale, nil),
+		GeneratedAt: i18n.Translate("report.label.generated_at", meta.Locale, nil),
+		TotalIssues: i18n.Translate("report.label.total_issues", meta.Locale, nil),
+		NoIssues:    i18n.Translate("report.label.no_issues", meta.Locale, nil),
+		Severity:    i18n.Translate("report.label.severity", meta.Locale, nil),
+		Count:       i18n.Translate("report.label.count", meta.Locale, nil),
+		Line:        i18n.Translate("report.label.line", meta.Locale, nil),
+		Rule:        i18n.Translate("report.label.rule", meta.Locale, nil),
+		Message:     i18n.Translate("report.label.message", meta.Locale, nil),
+	}
+}
@@ -0,0 +1,175 @@
+package report
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// Supported report formats
+const (
+	FormatSARIF    = "sarif"
+	FormatJUnit    = "junit"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// defaultRuleID rule ID used for issues without rule ID and issue types
+const defaultRuleID = "kbcenter-review"
+
+// Meta metadata of the exported report
+type Meta struct {
+	ReviewTaskID string
+	ToolName     string
+	ToolVersion  string
+	GeneratedAt  string
+	Locale       string // Locale of the human-readable reports, default locale when empty
+}
+
+// Render convert issues into a report of the given format
+func Render(format string, meta Meta, issues []types.Issue) ([]byte, error) {
+	switch format {
+	case FormatSARIF:
+		return renderSARIF(meta, issues)
+	case FormatJUnit:
+		return renderJUnit(meta, issues)
+	case FormatMarkdown:
+		return renderMarkdown(meta, issues)
+	case FormatHTML:
+		return renderHTML(meta, issues)
+	default:
+		return nil, fmt.Errorf("%s", i18n.Translate("report.unsupported_format", "", map[string]interface{}{"format": format}))
+	}
+}
+
+// ContentType HTTP content type of the given format
+func ContentType(format string) string {
+	switch format {
+	case FormatSARIF:
+		return "application/sarif+json"
+	case FormatJUnit:
+		return "application/xml; charset=utf-8"
+	case FormatMarkdown:
+		return "text/markdown; charset=utf-8"
+	case FormatHTML:
+		return "text/html; charset=utf-8"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// FileExtension file extension of the given format
+func FileExtension(format string) string {
+	switch format {
+	case FormatSARIF:
+		return ".sarif"
+	case FormatJUnit:
+		return ".xml"
+	case FormatMarkdown:
+		return ".md"
+	case FormatHTML:
+		return ".html"
+	default:
+		return ""
+	}
+}
+
+// IsSupported whether the format is supported
+func IsSupported(format string) bool {
+	return FileExtension(format) != ""
+}
+
+// ruleID rule identifier of an issue
+func ruleID(issue types.Issue) string {
+	if issue.RuleID != "" {
+		return issue.RuleID
+	}
+	if len(issue.IssueTypes) > 0 && issue.IssueTypes[0] != "" {
+		return issue.IssueTypes[0]
+	}
+	return defaultRuleID
+}
+
+// title short description of an issue
+func title(issue types.Issue) string {
+	if issue.Title != nil && *issue.Title != "" {
+		return *issue.Title
+	}
+	return ruleID(issue)
+}
+
+// severityRank sort rank of a severity, higher severity first
+func severityRank(severity string) int {
+	switch severity {
+	case types.SeverityHigh:
+		return 0
+	case types.SeverityMiddle:
+		return 1
+	case types.SeverityLow:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// fileGroup issues of a single file grouped by severity
+type fileGroup struct {
+	FilePath   string
+	Severities []severityGroup
+	Count      int
+}
+
+// severityGroup issues of a single severity
+type severityGroup struct {
+	Severity string
+	Issues   []types.Issue
+}
+
+// groupByFile group issues by file path and severity, files sorted by path and issues by line
+func groupByFile(issues []types.Issue) []fileGroup {
+	byFile := make(map[string][]types.Issue)
+	for _, issue := range issues {
+		byFile[issue.FilePath] = append(byFile[issue.FilePath], issue)
+	}
+
+	paths := make([]string, 0, len(byFile))
+	for path := range byFile {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	groups := make([]fileGroup, 0, len(paths))
+	for _, path := range paths {
+		fileIssues := byFile[path]
+		sort.SliceStable(fileIssues, func(i, j int) bool {
+			ri, rj := severityRank(fileIssues[i].Severity), severityRank(fileIssues[j].Severity)
+			if ri != rj {
+				return ri < rj
+			}
+			return fileIssues[i].StartLine < fileIssues[j].StartLine
+		})
+
+		group := fileGroup{FilePath: path, Count: len(fileIssues)}
+		for _, issue := range fileIssues {
+			n := len(group.Severities)
+			if n == 0 || group.Severities[n-1].Severity != issue.Severity {
+				group.Severities = append(group.Severities, severityGroup{Severity: issue.Severity})
+				n++
+			}
+			group.Severities[n-1].Issues = append(group.Severities[n-1].Issues, issue)
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// severityCounts number of issues per severity
+func severityCounts(issues []types.Issue) map[string]int {
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Severity]++
+	}
+	return counts
+}
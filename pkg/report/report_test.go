@@ -0,0 +1,108 @@
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func testIssues() []types.Issue {
+	title := "Leftover TODO"
+	code := "// TODO: remove"
+	return []types.Issue{
+		{IssueID: "1", RuleID: "todo-comment", FilePath: "b.go", StartLine: 7, EndLine: 7, Title: &title, Message: "TODO found", IssueCode: &code, Severity: types.SeverityLow},
+		{IssueID: "2", RuleID: "sql-injection", FilePath: "a.go", StartLine: 3, EndLine: 5, Message: "Query built from input <x>", Severity: types.SeverityHigh},
+		{IssueID: "3", RuleID: "todo-comment", FilePath: "a.go", StartLine: 1, EndLine: 1, Title: &title, Message: "TODO found", Severity: types.SeverityLow},
+	}
+}
+
+func TestRender_SARIF(t *testing.T) {
+	data, err := Render(FormatSARIF, Meta{ToolName: "mock-kbcenter"}, testIssues())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatalf("Invalid SARIF JSON: %v", err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("Unexpected SARIF log: version %q, %d runs", log.Version, len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 {
+		t.Errorf("Expected 2 distinct rules, got %d", len(run.Tool.Driver.Rules))
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(run.Results))
+	}
+	result := run.Results[1]
+	if result.Level != "error" || result.RuleID != "sql-injection" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+	region := result.Locations[0].PhysicalLocation.Region
+	if region.StartLine != 3 || region.EndLine != 5 {
+		t.Errorf("Unexpected region: %+v", region)
+	}
+	if run.Tool.Driver.Rules[result.RuleIndex].ID != result.RuleID {
+		t.Error("Rule index does not point at the result rule")
+	}
+}
+
+func TestRender_JUnit(t *testing.T) {
+	data, err := Render(FormatJUnit, Meta{ToolName: "mock-kbcenter"}, testIssues())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("Invalid JUnit XML: %v", err)
+	}
+	if suites.Failures != 3 || len(suites.Suites) != 2 {
+		t.Fatalf("Expected 3 failures in 2 suites, got %d in %d", suites.Failures, len(suites.Suites))
+	}
+	if suites.Suites[0].Name != "a.go" || suites.Suites[0].Cases[0].Failure.Type != types.SeverityHigh {
+		t.Errorf("Expected a.go first with high severity first, got %+v", suites.Suites[0])
+	}
+}
+
+func TestRender_Markdown(t *testing.T) {
+	data, err := Render(FormatMarkdown, Meta{ReviewTaskID: "task-1"}, testIssues())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	md := string(data)
+	aIndex := strings.Index(md, "## a.go (2)")
+	bIndex := strings.Index(md, "## b.go (1)")
+	if aIndex < 0 || bIndex < 0 || aIndex > bIndex {
+		t.Errorf("Expected files grouped and sorted by path:\n%s", md)
+	}
+	if !strings.Contains(md, "**L3-5** `sql-injection`") {
+		t.Errorf("Missing issue line:\n%s", md)
+	}
+}
+
+func TestRender_HTMLEscapes(t *testing.T) {
+	data, err := Render(FormatHTML, Meta{}, testIssues())
+	if err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if strings.Contains(string(data), "<x>") {
+		t.Error("Expected issue message to be HTML escaped")
+	}
+}
+
+func TestRender_UnsupportedFormat(t *testing.T) {
+	if _, err := Render("pdf", Meta{}, nil); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if IsSupported("pdf") || !IsSupported(FormatSARIF) {
+		t.Error("Unexpected IsSupported result")
+	}
+}
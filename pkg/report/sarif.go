@@ -0,0 +1,139 @@
+package report
+
+import (
+	"encoding/json"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+// SARIF 2.1.0 log, only the subset needed by code-scanning UIs
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version,omitempty"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+// sarifLevel map issue severity to SARIF result level
+func sarifLevel(severity string) string {
+	switch severity {
+	case types.SeverityHigh:
+		return "error"
+	case types.SeverityMiddle:
+		return "warning"
+	default:
+		return "note"
+	}
+}
+
+// renderSARIF render issues as a SARIF 2.1.0 log
+func renderSARIF(meta Meta, issues []types.Issue) ([]byte, error) {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:    meta.ToolName,
+			Version: meta.ToolVersion,
+			Rules:   []sarifRule{},
+		}},
+		Results: make([]sarifResult, 0, len(issues)),
+	}
+
+	ruleIndex := make(map[string]int)
+	for _, issue := range issues {
+		id := ruleID(issue)
+		index, ok := ruleIndex[id]
+		if !ok {
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[id] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:                   id,
+				ShortDescription:     sarifMessage{Text: title(issue)},
+				DefaultConfiguration: sarifConfiguration{Level: sarifLevel(issue.Severity)},
+			})
+		}
+
+		startLine := max(issue.StartLine, 1)
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			RuleIndex: index,
+			Level:     sarifLevel(issue.Severity),
+			Message:   sarifMessage{Text: issue.Message},
+			Locations: []sarifLocation{{
+				PhysicalLocation: sarifPhysicalLocation{
+					ArtifactLocation: sarifArtifactLocation{URI: issue.FilePath},
+					Region:           sarifRegion{StartLine: startLine, EndLine: max(issue.EndLine, startLine)},
+				},
+			}},
+			Properties: map[string]interface{}{
+				"issueId":    issue.IssueID,
+				"severity":   issue.Severity,
+				"confidence": issue.Confidence,
+			},
+		})
+	}
+
+	return json.MarshalIndent(sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+}
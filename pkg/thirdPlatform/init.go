@@ -16,6 +16,7 @@ type Service struct {
 
 type HttpServices struct {
 	IssueManager IssueManagerService
+	LLM          LLMService
 }
 
 var serverManager *HttpServices
@@ -26,9 +27,14 @@ func InitHTTPClient() error {
 	if err != nil {
 		return err
 	}
+	llmService, err := NewLLMService()
+	if err != nil {
+		return err
+	}
 
 	serverManager = &HttpServices{
 		IssueManager: *issueManagerService,
+		LLM:          *llmService,
 		// Add other services
 	}
 
@@ -55,6 +61,7 @@ func GetServiceConfig(serviceName string) (*httpclient.HttpServiceConfig, error)
 
 	// Create HTTP client config
 	clientConfig := &httpclient.HttpServiceConfig{
+		Service:            serviceName,
 		BaseURL:            serviceCfg.BaseURL,
 		Timeout:            time.Duration(serviceCfg.Timeout) * time.Second,
 		MaxRetries:         serviceCfg.MaxRetries,
@@ -0,0 +1,161 @@
+package thirdPlatform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+// FakeIssueManagerServer in-process issue manager implementing the endpoints used by IssueManagerService.
+// It keeps issues in memory and is intended for tests and local runs.
+type FakeIssueManagerServer struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	issues     map[string]types.Issue
+	taskIssues map[string][]string
+	nextID     int
+}
+
+// NewFakeIssueManagerServer start a fake issue manager server, call Close when done
+func NewFakeIssueManagerServer() *FakeIssueManagerServer {
+	f := &FakeIssueManagerServer{
+		issues:     make(map[string]types.Issue),
+		taskIssues: make(map[string][]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /issues/batch", f.handleBatchCreate)
+	mux.HandleFunc("GET /issues", f.handleList)
+	mux.HandleFunc("GET /issues/{id}", f.handleGet)
+	mux.HandleFunc("PUT /issues/{id}/status", f.handleUpdateStatus)
+	f.Server = httptest.NewServer(mux)
+
+	return f
+}
+
+// ServiceConfig HTTP client config pointing at the fake server
+func (f *FakeIssueManagerServer) ServiceConfig() *httpclient.HttpServiceConfig {
+	return &httpclient.HttpServiceConfig{
+		BaseURL:          f.URL,
+		Timeout:          5 * time.Second,
+		AuthType:         "none",
+		ValidStatusCodes: []int{http.StatusOK, http.StatusCreated, http.StatusAccepted, http.StatusNoContent},
+	}
+}
+
+// Issues issues received for a review task, in creation order
+func (f *FakeIssueManagerServer) Issues(reviewTaskID string) []types.Issue {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ids := f.taskIssues[reviewTaskID]
+	list := make([]types.Issue, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, f.issues[id])
+	}
+	return list
+}
+
+func (f *FakeIssueManagerServer) handleBatchCreate(w http.ResponseWriter, r *http.Request) {
+	var req issueBatchCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeResponse(w, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	result := IssueBatchCreateResult{IssueIDs: make([]string, 0, len(req.Issues))}
+	for _, issue := range req.Issues {
+		if issue.IssueID == "" {
+			f.nextID++
+			issue.IssueID = strconv.Itoa(f.nextID)
+		}
+		if _, exists := f.issues[issue.IssueID]; !exists {
+			f.taskIssues[req.ReviewTaskID] = append(f.taskIssues[req.ReviewTaskID], issue.IssueID)
+		}
+		f.issues[issue.IssueID] = issue
+		result.IssueIDs = append(result.IssueIDs, issue.IssueID)
+	}
+	result.Created = len(result.IssueIDs)
+	f.mu.Unlock()
+
+	writeFakeResponse(w, http.StatusCreated, result, "")
+}
+
+func (f *FakeIssueManagerServer) handleList(w http.ResponseWriter, r *http.Request) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	pageSize, _ := strconv.Atoi(r.URL.Query().Get("page_size"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+
+	all := f.Issues(r.URL.Query().Get("review_task_id"))
+	start := min((page-1)*pageSize, len(all))
+	end := min(start+pageSize, len(all))
+
+	writeFakeResponse(w, http.StatusOK, IssueListResult{
+		Total:    int64(len(all)),
+		Page:     page,
+		PageSize: pageSize,
+		List:     all[start:end],
+	}, "")
+}
+
+func (f *FakeIssueManagerServer) handleGet(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	issue, ok := f.issues[r.PathValue("id")]
+	f.mu.Unlock()
+
+	if !ok {
+		writeFakeResponse(w, http.StatusNotFound, nil, "issue not found")
+		return
+	}
+	writeFakeResponse(w, http.StatusOK, issue, "")
+}
+
+func (f *FakeIssueManagerServer) handleUpdateStatus(w http.ResponseWriter, r *http.Request) {
+	var req issueStatusUpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeFakeResponse(w, http.StatusBadRequest, nil, err.Error())
+		return
+	}
+
+	f.mu.Lock()
+	issue, ok := f.issues[r.PathValue("id")]
+	if ok {
+		issue.Status = req.Status
+		f.issues[issue.IssueID] = issue
+	}
+	f.mu.Unlock()
+
+	if !ok {
+		writeFakeResponse(w, http.StatusNotFound, nil, "issue not found")
+		return
+	}
+	writeFakeResponse(w, http.StatusOK, struct{}{}, "")
+}
+
+// writeFakeResponse write the issue manager response envelope
+func writeFakeResponse(w http.ResponseWriter, status int, data interface{}, message string) {
+	code := 0
+	if status >= http.StatusBadRequest {
+		code = status
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"code":    code,
+		"message": message,
+		"data":    data,
+	})
+}
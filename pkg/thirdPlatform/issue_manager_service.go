@@ -1,11 +1,54 @@
 package thirdPlatform
 
 import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
 	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
 )
 
 var TypeIssueManager = "issueManager"
 
+// issueBatchSize maximum number of issues sent in a single batch create request
+const issueBatchSize = 100
+
+// IssueBatchCreateResult result of a batch create request
+type IssueBatchCreateResult struct {
+	Created  int      `json:"created"`
+	IssueIDs []string `json:"issue_ids"`
+}
+
+// IssueListResult paginated issue list of a review task
+type IssueListResult struct {
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+	List     []types.Issue `json:"list"`
+}
+
+// issueBatchCreateRequest request body of batch create
+type issueBatchCreateRequest struct {
+	ReviewTaskID string        `json:"review_task_id"`
+	Issues       []types.Issue `json:"issues"`
+}
+
+// issueStatusUpdateRequest request body of status update
+type issueStatusUpdateRequest struct {
+	Status int `json:"status"`
+}
+
+// issueManagerResponse response envelope of the issue manager
+type issueManagerResponse[T any] struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    T      `json:"data"`
+}
+
 type IssueManagerService struct {
 	*Service
 }
@@ -16,6 +59,11 @@ func NewIssueManagerService() (*IssueManagerService, error) {
 		return nil, err
 	}
 
+	return NewIssueManagerServiceWithConfig(clientConfig)
+}
+
+// NewIssueManagerServiceWithConfig create issue manager service with the given HTTP client config
+func NewIssueManagerServiceWithConfig(clientConfig *httpclient.HttpServiceConfig) (*IssueManagerService, error) {
 	client, err := httpclient.NewClient(clientConfig)
 	if err != nil {
 		return nil, err
@@ -29,3 +77,103 @@ func NewIssueManagerService() (*IssueManagerService, error) {
 
 	return service, nil
 }
+
+// BatchCreateIssues create issues of a review task in one request
+func (s *IssueManagerService) BatchCreateIssues(ctx context.Context, reviewTaskID string, issues []types.Issue) (*IssueBatchCreateResult, error) {
+	var response issueManagerResponse[IssueBatchCreateResult]
+
+	body := issueBatchCreateRequest{
+		ReviewTaskID: reviewTaskID,
+		Issues:       issues,
+	}
+	err := s.client.PostJSON(ctx, "/issues/batch", body, nil, &response)
+	if err != nil {
+		logger.Error(i18n.Translate("issue_manager.batch_create.failed", "", nil), "error", err, "review_task_id", reviewTaskID)
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("issue_manager.batch_create.failed", "", nil), err)
+	}
+
+	if err := checkIssueManagerResponse(response.Code, response.Message, "review_task_id", reviewTaskID); err != nil {
+		return nil, err
+	}
+
+	return &response.Data, nil
+}
+
+// PushReviewResult push all issues of a finished review task, split into batches
+func (s *IssueManagerService) PushReviewResult(ctx context.Context, reviewTaskID string, issues []types.Issue) (int, error) {
+	created := 0
+	for start := 0; start < len(issues); start += issueBatchSize {
+		end := min(start+issueBatchSize, len(issues))
+		result, err := s.BatchCreateIssues(ctx, reviewTaskID, issues[start:end])
+		if err != nil {
+			return created, err
+		}
+		created += result.Created
+	}
+	return created, nil
+}
+
+// UpdateIssueStatus update the status of an issue
+func (s *IssueManagerService) UpdateIssueStatus(ctx context.Context, issueID string, status int) error {
+	var response issueManagerResponse[struct{}]
+
+	path := fmt.Sprintf("/issues/%s/status", url.PathEscape(issueID))
+	err := s.client.PutJSON(ctx, path, issueStatusUpdateRequest{Status: status}, nil, &response)
+	if err != nil {
+		logger.Error(i18n.Translate("issue_manager.update_status.failed", "", nil), "error", err, "issue_id", issueID)
+		return fmt.Errorf("%s: %w", i18n.Translate("issue_manager.update_status.failed", "", nil), err)
+	}
+
+	return checkIssueManagerResponse(response.Code, response.Message, "issue_id", issueID)
+}
+
+// ListIssuesByTask list issues of a review task by page
+func (s *IssueManagerService) ListIssuesByTask(ctx context.Context, reviewTaskID string, page, pageSize int) (*IssueListResult, error) {
+	var response issueManagerResponse[IssueListResult]
+
+	query := url.Values{}
+	query.Set("review_task_id", reviewTaskID)
+	query.Set("page", strconv.Itoa(page))
+	query.Set("page_size", strconv.Itoa(pageSize))
+
+	err := s.client.GetJSON(ctx, "/issues?"+query.Encode(), nil, &response)
+	if err != nil {
+		logger.Error(i18n.Translate("issue_manager.list.failed", "", nil), "error", err, "review_task_id", reviewTaskID)
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("issue_manager.list.failed", "", nil), err)
+	}
+
+	if err := checkIssueManagerResponse(response.Code, response.Message, "review_task_id", reviewTaskID); err != nil {
+		return nil, err
+	}
+
+	return &response.Data, nil
+}
+
+// GetIssue get issue details
+func (s *IssueManagerService) GetIssue(ctx context.Context, issueID string) (*types.Issue, error) {
+	var response issueManagerResponse[types.Issue]
+
+	err := s.client.GetJSON(ctx, fmt.Sprintf("/issues/%s", url.PathEscape(issueID)), nil, &response)
+	if err != nil {
+		logger.Error(i18n.Translate("issue_manager.get.failed", "", nil), "error", err, "issue_id", issueID)
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("issue_manager.get.failed", "", nil), err)
+	}
+
+	if err := checkIssueManagerResponse(response.Code, response.Message, "issue_id", issueID); err != nil {
+		return nil, err
+	}
+
+	return &response.Data, nil
+}
+
+// checkIssueManagerResponse check API response status of the envelope
+func checkIssueManagerResponse(code int, message string, keysAndValues ...interface{}) error {
+	if code == 0 {
+		return nil
+	}
+	logger.Error(i18n.Translate("issue_manager.api.error", "", nil), append([]interface{}{"code", code, "message", message}, keysAndValues...)...)
+	return fmt.Errorf("%s", i18n.Translate("issue_manager.api.error_message", "", map[string]interface{}{
+		"code":    code,
+		"message": message,
+	}))
+}
@@ -0,0 +1,98 @@
+package thirdPlatform
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/zgsm/mock-kbcenter/pkg/types"
+)
+
+func newTestIssueManager(t *testing.T) (*IssueManagerService, *FakeIssueManagerServer) {
+	t.Helper()
+	fake := NewFakeIssueManagerServer()
+	t.Cleanup(fake.Close)
+
+	service, err := NewIssueManagerServiceWithConfig(fake.ServiceConfig())
+	if err != nil {
+		t.Fatalf("NewIssueManagerServiceWithConfig failed: %v", err)
+	}
+	return service, fake
+}
+
+func TestIssueManagerService_BatchCreateAndGet(t *testing.T) {
+	service, fake := newTestIssueManager(t)
+	ctx := context.Background()
+
+	issues := []types.Issue{
+		{IssueID: "i-1", FilePath: "main.go", StartLine: 3, EndLine: 5, Message: "first", Severity: "high"},
+		{IssueID: "i-2", FilePath: "util.go", StartLine: 10, EndLine: 10, Message: "second", Severity: "low"},
+	}
+
+	result, err := service.BatchCreateIssues(ctx, "task-1", issues)
+	if err != nil {
+		t.Fatalf("BatchCreateIssues failed: %v", err)
+	}
+	if result.Created != 2 {
+		t.Errorf("Expected 2 created issues, got %d", result.Created)
+	}
+	if got := fake.Issues("task-1"); len(got) != 2 {
+		t.Fatalf("Expected fake server to store 2 issues, got %d", len(got))
+	}
+
+	issue, err := service.GetIssue(ctx, "i-2")
+	if err != nil {
+		t.Fatalf("GetIssue failed: %v", err)
+	}
+	if issue.FilePath != "util.go" || issue.Message != "second" {
+		t.Errorf("Unexpected issue: %+v", issue)
+	}
+
+	if _, err := service.GetIssue(ctx, "missing"); err == nil {
+		t.Error("Expected error for missing issue")
+	}
+}
+
+func TestIssueManagerService_UpdateIssueStatus(t *testing.T) {
+	service, fake := newTestIssueManager(t)
+	ctx := context.Background()
+
+	if _, err := service.BatchCreateIssues(ctx, "task-1", []types.Issue{{IssueID: "i-1"}}); err != nil {
+		t.Fatalf("BatchCreateIssues failed: %v", err)
+	}
+	if err := service.UpdateIssueStatus(ctx, "i-1", 2); err != nil {
+		t.Fatalf("UpdateIssueStatus failed: %v", err)
+	}
+	if got := fake.Issues("task-1")[0].Status; got != 2 {
+		t.Errorf("Expected status 2, got %d", got)
+	}
+}
+
+func TestIssueManagerService_PushAndList(t *testing.T) {
+	service, _ := newTestIssueManager(t)
+	ctx := context.Background()
+
+	issues := make([]types.Issue, 0, issueBatchSize+5)
+	for i := 0; i < issueBatchSize+5; i++ {
+		issues = append(issues, types.Issue{IssueID: fmt.Sprintf("i-%d", i), StartLine: i + 1})
+	}
+
+	created, err := service.PushReviewResult(ctx, "task-1", issues)
+	if err != nil {
+		t.Fatalf("PushReviewResult failed: %v", err)
+	}
+	if created != len(issues) {
+		t.Errorf("Expected %d created issues, got %d", len(issues), created)
+	}
+
+	page, err := service.ListIssuesByTask(ctx, "task-1", 2, issueBatchSize)
+	if err != nil {
+		t.Fatalf("ListIssuesByTask failed: %v", err)
+	}
+	if page.Total != int64(len(issues)) {
+		t.Errorf("Expected total %d, got %d", len(issues), page.Total)
+	}
+	if len(page.List) != 5 || page.List[0].IssueID != fmt.Sprintf("i-%d", issueBatchSize) {
+		t.Errorf("Unexpected second page: %d issues", len(page.List))
+	}
+}
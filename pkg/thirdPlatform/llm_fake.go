@@ -0,0 +1,184 @@
+package thirdPlatform
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+)
+
+// FakeLLMHandler OpenAI-compatible chat completion endpoint reviewing code with fixed heuristics.
+// It answers every request ending in /chat/completions and is intended for tests and local runs.
+type FakeLLMHandler struct {
+	// Respond reply content of a request, FakeLLMReview when nil
+	Respond func(req ChatCompletionRequest) string
+
+	mu       sync.Mutex
+	requests []ChatCompletionRequest
+}
+
+// NewFakeLLMHandler create a fake chat completion handler
+func NewFakeLLMHandler() *FakeLLMHandler {
+	return &FakeLLMHandler{}
+}
+
+// Requests chat completion requests received, in arrival order
+func (h *FakeLLMHandler) Requests() []ChatCompletionRequest {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return append([]ChatCompletionRequest(nil), h.requests...)
+}
+
+func (h *FakeLLMHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+		writeFakeLLMError(w, http.StatusNotFound, "not found")
+		return
+	}
+	var req ChatCompletionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Messages) == 0 {
+		writeFakeLLMError(w, http.StatusBadRequest, "invalid request")
+		return
+	}
+
+	h.mu.Lock()
+	h.requests = append(h.requests, req)
+	id := len(h.requests)
+	respond := h.Respond
+	h.mu.Unlock()
+
+	if respond == nil {
+		respond = FakeLLMReview
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(ChatCompletionResponse{
+		ID:    "chatcmpl-fake-" + strconv.Itoa(id),
+		Model: req.Model,
+		Choices: []ChatCompletionChoice{{
+			Message:      ChatMessage{Role: ChatRoleAssistant, Content: respond(req)},
+			FinishReason: "stop",
+		}},
+	})
+}
+
+// FakeLLMServer in-process model server serving FakeLLMHandler, call Close when done
+type FakeLLMServer struct {
+	*httptest.Server
+	*FakeLLMHandler
+}
+
+// NewFakeLLMServer start a fake model server
+func NewFakeLLMServer() *FakeLLMServer {
+	handler := NewFakeLLMHandler()
+	return &FakeLLMServer{
+		Server:         httptest.NewServer(handler),
+		FakeLLMHandler: handler,
+	}
+}
+
+// ServiceConfig HTTP client config pointing at the fake server
+func (f *FakeLLMServer) ServiceConfig() *httpclient.HttpServiceConfig {
+	return &httpclient.HttpServiceConfig{
+		BaseURL:  f.URL + "/v1",
+		Timeout:  5 * time.Second,
+		AuthType: "none",
+	}
+}
+
+// fakeLLMFinding heuristic of the fake reviewer
+type fakeLLMFinding struct {
+	pattern    *regexp.Regexp
+	ruleID     string
+	severity   string
+	issueType  string
+	confidence int
+	title      string
+	message    string
+}
+
+var (
+	// fakeLLMCodeLine code line numbered by language.FormatCodeWithLineNumbers
+	fakeLLMCodeLine = regexp.MustCompile(`^\s*(\d+) \| (.*)$`)
+
+	fakeLLMFindings = []fakeLLMFinding{
+		{
+			pattern:    regexp.MustCompile(`(?i)(password|secret|token|api_?key)\w*\s*:?=\s*"[^"]+"`),
+			ruleID:     "hardcoded-credential",
+			severity:   "high",
+			issueType:  "security",
+			confidence: 85,
+			title:      "Hardcoded credential",
+			message:    "A credential is hardcoded in the source, load it from configuration or a secret store instead.",
+		},
+		{
+			pattern:    regexp.MustCompile(`\bpanic\(`),
+			ruleID:     "avoid-panic",
+			severity:   "middle",
+			issueType:  "robustness",
+			confidence: 70,
+			title:      "Panic in library code",
+			message:    "panic aborts the whole program, return an error to the caller instead.",
+		},
+		{
+			pattern:    regexp.MustCompile(`^\s*_\s*=\s*[\w.]+\(`),
+			ruleID:     "ignored-error",
+			severity:   "low",
+			issueType:  "error_handling",
+			confidence: 50,
+			title:      "Ignored error",
+			message:    "The result of the call is discarded, check the error it may return.",
+		},
+	}
+)
+
+// FakeLLMReview review the numbered code lines of the last user message with the fake heuristics,
+// replying with the JSON issue list the LLM analyzer asks for
+func FakeLLMReview(req ChatCompletionRequest) string {
+	var content string
+	for _, message := range req.Messages {
+		if message.Role == ChatRoleUser {
+			content = message.Content
+		}
+	}
+
+	issues := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(content, "\n") {
+		match := fakeLLMCodeLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		lineNumber, _ := strconv.Atoi(match[1])
+		for _, finding := range fakeLLMFindings {
+			if finding.pattern.MatchString(match[2]) {
+				issues = append(issues, map[string]interface{}{
+					"rule_id":     finding.ruleID,
+					"start_line":  lineNumber,
+					"end_line":    lineNumber,
+					"title":       finding.title,
+					"message":     finding.message,
+					"severity":    finding.severity,
+					"issue_types": []string{finding.issueType},
+					"confidence":  finding.confidence,
+				})
+			}
+		}
+	}
+
+	reply, _ := json.Marshal(map[string]interface{}{"issues": issues})
+	return string(reply)
+}
+
+// writeFakeLLMError write an OpenAI-style error response
+func writeFakeLLMError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]string{"message": message, "type": "invalid_request_error"},
+	})
+}
@@ -0,0 +1,105 @@
+package thirdPlatform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/pkg/httpclient"
+	"github.com/zgsm/mock-kbcenter/pkg/logger"
+)
+
+var TypeLLM = "llm"
+
+// Chat message roles
+const (
+	ChatRoleSystem    = "system"
+	ChatRoleUser      = "user"
+	ChatRoleAssistant = "assistant"
+)
+
+// ChatMessage message of a chat completion
+type ChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// ChatResponseFormat output format requested from the model, "json_object" for JSON output
+type ChatResponseFormat struct {
+	Type string `json:"type"`
+}
+
+// ChatCompletionRequest request body of an OpenAI-compatible chat completion
+type ChatCompletionRequest struct {
+	Model          string              `json:"model"`
+	Messages       []ChatMessage       `json:"messages"`
+	Temperature    float64             `json:"temperature"`
+	MaxTokens      int                 `json:"max_tokens,omitempty"`
+	ResponseFormat *ChatResponseFormat `json:"response_format,omitempty"`
+}
+
+// ChatCompletionChoice generated message of a chat completion
+type ChatCompletionChoice struct {
+	Index        int         `json:"index"`
+	Message      ChatMessage `json:"message"`
+	FinishReason string      `json:"finish_reason"`
+}
+
+// ChatCompletionUsage token usage of a chat completion
+type ChatCompletionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// ChatCompletionResponse response of an OpenAI-compatible chat completion
+type ChatCompletionResponse struct {
+	ID      string                 `json:"id"`
+	Model   string                 `json:"model"`
+	Choices []ChatCompletionChoice `json:"choices"`
+	Usage   ChatCompletionUsage    `json:"usage"`
+}
+
+// LLMService client of an OpenAI-compatible model endpoint, base_url includes the API version such as /v1
+type LLMService struct {
+	*Service
+}
+
+func NewLLMService() (*LLMService, error) {
+	clientConfig, err := GetServiceConfig(TypeLLM)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLLMServiceWithConfig(clientConfig)
+}
+
+// NewLLMServiceWithConfig create LLM service with the given HTTP client config
+func NewLLMServiceWithConfig(clientConfig *httpclient.HttpServiceConfig) (*LLMService, error) {
+	client, err := httpclient.NewClient(clientConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLMService{
+		Service: &Service{
+			client: client,
+		},
+	}, nil
+}
+
+// ChatCompletion generate the reply of the model to the messages
+func (s *LLMService) ChatCompletion(ctx context.Context, req ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	var response ChatCompletionResponse
+
+	err := s.client.PostJSON(ctx, "/chat/completions", req, nil, &response)
+	if err != nil {
+		logger.Error(i18n.Translate("llm.chat_completion.failed", "", nil), "error", err, "model", req.Model)
+		return nil, fmt.Errorf("%s: %w", i18n.Translate("llm.chat_completion.failed", "", nil), err)
+	}
+	if len(response.Choices) == 0 {
+		return nil, fmt.Errorf("%s", i18n.Translate("llm.chat_completion.empty", "", nil))
+	}
+
+	return &response, nil
+}
@@ -0,0 +1,202 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hibiken/asynq"
+	"github.com/zgsm/mock-kbcenter/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters of the spans
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+)
+
+// tracerName instrumentation scope of the spans of the service
+const tracerName = "github.com/zgsm/mock-kbcenter"
+
+// defaultServiceName service name of the spans when none is configured
+const defaultServiceName = "mock-kbcenter"
+
+// payloadKey key of the trace context in the JSON payloads of tasks, ignored by the handlers decoding them
+const payloadKey = "_trace"
+
+// ErrInvalidExporter exporter other than otlp, stdout or file
+var ErrInvalidExporter = errors.New("invalid tracing exporter")
+
+// propagator W3C trace context and baggage, carried in the traceparent, tracestate and baggage headers
+var propagator = propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+
+// Init install the tracer provider of the process, component is appended to the service name of processes other
+// than the web server. The returned function flushes the buffered spans and must be called before exiting.
+// Without tracing enabled the spans are no-ops and no trace context is propagated.
+func Init(cfg config.Tracing, component string) (shutdown func(context.Context) error, err error) {
+	if !cfg.Enabled {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	var file io.Closer
+	switch cfg.Exporter {
+	case ExporterOTLP, "":
+		var options []otlptracehttp.Option
+		if strings.Contains(cfg.Endpoint, "://") {
+			options = append(options, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		} else if cfg.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		if err = os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+			return nil, err
+		}
+		var f *os.File
+		if f, err = os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, err
+		}
+		// One JSON span per line
+		file = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidExporter, cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	if component != "" {
+		serviceName += "-" + component
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, err
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if cfg.SampleRatio > 0 && cfg.SampleRatio < 1 {
+		sampler = sdktrace.TraceIDRatioBased(cfg.SampleRatio)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Traces started by a caller keep its sampling decision
+		sdktrace.WithSampler(sdktrace.ParentBased(sampler)),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			err = errors.Join(err, file.Close())
+		}
+		return err
+	}, nil
+}
+
+// Start start an internal span, a child of the span of ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// StartKind start a span of the kind, such as the server span of a request or the client span of a call
+func StartKind(ctx context.Context, kind trace.SpanKind, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(attrs...))
+}
+
+// End end the span, recording the error as its status
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Inject write the trace context of ctx into the headers of an outgoing request
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// Extract context carrying the trace context of the headers of an incoming request
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectPayload add the trace context of ctx to a JSON object payload of a task, other payloads are returned as is
+func InjectPayload(ctx context.Context, payload []byte) []byte {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return payload
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || fields == nil {
+		return payload
+	}
+	fields[payloadKey], _ = json.Marshal(carrier)
+	injected, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return injected
+}
+
+// ExtractPayload context carrying the trace context of a task payload
+func ExtractPayload(ctx context.Context, payload []byte) context.Context {
+	var fields struct {
+		Trace propagation.MapCarrier `json:"_trace"`
+	}
+	if err := json.Unmarshal(payload, &fields); err != nil || len(fields.Trace) == 0 {
+		return ctx
+	}
+	return otel.GetTextMapPropagator().Extract(ctx, fields.Trace)
+}
+
+// TaskMiddleware Asynq middleware processing every task within a consumer span, continuing the trace of the
+// request that enqueued it
+func TaskMiddleware(next asynq.Handler) asynq.Handler {
+	return asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		ctx = ExtractPayload(ctx, task.Payload())
+		attrs := []attribute.KeyValue{semconv.MessagingSystemKey.String("asynq"), attribute.String("asynq.task.type", task.Type())}
+		if id, ok := asynq.GetTaskID(ctx); ok {
+			attrs = append(attrs, semconv.MessagingMessageID(id))
+		}
+		if retried, ok := asynq.GetRetryCount(ctx); ok {
+			attrs = append(attrs, attribute.Int("asynq.task.retry", retried))
+		}
+		ctx, span := StartKind(ctx, trace.SpanKindConsumer, "process "+task.Type(), attrs...)
+		err := next.ProcessTask(ctx, task)
+		End(span, err)
+		return err
+	})
+}
@@ -0,0 +1,137 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hibiken/asynq"
+	"github.com/zgsm/mock-kbcenter/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// record install a tracer provider recording the ended spans for the test
+func record(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagator)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	return recorder
+}
+
+func TestPropagation(t *testing.T) {
+	record(t)
+	ctx, span := StartKind(context.Background(), trace.SpanKindServer, "request")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	if !strings.Contains(header.Get("traceparent"), span.SpanContext().TraceID().String()) {
+		t.Fatalf("Expected the trace ID in traceparent, got %q", header.Get("traceparent"))
+	}
+	if got := trace.SpanContextFromContext(Extract(context.Background(), header)); got.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("Expected the extracted trace %s, got %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+
+	// The trace context rides along the fields of the payload
+	payload := InjectPayload(ctx, []byte(`{"review_task_id":"rt-1"}`))
+	var decoded struct {
+		ReviewTaskID string `json:"review_task_id"`
+	}
+	if err := json.Unmarshal(payload, &decoded); err != nil || decoded.ReviewTaskID != "rt-1" {
+		t.Errorf("Expected the fields of the payload kept, got %s %v", payload, err)
+	}
+	if got := trace.SpanContextFromContext(ExtractPayload(context.Background(), payload)); got.TraceID() != span.SpanContext().TraceID() {
+		t.Errorf("Expected the trace of the payload %s, got %s", span.SpanContext().TraceID(), got.TraceID())
+	}
+
+	// Payloads other than JSON objects are left alone
+	for _, raw := range []string{`["a"]`, `null`, `binary`} {
+		if got := InjectPayload(ctx, []byte(raw)); string(got) != raw {
+			t.Errorf("Expected %s unchanged, got %s", raw, got)
+		}
+	}
+	if got := InjectPayload(context.Background(), []byte(`{}`)); string(got) != `{}` {
+		t.Errorf("Expected no trace context without a span, got %s", got)
+	}
+}
+
+func TestTaskMiddleware(t *testing.T) {
+	recorder := record(t)
+	ctx, parent := Start(context.Background(), "enqueue")
+	parent.End()
+	task := asynq.NewTask("review:chunk", InjectPayload(ctx, []byte(`{"index":1}`)))
+
+	var handlerSpan trace.SpanContext
+	handler := TaskMiddleware(asynq.HandlerFunc(func(ctx context.Context, task *asynq.Task) error {
+		handlerSpan = trace.SpanContextFromContext(ctx)
+		return errors.New("failed")
+	}))
+	if err := handler.ProcessTask(context.Background(), task); err == nil {
+		t.Error("Expected the error of the handler")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, got %d", len(spans))
+	}
+	process := spans[1]
+	if process.Name() != "process review:chunk" || process.SpanKind() != trace.SpanKindConsumer {
+		t.Errorf("Unexpected span %s of kind %s", process.Name(), process.SpanKind())
+	}
+	if process.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("Expected the task processed in the trace of the enqueueing span")
+	}
+	if process.SpanContext().SpanID() != handlerSpan.SpanID() {
+		t.Error("Expected the handler to run within the span")
+	}
+	if process.Status().Code != codes.Error {
+		t.Errorf("Expected the error status, got %v", process.Status())
+	}
+}
+
+func TestInit(t *testing.T) {
+	if _, err := Init(config.Tracing{Enabled: true, Exporter: "jaeger"}, ""); !errors.Is(err, ErrInvalidExporter) {
+		t.Errorf("Expected ErrInvalidExporter, got %v", err)
+	}
+
+	previous, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+	file := filepath.Join(t.TempDir(), "logs", "traces.jsonl")
+	shutdown, err := Init(config.Tracing{Enabled: true, Exporter: ExporterFile, File: file}, "worker")
+	if err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	_, span := Start(context.Background(), "file.read")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("Reading the spans failed: %v", err)
+	}
+	for _, want := range []string{`"Name":"file.read"`, `"Value":"mock-kbcenter-worker"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("Expected %s in the exported spans, got %s", want, data)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// defaultRetryInterval wait before reconnecting a closed tunnel
+const defaultRetryInterval = 5 * time.Second
+
+// Agent client side of a tunnel: connects out to the proxy and answers the requests sent through the tunnel
+// with its handler
+type Agent struct {
+	ServerURL     string        // Base URL of the proxy
+	ClientID      string        // Client ID the tunnel is registered for
+	Token         string        // Bearer token of the tunnel endpoint, if the proxy requires one
+	Key           string        // Key of the tunnels of the agent, a random one is generated when empty
+	Handler       http.Handler  // Handler of the requests of the proxy
+	PingInterval  time.Duration // Interval of the health check pings of an idle tunnel, 0 disables them
+	RetryInterval time.Duration // Wait before reconnecting, 5 seconds when 0
+
+	OnConnect    func()          // Called when a tunnel is open
+	OnDisconnect func(err error) // Called when a tunnel fails or closes, before reconnecting
+}
+
+// Run serve tunnels, reconnecting after failures, until the context is done
+func (a *Agent) Run(ctx context.Context) error {
+	retryInterval := a.RetryInterval
+	if retryInterval <= 0 {
+		retryInterval = defaultRetryInterval
+	}
+	for {
+		err := a.Serve(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if a.OnDisconnect != nil {
+			a.OnDisconnect(err)
+		}
+
+		timer := time.NewTimer(retryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Serve open one tunnel and serve it until it closes or the context is done
+func (a *Agent) Serve(ctx context.Context) error {
+	if a.Key == "" {
+		key := make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return err
+		}
+		a.Key = hex.EncodeToString(key)
+	}
+	conn, err := Dial(ctx, a.ServerURL, a.ClientID, a.Token, a.Key)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	if a.OnConnect != nil {
+		a.OnConnect()
+	}
+
+	server := &http2.Server{ReadIdleTimeout: a.PingInterval, PingTimeout: pingTimeout}
+	server.ServeConn(conn, &http2.ServeConnOpts{Context: ctx, Handler: a.Handler})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return ErrClosed
+}
+
+// NewForwarder handler forwarding the requests of the proxy to the target, joining the request path to the
+// target path like the proxy does. The X-Forwarded headers set by the proxy are kept.
+func NewForwarder(target *url.URL) http.Handler {
+	return &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.SetURL(target)
+			for _, header := range []string{"X-Forwarded-For", "X-Forwarded-Host", "X-Forwarded-Proto"} {
+				if values := pr.In.Header.Values(header); len(values) > 0 {
+					pr.Out.Header[header] = values
+				}
+			}
+		},
+		FlushInterval: -1,
+	}
+}
@@ -0,0 +1,223 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/websocket"
+)
+
+// A tunnel is a WebSocket connection opened by an agent to the proxy. The proxy sends the requests of the
+// client over it as HTTP/2 streams, being the HTTP/2 client while the agent is the HTTP/2 server, so that
+// concurrent requests, streamed bodies and cancellation share the single connection.
+
+// PathPrefix path of the tunnel endpoint of the proxy, followed by the client ID
+const PathPrefix = "/_proxy/tunnel/"
+
+// KeyHeader header carrying the key an agent opens its tunnels with: a tunnel is only replaced by a
+// connection of the same key
+const KeyHeader = "X-Tunnel-Key"
+
+// pingTimeout time to wait for the answer of a health check ping before closing the tunnel
+const pingTimeout = 15 * time.Second
+
+// ErrNotConnected client without an open tunnel
+var ErrNotConnected = errors.New("tunnel not connected")
+
+// ErrUpgradeUnsupported protocol upgrade requested through a tunnel
+var ErrUpgradeUnsupported = errors.New("protocol upgrades are not supported through tunnels")
+
+// ErrClosed tunnel closed by the other side
+var ErrClosed = errors.New("tunnel closed")
+
+// ErrInUse client with an open tunnel of another agent
+var ErrInUse = errors.New("tunnel in use by another agent")
+
+// Hub proxy side of the tunnels, at most one per client ID, safe for concurrent use
+type Hub struct {
+	mu        sync.RWMutex
+	tunnels   map[string]*tunnel
+	transport *http2.Transport
+}
+
+// tunnel open tunnel of a client
+type tunnel struct {
+	key  string
+	conn *http2.ClientConn
+	done chan struct{}
+}
+
+// NewHub create hub checking idle tunnels with a ping every pingInterval, 0 disables the checks
+func NewHub(pingInterval time.Duration) *Hub {
+	return &Hub{
+		tunnels:   make(map[string]*tunnel),
+		transport: &http2.Transport{ReadIdleTimeout: pingInterval, PingTimeout: pingTimeout},
+	}
+}
+
+// Upgrade accept the WebSocket connection of an agent as the tunnel of the client and serve it until it
+// closes, answering 409 while another agent has the tunnel of the client. Callers authenticate the agent before.
+func (h *Hub) Upgrade(w http.ResponseWriter, r *http.Request, id string) {
+	key := r.Header.Get(KeyHeader)
+	if h.inUse(id, key) {
+		http.Error(w, fmt.Sprintf("%v: %s", ErrInUse, id), http.StatusConflict)
+		return
+	}
+	server := websocket.Server{
+		// Agents are not browsers, there is no origin to check
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			ws.PayloadType = websocket.BinaryFrame
+			_ = h.Serve(id, key, ws)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// Serve use the connection as the tunnel of the client until it closes. A previous tunnel of the same key is
+// replaced so that a reconnecting agent does not wait for its dead connection to time out, while the tunnel of
+// another key is kept until its health checks fail and the connection is refused with ErrInUse.
+func (h *Hub) Serve(id, key string, conn net.Conn) error {
+	notify := &notifyConn{Conn: conn, done: make(chan struct{})}
+	clientConn, err := h.transport.NewClientConn(notify)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	t := &tunnel{key: key, conn: clientConn, done: notify.done}
+
+	h.mu.Lock()
+	if h.inUseLocked(id, key) {
+		h.mu.Unlock()
+		clientConn.Close()
+		return ErrInUse
+	}
+	previous := h.tunnels[id]
+	h.tunnels[id] = t
+	h.mu.Unlock()
+	if previous != nil {
+		previous.conn.Close()
+	}
+
+	<-t.done
+	h.mu.Lock()
+	if h.tunnels[id] == t {
+		delete(h.tunnels, id)
+	}
+	h.mu.Unlock()
+	return clientConn.Close()
+}
+
+// inUse whether the client has an open tunnel a connection of the key may not replace
+func (h *Hub) inUse(id, key string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.inUseLocked(id, key)
+}
+
+// inUseLocked inUse with the lock held, connections without a key replace no tunnel
+func (h *Hub) inUseLocked(id, key string) bool {
+	previous, ok := h.tunnels[id]
+	return ok && (key == "" || previous.key != key)
+}
+
+// Connected whether the client has an open tunnel
+func (h *Hub) Connected(id string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	_, ok := h.tunnels[id]
+	return ok
+}
+
+// List clients with an open tunnel in ID order
+func (h *Hub) List() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	ids := make([]string, 0, len(h.tunnels))
+	for id := range h.tunnels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// RoundTrip send the request through the tunnel of the client
+func (h *Hub) RoundTrip(id string, req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Upgrade") != "" {
+		return nil, ErrUpgradeUnsupported
+	}
+	h.mu.RLock()
+	t := h.tunnels[id]
+	h.mu.RUnlock()
+	if t == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotConnected, id)
+	}
+	return t.conn.RoundTrip(req)
+}
+
+// Dial open a tunnel for the client to the proxy at serverURL, an http, https, ws or wss base URL, with the key
+// identifying the tunnels of the agent
+func Dial(ctx context.Context, serverURL, clientID, token, key string) (net.Conn, error) {
+	target, err := url.Parse(serverURL)
+	if err != nil {
+		return nil, err
+	}
+	origin := *target
+	switch target.Scheme {
+	case "http", "ws":
+		target.Scheme, origin.Scheme = "ws", "http"
+	case "https", "wss":
+		target.Scheme, origin.Scheme = "wss", "https"
+	default:
+		return nil, fmt.Errorf("unsupported tunnel server URL %q", serverURL)
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/") + PathPrefix + url.PathEscape(clientID)
+
+	config, err := websocket.NewConfig(target.String(), origin.String())
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		config.Header.Set("Authorization", "Bearer "+token)
+	}
+	if key != "" {
+		config.Header.Set(KeyHeader, key)
+	}
+	ws, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ws.PayloadType = websocket.BinaryFrame
+	return ws, nil
+}
+
+// notifyConn connection closing its done channel once it fails or is closed
+type notifyConn struct {
+	net.Conn
+	once sync.Once
+	done chan struct{}
+}
+
+func (c *notifyConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if err != nil {
+		c.once.Do(func() { close(c.done) })
+	}
+	return n, err
+}
+
+func (c *notifyConn) Close() error {
+	c.once.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
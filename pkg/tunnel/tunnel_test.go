@@ -0,0 +1,156 @@
+package tunnel
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startTunnel serve a hub on a test server and connect an agent for the client with the handler
+func startTunnel(t *testing.T, id string, handler http.Handler) (*Hub, context.CancelFunc) {
+	hub := NewHub(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Upgrade(w, r, strings.TrimPrefix(r.URL.Path, PathPrefix))
+	}))
+	t.Cleanup(server.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	agent := &Agent{ServerURL: server.URL, ClientID: id, Handler: handler, RetryInterval: 10 * time.Millisecond}
+	go func() { _ = agent.Run(ctx) }()
+
+	waitFor(t, func() bool { return hub.Connected(id) })
+	return hub, cancel
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !condition(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the tunnel")
+		}
+	}
+}
+
+func TestTunnel_MultiplexesRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s?%s %s forwarded=%s", r.Method, r.URL.Path, r.URL.RawQuery, body, r.Header.Get("X-Forwarded-For"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL + "/base")
+	hub, _ := startTunnel(t, "ide-1", NewForwarder(target))
+
+	if ids := hub.List(); len(ids) != 1 || ids[0] != "ide-1" {
+		t.Errorf("Unexpected tunnels %v", ids)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("http://ide-1/files/%d?q=1", i), strings.NewReader("body"))
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			resp, err := hub.RoundTrip("ide-1", req)
+			if err != nil {
+				t.Errorf("RoundTrip failed: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if want := fmt.Sprintf("POST /base/files/%d?q=1 body forwarded=10.0.0.1", i); string(body) != want {
+				t.Errorf("Unexpected response %q, want %q", body, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	req, _ := http.NewRequest(http.MethodGet, "http://ide-2/", nil)
+	if _, err := hub.RoundTrip("ide-2", req); !errors.Is(err, ErrNotConnected) {
+		t.Errorf("Expected ErrNotConnected, got %v", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	if _, err := hub.RoundTrip("ide-1", req); !errors.Is(err, ErrUpgradeUnsupported) {
+		t.Errorf("Expected ErrUpgradeUnsupported, got %v", err)
+	}
+}
+
+func TestTunnel_StreamsAndCloses(t *testing.T) {
+	release := make(chan struct{})
+	hub, cancel := startTunnel(t, "ide-1", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer close(release)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://ide-1/events", nil)
+	resp, err := hub.RoundTrip("ide-1", req)
+	if err != nil {
+		t.Fatalf("RoundTrip failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if line, _ := bufio.NewReader(resp.Body).ReadString('\n'); line != "data: first\n" {
+		t.Errorf("Unexpected event %q", line)
+	}
+
+	// The hub forgets the tunnel once the agent stops
+	cancel()
+	waitFor(t, func() bool { return !hub.Connected("ide-1") })
+}
+
+func TestHub_KeepsLiveTunnels(t *testing.T) {
+	hub := NewHub(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hub.Upgrade(w, r, strings.TrimPrefix(r.URL.Path, PathPrefix))
+	}))
+	t.Cleanup(server.Close)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	reply := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) })
+	}
+	serve := func(agent *Agent) <-chan error {
+		errs := make(chan error, 1)
+		go func() { errs <- agent.Serve(ctx) }()
+		return errs
+	}
+	get := func() string {
+		req, _ := http.NewRequest(http.MethodGet, "http://ide-1/", nil)
+		resp, err := hub.RoundTrip("ide-1", req)
+		if err != nil {
+			return err.Error()
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	first := &Agent{ServerURL: server.URL, ClientID: "ide-1", Handler: reply("first")}
+	serve(first)
+	waitFor(t, func() bool { return hub.Connected("ide-1") })
+
+	// Another agent cannot take over the live tunnel
+	if err := <-serve(&Agent{ServerURL: server.URL, ClientID: "ide-1", Handler: reply("other")}); err == nil || errors.Is(err, ErrClosed) {
+		t.Fatalf("Expected the other agent refused, got %v", err)
+	}
+	if body := get(); body != "first" {
+		t.Errorf("Expected the first tunnel kept, got %q", body)
+	}
+
+	// The same agent reconnecting replaces its tunnel
+	serve(&Agent{ServerURL: server.URL, ClientID: "ide-1", Key: first.Key, Handler: reply("reconnected")})
+	waitFor(t, func() bool { return get() == "reconnected" })
+}
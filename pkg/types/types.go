@@ -6,22 +6,146 @@ import (
 	"github.com/zgsm/mock-kbcenter/i18n"
 )
 
+// Issue severity levels
+const (
+	SeverityLow    = "low"
+	SeverityMiddle = "middle"
+	SeverityHigh   = "high"
+)
+
+// Review task status
+const (
+	ReviewTaskStatusPending = "pending"
+	ReviewTaskStatusRunning = "running"
+	ReviewTaskStatusDone    = "done"
+	ReviewTaskStatusFailed  = "failed"
+	// Stopped before all files were reviewed, the issues found so far are kept
+	ReviewTaskStatusCancelled = "cancelled"
+	ReviewTaskStatusTimeout   = "timeout"
+)
+
+// Issue status lifecycle
+const (
+	IssueStatusOpen      = 0 // Found and not triaged yet
+	IssueStatusConfirmed = 1 // Accepted as a real problem
+	IssueStatusIgnored   = 2 // Dismissed as false positive or won't fix
+	IssueStatusFixed     = 3 // Fixed in the codebase
+)
+
+// issueStatusTransitions allowed target statuses of each status
+var issueStatusTransitions = map[int][]int{
+	IssueStatusOpen:      {IssueStatusConfirmed, IssueStatusIgnored, IssueStatusFixed},
+	IssueStatusConfirmed: {IssueStatusOpen, IssueStatusIgnored, IssueStatusFixed},
+	IssueStatusIgnored:   {IssueStatusOpen},
+	IssueStatusFixed:     {IssueStatusOpen},
+}
+
+// ValidIssueStatus whether status is a known issue status
+func ValidIssueStatus(status int) bool {
+	_, ok := issueStatusTransitions[status]
+	return ok
+}
+
+// CanTransitionIssueStatus whether an issue may move from one status to another, staying in the same status is allowed
+func CanTransitionIssueStatus(from, to int) bool {
+	if from == to {
+		return ValidIssueStatus(to)
+	}
+	for _, allowed := range issueStatusTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Issue change compared with the previous review of the same codebase
+const (
+	IssueChangeNew       = "new"
+	IssueChangeUnchanged = "unchanged"
+	IssueChangeResolved  = "resolved"
+)
+
 // Issue represents a code review finding
 type Issue struct {
-	IssueID    string   `json:"issue_id"`
-	FilePath   string   `json:"file_path"`
-	IssueCode  *string  `json:"issue_code,omitempty"`
-	FixPatch   *string  `json:"fix_patch,omitempty"`
-	StartLine  int      `json:"start_line"`
-	EndLine    int      `json:"end_line"`
-	Title      *string  `json:"title,omitempty"`
-	Message    string   `json:"message"`
-	IssueTypes []string `json:"issue_types"`
-	Severity   string   `json:"severity"` // low | middle | high
-	Status     int      `json:"status"`   // 0 | 1 | 2 | 3
-	Confidence int      `json:"confidence"`
-	CreatedAt  string   `json:"created_at"`
-	UpdatedAt  string   `json:"updated_at"`
+	IssueID     string   `json:"issue_id"`
+	RuleID      string   `json:"rule_id,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"` // Stable across line shifts, matches issues between reviews
+	Change      string   `json:"change,omitempty"`      // new | unchanged | resolved
+	FilePath    string   `json:"file_path"`
+	IssueCode   *string  `json:"issue_code,omitempty"`
+	FixPatch    *string  `json:"fix_patch,omitempty"`
+	StartLine   int      `json:"start_line"`
+	EndLine     int      `json:"end_line"`
+	Title       *string  `json:"title,omitempty"`
+	Message     string   `json:"message"`
+	IssueTypes  []string `json:"issue_types"`
+	Severity    string   `json:"severity"` // low | middle | high
+	Status      int      `json:"status"`   // 0 open | 1 confirmed | 2 ignored | 3 fixed
+	Assignee    string   `json:"assignee,omitempty"`
+	Confidence  int      `json:"confidence"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+
+	// Edits structured fix produced by an analyzer, rendered into FixPatch by the review pipeline
+	Edits []TextEdit `json:"-"`
+}
+
+// IssueHistory change of an issue made through triage
+type IssueHistory struct {
+	IssueID      string `json:"issue_id"`
+	Operator     string `json:"operator"`
+	FromStatus   int    `json:"from_status"`
+	ToStatus     int    `json:"to_status"`
+	FromAssignee string `json:"from_assignee,omitempty"`
+	ToAssignee   string `json:"to_assignee,omitempty"`
+	Comment      string `json:"comment,omitempty"`
+	CreatedAt    string `json:"created_at"`
+}
+
+// IssueFilter conditions of issue listing and bulk updates, zero values match everything
+type IssueFilter struct {
+	ReviewTaskID string `json:"review_task_id" form:"review_task_id"`
+	Status       *int   `json:"status" form:"status"`
+	Severity     string `json:"severity" form:"severity"`
+	RuleID       string `json:"rule_id" form:"rule_id"`
+	FilePath     string `json:"file_path" form:"file_path"`
+	Assignee     string `json:"assignee" form:"assignee"`
+	Change       string `json:"change" form:"change"`
+}
+
+// IssueBatchUpdateResult result of a bulk issue status update
+type IssueBatchUpdateResult struct {
+	Matched int      `json:"matched"`
+	Updated int      `json:"updated"`
+	Skipped []string `json:"skipped"` // Issues whose status cannot transition to the target status
+}
+
+// TextEdit replace the lines [StartLine, EndLine] with NewLines.
+// EndLine = StartLine - 1 inserts before StartLine, empty NewLines deletes the lines.
+type TextEdit struct {
+	StartLine int      `json:"start_line"`
+	EndLine   int      `json:"end_line"`
+	NewLines  []string `json:"new_lines"`
+}
+
+// FixRegion region of a file changed by a fix patch, with surrounding context lines
+type FixRegion struct {
+	OriginalStartLine int    `json:"original_start_line"`
+	OriginalEndLine   int    `json:"original_end_line"`
+	Original          string `json:"original"`
+	StartLine         int    `json:"start_line"` // First line of the region in the patched file
+	EndLine           int    `json:"end_line"`
+	Patched           string `json:"patched"`
+}
+
+// IssueFixResult preview or application result of an issue fix patch
+type IssueFixResult struct {
+	IssueID  string      `json:"issue_id"`
+	FilePath string      `json:"file_path"`
+	FixPatch string      `json:"fix_patch"`
+	Applied  bool        `json:"applied"`
+	Regions  []FixRegion `json:"regions"`
 }
 
 type IssueIncrementReviewTaskResult struct {
@@ -30,21 +154,107 @@ type IssueIncrementReviewTaskResult struct {
 	Total      int     `json:"total"`
 	NextOffset int     `json:"next_offset"`
 	Issues     []Issue `json:"issues"`
+	Suppressed int     `json:"suppressed"` // Issues filtered out by inline suppression comments
+	Baselined  int     `json:"baselined"`  // Issues filtered out by the baseline
+	Filtered   int     `json:"filtered"`   // Issues dropped by the issue policies
+}
+
+// Review task event types
+const (
+	ReviewTaskEventProgress = "progress" // Task status and progress changed
+	ReviewTaskEventIssues   = "issues"   // Issues were found, in the order of the incremental result
+	ReviewTaskEventFinished = "finished" // Task reached a final status, the last event of a task
+)
+
+// ReviewTaskEvent progress update or newly found issues of a review task, published as the task runs
+type ReviewTaskEvent struct {
+	Type         string      `json:"type"`
+	ReviewTaskID string      `json:"review_task_id"`
+	Task         *ReviewTask `json:"task,omitempty"`   // Progress and finished events
+	Offset       int         `json:"offset"`           // Offset of the first issue in the incremental result
+	NextOffset   int         `json:"next_offset"`      // Offset after the issues, to resume from
+	Issues       []Issue     `json:"issues,omitempty"` // Issues events
+}
+
+// Baseline known issues of a codebase, generated from a review and filtered out of later reviews
+type Baseline struct {
+	Version      int             `json:"version"`
+	ReviewTaskID string          `json:"review_task_id,omitempty"`
+	GeneratedAt  string          `json:"generated_at,omitempty"`
+	Issues       []BaselineIssue `json:"issues"`
+}
+
+// BaselineIssue known issue of a baseline, matched by fingerprint
+type BaselineIssue struct {
+	Fingerprint string `json:"fingerprint"`
+	RuleID      string `json:"rule_id,omitempty"`
+	FilePath    string `json:"file_path,omitempty"`
+	StartLine   int    `json:"start_line,omitempty"`
+}
+
+// ReviewTask review task over a set of targets in a codebase
+type ReviewTask struct {
+	ReviewTaskID string   `json:"review_task_id"`
+	ClientID     string   `json:"client_id"`
+	CodebasePath string   `json:"codebase_path"`
+	Targets      []Target `json:"targets"`
+	Status       string   `json:"status"`   // pending | running | done | failed | cancelled | timeout
+	Progress     float64  `json:"progress"` // Ratio of reviewed files, 0 to 1
+	Total        int      `json:"total"`    // Number of files to review
+	Processed    int      `json:"processed"`
+	Subtasks     int      `json:"subtasks"` // Number of subtasks the files are split into
+	Error        string   `json:"error,omitempty"`
+	// Issue changes compared with the previous finished reviews of the reviewed files, the base is the latest finished
+	// review of the same codebase
+	BaseReviewTaskID string `json:"base_review_task_id,omitempty"`
+	NewIssues        int    `json:"new_issues"`
+	UnchangedIssues  int    `json:"unchanged_issues"`
+	ResolvedIssues   int    `json:"resolved_issues"`
+	// Issues filtered out by inline suppression comments, by the baseline and by the issue policies
+	SuppressedIssues int    `json:"suppressed_issues"`
+	BaselineIssues   int    `json:"baseline_issues"`
+	FilteredIssues   int    `json:"filtered_issues"`
+	StartedAt        string `json:"started_at,omitempty"`
+	CreatedAt        string `json:"created_at"`
+	UpdatedAt        string `json:"updated_at"`
+}
+
+// IsFinished whether the review task has reached a final status
+func (t *ReviewTask) IsFinished() bool {
+	return t.Status == ReviewTaskStatusDone || t.Status == ReviewTaskStatusFailed ||
+		t.Status == ReviewTaskStatusCancelled || t.Status == ReviewTaskStatusTimeout
 }
 
 type Target struct {
-	Type      string `json:"type"`                 // file | folder | code
-	FilePath  string `json:"file_path"`            // File path
+	Type      string `json:"type"`                 // file | folder | code | diff
+	FilePath  string `json:"file_path"`            // File path, for diff targets an optional path the diff is limited to
 	LineRange []int  `json:"line_range,omitempty"` // Optional line range [start, end]
+
+	// Diff target: changes between two refs of the codebase git repository, or a raw unified diff
+	BaseRef string `json:"base_ref,omitempty"`
+	HeadRef string `json:"head_ref,omitempty"` // Empty compares with the working tree
+	Diff    string `json:"diff,omitempty"`     // Raw unified diff, applied to the working tree
+
+	// Code target: inline code reviewed as a virtual file named by FilePath, e.g. an unsaved editor selection
+	Code     string `json:"code,omitempty"`
+	Language string `json:"language,omitempty"` // Language hint, detected from the FilePath extension when empty
 }
 
 func (t *Target) Validate() error {
 	// Validate type
-	if t.Type != "file" && t.Type != "folder" && t.Type != "code" {
+	if t.Type != "file" && t.Type != "folder" && t.Type != "code" && t.Type != "diff" {
 		return fmt.Errorf("%s", i18n.Translate("review_task.invalid_target_type", "", nil))
 	}
+	// Validate diff source
+	if t.Type == "diff" && t.Diff == "" && t.BaseRef == "" {
+		return fmt.Errorf("%s", i18n.Translate("review_task.invalid_diff_target", "", nil))
+	}
+	// Validate inline code
+	if t.Type == "code" && (t.Code == "" || (t.FilePath == "" && t.Language == "")) {
+		return fmt.Errorf("%s", i18n.Translate("review_task.invalid_code_target", "", nil))
+	}
 	// Validate file_path
-	if t.FilePath == "" && t.Type != "folder" {
+	if t.FilePath == "" && t.Type != "folder" && t.Type != "diff" && t.Type != "code" {
 		return fmt.Errorf("%s", i18n.Translate("review_task.invalid_file_path", "", nil))
 	}
 	// Validate line_range
@@ -53,3 +263,113 @@ func (t *Target) Validate() error {
 	}
 	return nil
 }
+
+// ReviewChunk subtask of a review task, reviewing a slice of the task files
+type ReviewChunk struct {
+	ReviewTaskID string            `json:"review_task_id"`
+	Index        int               `json:"index"` // Position of the subtask, issues are stored in subtask order
+	Files        []ReviewChunkFile `json:"files"`
+}
+
+// ReviewChunkFile file of a review subtask and the targets it was collected from
+type ReviewChunkFile struct {
+	Path         string   `json:"path"`
+	Targets      []Target `json:"targets"`
+	Ref          string   `json:"ref,omitempty"`
+	ChangedLines []int    `json:"changed_lines,omitempty"`
+	Virtual      bool     `json:"virtual,omitempty"`
+}
+
+// InLineRange whether the lines [startLine, endLine] intersect the target line range
+func (t *Target) InLineRange(startLine, endLine int) bool {
+	if len(t.LineRange) != 2 {
+		return true
+	}
+	return startLine <= t.LineRange[1] && endLine >= t.LineRange[0]
+}
+
+// Webhook events of the review task lifecycle
+const (
+	WebhookEventTaskCreated   = "review_task.created"
+	WebhookEventTaskStarted   = "review_task.started"
+	WebhookEventTaskProgress  = "review_task.progress" // Progress passed one of the configured milestones
+	WebhookEventTaskCompleted = "review_task.completed"
+	WebhookEventTaskFailed    = "review_task.failed"
+	WebhookEventTaskCancelled = "review_task.cancelled"
+	WebhookEventTaskTimeout   = "review_task.timeout"
+)
+
+// WebhookEvents all webhook events
+var WebhookEvents = []string{
+	WebhookEventTaskCreated,
+	WebhookEventTaskStarted,
+	WebhookEventTaskProgress,
+	WebhookEventTaskCompleted,
+	WebhookEventTaskFailed,
+	WebhookEventTaskCancelled,
+	WebhookEventTaskTimeout,
+}
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook endpoint notified of review task events
+type Webhook struct {
+	WebhookID    string   `json:"webhook_id"`
+	URL          string   `json:"url"`
+	Secret       string   `json:"secret,omitempty"`        // Only returned on creation
+	CodebasePath string   `json:"codebase_path,omitempty"` // Empty for tasks of every codebase
+	Events       []string `json:"events,omitempty"`        // Empty for every event
+	Enabled      bool     `json:"enabled"`
+	CreatedAt    string   `json:"created_at"`
+	UpdatedAt    string   `json:"updated_at"`
+}
+
+// WebhookPayload JSON body of a webhook request, signed with the webhook secret
+type WebhookPayload struct {
+	Event     string      `json:"event"`
+	Timestamp string      `json:"timestamp"`
+	Task      *ReviewTask `json:"task"`
+}
+
+// WebhookDelivery attempt to deliver an event to a webhook
+type WebhookDelivery struct {
+	DeliveryID   string `json:"delivery_id"`
+	WebhookID    string `json:"webhook_id"`
+	ReviewTaskID string `json:"review_task_id"`
+	Event        string `json:"event"`
+	Payload      string `json:"payload"`
+	Status       string `json:"status"`
+	StatusCode   int    `json:"status_code,omitempty"` // Response status of the last request
+	Error        string `json:"error,omitempty"`
+	Duration     int64  `json:"duration"`                // Milliseconds, retries included
+	RedeliveryOf string `json:"redelivery_of,omitempty"` // Delivery this one was redelivered from
+	CreatedAt    string `json:"created_at"`
+	DeliveredAt  string `json:"delivered_at,omitempty"`
+}
+
+// PromptTemplate version of a review prompt template. System and user prompts are Go text/template
+// sources rendered with the file path, language, numbered code and surrounding symbols.
+type PromptTemplate struct {
+	Name                 string            `json:"name"`
+	Version              int               `json:"version"` // 0 for the built-in template
+	Description          string            `json:"description,omitempty"`
+	SystemPrompt         string            `json:"system_prompt"`
+	UserPrompt           string            `json:"user_prompt"`
+	LanguageInstructions map[string]string `json:"language_instructions,omitempty"` // By lowercase language
+	OutputSchema         string            `json:"output_schema,omitempty"`
+	Active               bool              `json:"active"` // Used by the LLM analyzer
+	CreatedAt            string            `json:"created_at,omitempty"`
+}
+
+// RenderedPrompt prompt template rendered for a piece of code
+type RenderedPrompt struct {
+	Name         string `json:"name,omitempty"` // Empty for drafts
+	Version      int    `json:"version"`
+	SystemPrompt string `json:"system_prompt"`
+	UserPrompt   string `json:"user_prompt"`
+}
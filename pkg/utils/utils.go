@@ -7,6 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"math/big"
+	"net"
+	"path"
 	"regexp"
 	"strings"
 	"time"
@@ -155,3 +157,62 @@ func SplitAndTrim(s, sep string) []string {
 	}
 	return result
 }
+
+// MatchGlob whether a slash separated value matches a glob, "**" segments match any number of segments.
+// Malformed globs match nothing.
+func MatchGlob(glob, value string) bool {
+	return matchSegments(strings.Split(glob, "/"), strings.Split(value, "/"))
+}
+
+// matchSegments match glob segments against value segments
+func matchSegments(globs, values []string) bool {
+	for len(globs) > 0 {
+		if globs[0] == "**" {
+			for i := 0; i <= len(values); i++ {
+				if matchSegments(globs[1:], values[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(values) == 0 {
+			return false
+		}
+		if ok, err := path.Match(globs[0], values[0]); err != nil || !ok {
+			return false
+		}
+		globs, values = globs[1:], values[1:]
+	}
+	return len(values) == 0
+}
+
+// nonPublicNets ranges not covered by the net.IP predicates that never host public services
+var nonPublicNets = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "192.0.0.0/24", "198.18.0.0/15", "240.0.0.0/4")
+
+// IsPublicIP whether the address may belong to a public service: not loopback, private, link-local such as the
+// cloud metadata address, multicast, unspecified or reserved
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return false
+	}
+	for _, network := range nonPublicNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// mustParseCIDRs parse constant CIDRs
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, network)
+	}
+	return nets
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		value string
+		want  bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "main.go", true},
+		{"**/*.go", "cmd/web/main.go", true},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"internal/**/testdata/*", "internal/x/y/testdata/a.json", true},
+		{"llm/*", "llm/avoid-panic", true},
+		{"llm/*", "todo-comment", false},
+		{"hotfix/*", "hotfix/1.2", true},
+		{"[", "[", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.glob, tt.value); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.glob, tt.value, got, tt.want)
+		}
+	}
+}
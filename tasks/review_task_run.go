@@ -3,23 +3,89 @@ package tasks
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 
 	"github.com/hibiken/asynq"
+	"github.com/zgsm/mock-kbcenter/i18n"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	queue "github.com/zgsm/mock-kbcenter/pkg/asynq"
 	"github.com/zgsm/mock-kbcenter/pkg/logger"
+	"github.com/zgsm/mock-kbcenter/pkg/types"
 )
 
 type RunReviewTaskPayload struct {
 	ReviewTaskID string `json:"review_task_id"`
 }
 
-func NewRunReviewTaskPayload(payload RunReviewTaskPayload, queue string) (*asynq.Task, error) {
+// NewRunReviewTaskPayload payload and options of the task running a review task, enqueued with queue.EnqueueTask
+func NewRunReviewTaskPayload(payload RunReviewTaskPayload) ([]byte, []asynq.Option, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	return asynq.NewTask(TypeRunReviewTask, payloadBytes, asynq.Queue(queue)), nil
+	taskID := fmt.Sprintf("%s:%s", TypeRunReviewTask, payload.ReviewTaskID)
+	return payloadBytes, []asynq.Option{asynq.TaskID(taskID)}, nil
 }
 
+// DispatchReviewTask run a review task on the worker when Asynq is enabled, otherwise in the current process
+func DispatchReviewTask(ctx context.Context, reviewTaskID string) error {
+	if queue.GetClient() == nil {
+		// The run outlives the request but stays in its trace
+		ctx = context.WithoutCancel(ctx)
+		go func() {
+			if err := service.NewReviewTaskService("").RunTask(ctx, reviewTaskID); err != nil {
+				logger.Error(i18n.Translate("review_task.run_failed", "", nil), "review_task_id", reviewTaskID, "error", err)
+			}
+		}()
+		return nil
+	}
+
+	payload, opts, err := NewRunReviewTaskPayload(RunReviewTaskPayload{ReviewTaskID: reviewTaskID})
+	if err != nil {
+		return err
+	}
+	// The task ID keeps a review task from being enqueued twice
+	if _, err = queue.EnqueueTask(ctx, TypeRunReviewTask, payload, QueueDefault, opts...); errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// NewRunReviewChunkPayload payload and options of the task of a review subtask, its ID makes enqueueing the same
+// subtask again a no-op
+func NewRunReviewChunkPayload(chunk types.ReviewChunk) ([]byte, []asynq.Option, error) {
+	payloadBytes, err := json.Marshal(chunk)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payloadBytes, []asynq.Option{asynq.TaskID(reviewChunkTaskID(chunk.ReviewTaskID, chunk.Index))}, nil
+}
+
+// reviewChunkTaskID queue task ID of a review subtask
+func reviewChunkTaskID(reviewTaskID string, index int) string {
+	return fmt.Sprintf("%s:%s:%d", TypeRunReviewChunk, reviewTaskID, index)
+}
+
+// CancelReviewTask remove the queued run and subtasks of a cancelled review task, and cancel the ones being processed
+func CancelReviewTask(task *types.ReviewTask) error {
+	if queue.GetClient() == nil {
+		return nil
+	}
+
+	taskIDs := []string{fmt.Sprintf("%s:%s", TypeRunReviewTask, task.ReviewTaskID)}
+	for index := 0; index < task.Subtasks; index++ {
+		taskIDs = append(taskIDs, reviewChunkTaskID(task.ReviewTaskID, index))
+	}
+	for _, taskID := range taskIDs {
+		if err := queue.CancelTask(QueueDefault, taskID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HandleRunReviewTask split a review task into subtasks and fan them out across the worker pool
 func HandleRunReviewTask(ctx context.Context, t *asynq.Task) error {
 	var payload RunReviewTaskPayload
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
@@ -29,5 +95,40 @@ func HandleRunReviewTask(ctx context.Context, t *asynq.Task) error {
 	// Start executing review task
 	logger.Info("RunReviewTask", "payload", payload)
 
+	chunks, err := service.NewReviewTaskService("").PrepareTask(ctx, payload.ReviewTaskID)
+	if err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		payload, opts, err := NewRunReviewChunkPayload(chunk)
+		if err != nil {
+			return err
+		}
+		if _, err := queue.EnqueueTask(ctx, TypeRunReviewChunk, payload, QueueDefault, opts...); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return err
+		}
+	}
 	return nil
 }
+
+// HandleRunReviewChunk run a review subtask, the review task fails once the subtask has used up its retries
+func HandleRunReviewChunk(ctx context.Context, t *asynq.Task) error {
+	var chunk types.ReviewChunk
+	if err := json.Unmarshal(t.Payload(), &chunk); err != nil {
+		return err
+	}
+
+	reviewService := service.NewReviewTaskService("")
+	err := reviewService.RunChunk(ctx, chunk)
+	if err == nil {
+		return nil
+	}
+	logger.Warn(i18n.Translate("review_task.subtask_failed", "", nil), "review_task_id", chunk.ReviewTaskID, "index", chunk.Index, "error", err)
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	if retried >= maxRetry {
+		return reviewService.FailTask(ctx, chunk.ReviewTaskID, err)
+	}
+	return err
+}
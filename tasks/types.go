@@ -2,7 +2,9 @@ package tasks
 
 const (
 	// Task type constants
-	TypeRunReviewTask = "review:run"
+	TypeRunReviewTask  = "review:run"
+	TypeRunReviewChunk = "review:chunk"
+	TypeDeliverWebhook = "webhook:deliver"
 
 	// Task queue constants
 	QueueDefault  = "default"
@@ -0,0 +1,57 @@
+package tasks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+	"github.com/zgsm/mock-kbcenter/internal/service"
+	queue "github.com/zgsm/mock-kbcenter/pkg/asynq"
+)
+
+type DeliverWebhookPayload struct {
+	DeliveryID string `json:"delivery_id"`
+}
+
+func init() {
+	service.SetWebhookDispatcher(DispatchWebhookDelivery)
+}
+
+// NewDeliverWebhookPayload payload and options of the task sending a webhook delivery
+func NewDeliverWebhookPayload(payload DeliverWebhookPayload) ([]byte, []asynq.Option, error) {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	taskID := fmt.Sprintf("%s:%s", TypeDeliverWebhook, payload.DeliveryID)
+	return payloadBytes, []asynq.Option{asynq.TaskID(taskID)}, nil
+}
+
+// DispatchWebhookDelivery send a webhook delivery on the worker when Asynq is enabled, otherwise in the current process.
+// Queued deliveries survive restarts of the processes.
+func DispatchWebhookDelivery(ctx context.Context, deliveryID string) error {
+	if queue.GetClient() == nil {
+		return service.DeliverInProcess(ctx, deliveryID)
+	}
+
+	payload, opts, err := NewDeliverWebhookPayload(DeliverWebhookPayload{DeliveryID: deliveryID})
+	if err != nil {
+		return err
+	}
+	// The HTTP client retries the endpoint, the task is retried only when the delivery cannot be read or recorded
+	if _, err = queue.EnqueueTask(ctx, TypeDeliverWebhook, payload, QueueDefault, opts...); errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	return err
+}
+
+// HandleDeliverWebhook send a pending webhook delivery and record its outcome
+func HandleDeliverWebhook(ctx context.Context, t *asynq.Task) error {
+	var payload DeliverWebhookPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return err
+	}
+	return service.NewWebhookService().DeliverPending(ctx, payload.DeliveryID)
+}